@@ -0,0 +1,194 @@
+// Package auth authenticates API clients with keys stored in the mailing list database
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/IM-Deane/mailing-list/mdb"
+)
+
+// Scope is a permission granted to an API key
+type Scope string
+
+const (
+	// Public routes need no key, e.g. confirmation and unsubscribe links that carry their own token
+	Public Scope = ""
+	// Read allows fetching emails and lists
+	Read Scope = "read"
+	// Write also allows creating, updating and opting out emails
+	Write Scope = "write"
+	// Admin also allows managing mailing lists
+	Admin Scope = "admin"
+)
+
+// keyPrefix starts every key so they're easy to recognise, e.g. by secret scanners
+const keyPrefix = "mlk_"
+
+// Errors returned when a request can't be authorized, check for them with errors.Is
+var (
+	// ErrMissingKey means the request didn't include an API key
+	ErrMissingKey = errors.New("API key required")
+	// ErrInvalidKey means the key is malformed, unknown or revoked
+	ErrInvalidKey = errors.New("invalid API key")
+	// ErrInsufficientScope means the key is valid but wasn't granted the scope the request needs
+	ErrInsufficientScope = errors.New("insufficient scope")
+)
+
+// ScopeError is an ErrInsufficientScope naming the scope that was needed
+type ScopeError struct {
+	Required Scope
+}
+
+func (e *ScopeError) Error() string {
+	return fmt.Sprintf("API key doesn't have the %v scope", e.Required)
+}
+
+func (e *ScopeError) Is(target error) bool {
+	return target == ErrInsufficientScope
+}
+
+// ParseScopes parses a comma separated list of scopes such as "read,write"
+func ParseScopes(s string) ([]Scope, error) {
+	var scopes []Scope
+	for _, name := range strings.Split(s, ",") {
+		scope := Scope(strings.TrimSpace(name))
+		switch scope {
+		case Read, Write, Admin:
+			scopes = append(scopes, scope)
+		default:
+			return nil, fmt.Errorf("unknown scope %q, expected read, write or admin", string(scope))
+		}
+	}
+	return scopes, nil
+}
+
+// Grants reports whether a key with scopes may make requests that need required.
+// Admin grants every scope and write also grants read.
+func Grants(scopes []string, required Scope) bool {
+	if required == Public {
+		return true
+	}
+	for _, s := range scopes {
+		switch {
+		case Scope(s) == required, Scope(s) == Admin:
+			return true
+		case Scope(s) == Write && required == Read:
+			return true
+		}
+	}
+	return false
+}
+
+// hashSecret hashes a key's secret for storage. Keys are random, so a fast hash is enough.
+func hashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateKey creates a new key, returning the key to give to the client and the
+// record to store, which only has a hash of its secret.
+// Keys look like mlk_<prefix>_<secret>, the prefix identifies them in the database.
+func GenerateKey(name string, scopes []Scope) (string, mdb.APIKey, error) {
+	id := make([]byte, 4)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return "", mdb.APIKey{}, err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", mdb.APIKey{}, err
+	}
+
+	prefix := hex.EncodeToString(id)
+	encoded := base64.RawURLEncoding.EncodeToString(secret)
+	record := mdb.APIKey{
+		Name: name,
+		Prefix: prefix,
+		Hash: hashSecret(encoded),
+		CreatedAt: time.Unix(time.Now().Unix(), 0),
+	}
+	for _, scope := range scopes {
+		record.Scopes = append(record.Scopes, string(scope))
+	}
+
+	return keyPrefix + prefix + "_" + encoded, record, nil
+}
+
+// splitKey returns the prefix and secret of a key
+func splitKey(key string) (string, string, bool) {
+	if !strings.HasPrefix(key, keyPrefix) {
+		return "", "", false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(key, keyPrefix), "_", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// KeyStore looks up stored API keys, mdb.KeyStore satisfies it
+type KeyStore interface {
+	GetAPIKey(prefix string) (*mdb.APIKey, error)
+}
+
+// Authenticator checks API keys against the hashes in a KeyStore
+type Authenticator struct {
+	keys KeyStore
+}
+
+// NewAuthenticator creates an Authenticator for the keys in store
+func NewAuthenticator(keys KeyStore) *Authenticator {
+	return &Authenticator{keys: keys}
+}
+
+// Authorize checks that key is active and grants scope, returning its record.
+// Public requests are allowed without a key, a key sent with them is still checked.
+func (a *Authenticator) Authorize(key string, scope Scope) (*mdb.APIKey, error) {
+	if key == "" {
+		if scope == Public {
+			return nil, nil
+		}
+		return nil, ErrMissingKey
+	}
+
+	prefix, secret, ok := splitKey(key)
+	if !ok {
+		return nil, ErrInvalidKey
+	}
+	record, err := a.keys.GetAPIKey(prefix)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil || record.RevokedAt != nil {
+		return nil, ErrInvalidKey
+	}
+	if subtle.ConstantTimeCompare([]byte(hashSecret(secret)), []byte(record.Hash)) != 1 {
+		return nil, ErrInvalidKey
+	}
+
+	if !Grants(record.Scopes, scope) {
+		return nil, &ScopeError{Required: scope}
+	}
+	return record, nil
+}
+
+type contextKey struct{}
+
+// WithKey returns a context carrying the API key a request was authorized with
+func WithKey(ctx context.Context, key *mdb.APIKey) context.Context {
+	return context.WithValue(ctx, contextKey{}, key)
+}
+
+// KeyFromContext returns the API key a request was authorized with, nil for public requests
+func KeyFromContext(ctx context.Context) *mdb.APIKey {
+	key, _ := ctx.Value(contextKey{}).(*mdb.APIKey)
+	return key
+}
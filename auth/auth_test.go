@@ -0,0 +1,141 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/IM-Deane/mailing-list/mdb"
+)
+
+// testKeys stores a new key with each scope and returns the store and the keys by scope
+func testKeys(t *testing.T) (*mdb.MemoryStore, map[Scope]string) {
+	store := mdb.NewMemoryStore(mdb.Options{})
+	keys := make(map[Scope]string)
+	for _, scope := range []Scope{Read, Write, Admin} {
+		key, record, err := GenerateKey(string(scope)+" key", []Scope{scope})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.CreateAPIKey(record); err != nil {
+			t.Fatal(err)
+		}
+		keys[scope] = key
+	}
+	return store, keys
+}
+
+func TestGenerateKey(t *testing.T) {
+	key, record, err := GenerateKey("ci", []Scope{Read, Write})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	prefix, secret, ok := splitKey(key)
+	if !ok || !strings.HasPrefix(key, keyPrefix) {
+		t.Fatalf("got key %q, want %v<prefix>_<secret>", key, keyPrefix)
+	}
+	if prefix != record.Prefix || len(prefix) != 8 {
+		t.Errorf("got prefix %q in the key and %q in the record", prefix, record.Prefix)
+	}
+
+	// only a hash of the secret is stored
+	if strings.Contains(record.Hash, secret) || record.Hash != hashSecret(secret) {
+		t.Errorf("got hash %q for secret %q", record.Hash, secret)
+	}
+	if record.Name != "ci" || len(record.Scopes) != 2 || record.Scopes[0] != "read" || record.Scopes[1] != "write" {
+		t.Errorf("got record %+v", record)
+	}
+
+	other, otherRecord, err := GenerateKey("ci", []Scope{Read})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == key || otherRecord.Prefix == record.Prefix {
+		t.Error("generated the same key twice")
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	store, keys := testKeys(t)
+
+	revoked, record, err := GenerateKey("revoked", []Scope{Admin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CreateAPIKey(record); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.RevokeAPIKey(record.Prefix); err != nil {
+		t.Fatal(err)
+	}
+
+	// a key with a known prefix and someone else's secret
+	prefix, _, _ := splitKey(keys[Admin])
+	_, wrongSecret, _ := splitKey(keys[Read])
+	unknown, _, err := GenerateKey("never stored", []Scope{Admin})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		key string
+		scope Scope
+		// err is the error wanted, nil when the key should be authorized
+		err error
+	}{
+		{"public without a key", "", Public, nil},
+		{"public with a key", keys[Read], Public, nil},
+		{"missing key", "", Read, ErrMissingKey},
+		{"read", keys[Read], Read, nil},
+		{"read can't write", keys[Read], Write, ErrInsufficientScope},
+		{"write can read", keys[Write], Read, nil},
+		{"write can't administer", keys[Write], Admin, ErrInsufficientScope},
+		{"admin can do anything", keys[Admin], Write, nil},
+		{"malformed", "not a key", Read, ErrInvalidKey},
+		{"malformed with the prefix", keyPrefix + "abc", Read, ErrInvalidKey},
+		{"unknown", unknown, Read, ErrInvalidKey},
+		{"wrong secret", keyPrefix + prefix + "_" + wrongSecret, Read, ErrInvalidKey},
+		{"revoked", revoked, Read, ErrInvalidKey},
+		{"revoked on a public request", revoked, Public, ErrInvalidKey},
+	}
+
+	authenticator := NewAuthenticator(store)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := authenticator.Authorize(tt.key, tt.scope)
+			if tt.err == nil {
+				if err != nil {
+					t.Fatalf("got %v, want the key authorized", err)
+				}
+				if tt.key != "" && (key == nil || !strings.HasPrefix(tt.key, keyPrefix+key.Prefix+"_")) {
+					t.Errorf("got record %+v for key %v", key, tt.key)
+				}
+				return
+			}
+			if !errors.Is(err, tt.err) || key != nil {
+				t.Errorf("got %v, %v, want %v", key, err, tt.err)
+			}
+		})
+	}
+
+	// scope errors name the scope that was missing
+	_, err = authenticator.Authorize(keys[Read], Admin)
+	var scopeErr *ScopeError
+	if !errors.As(err, &scopeErr) || scopeErr.Required != Admin {
+		t.Errorf("got %v, want a ScopeError for admin", err)
+	}
+}
+
+func TestParseScopes(t *testing.T) {
+	scopes, err := ParseScopes("read, write,admin")
+	if err != nil || len(scopes) != 3 || scopes[0] != Read || scopes[1] != Write || scopes[2] != Admin {
+		t.Errorf("got %v, %v", scopes, err)
+	}
+	for _, s := range []string{"", "read,", "owner", "READ"} {
+		if _, err := ParseScopes(s); err == nil {
+			t.Errorf("parsed %q", s)
+		}
+	}
+}
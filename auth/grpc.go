@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// KeyFromMetadata reads an API key from "authorization: Bearer <key>" or x-api-key metadata
+func KeyFromMetadata(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	for _, authorization := range md.Get("authorization") {
+		if len(authorization) > len("Bearer ") && strings.EqualFold(authorization[:len("Bearer ")], "Bearer ") {
+			return strings.TrimSpace(authorization[len("Bearer "):])
+		}
+		return ""
+	}
+	if keys := md.Get("x-api-key"); len(keys) > 0 {
+		return keys[0]
+	}
+	return ""
+}
+
+// methodScope is the scope needed for a method, methods missing from scopes need admin
+func methodScope(scopes map[string]Scope, method string) Scope {
+	scope, ok := scopes[method]
+	if !ok {
+		return Admin
+	}
+	return scope
+}
+
+// UnaryServerInterceptor only lets calls through when they have an API key with the
+// scope scopes lists for their method, e.g. "/proto.MailingListService/GetEmail"
+func UnaryServerInterceptor(a *Authenticator, scopes map[string]Scope) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		key, err := a.Authorize(KeyFromMetadata(ctx), methodScope(scopes, info.FullMethod))
+		if err != nil {
+			return nil, err
+		}
+		if key != nil {
+			ctx = WithKey(ctx, key)
+		}
+		return handler(ctx, req)
+	}
+}
+
+// keyStream carries the authorized key in the stream's context
+type keyStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *keyStream) Context() context.Context {
+	return s.ctx
+}
+
+// StreamServerInterceptor is UnaryServerInterceptor for streaming RPCs
+func StreamServerInterceptor(a *Authenticator, scopes map[string]Scope) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		key, err := a.Authorize(KeyFromMetadata(ss.Context()), methodScope(scopes, info.FullMethod))
+		if err != nil {
+			return err
+		}
+		if key != nil {
+			ss = &keyStream{ServerStream: ss, ctx: WithKey(ss.Context(), key)}
+		}
+		return handler(srv, ss)
+	}
+}
+
+// Credentials sends an API key with every call of a gRPC client
+type Credentials string
+
+func (c Credentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(c)}, nil
+}
+
+func (c Credentials) RequireTransportSecurity() bool {
+	return false
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// testScopes needs read for Get, admin for Create and nothing for Confirm
+var testScopes = map[string]Scope{
+	"/test.Service/Get": Read,
+	"/test.Service/Create": Admin,
+	"/test.Service/Confirm": Public,
+}
+
+// testStream is a server stream with only a context
+type testStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s testStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestKeyFromMetadata(t *testing.T) {
+	tests := []struct {
+		name string
+		md metadata.MD
+		want string
+	}{
+		{"bearer", metadata.Pairs("authorization", "Bearer mlk_a_b"), "mlk_a_b"},
+		{"bearer in any case", metadata.Pairs("authorization", "bearer mlk_a_b"), "mlk_a_b"},
+		{"x-api-key", metadata.Pairs("x-api-key", "mlk_a_b"), "mlk_a_b"},
+		{"other scheme", metadata.Pairs("authorization", "Basic dXNlcg=="), ""},
+		{"authorization wins", metadata.Pairs("authorization", "Basic dXNlcg==", "x-api-key", "mlk_a_b"), ""},
+		{"none", metadata.MD{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := metadata.NewIncomingContext(context.Background(), tt.md)
+			if got := KeyFromMetadata(ctx); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInterceptors(t *testing.T) {
+	store, keys := testKeys(t)
+	authenticator := NewAuthenticator(store)
+	unary := UnaryServerInterceptor(authenticator, testScopes)
+	stream := StreamServerInterceptor(authenticator, testScopes)
+
+	tests := []struct {
+		name string
+		method string
+		key string
+		err error
+	}{
+		{"authorized", "/test.Service/Get", keys[Read], nil},
+		{"missing key", "/test.Service/Get", "", ErrMissingKey},
+		{"invalid key", "/test.Service/Get", keyPrefix + "abc_def", ErrInvalidKey},
+		{"insufficient scope", "/test.Service/Create", keys[Write], ErrInsufficientScope},
+		{"admin", "/test.Service/Create", keys[Admin], nil},
+		{"public", "/test.Service/Confirm", "", nil},
+		{"unlisted methods need admin", "/test.Service/Delete", keys[Write], ErrInsufficientScope},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			md := metadata.MD{}
+			if tt.key != "" {
+				md = metadata.Pairs("authorization", "Bearer "+tt.key)
+			}
+			ctx := metadata.NewIncomingContext(context.Background(), md)
+
+			// check is the handler, it records whether it ran and with which key
+			var called bool
+			check := func(ctx context.Context) {
+				called = true
+				key := KeyFromContext(ctx)
+				if (key != nil) != (tt.key != "") {
+					t.Errorf("got key %+v in the handler's context", key)
+				}
+			}
+
+			_, err := unary(ctx, nil, &grpc.UnaryServerInfo{FullMethod: tt.method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+				check(ctx)
+				return nil, nil
+			})
+			if !errors.Is(err, tt.err) || (err != nil && tt.err == nil) || called != (tt.err == nil) {
+				t.Errorf("unary: got %v, called %v, want %v", err, called, tt.err)
+			}
+
+			called = false
+			err = stream(nil, testStream{ctx: ctx}, &grpc.StreamServerInfo{FullMethod: tt.method}, func(srv interface{}, ss grpc.ServerStream) error {
+				check(ss.Context())
+				return nil
+			})
+			if !errors.Is(err, tt.err) || (err != nil && tt.err == nil) || called != (tt.err == nil) {
+				t.Errorf("stream: got %v, called %v, want %v", err, called, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// KeyFromHeader reads an API key from "Authorization: Bearer <key>" or an X-API-Key header
+func KeyFromHeader(h http.Header) string {
+	if authorization := h.Get("Authorization"); authorization != "" {
+		if len(authorization) > len("Bearer ") && strings.EqualFold(authorization[:len("Bearer ")], "Bearer ") {
+			return strings.TrimSpace(authorization[len("Bearer "):])
+		}
+		return ""
+	}
+	return h.Get("X-API-Key")
+}
+
+// Middleware only lets requests through when they have an API key with the scope
+// picked by scope. Failures are written by onError so each API reports them in its
+// own format, unauthenticated requests also get a WWW-Authenticate header.
+func Middleware(a *Authenticator, scope func(r *http.Request) Scope, onError func(w http.ResponseWriter, err error), next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, err := a.Authorize(KeyFromHeader(r.Header), scope(r))
+		if err != nil {
+			if errors.Is(err, ErrMissingKey) || errors.Is(err, ErrInvalidKey) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="mailinglist"`)
+			}
+			onError(w, err)
+			return
+		}
+
+		if key != nil {
+			r = r.WithContext(WithKey(r.Context(), key))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestKeyFromHeader(t *testing.T) {
+	tests := []struct {
+		name string
+		header http.Header
+		want string
+	}{
+		{"bearer", http.Header{"Authorization": {"Bearer mlk_a_b"}}, "mlk_a_b"},
+		{"bearer in any case", http.Header{"Authorization": {"BEARER  mlk_a_b"}}, "mlk_a_b"},
+		{"x-api-key", http.Header{"X-Api-Key": {"mlk_a_b"}}, "mlk_a_b"},
+		{"other scheme", http.Header{"Authorization": {"Basic dXNlcg=="}}, ""},
+		{"authorization wins", http.Header{"Authorization": {"Basic dXNlcg=="}, "X-Api-Key": {"mlk_a_b"}}, ""},
+		{"none", http.Header{}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := KeyFromHeader(tt.header); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	store, keys := testKeys(t)
+
+	// GET needs read, everything else admin
+	scope := func(r *http.Request) Scope {
+		if r.Method == http.MethodGet {
+			return Read
+		}
+		return Admin
+	}
+	var failure error
+	onError := func(w http.ResponseWriter, err error) {
+		failure = err
+		w.WriteHeader(http.StatusTeapot)
+	}
+	handler := Middleware(NewAuthenticator(store), scope, onError, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := KeyFromContext(r.Context())
+		if key == nil {
+			t.Error("got no key in the request's context")
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	tests := []struct {
+		name string
+		method string
+		key string
+		err error
+		// challenged is whether the response should have a WWW-Authenticate header
+		challenged bool
+	}{
+		{"authorized", "GET", keys[Read], nil, false},
+		{"missing key", "GET", "", ErrMissingKey, true},
+		{"invalid key", "GET", keyPrefix + "abc_def", ErrInvalidKey, true},
+		{"insufficient scope", "POST", keys[Write], ErrInsufficientScope, false},
+		{"admin", "POST", keys[Admin], nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failure = nil
+			req := httptest.NewRequest(tt.method, "/", nil)
+			if tt.key != "" {
+				req.Header.Set("X-API-Key", tt.key)
+			}
+			res := httptest.NewRecorder()
+			handler.ServeHTTP(res, req)
+
+			if tt.err == nil {
+				if res.Code != http.StatusNoContent || failure != nil {
+					t.Errorf("got status %v, error %v, want the request through", res.Code, failure)
+				}
+			} else if res.Code != http.StatusTeapot || !errors.Is(failure, tt.err) {
+				t.Errorf("got status %v, error %v, want %v written by onError", res.Code, failure, tt.err)
+			}
+			if challenged := res.Header().Get("WWW-Authenticate") != ""; challenged != tt.challenged {
+				t.Errorf("got WWW-Authenticate %q, want one: %v", res.Header().Get("WWW-Authenticate"), tt.challenged)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package domaincheck
+
+import (
+	"bufio"
+	_ "embed"
+	"io"
+	"os"
+	"strings"
+)
+
+//go:embed disposable.txt
+var bundledBlocklist string
+
+// Blocklist is a set of blocked domains, subdomains of a blocked domain are blocked too
+type Blocklist map[string]bool
+
+// DefaultBlocklist returns the bundled list of disposable email providers
+func DefaultBlocklist() Blocklist {
+	// the bundled list is known to parse
+	blocklist, _ := ParseBlocklist(strings.NewReader(bundledBlocklist))
+	return blocklist
+}
+
+// ParseBlocklist reads one domain per line, blank lines and lines starting with # are skipped
+func ParseBlocklist(r io.Reader) (Blocklist, error) {
+	blocklist := make(Blocklist)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		blocklist[strings.ToLower(strings.TrimSuffix(line, "."))] = true
+	}
+
+	return blocklist, scanner.Err()
+}
+
+// LoadBlocklist reads a blocklist file, see ParseBlocklist
+func LoadBlocklist(path string) (Blocklist, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return ParseBlocklist(file)
+}
+
+// Contains reports whether domain or one of its parent domains is blocked
+func (b Blocklist) Contains(domain string) bool {
+	for {
+		if b[domain] {
+			return true
+		}
+		i := strings.Index(domain, ".")
+		if i < 0 {
+			return false
+		}
+		domain = domain[i+1:]
+	}
+}
@@ -0,0 +1,107 @@
+// Package domaincheck rejects or flags signups from domains that can't receive
+// email or belong to disposable email providers.
+package domaincheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+	"time"
+)
+
+// Resolver looks up the DNS records used to check a domain.
+// *net.Resolver satisfies it, see StaticResolver for a resolver that never touches the network.
+type Resolver interface {
+	LookupMX(ctx context.Context, name string) ([]*net.MX, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// StaticResolver answers from fixed records, for tests and deployments without DNS access.
+// Domains missing from both maps don't exist.
+type StaticResolver struct {
+	MX    map[string][]*net.MX
+	Hosts map[string][]string
+}
+
+// LookupMX returns the MX records for name
+func (r StaticResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	records, ok := r.MX[strings.TrimSuffix(name, ".")]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return records, nil
+}
+
+// LookupHost returns the addresses of host
+func (r StaticResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	addrs, ok := r.Hosts[strings.TrimSuffix(host, ".")]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+	return addrs, nil
+}
+
+// defaultTimeout bounds the DNS lookups for a single check
+const defaultTimeout = 2 * time.Second
+
+// Checker checks domains against a blocklist and, when it has a resolver, their MX records
+type Checker struct {
+	resolver  Resolver
+	blocklist Blocklist
+	// Timeout bounds the DNS lookups for a single check
+	Timeout time.Duration
+}
+
+// NewChecker creates a checker, a nil resolver only checks the blocklist
+func NewChecker(resolver Resolver, blocklist Blocklist) *Checker {
+	return &Checker{resolver: resolver, blocklist: blocklist, Timeout: defaultTimeout}
+}
+
+// Check returns why domain shouldn't be used for signups, or "" if it passed.
+// DNS failures other than the domain not existing pass, so an outage doesn't block every signup.
+func (c *Checker) Check(domain string) string {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+
+	if c.blocklist.Contains(domain) {
+		return fmt.Sprintf("%v is a disposable email provider", domain)
+	}
+	if c.resolver == nil {
+		return ""
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+
+	records, err := c.resolver.LookupMX(ctx, domain)
+	if err == nil {
+		// a single "." record is a null MX (RFC 7505), the domain explicitly accepts no mail
+		if len(records) == 1 && records[0].Host == "." {
+			return fmt.Sprintf("%v does not accept email", domain)
+		}
+		if len(records) > 0 {
+			return ""
+		}
+	} else if !isNotFound(err) {
+		log.Printf("domain check: MX lookup for %v failed: %v", domain, err)
+		return ""
+	}
+
+	// without MX records mail is delivered to the domain's own address (RFC 5321 section 5.1)
+	if _, err := c.resolver.LookupHost(ctx, domain); err != nil {
+		if isNotFound(err) {
+			return fmt.Sprintf("%v can't receive email", domain)
+		}
+		log.Printf("domain check: host lookup for %v failed: %v", domain, err)
+	}
+
+	return ""
+}
+
+// isNotFound reports whether err means the DNS records don't exist
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
@@ -0,0 +1,102 @@
+package domaincheck
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// failingResolver fails every lookup with a temporary DNS error
+type failingResolver struct{}
+
+func (failingResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	return nil, &net.DNSError{Err: "server misbehaving", Name: name, IsTemporary: true}
+}
+
+func (failingResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	return nil, &net.DNSError{Err: "server misbehaving", Name: host, IsTemporary: true}
+}
+
+// mxFoundResolver has MX records for every domain but fails host lookups, so a
+// host lookup would only fail the check if it wasn't skipped
+type mxFoundResolver struct{ failingResolver }
+
+func (mxFoundResolver) LookupMX(ctx context.Context, name string) ([]*net.MX, error) {
+	return []*net.MX{{Host: "mx." + name, Pref: 10}}, nil
+}
+
+var testResolver = StaticResolver{
+	MX: map[string][]*net.MX{
+		"example.com": {{Host: "mx.example.com.", Pref: 10}},
+		"nullmx.example": {{Host: ".", Pref: 0}},
+	},
+	Hosts: map[string][]string{
+		// no MX records, mail goes to the domain's own address
+		"a-only.example": {"192.0.2.1"},
+	},
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name string
+		resolver Resolver
+		domain string
+		// fails is whether the domain should fail the check
+		fails bool
+	}{
+		{"blocked", nil, "mailinator.com", true},
+		{"blocked subdomain", nil, "eu.mailinator.com", true},
+		{"blocked is case insensitive", nil, "MAILINATOR.COM.", true},
+		{"not blocked without a resolver", nil, "nowhere.example", false},
+		{"blocklist checked before DNS", testResolver, "mailinator.com", true},
+		{"MX records", testResolver, "example.com", false},
+		{"null MX", testResolver, "nullmx.example", true},
+		{"A record without MX", testResolver, "a-only.example", false},
+		{"missing domain", testResolver, "nowhere.example", true},
+		{"resolver errors let it through", failingResolver{}, "nowhere.example", false},
+		{"MX found skips the host lookup", mxFoundResolver{}, "nowhere.example", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reason := NewChecker(tt.resolver, DefaultBlocklist()).Check(tt.domain)
+			if (reason != "") != tt.fails {
+				t.Errorf("got reason %q, want it to fail: %v", reason, tt.fails)
+			}
+		})
+	}
+}
+
+func TestLoadBlocklist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist.txt")
+	list := "# our own list\n\nSpam.Example.\nthrowaway.test\n"
+	if err := os.WriteFile(path, []byte(list), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	blocklist, err := LoadBlocklist(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocklist) != 2 {
+		t.Errorf("got %v, want the two domains without comments or blank lines", blocklist)
+	}
+
+	// the file replaces the bundled list
+	checker := NewChecker(nil, blocklist)
+	for domain, fails := range map[string]bool{
+		"spam.example": true,
+		"mail.throwaway.test": true,
+		"mailinator.com": false,
+		"example.com": false,
+	} {
+		if reason := checker.Check(domain); (reason != "") != fails {
+			t.Errorf("%v: got reason %q, want it to fail: %v", domain, reason, fails)
+		}
+	}
+
+	if _, err := LoadBlocklist(filepath.Join(t.TempDir(), "missing.txt")); err == nil {
+		t.Error("loaded a missing file")
+	}
+}
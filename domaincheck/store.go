@@ -0,0 +1,179 @@
+package domaincheck
+
+import (
+	"log"
+	"strings"
+
+	"github.com/IM-Deane/mailing-list/mdb"
+)
+
+// Store checks the domain of every address the wrapped store could add: signups,
+// updates that create the email or subscription, and imports. Every other call
+// goes straight through.
+type Store struct {
+	mdb.SubscriberStore
+	checker *Checker
+	// policy for the global email list and lists without a policy of their own
+	policy mdb.DomainPolicy
+}
+
+// NewStore wraps store so the calls that add addresses run checker first
+func NewStore(store mdb.SubscriberStore, checker *Checker, policy mdb.DomainPolicy) (*Store, error) {
+	if policy == mdb.DomainPolicyDefault {
+		policy = mdb.DomainPolicyReject
+	}
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Store{SubscriberStore: store, checker: checker, policy: policy}, nil
+}
+
+// policyFor returns the policy for addresses added to list
+func (s *Store) policyFor(list string) (mdb.DomainPolicy, error) {
+	if list == "" {
+		return s.policy, nil
+	}
+	l, err := s.SubscriberStore.GetList(list)
+	if err != nil {
+		return s.policy, err
+	}
+	if l != nil && l.DomainPolicy != mdb.DomainPolicyDefault {
+		return l.DomainPolicy, nil
+	}
+	return s.policy, nil
+}
+
+// reason returns why email's domain failed the check, empty if it passed.
+// Results are kept in checked by domain when it isn't nil.
+func (s *Store) reason(email string, checked map[string]string) string {
+	address, err := mdb.NormalizeAddress(email)
+	if err != nil {
+		// let the wrapped store report invalid addresses
+		return ""
+	}
+
+	domain := address[strings.LastIndex(address, "@")+1:]
+	if reason, ok := checked[domain]; ok {
+		return reason
+	}
+	reason := s.checker.Check(domain)
+	if checked != nil {
+		checked[domain] = reason
+	}
+	return reason
+}
+
+// flag records why email's domain was flagged. The email has already been added
+// by then, so a failure is logged rather than failing the signup.
+func (s *Store) flag(email string, reason string) {
+	if err := s.SubscriberStore.FlagEmail(email, reason); err != nil {
+		log.Printf("flag %v: %v", email, err)
+	}
+}
+
+// create runs the domain check around add, which adds the email to the wrapped store
+func (s *Store) create(list string, email string, add func() error) error {
+	policy, err := s.policyFor(list)
+	if err != nil {
+		return err
+	}
+	reason := s.reason(email, nil)
+	if reason != "" && policy == mdb.DomainPolicyReject {
+		return &mdb.ValidationError{Email: email, Reason: reason}
+	}
+
+	if err := add(); err != nil {
+		return err
+	}
+
+	if reason != "" {
+		s.flag(email, reason)
+	}
+
+	return nil
+}
+
+// CreateEmail checks the email's domain then adds it to the global email list
+func (s *Store) CreateEmail(email string) error {
+	return s.create("", email, func() error {
+		return s.SubscriberStore.CreateEmail(email)
+	})
+}
+
+// Subscribe checks the email's domain then adds it to list
+func (s *Store) Subscribe(list string, email string) error {
+	return s.create(list, email, func() error {
+		return s.SubscriberStore.Subscribe(list, email)
+	})
+}
+
+// UpdateEmail checks the email's domain when the update would create it,
+// updates to an existing email go straight through
+func (s *Store) UpdateEmail(entry mdb.EmailEntry) error {
+	existing, err := s.SubscriberStore.GetEmail(entry.Email)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return s.SubscriberStore.UpdateEmail(entry)
+	}
+	return s.create("", entry.Email, func() error {
+		return s.SubscriberStore.UpdateEmail(entry)
+	})
+}
+
+// UpdateSubscription checks the email's domain when the update would subscribe it
+// to list, updates to an existing subscription go straight through
+func (s *Store) UpdateSubscription(list string, entry mdb.EmailEntry) error {
+	existing, err := s.SubscriberStore.GetSubscription(list, entry.Email)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return s.SubscriberStore.UpdateSubscription(list, entry)
+	}
+	return s.create(list, entry.Email, func() error {
+		return s.SubscriberStore.UpdateSubscription(list, entry)
+	})
+}
+
+// ImportEmails checks the domain of every entry. Entries the policy rejects are reported
+// as invalid with the reason, the rest are imported and flagged once they're created.
+func (s *Store) ImportEmails(list string, entries []mdb.EmailEntry) ([]mdb.ImportResult, error) {
+	policy, err := s.policyFor(list)
+	if err != nil {
+		return nil, err
+	}
+
+	// imports are mostly a few domains, so each is only looked up once
+	checked := make(map[string]string)
+	results := make([]mdb.ImportResult, len(entries))
+	accepted := make([]mdb.EmailEntry, 0, len(entries))
+	// positions and reasons of the accepted entries
+	positions := make([]int, 0, len(entries))
+	reasons := make([]string, 0, len(entries))
+	for i, entry := range entries {
+		reason := s.reason(entry.Email, checked)
+		if reason != "" && policy == mdb.DomainPolicyReject {
+			results[i] = mdb.ImportResult{Email: entry.Email, Status: mdb.ImportInvalid, Reason: reason}
+			continue
+		}
+		accepted = append(accepted, entry)
+		positions = append(positions, i)
+		reasons = append(reasons, reason)
+	}
+
+	imported, err := s.SubscriberStore.ImportEmails(list, accepted)
+	if err != nil {
+		return nil, err
+	}
+	for i, result := range imported {
+		results[positions[i]] = result
+		if result.Status == mdb.ImportCreated && reasons[i] != "" {
+			s.flag(accepted[i].Email, reasons[i])
+		}
+	}
+
+	return results, nil
+}
@@ -0,0 +1,179 @@
+package domaincheck
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/IM-Deane/mailing-list/mdb"
+)
+
+// testStore wraps an empty memory store with a blocklist only checker and policy.
+// The backend is returned too so tests can add entries without the check.
+func testStore(t *testing.T, policy mdb.DomainPolicy) (*Store, *mdb.MemoryStore) {
+	backend := mdb.NewMemoryStore(mdb.Options{})
+	for name, policy := range map[string]mdb.DomainPolicy{"flagged": mdb.DomainPolicyFlag, "rejected": mdb.DomainPolicyReject, "default": mdb.DomainPolicyDefault} {
+		if err := backend.CreateList(name, policy); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	store, err := NewStore(backend, NewChecker(nil, DefaultBlocklist()), policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, backend
+}
+
+// domainFlag returns the stored DomainFlag of email, failing if it doesn't exist
+func domainFlag(t *testing.T, store mdb.EmailStore, email string) string {
+	t.Helper()
+	entry, err := store.GetEmail(email)
+	if err != nil || entry == nil {
+		t.Fatalf("got %v, %v for %v", entry, err, email)
+	}
+	return entry.DomainFlag
+}
+
+func TestPolicies(t *testing.T) {
+	tests := []struct {
+		name string
+		policy mdb.DomainPolicy
+		list string
+		// rejected is whether a blocked address is refused, otherwise it's flagged
+		rejected bool
+	}{
+		{"reject by default", mdb.DomainPolicyDefault, "", true},
+		{"store flags", mdb.DomainPolicyFlag, "", false},
+		{"list without a policy uses the store's", mdb.DomainPolicyFlag, "default", false},
+		{"list rejects", mdb.DomainPolicyFlag, "rejected", true},
+		{"list flags", mdb.DomainPolicyReject, "flagged", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store, backend := testStore(t, tt.policy)
+
+			create := func(email string) error {
+				if tt.list == "" {
+					return store.CreateEmail(email)
+				}
+				return store.Subscribe(tt.list, email)
+			}
+
+			if err := create("someone@example.com"); err != nil {
+				t.Fatal(err)
+			}
+			if flag := domainFlag(t, backend, "someone@example.com"); flag != "" {
+				t.Errorf("got flag %q for an address that passed", flag)
+			}
+
+			err := create("someone@mailinator.com")
+			if tt.rejected {
+				var validationErr *mdb.ValidationError
+				if !errors.As(err, &validationErr) || !errors.Is(err, mdb.ErrInvalid) {
+					t.Fatalf("got %v, want a ValidationError", err)
+				}
+				if entry, _ := backend.GetEmail("someone@mailinator.com"); entry != nil {
+					t.Error("a rejected address was added")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if flag := domainFlag(t, backend, "someone@mailinator.com"); flag == "" {
+				t.Error("a flagged address has no DomainFlag")
+			}
+		})
+	}
+}
+
+func TestUpdates(t *testing.T) {
+	store, backend := testStore(t, mdb.DomainPolicyReject)
+	blocked := mdb.EmailEntry{Email: "someone@mailinator.com", OptOut: true}
+
+	// updates that would create the email or subscription are checked
+	if err := store.UpdateEmail(blocked); !errors.Is(err, mdb.ErrInvalid) {
+		t.Errorf("got %v creating a blocked email, want ErrInvalid", err)
+	}
+	if err := store.UpdateSubscription("rejected", blocked); !errors.Is(err, mdb.ErrInvalid) {
+		t.Errorf("got %v subscribing a blocked email, want ErrInvalid", err)
+	}
+
+	// ones to existing entries, added before the domain was blocked, go through
+	if err := backend.CreateEmail(blocked.Email); err != nil {
+		t.Fatal(err)
+	}
+	if err := backend.Subscribe("rejected", blocked.Email); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.UpdateEmail(blocked); err != nil {
+		t.Errorf("got %v updating an existing email", err)
+	}
+	if err := store.UpdateSubscription("rejected", blocked); err != nil {
+		t.Errorf("got %v updating an existing subscription", err)
+	}
+	sub, err := backend.GetSubscription("rejected", blocked.Email)
+	if err != nil || sub == nil || !sub.OptOut {
+		t.Errorf("got %+v, %v, want the update applied", sub, err)
+	}
+}
+
+func TestImport(t *testing.T) {
+	entries := []mdb.EmailEntry{
+		{Email: "a@example.com"},
+		{Email: "b@mailinator.com"},
+		{Email: "c@example.com"},
+		{Email: "d@eu.mailinator.com"},
+	}
+
+	t.Run("reject", func(t *testing.T) {
+		store, backend := testStore(t, mdb.DomainPolicyReject)
+		results, err := store.ImportEmails("", entries)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// results stay in the order of the entries
+		want := []mdb.ImportStatus{mdb.ImportCreated, mdb.ImportInvalid, mdb.ImportCreated, mdb.ImportInvalid}
+		if len(results) != len(want) {
+			t.Fatalf("got %v, want %v results", results, len(want))
+		}
+		for i, result := range results {
+			if result.Email != entries[i].Email || result.Status != want[i] {
+				t.Errorf("got %+v for %v, want %v", result, entries[i].Email, want[i])
+			}
+			if result.Status == mdb.ImportInvalid && result.Reason == "" {
+				t.Errorf("%v was rejected without a reason", result.Email)
+			}
+		}
+		if entry, _ := backend.GetEmail("b@mailinator.com"); entry != nil {
+			t.Error("a rejected address was imported")
+		}
+	})
+
+	t.Run("flag", func(t *testing.T) {
+		store, backend := testStore(t, mdb.DomainPolicyReject)
+		results, err := store.ImportEmails("flagged", entries)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, result := range results {
+			if result.Status != mdb.ImportCreated {
+				t.Errorf("got %+v, want every address imported", result)
+			}
+		}
+		if flag := domainFlag(t, backend, "d@eu.mailinator.com"); flag == "" {
+			t.Error("a flagged import has no DomainFlag")
+		}
+		if flag := domainFlag(t, backend, "a@example.com"); flag != "" {
+			t.Errorf("got flag %q for an address that passed", flag)
+		}
+	})
+
+	t.Run("missing list", func(t *testing.T) {
+		store, _ := testStore(t, mdb.DomainPolicyReject)
+		if _, err := store.ImportEmails("missing", entries); !errors.Is(err, mdb.ErrNotFound) {
+			t.Errorf("got %v, want ErrNotFound", err)
+		}
+	})
+}
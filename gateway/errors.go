@@ -0,0 +1,190 @@
+package gateway
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// problemTypePrefix is prepended to error codes to make problem type URIs, the same as the JSON API's
+const problemTypePrefix = "urn:mailinglist:error:"
+
+// problem is an RFC 7807 problem details body, in the same shape the JSON API returns
+type problem struct {
+	Type string `json:"type"`
+	Title string `json:"title"`
+	Status int `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Code string `json:"code"`
+	// InvalidParams lists the fields that failed validation
+	InvalidParams []invalidParam `json:"invalid_params,omitempty"`
+}
+
+// invalidParam is a single field that failed validation
+type invalidParam struct {
+	Name string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// httpStatus maps gRPC codes to HTTP status codes, as in the google.rpc.Code documentation
+var httpStatus = map[codes.Code]int{
+	codes.OK: http.StatusOK,
+	codes.Canceled: 499,
+	codes.Unknown: http.StatusInternalServerError,
+	codes.InvalidArgument: http.StatusBadRequest,
+	codes.DeadlineExceeded: http.StatusGatewayTimeout,
+	codes.NotFound: http.StatusNotFound,
+	codes.AlreadyExists: http.StatusConflict,
+	codes.PermissionDenied: http.StatusForbidden,
+	codes.ResourceExhausted: http.StatusTooManyRequests,
+	codes.FailedPrecondition: http.StatusBadRequest,
+	codes.Aborted: http.StatusConflict,
+	codes.OutOfRange: http.StatusBadRequest,
+	codes.Unimplemented: http.StatusNotImplemented,
+	codes.Internal: http.StatusInternalServerError,
+	codes.Unavailable: http.StatusServiceUnavailable,
+	codes.DataLoss: http.StatusInternalServerError,
+	codes.Unauthenticated: http.StatusUnauthorized,
+}
+
+// reasonStatus overrides the status for ErrorInfo reasons the JSON API has always reported differently
+var reasonStatus = map[string]int{
+	"INVALID_EMAIL": http.StatusUnprocessableEntity,
+	"INVALID_ATTRIBUTE": http.StatusUnprocessableEntity,
+	"IDEMPOTENCY_KEY_REUSED": http.StatusUnprocessableEntity,
+}
+
+// reasonCode renames ErrorInfo reasons to the codes the JSON API uses for them
+var reasonCode = map[string]string{
+	"INVALID_ARGUMENT": "invalid_request",
+}
+
+// codeName turns a gRPC code like InvalidArgument into invalid_argument
+func codeName(c codes.Code) string {
+	var name strings.Builder
+	for i, r := range c.String() {
+		if unicode.IsUpper(r) && i > 0 {
+			name.WriteByte('_')
+		}
+		name.WriteRune(unicode.ToLower(r))
+	}
+	return name.String()
+}
+
+// newProblem converts a gRPC status into problem details.
+// The code comes from the ErrorInfo reason, lowercased, so EMAIL_NOT_FOUND is email_not_found.
+func newProblem(st *status.Status) problem {
+	httpCode, ok := httpStatus[st.Code()]
+	if !ok {
+		httpCode = http.StatusInternalServerError
+	}
+	code := codeName(st.Code())
+
+	p := problem{Detail: st.Message()}
+	for _, detail := range st.Details() {
+		switch detail := detail.(type) {
+		case *errdetails.ErrorInfo:
+			if s, ok := reasonStatus[detail.Reason]; ok {
+				httpCode = s
+			}
+			code = strings.ToLower(detail.Reason)
+			if c, ok := reasonCode[detail.Reason]; ok {
+				code = c
+			}
+		case *errdetails.BadRequest:
+			for _, violation := range detail.FieldViolations {
+				p.InvalidParams = append(p.InvalidParams, invalidParam{Name: violation.Field, Reason: violation.Description})
+			}
+		}
+	}
+
+	p.Type = problemTypePrefix + code
+	p.Title = http.StatusText(httpCode)
+	p.Status = httpCode
+	p.Code = code
+	if p.Title == "" {
+		p.Title = st.Code().String()
+	}
+
+	return p
+}
+
+// errorProblem converts any error from transcoding or the RPC into problem details
+func errorProblem(err error) problem {
+	if errors.Is(err, errRequestTooLarge) {
+		return problem{
+			Type: problemTypePrefix + "request_too_large",
+			Title: http.StatusText(http.StatusRequestEntityTooLarge),
+			Status: http.StatusRequestEntityTooLarge,
+			Detail: fmt.Sprintf("request bodies, and each line of a streaming request, are limited to %v bytes", maxRequestSize),
+			Code: "request_too_large",
+		}
+	}
+
+	var reqErr requestError
+	if errors.As(err, &reqErr) {
+		return problem{
+			Type: problemTypePrefix + "invalid_request",
+			Title: http.StatusText(http.StatusBadRequest),
+			Status: http.StatusBadRequest,
+			Detail: reqErr.Error(),
+			Code: "invalid_request",
+		}
+	}
+
+	st, ok := status.FromError(err)
+	if !ok {
+		log.Println(err)
+		st = status.New(codes.Internal, "internal error")
+	}
+	return newProblem(st)
+}
+
+// writeProblem writes p as an application/problem+json response
+func writeProblem(w http.ResponseWriter, p problem) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	if p.Status == http.StatusUnauthorized {
+		// tell clients how to authenticate, as the JSON API's middleware does
+		w.Header().Set("WWW-Authenticate", `Bearer realm="mailinglist"`)
+	}
+	w.WriteHeader(p.Status)
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		log.Println(err)
+	}
+}
+
+// writeErr writes err as a problem+json response, with a Retry-After header
+// when the status says when to retry, e.g. for rate limited calls
+func writeErr(w http.ResponseWriter, err error) {
+	if st, ok := status.FromError(err); ok {
+		for _, detail := range st.Details() {
+			if retry, ok := detail.(*errdetails.RetryInfo); ok && retry.RetryDelay != nil {
+				seconds := int(math.Ceil(retry.RetryDelay.AsDuration().Seconds()))
+				w.Header().Set("Retry-After", strconv.Itoa(seconds))
+			}
+		}
+	}
+	writeProblem(w, errorProblem(err))
+}
+
+// methodNotAllowed responds 405 with an Allow header listing the methods the route accepts
+func methodNotAllowed(w http.ResponseWriter, r *http.Request, allowed []string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeProblem(w, problem{
+		Type: problemTypePrefix + "method_not_allowed",
+		Title: http.StatusText(http.StatusMethodNotAllowed),
+		Status: http.StatusMethodNotAllowed,
+		Detail: r.Method + " is not allowed, use " + strings.Join(allowed, " or "),
+		Code: "method_not_allowed",
+	})
+}
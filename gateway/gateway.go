@@ -0,0 +1,481 @@
+// Package gateway serves a gRPC service over HTTP/JSON, transcoding requests
+// according to the google.api.http options in its proto definition
+package gateway
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/IM-Deane/mailing-list/loopback"
+	"github.com/IM-Deane/mailing-list/tlsconfig"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+)
+
+// metadataHeaderPrefix forwards HTTP headers as gRPC metadata and back, as grpc-gateway does
+const metadataHeaderPrefix = "Grpc-Metadata-"
+
+// binding is a single HTTP route of an RPC
+type binding struct {
+	httpMethod string
+	pattern *pattern
+	method protoreflect.MethodDescriptor
+	// fullMethod is the gRPC method name, e.g. /proto.MailingListService/GetEmail
+	fullMethod string
+	// body is the request field read from the HTTP body, "*" for the whole message or empty for none
+	body string
+	// responseBody is the response field written as the HTTP body, empty for the whole message
+	responseBody string
+}
+
+// Gateway is an http.Handler that calls a gRPC service for every route in its HttpRules
+type Gateway struct {
+	conn grpc.ClientConnInterface
+	service protoreflect.ServiceDescriptor
+	bindings []*binding
+}
+
+// New creates a gateway for service that sends requests over conn.
+// RPCs without a google.api.http option aren't served.
+func New(conn grpc.ClientConnInterface, service protoreflect.ServiceDescriptor) (*Gateway, error) {
+	g := Gateway{conn: conn, service: service}
+
+	methods := service.Methods()
+	for i := 0; i < methods.Len(); i++ {
+		method := methods.Get(i)
+		rule, ok := proto.GetExtension(method.Options(), annotations.E_Http).(*annotations.HttpRule)
+		if !ok || rule == nil {
+			continue
+		}
+
+		for _, r := range append([]*annotations.HttpRule{rule}, rule.AdditionalBindings...) {
+			b, err := newBinding(method, r)
+			if err != nil {
+				return nil, fmt.Errorf("%v: %v", method.FullName(), err)
+			}
+			g.bindings = append(g.bindings, b)
+		}
+	}
+
+	return &g, nil
+}
+
+// newBinding reads the route of a single HttpRule
+func newBinding(method protoreflect.MethodDescriptor, rule *annotations.HttpRule) (*binding, error) {
+	var httpMethod, template string
+	switch p := rule.Pattern.(type) {
+	case *annotations.HttpRule_Get:
+		httpMethod, template = "GET", p.Get
+	case *annotations.HttpRule_Put:
+		httpMethod, template = "PUT", p.Put
+	case *annotations.HttpRule_Post:
+		httpMethod, template = "POST", p.Post
+	case *annotations.HttpRule_Delete:
+		httpMethod, template = "DELETE", p.Delete
+	case *annotations.HttpRule_Patch:
+		httpMethod, template = "PATCH", p.Patch
+	case *annotations.HttpRule_Custom:
+		httpMethod, template = p.Custom.Kind, p.Custom.Path
+	default:
+		return nil, errors.New("http rule has no pattern")
+	}
+
+	pat, err := parsePattern(template)
+	if err != nil {
+		return nil, err
+	}
+
+	return &binding{
+		httpMethod: httpMethod,
+		pattern: pat,
+		method: method,
+		fullMethod: fmt.Sprintf("/%v/%v", method.Parent().FullName(), method.Name()),
+		body: rule.Body,
+		responseBody: rule.ResponseBody,
+	}, nil
+}
+
+// newMessage creates an empty message of the generated type for desc
+func newMessage(desc protoreflect.MessageDescriptor) (proto.Message, error) {
+	mt, err := protoregistry.GlobalTypes.FindMessageByName(desc.FullName())
+	if err != nil {
+		return nil, err
+	}
+	return mt.New().Interface(), nil
+}
+
+// ServeHTTP finds the RPC bound to the request's route and calls it
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var allowed []string
+	for _, b := range g.bindings {
+		vars, ok := b.pattern.match(r.URL.EscapedPath())
+		if !ok {
+			continue
+		}
+		if b.httpMethod != r.Method {
+			allowed = append(allowed, b.httpMethod)
+			continue
+		}
+
+		log.Printf("Gateway %v: %v %v\n", b.method.Name(), r.Method, r.URL.Path)
+		g.serve(w, r, b, vars)
+		return
+	}
+
+	if len(allowed) > 0 {
+		sort.Strings(allowed)
+		methodNotAllowed(w, r, allowed)
+		return
+	}
+
+	writeProblem(w, problem{
+		Type: problemTypePrefix + "route_not_found",
+		Title: http.StatusText(http.StatusNotFound),
+		Status: http.StatusNotFound,
+		Detail: "no route for " + r.URL.Path,
+		Code: "route_not_found",
+	})
+}
+
+// outgoingContext forwards the request's credentials and Grpc-Metadata-* headers as gRPC metadata,
+// along with the client's address and the identity of the certificate it connected with
+func outgoingContext(r *http.Request) context.Context {
+	md := metadata.MD{}
+	for name, values := range r.Header {
+		switch {
+		case name == "Authorization":
+			md.Append("authorization", values...)
+		case name == "X-Api-Key":
+			md.Append("x-api-key", values...)
+		case name == "Idempotency-Key":
+			md.Append("idempotency-key", values...)
+		case strings.HasPrefix(name, metadataHeaderPrefix):
+			md.Append(strings.TrimPrefix(name, metadataHeaderPrefix), values...)
+		}
+	}
+
+	// let the service see who actually sent the request
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		forwarded := host
+		if prior := r.Header.Get("X-Forwarded-For"); prior != "" {
+			forwarded = prior + ", " + host
+		}
+		md.Set(loopback.ForwardedForKey, forwarded)
+	}
+	tlsconfig.ForwardIdentity(md, tlsconfig.IdentityFromContext(r.Context()))
+
+	return metadata.NewOutgoingContext(r.Context(), md)
+}
+
+// writeHeaderMetadata returns the RPC's header metadata as Grpc-Metadata-* response headers
+func writeHeaderMetadata(w http.ResponseWriter, md metadata.MD) {
+	for name, values := range md {
+		// binary metadata and the content type don't mean anything to HTTP clients
+		if strings.HasSuffix(name, "-bin") || name == "content-type" {
+			continue
+		}
+		header := metadataHeaderPrefix + name
+		if name == "idempotent-replayed" {
+			// the header clients of idempotent HTTP APIs expect
+			header = "Idempotent-Replayed"
+		}
+		for _, value := range values {
+			w.Header().Add(header, value)
+		}
+	}
+}
+
+// decodeRequest builds the RPC request from the query string, body and path variables,
+// later sources overwrite earlier ones
+func decodeRequest(r *http.Request, b *binding, vars map[string]string) (proto.Message, error) {
+	req, err := newMessage(b.method.Input())
+	if err != nil {
+		return nil, err
+	}
+
+	// with a body of "*" every field comes from the body
+	if b.body != "*" {
+		if err := decodeQuery(req, r.URL.Query()); err != nil {
+			return nil, err
+		}
+	}
+	if b.body != "" {
+		if err := decodeBody(req, b, r.Body); err != nil {
+			return nil, err
+		}
+	}
+	for field, value := range vars {
+		if err := setField(req.ProtoReflect(), field, value); err != nil {
+			return nil, err
+		}
+	}
+
+	return req, nil
+}
+
+// responseMessage picks the part of res written as the HTTP body
+func responseMessage(res proto.Message, b *binding) (interface{}, error) {
+	if b.responseBody == "" {
+		return res, nil
+	}
+	fd := findField(res.ProtoReflect().Descriptor(), b.responseBody)
+	if fd == nil {
+		return nil, fmt.Errorf("response has no field %q", b.responseBody)
+	}
+	return res.ProtoReflect().Get(fd).Interface(), nil
+}
+
+// marshal encodes a response message, or a field of one, as JSON
+func marshal(v interface{}) ([]byte, error) {
+	switch v := v.(type) {
+	case proto.Message:
+		return marshalOptions.Marshal(v)
+	case protoreflect.Message:
+		return marshalOptions.Marshal(v.Interface())
+	}
+	return json.Marshal(v)
+}
+
+// serve transcodes a request to its RPC and the RPC's response back to JSON
+func (g *Gateway) serve(w http.ResponseWriter, r *http.Request, b *binding, vars map[string]string) {
+	if b.method.IsStreamingClient() {
+		g.serveClientStream(w, r, b, vars)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+	req, err := decodeRequest(r, b, vars)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	ctx := outgoingContext(r)
+	if b.method.IsStreamingServer() {
+		stream, err := g.conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, b.fullMethod)
+		if err != nil {
+			writeErr(w, err)
+			return
+		}
+		if err := stream.SendMsg(req); err != nil {
+			writeErr(w, err)
+			return
+		}
+		if err := stream.CloseSend(); err != nil {
+			writeErr(w, err)
+			return
+		}
+		g.writeStream(w, stream, b, nil, false)
+		return
+	}
+
+	res, err := newMessage(b.method.Output())
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+	var header metadata.MD
+	if err := g.conn.Invoke(ctx, b.fullMethod, req, res, grpc.Header(&header)); err != nil {
+		writeHeaderMetadata(w, header)
+		writeErr(w, err)
+		return
+	}
+
+	body, err := responseMessage(res, b)
+	if err == nil {
+		var data []byte
+		if data, err = marshal(body); err == nil {
+			writeHeaderMetadata(w, header)
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.Write(data)
+			return
+		}
+	}
+	writeErr(w, err)
+}
+
+// serveClientStream sends each line of the request body as a message of a client streaming RPC,
+// decoding one line at a time as the RPC runs so the body is never held in memory
+func (g *Gateway) serveClientStream(w http.ResponseWriter, r *http.Request, b *binding, vars map[string]string) {
+	ctx, cancel := context.WithCancel(outgoingContext(r))
+	defer cancel()
+	desc := grpc.StreamDesc{ClientStreams: true, ServerStreams: b.method.IsStreamingServer()}
+	stream, err := g.conn.NewStream(ctx, &desc, b.fullMethod)
+	if err != nil {
+		writeErr(w, err)
+		return
+	}
+
+	// send while the responses are read, the server replies as it goes and
+	// would stop reading once its responses fill the flow control window
+	sent := make(chan error, 1)
+	go func() {
+		err := sendLines(stream, r.Body, b, vars)
+		if err != nil {
+			// stop the RPC, writeStream reports err in place of the cancellation
+			cancel()
+		}
+		sent <- err
+	}()
+
+	// HTTP/1 handlers can't read the request once they start responding
+	g.writeStream(w, stream, b, sent, r.ProtoMajor < 2)
+}
+
+// sendLines decodes each line of body into a request, sets the path variables on it
+// and sends it on stream, then closes the sending side. Blank lines are skipped.
+func sendLines(stream grpc.ClientStream, body io.Reader, b *binding, vars map[string]string) error {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(nil, maxRequestSize)
+	line := 0
+	for scanner.Scan() {
+		line++
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		req, err := newMessage(b.method.Input())
+		if err != nil {
+			return err
+		}
+		if err := unmarshalOptions.Unmarshal(data, req); err != nil {
+			return requestError(fmt.Sprintf("invalid JSON on line %v: %v", line, err))
+		}
+		for field, value := range vars {
+			if err := setField(req.ProtoReflect(), field, value); err != nil {
+				return err
+			}
+		}
+
+		if err := stream.SendMsg(req); err != nil {
+			// the RPC is over, its error is reported by RecvMsg
+			return nil
+		}
+	}
+	if err := scanner.Err(); err == bufio.ErrTooLong {
+		return errRequestTooLarge
+	} else if err != nil {
+		return err
+	}
+
+	return stream.CloseSend()
+}
+
+// writeStream writes every response of a server stream as a line of JSON, {"result": ...},
+// flushing after each one. Errors after the first response are written as {"error": <problem>}.
+// For client streams sent reports how sending the request ended, and with hold the responses
+// received before then are kept until it has.
+func (g *Gateway) writeStream(w http.ResponseWriter, stream grpc.ClientStream, b *binding, sent <-chan error, hold bool) {
+	flusher, _ := w.(http.Flusher)
+	started := false
+	var held [][]byte
+
+	sending := sent != nil
+	var sendErr error
+	// wait checks whether sending has ended, blocking until it has if block is set
+	wait := func(block bool) {
+		if !sending {
+			return
+		}
+		if block {
+			sendErr = <-sent
+			sending = false
+			return
+		}
+		select {
+		case sendErr = <-sent:
+			sending = false
+		default:
+		}
+	}
+
+	// write writes a line, starting the response before the first one
+	write := func(line []byte) {
+		if !started {
+			header, _ := stream.Header()
+			writeHeaderMetadata(w, header)
+			w.Header().Set("Content-Type", "application/x-ndjson")
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+		w.Write(line)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+
+	for {
+		res, err := newMessage(b.method.Output())
+		if err == nil {
+			err = stream.RecvMsg(res)
+		}
+		if err != nil {
+			// the RPC is over, so sending has ended or is about to
+			wait(true)
+			if sendErr != nil {
+				err = sendErr
+			}
+			for _, line := range held {
+				write(line)
+			}
+		}
+		if err == io.EOF {
+			if !started {
+				// an empty stream is still a successful one
+				w.Header().Set("Content-Type", "application/x-ndjson")
+				w.WriteHeader(http.StatusOK)
+			}
+			return
+		}
+		if err != nil {
+			if !started {
+				header, _ := stream.Header()
+				writeHeaderMetadata(w, header)
+				writeErr(w, err)
+				return
+			}
+			line, _ := json.Marshal(map[string]problem{"error": errorProblem(err)})
+			w.Write(append(line, '\n'))
+			return
+		}
+
+		body, err := responseMessage(res, b)
+		var data []byte
+		if err == nil {
+			data, err = marshal(body)
+		}
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		line := []byte(fmt.Sprintf("{\"result\":%s}\n", data))
+
+		if hold {
+			wait(false)
+		}
+		if hold && sending {
+			held = append(held, line)
+			continue
+		}
+		for _, h := range held {
+			write(h)
+		}
+		held = nil
+		write(line)
+	}
+}
@@ -0,0 +1,430 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	pb "github.com/IM-Deane/mailing-list/proto"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/runtime/protoiface"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// testService records the last request it got and echoes it back,
+// the unary RPCs fail with err when it's set
+type testService struct {
+	pb.UnimplementedMailingListServiceServer
+	mu sync.Mutex
+	last proto.Message
+	imported []*pb.ImportEmailsRequest
+	err error
+}
+
+func (s *testService) record(req proto.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.last = req
+	return s.err
+}
+
+func (s *testService) lastRequest() proto.Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.last
+}
+
+func (s *testService) CreateEmail(ctx context.Context, req *pb.CreateEmailRequest) (*pb.EmailResponse, error) {
+	if err := s.record(req); err != nil {
+		return nil, err
+	}
+	return &pb.EmailResponse{EmailEntry: &pb.EmailEntry{Email: req.EmailAddr}}, nil
+}
+
+func (s *testService) GetEmail(ctx context.Context, req *pb.GetEmailRequest) (*pb.EmailResponse, error) {
+	if err := s.record(req); err != nil {
+		return nil, err
+	}
+	return &pb.EmailResponse{EmailEntry: &pb.EmailEntry{Email: req.EmailAddr}}, nil
+}
+
+func (s *testService) UpdateEmail(ctx context.Context, req *pb.UpdateEmailRequest) (*pb.EmailResponse, error) {
+	if err := s.record(req); err != nil {
+		return nil, err
+	}
+	return &pb.EmailResponse{EmailEntry: req.EmailEntry}, nil
+}
+
+func (s *testService) GetEmailBatch(ctx context.Context, req *pb.GetEmailBatchRequest) (*pb.GetEmailBatchResponse, error) {
+	if err := s.record(req); err != nil {
+		return nil, err
+	}
+	return &pb.GetEmailBatchResponse{}, nil
+}
+
+func (s *testService) StreamEmails(req *pb.StreamEmailsRequest, stream pb.MailingListService_StreamEmailsServer) error {
+	s.record(req)
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		if err := stream.Send(&pb.EmailEntry{Email: email}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *testService) ImportEmails(stream pb.MailingListService_ImportEmailsServer) error {
+	summary := &pb.ImportSummary{}
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		s.mu.Lock()
+		s.imported = append(s.imported, req)
+		s.mu.Unlock()
+
+		summary.Total++
+		summary.Created++
+		err = stream.Send(&pb.ImportEmailsResponse{Results: []*pb.ImportEmailResult{
+			{Email: req.EmailEntry.GetEmail(), Status: pb.ImportStatus_IMPORT_STATUS_CREATED},
+		}})
+		if err != nil {
+			return err
+		}
+	}
+	return stream.Send(&pb.ImportEmailsResponse{Summary: summary})
+}
+
+// testGateway serves a gateway in front of a testService reached over an in-memory connection
+func testGateway(t *testing.T) (*Gateway, *httptest.Server, *testService) {
+	svc := &testService{}
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	pb.RegisterMailingListServiceServer(server, svc)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufconn",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	gw, err := New(conn, pb.File_Proto_mail_proto.Services().ByName("MailingListService"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(gw)
+	t.Cleanup(srv.Close)
+
+	return gw, srv, svc
+}
+
+// request sends a request to the gateway and returns the response with its body
+func request(t *testing.T, srv *httptest.Server, method string, path string, body string) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	res, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res, data
+}
+
+// expectProblem fails unless the response is a problem+json body with status and code
+func expectProblem(t *testing.T, res *http.Response, data []byte, want int, code string) {
+	t.Helper()
+	if res.StatusCode != want {
+		t.Fatalf("got %v %s, want %v", res.StatusCode, data, want)
+	}
+	if ct := res.Header.Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("got Content-Type %q, want application/problem+json", ct)
+	}
+	var p problem
+	if err := json.Unmarshal(data, &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Code != code || p.Status != want {
+		t.Errorf("got %+v, want code %v", p, code)
+	}
+}
+
+// streamLines decodes each line of an NDJSON response
+func streamLines(t *testing.T, data []byte) []map[string]json.RawMessage {
+	t.Helper()
+	var lines []map[string]json.RawMessage
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var v map[string]json.RawMessage
+		if err := json.Unmarshal([]byte(line), &v); err != nil {
+			t.Fatalf("line %q: %v", line, err)
+		}
+		lines = append(lines, v)
+	}
+	return lines
+}
+
+func TestPathTemplates(t *testing.T) {
+	_, srv, svc := testGateway(t)
+
+	// escaped path variables are decoded
+	res, data := request(t, srv, "GET", "/v1/emails/some%2Bone@example.com", "")
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got %v %s", res.StatusCode, data)
+	}
+	if got := svc.lastRequest().(*pb.GetEmailRequest).EmailAddr; got != "some+one@example.com" {
+		t.Errorf("got email_addr %q", got)
+	}
+
+	// nested fields are bound from the path
+	res, data = request(t, srv, "PATCH", "/v1/emails/someone@example.com", `{"optOut": true}`)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got %v %s", res.StatusCode, data)
+	}
+	if got := svc.lastRequest().(*pb.UpdateEmailRequest).EmailEntry.GetEmail(); got != "someone@example.com" {
+		t.Errorf("got email_entry.email %q", got)
+	}
+
+	// the verb picks StreamEmails rather than GetEmail for "emails:stream"
+	res, data = request(t, srv, "GET", "/v1/emails:stream", "")
+	if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("got %v %v %s", res.StatusCode, res.Header.Get("Content-Type"), data)
+	}
+	lines := streamLines(t, data)
+	if len(lines) != 2 || !strings.Contains(string(lines[0]["result"]), "a@example.com") {
+		t.Errorf("got %s, want a result line per email", data)
+	}
+	if _, ok := svc.lastRequest().(*pb.StreamEmailsRequest); !ok {
+		t.Errorf("got %T, want a StreamEmailsRequest", svc.lastRequest())
+	}
+}
+
+func TestQueryParams(t *testing.T) {
+	_, srv, svc := testGateway(t)
+
+	res, data := request(t, srv, "GET", "/v1/emails?count=5&list=news&confirmedOnly=true&filters=a%3D1&filters=b%3D2", "")
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got %v %s", res.StatusCode, data)
+	}
+	want := &pb.GetEmailBatchRequest{Count: 5, List: "news", ConfirmedOnly: true, Filters: []string{"a=1", "b=2"}}
+	if got := svc.lastRequest(); !proto.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	res, data = request(t, srv, "GET", "/v1/emails?count=many", "")
+	expectProblem(t, res, data, http.StatusBadRequest, "invalid_request")
+	res, data = request(t, srv, "GET", "/v1/emails?nope=1", "")
+	expectProblem(t, res, data, http.StatusBadRequest, "invalid_request")
+}
+
+func TestBody(t *testing.T) {
+	_, srv, svc := testGateway(t)
+
+	// with body "*" the whole request comes from the body and the query string is ignored
+	res, data := request(t, srv, "POST", "/v1/emails?list=ignored", `{"emailAddr": "someone@example.com", "list": "news"}`)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got %v %s", res.StatusCode, data)
+	}
+	want := &pb.CreateEmailRequest{EmailAddr: "someone@example.com", List: "news"}
+	if got := svc.lastRequest(); !proto.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// a named body fills that field, the rest comes from the query string
+	// and the update mask lists the fields present in the body
+	res, data = request(t, srv, "PATCH", "/v1/emails/someone@example.com?list=news", `{"confirmed_at": "1700000000", "optOut": false}`)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got %v %s", res.StatusCode, data)
+	}
+	update := svc.lastRequest().(*pb.UpdateEmailRequest)
+	if update.List != "news" || update.EmailEntry.GetConfirmedAt() != 1700000000 {
+		t.Errorf("got %v", update)
+	}
+	if paths := update.UpdateMask.GetPaths(); !reflect.DeepEqual(paths, []string{"confirmed_at", "opt_out"}) {
+		t.Errorf("got update mask %v, want the fields in the body", paths)
+	}
+
+	// an explicit mask is kept
+	res, data = request(t, srv, "PATCH", "/v1/emails/someone@example.com?update_mask=opt_out", `{"optOut": true, "confirmedAt": "1"}`)
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got %v %s", res.StatusCode, data)
+	}
+	if paths := svc.lastRequest().(*pb.UpdateEmailRequest).UpdateMask.GetPaths(); !reflect.DeepEqual(paths, []string{"opt_out"}) {
+		t.Errorf("got update mask %v, want opt_out", paths)
+	}
+
+	res, data = request(t, srv, "POST", "/v1/emails", `{"emailAddr": `)
+	expectProblem(t, res, data, http.StatusBadRequest, "invalid_request")
+
+	huge := `{"emailAddr": "` + strings.Repeat("a", maxRequestSize) + `"}`
+	res, data = request(t, srv, "POST", "/v1/emails", huge)
+	expectProblem(t, res, data, http.StatusRequestEntityTooLarge, "request_too_large")
+}
+
+func TestErrors(t *testing.T) {
+	_, srv, svc := testGateway(t)
+
+	withDetails := func(code codes.Code, msg string, details ...protoiface.MessageV1) error {
+		st, err := status.New(code, msg).WithDetails(details...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return st.Err()
+	}
+
+	tests := []struct {
+		name string
+		err error
+		status int
+		code string
+		retryAfter string
+	}{
+		{"not found", withDetails(codes.NotFound, "email not found", &errdetails.ErrorInfo{Reason: "EMAIL_NOT_FOUND"}), http.StatusNotFound, "email_not_found", ""},
+		{"invalid email", withDetails(codes.InvalidArgument, "invalid email", &errdetails.ErrorInfo{Reason: "INVALID_EMAIL"}), http.StatusUnprocessableEntity, "invalid_email", ""},
+		{"invalid argument", withDetails(codes.InvalidArgument, "count is required", &errdetails.ErrorInfo{Reason: "INVALID_ARGUMENT"}), http.StatusBadRequest, "invalid_request", ""},
+		{"without details", status.Error(codes.Unavailable, "down"), http.StatusServiceUnavailable, "unavailable", ""},
+		{"unauthenticated", status.Error(codes.Unauthenticated, "no key"), http.StatusUnauthorized, "unauthenticated", ""},
+		{"rate limited", withDetails(codes.ResourceExhausted, "slow down",
+			&errdetails.ErrorInfo{Reason: "RATE_LIMITED"},
+			&errdetails.RetryInfo{RetryDelay: durationpb.New(1500 * time.Millisecond)}), http.StatusTooManyRequests, "rate_limited", "2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc.mu.Lock()
+			svc.err = tt.err
+			svc.mu.Unlock()
+
+			res, data := request(t, srv, "GET", "/v1/emails/someone@example.com", "")
+			expectProblem(t, res, data, tt.status, tt.code)
+			if got := res.Header.Get("Retry-After"); got != tt.retryAfter {
+				t.Errorf("got Retry-After %q, want %q", got, tt.retryAfter)
+			}
+			if tt.status == http.StatusUnauthorized && res.Header.Get("WWW-Authenticate") == "" {
+				t.Error("401 without a WWW-Authenticate header")
+			}
+		})
+	}
+
+	res, data := request(t, srv, "GET", "/v2/nothing", "")
+	expectProblem(t, res, data, http.StatusNotFound, "route_not_found")
+
+	res, data = request(t, srv, "PUT", "/v1/emails/someone@example.com", "{}")
+	expectProblem(t, res, data, http.StatusMethodNotAllowed, "method_not_allowed")
+	if allow := res.Header.Get("Allow"); allow != "DELETE, GET, PATCH" {
+		t.Errorf("got Allow %q", allow)
+	}
+}
+
+func TestImportStream(t *testing.T) {
+	_, srv, svc := testGateway(t)
+
+	body := `{"emailEntry": {"email": "a@example.com"}, "list": "news", "batchSize": 10}
+
+{"emailEntry": {"email": "b@example.com"}, "list": "news"}
+`
+	res, data := request(t, srv, "POST", "/v1/emails:import", body)
+	if res.StatusCode != http.StatusOK || res.Header.Get("Content-Type") != "application/x-ndjson" {
+		t.Fatalf("got %v %v %s", res.StatusCode, res.Header.Get("Content-Type"), data)
+	}
+
+	// a result per line, blank lines are skipped, then the summary
+	lines := streamLines(t, data)
+	if len(lines) != 3 {
+		t.Fatalf("got %s, want 3 lines", data)
+	}
+	var last struct {
+		Summary struct {
+			Total int `json:"total"`
+		} `json:"summary"`
+	}
+	if err := json.Unmarshal(lines[2]["result"], &last); err != nil || last.Summary.Total != 2 {
+		t.Errorf("got last line %s, want a summary of 2", lines[2]["result"])
+	}
+
+	svc.mu.Lock()
+	imported := svc.imported
+	svc.mu.Unlock()
+	if len(imported) != 2 || imported[0].BatchSize != 10 || imported[1].EmailEntry.GetEmail() != "b@example.com" {
+		t.Errorf("got %v, want both requests", imported)
+	}
+
+	res, data = request(t, srv, "POST", "/v1/emails:import", "{nope}\n")
+	expectProblem(t, res, data, http.StatusBadRequest, "invalid_request")
+	if !strings.Contains(string(data), "line 1") {
+		t.Errorf("got %s, want the line number", data)
+	}
+
+	// each line is limited rather than the whole body
+	huge := `{"emailEntry": {"email": "` + strings.Repeat("a", maxRequestSize) + `"}}` + "\n"
+	res, data = request(t, srv, "POST", "/v1/emails:import", huge)
+	expectProblem(t, res, data, http.StatusRequestEntityTooLarge, "request_too_large")
+}
+
+func TestOpenAPI(t *testing.T) {
+	gw, _, _ := testGateway(t)
+	doc := gw.OpenAPI()
+
+	routes := 0
+	for path, item := range doc.Paths {
+		for method, op := range item {
+			routes++
+			if method == "get" && op.RequestBody != nil {
+				t.Errorf("GET %v documents a request body", path)
+			}
+		}
+	}
+	if routes != len(gw.bindings) {
+		t.Errorf("got %v operations, want one per binding (%v)", routes, len(gw.bindings))
+	}
+
+	update := doc.Paths["/v1/emails/{email_entry.email}"]["patch"]
+	if update == nil || update.RequestBody == nil {
+		t.Fatalf("got %+v, want PATCH /v1/emails/{email_entry.email} with a body", doc.Paths["/v1/emails/{email_entry.email}"])
+	}
+	params := map[string]string{}
+	for _, p := range update.Parameters {
+		params[p.Name] = p.In
+	}
+	want := map[string]string{"email_entry.email": "path", "list": "query", "update_mask": "query"}
+	if !reflect.DeepEqual(params, want) {
+		t.Errorf("got parameters %v, want %v", params, want)
+	}
+
+	imp := doc.Paths["/v1/emails:import"]["post"]
+	if imp == nil || imp.RequestBody == nil || imp.RequestBody.Content["application/x-ndjson"].Schema == nil {
+		t.Errorf("got %+v, want an NDJSON request body for the import", imp)
+	}
+	if _, ok := doc.Components.Schemas["EmailEntry"]; !ok {
+		t.Error("EmailEntry schema is missing")
+	}
+}
@@ -0,0 +1,242 @@
+package gateway
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/IM-Deane/mailing-list/openapi"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// problemSchema describes the problem+json errors written by writeProblem
+var problemSchema = &openapi.Schema{
+	Type: "object",
+	Properties: map[string]*openapi.Schema{
+		"type": {Type: "string"},
+		"title": {Type: "string"},
+		"status": {Type: "integer", Format: "int32"},
+		"detail": {Type: "string"},
+		"code": {Type: "string", Description: "stable error code, the lowercased ErrorInfo reason of the gRPC status"},
+		"invalid_params": {Type: "array", Items: &openapi.Schema{
+			Type: "object",
+			Properties: map[string]*openapi.Schema{"name": {Type: "string"}, "reason": {Type: "string"}},
+		}},
+	},
+	Required: []string{"code", "status", "title", "type"},
+}
+
+// docBuilder collects the schemas of the messages used by the bindings
+type docBuilder struct {
+	schemas map[string]*openapi.Schema
+}
+
+// messageRef publishes the schema of a message under its name and refers to it
+func (d *docBuilder) messageRef(desc protoreflect.MessageDescriptor) *openapi.Schema {
+	name := string(desc.Name())
+	if _, ok := d.schemas[name]; ok {
+		return openapi.Ref(name)
+	}
+
+	s := &openapi.Schema{Type: "object", Properties: map[string]*openapi.Schema{}}
+	// published before the fields so recursive messages refer to themselves
+	d.schemas[name] = s
+	fields := desc.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		s.Properties[fd.JSONName()] = d.fieldSchema(fd)
+	}
+
+	return openapi.Ref(name)
+}
+
+// fieldSchema describes a field in the protobuf JSON mapping
+func (d *docBuilder) fieldSchema(fd protoreflect.FieldDescriptor) *openapi.Schema {
+	if fd.IsMap() {
+		return &openapi.Schema{Type: "object", AdditionalProperties: d.singularSchema(fd.MapValue())}
+	}
+	if fd.IsList() {
+		return &openapi.Schema{Type: "array", Items: d.singularSchema(fd)}
+	}
+	return d.singularSchema(fd)
+}
+
+// singularSchema describes a single value of a field, ignoring whether it's repeated
+func (d *docBuilder) singularSchema(fd protoreflect.FieldDescriptor) *openapi.Schema {
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return &openapi.Schema{Type: "string"}
+	case protoreflect.BoolKind:
+		return &openapi.Schema{Type: "boolean"}
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return &openapi.Schema{Type: "integer", Format: "int32"}
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return &openapi.Schema{Type: "integer", Format: "int64"}
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		// 64 bit integers are JSON strings so they survive JavaScript's doubles
+		return &openapi.Schema{Type: "string", Format: "int64"}
+	case protoreflect.FloatKind:
+		return &openapi.Schema{Type: "number", Format: "float"}
+	case protoreflect.DoubleKind:
+		return &openapi.Schema{Type: "number", Format: "double"}
+	case protoreflect.BytesKind:
+		return &openapi.Schema{Type: "string", Format: "byte"}
+	case protoreflect.EnumKind:
+		s := &openapi.Schema{Type: "string"}
+		values := fd.Enum().Values()
+		for i := 0; i < values.Len(); i++ {
+			s.Enum = append(s.Enum, string(values.Get(i).Name()))
+		}
+		return s
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		if fd.Message().FullName() == "google.protobuf.Value" {
+			// Values are plain JSON values rather than objects
+			return &openapi.Schema{Description: "any JSON value"}
+		}
+		if isFieldMask(fd) {
+			return &openapi.Schema{Type: "string", Description: "comma separated field paths"}
+		}
+		return d.messageRef(fd.Message())
+	}
+	return &openapi.Schema{}
+}
+
+// operation documents a single binding
+func (d *docBuilder) operation(b *binding) *openapi.Operation {
+	method := b.method
+	op := &openapi.Operation{
+		OperationID: string(method.Name()),
+		Summary: string(method.Name()),
+		Responses: map[string]openapi.Response{
+			"default": {
+				Description: "Error, see the code field for the reason",
+				Content: map[string]openapi.MediaType{"application/problem+json": {Schema: openapi.Ref("Problem")}},
+			},
+		},
+	}
+
+	input := method.Input()
+	inPath := map[string]bool{}
+	for _, seg := range b.pattern.segments {
+		if seg.field == "" {
+			continue
+		}
+		inPath[strings.Split(seg.field, ".")[0]] = true
+		param := openapi.Parameter{Name: seg.field, In: "path", Required: true, Schema: &openapi.Schema{Type: "string"}}
+		if fd := pathField(input, seg.field); fd != nil {
+			param.Schema = d.singularSchema(fd)
+		}
+		op.Parameters = append(op.Parameters, param)
+	}
+
+	// fields not bound by the path or body can be set in the query string
+	if b.body != "*" {
+		fields := input.Fields()
+		for i := 0; i < fields.Len(); i++ {
+			fd := fields.Get(i)
+			if inPath[string(fd.Name())] || string(fd.Name()) == b.body || (fd.Message() != nil && !isFieldMask(fd)) {
+				continue
+			}
+			op.Parameters = append(op.Parameters, openapi.Parameter{Name: string(fd.Name()), In: "query", Schema: d.fieldSchema(fd)})
+		}
+	}
+
+	switch {
+	case b.body == "*" && method.IsStreamingClient():
+		op.Description = "The request body is one JSON request per line."
+		op.RequestBody = &openapi.RequestBody{Required: true, Content: map[string]openapi.MediaType{
+			"application/x-ndjson": {Schema: d.messageRef(input)},
+		}}
+	case b.body == "*":
+		op.WithBody(d.messageRef(input))
+	case b.body != "":
+		if fd := pathField(input, b.body); fd != nil {
+			op.WithBody(d.fieldSchema(fd))
+		}
+		if b.httpMethod == "PATCH" && input.Fields().ByName(updateMaskField) != nil {
+			op.Description = "Only the fields present in the body are changed, unless update_mask lists them."
+		}
+	}
+
+	output := d.messageRef(method.Output())
+	if b.responseBody != "" {
+		if fd := pathField(method.Output(), b.responseBody); fd != nil {
+			output = d.fieldSchema(fd)
+		}
+	}
+	if method.IsStreamingServer() {
+		op.Responses["200"] = openapi.Response{
+			Description: "One JSON object per line, each response as result or a final error",
+			Content: map[string]openapi.MediaType{"application/x-ndjson": {Schema: &openapi.Schema{
+				Type: "object",
+				Properties: map[string]*openapi.Schema{"result": output, "error": openapi.Ref("Problem")},
+			}}},
+		}
+	} else {
+		op.Responses["200"] = openapi.Response{Description: "OK", Content: openapi.JSONContent(output)}
+	}
+
+	return op
+}
+
+// pathField finds the field at a dotted path, nil if there isn't one
+func pathField(desc protoreflect.MessageDescriptor, path string) protoreflect.FieldDescriptor {
+	var fd protoreflect.FieldDescriptor
+	for _, name := range strings.Split(path, ".") {
+		if desc == nil {
+			return nil
+		}
+		if fd = findField(desc, name); fd == nil {
+			return nil
+		}
+		desc = fd.Message()
+	}
+	return fd
+}
+
+// OpenAPI describes the gateway's routes, generated from the same HttpRules and
+// message descriptors the requests are transcoded with
+func (g *Gateway) OpenAPI() *openapi.Document {
+	d := docBuilder{schemas: map[string]*openapi.Schema{"Problem": problemSchema}}
+	doc := &openapi.Document{
+		OpenAPI: "3.0.3",
+		Info: openapi.Info{
+			Title: string(g.service.FullName()) + " HTTP/JSON gateway",
+			Version: "1.0.0",
+			Description: "Transcoded from the google.api.http options of " + string(g.service.ParentFile().Path()) + ". Errors are RFC 7807 problem details with a stable code.",
+		},
+		Paths: map[string]openapi.PathItem{},
+	}
+
+	for _, b := range g.bindings {
+		item, ok := doc.Paths[b.pattern.template]
+		if !ok {
+			item = openapi.PathItem{}
+			doc.Paths[b.pattern.template] = item
+		}
+		item[strings.ToLower(b.httpMethod)] = d.operation(b)
+	}
+	doc.Components.Schemas = d.schemas
+
+	return doc
+}
+
+// OpenAPIHandler serves the gateway's OpenAPI document
+func (g *Gateway) OpenAPIHandler() http.Handler {
+	doc, err := marshal(g.OpenAPI())
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			methodNotAllowed(w, r, []string{"GET"})
+			return
+		}
+		if err != nil {
+			writeErr(w, err)
+			return
+		}
+
+		log.Printf("Gateway OpenAPI\n")
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write(doc)
+	})
+}
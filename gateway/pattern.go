@@ -0,0 +1,118 @@
+package gateway
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// pattern is a parsed HttpRule path template such as /v1/emails/{email_addr} or /v1/emails:stream.
+// Variables can be {field}, {field=*} for one segment or {field=**} for the rest of the path.
+type pattern struct {
+	template string
+	segments []segment
+	// verb is the custom method after the last ":", e.g. "stream"
+	verb string
+}
+
+// segment is a literal path segment, or a variable bound to a request field
+type segment struct {
+	literal string
+	// field is the dotted path of the request field the segment is bound to, e.g. email_entry.email
+	field string
+	// rest binds every remaining segment, from {field=**}
+	rest bool
+}
+
+// parsePattern parses an HttpRule path template
+func parsePattern(template string) (*pattern, error) {
+	if !strings.HasPrefix(template, "/") {
+		return nil, fmt.Errorf("path template %q must start with /", template)
+	}
+	p := pattern{template: template}
+
+	path := template[1:]
+	// the verb follows the last segment, which can't be a variable
+	if i := strings.LastIndex(path, ":"); i > strings.LastIndex(path, "/") && i > strings.LastIndex(path, "}") {
+		path, p.verb = path[:i], path[i+1:]
+	}
+
+	for i, part := range strings.Split(path, "/") {
+		if !strings.HasPrefix(part, "{") {
+			if part == "" || strings.ContainsAny(part, "{}*") {
+				return nil, fmt.Errorf("path template %q has an invalid segment %q", template, part)
+			}
+			p.segments = append(p.segments, segment{literal: part})
+			continue
+		}
+		if !strings.HasSuffix(part, "}") {
+			return nil, fmt.Errorf("path template %q has an unterminated variable %q", template, part)
+		}
+
+		field, match := part[1:len(part)-1], "*"
+		if eq := strings.Index(field, "="); eq >= 0 {
+			field, match = field[:eq], field[eq+1:]
+		}
+		switch match {
+		case "*":
+			p.segments = append(p.segments, segment{field: field})
+		case "**":
+			if i != strings.Count(path, "/") {
+				return nil, fmt.Errorf("path template %q can only use ** in the last segment", template)
+			}
+			p.segments = append(p.segments, segment{field: field, rest: true})
+		default:
+			return nil, fmt.Errorf("path template %q uses an unsupported variable pattern %q", template, match)
+		}
+	}
+
+	return &p, nil
+}
+
+// match matches an escaped request path, returning the unescaped value of every variable
+func (p *pattern) match(path string) (map[string]string, bool) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, false
+	}
+	path = path[1:]
+	if p.verb != "" {
+		if !strings.HasSuffix(path, ":"+p.verb) {
+			return nil, false
+		}
+		path = strings.TrimSuffix(path, ":"+p.verb)
+	}
+
+	parts := strings.Split(path, "/")
+	vars := map[string]string{}
+	for i, seg := range p.segments {
+		if i >= len(parts) {
+			return nil, false
+		}
+
+		if seg.rest {
+			value, err := url.PathUnescape(strings.Join(parts[i:], "/"))
+			if err != nil || value == "" {
+				return nil, false
+			}
+			vars[seg.field] = value
+			return vars, true
+		}
+
+		value, err := url.PathUnescape(parts[i])
+		if err != nil || value == "" {
+			return nil, false
+		}
+		if seg.field == "" {
+			if value != seg.literal {
+				return nil, false
+			}
+			continue
+		}
+		vars[seg.field] = value
+	}
+
+	if len(parts) != len(p.segments) {
+		return nil, false
+	}
+	return vars, true
+}
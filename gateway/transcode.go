@@ -0,0 +1,221 @@
+package gateway
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+var (
+	marshalOptions = protojson.MarshalOptions{EmitUnpopulated: true}
+	// unknown fields are ignored so older servers accept requests from newer clients
+	unmarshalOptions = protojson.UnmarshalOptions{DiscardUnknown: true}
+)
+
+const (
+	// maxRequestSize caps request bodies, and each line of a streaming request's body
+	maxRequestSize = 1 << 20
+	// updateMaskField is the request field listing the fields a PATCH changes
+	updateMaskField = "update_mask"
+)
+
+// errRequestTooLarge is returned for bodies, or lines of a streaming body, over maxRequestSize
+var errRequestTooLarge = errors.New("request body too large")
+
+// requestError is a request that couldn't be transcoded into the RPC's request message
+type requestError string
+
+func (e requestError) Error() string {
+	return string(e)
+}
+
+// findField looks up a field by its proto name or its JSON name
+func findField(desc protoreflect.MessageDescriptor, name string) protoreflect.FieldDescriptor {
+	if fd := desc.Fields().ByName(protoreflect.Name(name)); fd != nil {
+		return fd
+	}
+	return desc.Fields().ByJSONName(name)
+}
+
+// fieldPath resolves a dotted field path such as email_entry.email, creating the
+// parent messages on the way, and returns the message holding the last field
+func fieldPath(msg protoreflect.Message, path string) (protoreflect.Message, protoreflect.FieldDescriptor, error) {
+	names := strings.Split(path, ".")
+	for i, name := range names {
+		fd := findField(msg.Descriptor(), name)
+		if fd == nil {
+			return nil, nil, requestError(fmt.Sprintf("unknown field %q", path))
+		}
+		if i == len(names)-1 {
+			return msg, fd, nil
+		}
+		if fd.Message() == nil || fd.IsList() || fd.IsMap() {
+			return nil, nil, requestError(fmt.Sprintf("field %q is not a message", strings.Join(names[:i+1], ".")))
+		}
+		msg = msg.Mutable(fd).Message()
+	}
+	return nil, nil, requestError(fmt.Sprintf("unknown field %q", path))
+}
+
+// parseScalar converts a path or query string value to the type of fd
+func parseScalar(fd protoreflect.FieldDescriptor, value string) (protoreflect.Value, error) {
+	var v interface{}
+	var err error
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(value), nil
+	case protoreflect.BoolKind:
+		v, err = strconv.ParseBool(value)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		var n int64
+		n, err = strconv.ParseInt(value, 10, 32)
+		v = int32(n)
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		v, err = strconv.ParseInt(value, 10, 64)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		var n uint64
+		n, err = strconv.ParseUint(value, 10, 32)
+		v = uint32(n)
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		v, err = strconv.ParseUint(value, 10, 64)
+	case protoreflect.FloatKind:
+		var f float64
+		f, err = strconv.ParseFloat(value, 32)
+		v = float32(f)
+	case protoreflect.DoubleKind:
+		v, err = strconv.ParseFloat(value, 64)
+	case protoreflect.BytesKind:
+		v, err = base64.StdEncoding.DecodeString(value)
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByName(protoreflect.Name(value)); ev != nil {
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		}
+		var n int64
+		n, err = strconv.ParseInt(value, 10, 32)
+		v = protoreflect.EnumNumber(n)
+	default:
+		return protoreflect.Value{}, requestError(fmt.Sprintf("field %q can't be set from a string", fd.Name()))
+	}
+	if err != nil {
+		return protoreflect.Value{}, requestError(fmt.Sprintf("invalid value %q for field %q", value, fd.Name()))
+	}
+	return protoreflect.ValueOf(v), nil
+}
+
+// setField sets the field at path from its string form, values are appended to repeated fields
+func setField(msg protoreflect.Message, path string, value string) error {
+	parent, fd, err := fieldPath(msg, path)
+	if err != nil {
+		return err
+	}
+	if isFieldMask(fd) {
+		// a comma separated list of paths, e.g. ?update_mask=opt_out,attributes
+		mask := parent.Mutable(fd).Message().Interface().(*fieldmaskpb.FieldMask)
+		mask.Paths = append(mask.Paths, strings.Split(value, ",")...)
+		return nil
+	}
+	if fd.Message() != nil {
+		return requestError(fmt.Sprintf("field %q can't be set from a string", path))
+	}
+
+	v, err := parseScalar(fd, value)
+	if err != nil {
+		return err
+	}
+	if fd.IsList() {
+		parent.Mutable(fd).List().Append(v)
+		return nil
+	}
+	parent.Set(fd, v)
+	return nil
+}
+
+// decodeBody reads the JSON request body into the field named by the rule's body,
+// or into the whole message for "*". PATCH requests without an update mask get one
+// listing the fields present in the body, so only those are changed.
+func decodeBody(msg proto.Message, b *binding, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		// the body is read through a MaxBytesReader, which stops at the limit
+		if int64(len(data)) >= maxRequestSize {
+			return errRequestTooLarge
+		}
+		return err
+	}
+	if len(data) == 0 {
+		return nil
+	}
+
+	target := msg
+	if b.body != "*" {
+		parent, fd, err := fieldPath(msg.ProtoReflect(), b.body)
+		if err != nil {
+			return err
+		}
+		if fd.Message() == nil || fd.IsList() || fd.IsMap() {
+			return requestError(fmt.Sprintf("body field %q is not a message", b.body))
+		}
+		target = parent.Mutable(fd).Message().Interface()
+	}
+
+	if err := unmarshalOptions.Unmarshal(data, target); err != nil {
+		return requestError("invalid JSON body: " + err.Error())
+	}
+	if b.httpMethod == "PATCH" && b.body != "*" {
+		return setUpdateMask(msg.ProtoReflect(), target.ProtoReflect().Descriptor(), data)
+	}
+	return nil
+}
+
+// isFieldMask reports whether fd is a google.protobuf.FieldMask
+func isFieldMask(fd protoreflect.FieldDescriptor) bool {
+	return fd.Message() != nil && fd.Message().FullName() == "google.protobuf.FieldMask"
+}
+
+// setUpdateMask sets the request's update_mask, when it has one the client didn't set,
+// to the fields of the body's message present in data, as grpc-gateway does
+func setUpdateMask(msg protoreflect.Message, body protoreflect.MessageDescriptor, data []byte) error {
+	fd := msg.Descriptor().Fields().ByName(updateMaskField)
+	if fd == nil || !isFieldMask(fd) || msg.Has(fd) {
+		return nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return requestError("invalid JSON body: " + err.Error())
+	}
+	mask := &fieldmaskpb.FieldMask{}
+	for name := range fields {
+		// unknown fields were discarded when the body was decoded
+		if field := findField(body, name); field != nil {
+			mask.Paths = append(mask.Paths, string(field.Name()))
+		}
+	}
+	sort.Strings(mask.Paths)
+
+	msg.Set(fd, protoreflect.ValueOfMessage(mask.ProtoReflect()))
+	return nil
+}
+
+// decodeQuery sets fields from the query string, e.g. ?list=news&confirmed_only=true
+func decodeQuery(msg proto.Message, query url.Values) error {
+	for name, values := range query {
+		for _, value := range values {
+			if err := setField(msg.ProtoReflect(), name, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -4,7 +4,9 @@ go 1.18
 
 require (
 	github.com/alexflint/go-arg v1.4.3
+	github.com/lib/pq v1.10.7
 	github.com/mattn/go-sqlite3 v1.14.16
+	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013
 	google.golang.org/grpc v1.50.1
 	google.golang.org/protobuf v1.28.1
 )
@@ -15,5 +17,4 @@ require (
 	golang.org/x/net v0.0.0-20201021035429-f5854403a974 // indirect
 	golang.org/x/sys v0.0.0-20210119212857-b64e53b001e4 // indirect
 	golang.org/x/text v0.3.3 // indirect
-	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013 // indirect
 )
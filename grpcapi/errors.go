@@ -0,0 +1,144 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"log"
+	"strings"
+
+	"github.com/IM-Deane/mailing-list/auth"
+	"github.com/IM-Deane/mailing-list/idempotency"
+	"github.com/IM-Deane/mailing-list/mdb"
+	"github.com/IM-Deane/mailing-list/ratelimit"
+	"github.com/IM-Deane/mailing-list/token"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/runtime/protoiface"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// errorDomain identifies our errors in ErrorInfo details
+const errorDomain = "mailinglist"
+
+// errorCode picks the status code for err along with a stable reason clients can match on
+func errorCode(err error) (codes.Code, string) {
+	var mdbErr *mdb.Error
+	var validationErr *mdb.ValidationError
+	var attributeErr *mdb.AttributeError
+	switch {
+	case errors.As(err, &mdbErr) && errors.Is(err, mdb.ErrNotFound):
+		return codes.NotFound, strings.ToUpper(mdbErr.Resource) + "_NOT_FOUND"
+	case errors.As(err, &mdbErr) && errors.Is(err, mdb.ErrAlreadyExists):
+		return codes.AlreadyExists, strings.ToUpper(mdbErr.Resource) + "_ALREADY_EXISTS"
+	case errors.As(err, &validationErr):
+		return codes.InvalidArgument, "INVALID_EMAIL"
+	case errors.As(err, &attributeErr):
+		return codes.InvalidArgument, "INVALID_ATTRIBUTE"
+	case errors.Is(err, mdb.ErrNotFound):
+		return codes.NotFound, "NOT_FOUND"
+	case errors.Is(err, mdb.ErrAlreadyExists):
+		return codes.AlreadyExists, "ALREADY_EXISTS"
+	case errors.Is(err, mdb.ErrInvalid):
+		return codes.InvalidArgument, "INVALID_ARGUMENT"
+	case errors.Is(err, token.ErrInvalid):
+		return codes.InvalidArgument, "INVALID_TOKEN"
+	case errors.Is(err, token.ErrExpired):
+		return codes.InvalidArgument, "TOKEN_EXPIRED"
+	case errors.Is(err, auth.ErrMissingKey):
+		return codes.Unauthenticated, "API_KEY_REQUIRED"
+	case errors.Is(err, auth.ErrInvalidKey):
+		return codes.Unauthenticated, "API_KEY_INVALID"
+	case errors.Is(err, auth.ErrInsufficientScope):
+		return codes.PermissionDenied, "INSUFFICIENT_SCOPE"
+	case errors.Is(err, ratelimit.ErrLimited):
+		return codes.ResourceExhausted, "RATE_LIMITED"
+	case errors.Is(err, idempotency.ErrInvalidKey):
+		return codes.InvalidArgument, "INVALID_IDEMPOTENCY_KEY"
+	case errors.Is(err, idempotency.ErrInProgress):
+		return codes.Aborted, "IDEMPOTENCY_KEY_IN_PROGRESS"
+	case errors.Is(err, idempotency.ErrKeyReused):
+		return codes.InvalidArgument, "IDEMPOTENCY_KEY_REUSED"
+	}
+	return codes.Internal, "INTERNAL"
+}
+
+// statusErr converts errors returned by handlers into gRPC status errors with
+// an ErrorInfo detail, plus ResourceInfo or BadRequest details where they apply.
+// Unexpected errors are logged and returned as Internal without their message,
+// which can contain database details.
+func statusErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		// already a status, e.g. from the stream or our own handlers
+		return err
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+
+	code, reason := errorCode(err)
+	msg := err.Error()
+	if code == codes.Internal {
+		log.Println(err)
+		msg = "internal error"
+	}
+
+	st := status.New(code, msg)
+	st = withDetail(st, &errdetails.ErrorInfo{Reason: reason, Domain: errorDomain})
+
+	var mdbErr *mdb.Error
+	if errors.As(err, &mdbErr) {
+		st = withDetail(st, &errdetails.ResourceInfo{
+			ResourceType: mdbErr.Resource,
+			ResourceName: mdbErr.Name,
+			Description: msg,
+		})
+	}
+	var limitErr *ratelimit.LimitError
+	if errors.As(err, &limitErr) {
+		st = withDetail(st, &errdetails.RetryInfo{RetryDelay: durationpb.New(limitErr.RetryAfter)})
+	}
+	var validationErr *mdb.ValidationError
+	if errors.As(err, &validationErr) {
+		st = withDetail(st, &errdetails.BadRequest{
+			FieldViolations: []*errdetails.BadRequest_FieldViolation{
+				{Field: "email", Description: validationErr.Reason},
+			},
+		})
+	}
+	var attributeErr *mdb.AttributeError
+	if errors.As(err, &attributeErr) {
+		st = withDetail(st, &errdetails.BadRequest{
+			FieldViolations: []*errdetails.BadRequest_FieldViolation{
+				{Field: "attributes." + attributeErr.Field, Description: attributeErr.Reason},
+			},
+		})
+	}
+
+	return st.Err()
+}
+
+// withDetail adds detail to st, keeping st as is if the detail can't be encoded
+func withDetail(st *status.Status, detail protoiface.MessageV1) *status.Status {
+	withDetail, err := st.WithDetails(detail)
+	if err != nil {
+		log.Println(err)
+		return st
+	}
+	return withDetail
+}
+
+// unaryErrorInterceptor converts errors returned by unary handlers with statusErr
+func unaryErrorInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	res, err := handler(ctx, req)
+	return res, statusErr(err)
+}
+
+// streamErrorInterceptor converts errors returned by streaming handlers with statusErr
+func streamErrorInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	return statusErr(handler(srv, ss))
+}
@@ -2,19 +2,30 @@ package grpcapi
 
 import (
 	"context"
-	"database/sql"
+	"crypto/tls"
 	"log"
 	"net"
 	"time"
 
+	"github.com/IM-Deane/mailing-list/auth"
+	"github.com/IM-Deane/mailing-list/idempotency"
+	"github.com/IM-Deane/mailing-list/loopback"
 	"github.com/IM-Deane/mailing-list/mdb"
 	pb "github.com/IM-Deane/mailing-list/proto"
+	"github.com/IM-Deane/mailing-list/ratelimit"
+	"github.com/IM-Deane/mailing-list/tlsconfig"
+	"github.com/IM-Deane/mailing-list/token"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 type MailServer struct {
 	pb.UnimplementedMailingListServiceServer
-	db *sql.DB
+	store mdb.SubscriberStore
+	tokens *token.Signer
 }
 
 // pbEntryToMdbEntry accepts protocol buffer and converts to mailing database EmailEntry
@@ -25,9 +36,58 @@ func pbEntryToMdbEntry(pbEntry *pb.EmailEntry) mdb.EmailEntry {
 		Email: pbEntry.Email,
 		ConfirmedAt: &t,
 		OptOut: pbEntry.OptOut,
+		Attributes: pbAttributesToMdb(pbEntry.Attributes),
 	}
 }
 
+// pbMaskToPatch builds a patch from the fields of pbEntry named in an update mask.
+// The fields identifying the email are ignored, other paths are an error.
+func pbMaskToPatch(pbEntry *pb.EmailEntry, paths []string) (mdb.EmailPatch, error) {
+	patch := mdb.EmailPatch{}
+	for _, path := range paths {
+		switch path {
+		case "confirmed_at":
+			t := time.Unix(pbEntry.ConfirmedAt, 0)
+			patch.ConfirmedAt = &t
+		case "opt_out":
+			optOut := pbEntry.OptOut
+			patch.OptOut = &optOut
+		case "attributes":
+			patch.Attributes = pbAttributesToMdb(pbEntry.Attributes)
+		case "id", "email":
+		default:
+			return patch, status.Errorf(codes.InvalidArgument, "update_mask path %q can't be updated", path)
+		}
+	}
+	return patch, nil
+}
+
+// pbAttributesToMdb converts protocol buffer attribute values, null values remove the attribute
+func pbAttributesToMdb(pbAttrs map[string]*structpb.Value) mdb.Attributes {
+	if len(pbAttrs) == 0 {
+		return nil
+	}
+	attrs := make(mdb.Attributes, len(pbAttrs))
+	for name, value := range pbAttrs {
+		attrs[name] = value.AsInterface()
+	}
+	return attrs
+}
+
+// mdbAttributesToPb converts attribute values to protocol buffer values
+func mdbAttributesToPb(attrs mdb.Attributes) map[string]*structpb.Value {
+	pbAttrs := make(map[string]*structpb.Value, len(attrs))
+	for name, value := range attrs {
+		pbValue, err := structpb.NewValue(value)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		pbAttrs[name] = pbValue
+	}
+	return pbAttrs
+}
+
 
 // mdbEntryToPbEntry accepts an mailing database pointer and converts to a protocol buffer
 func mdbEntryToPbEntry(mdbEntry *mdb.EmailEntry) *pb.EmailEntry {
@@ -36,18 +96,47 @@ func mdbEntryToPbEntry(mdbEntry *mdb.EmailEntry) *pb.EmailEntry {
 		Email: mdbEntry.Email,
 		ConfirmedAt: mdbEntry.ConfirmedAt.Unix(),
 		OptOut: mdbEntry.OptOut,
+		DomainFlag: mdbEntry.DomainFlag,
+		Attributes: mdbAttributesToPb(mdbEntry.Attributes),
 	}
 }
 
+// mdbFieldToPbField accepts a mailing database field and converts to a protocol buffer
+func mdbFieldToPbField(mdbField *mdb.Field) *pb.Field {
+	return &pb.Field{
+		Id: mdbField.ID,
+		Name: mdbField.Name,
+		Type: string(mdbField.Type),
+		Description: mdbField.Description,
+	}
+}
+
+// mdbListToPbList accepts a mailing database list and converts to a protocol buffer
+func mdbListToPbList(mdbList *mdb.List) *pb.MailingList {
+	return &pb.MailingList{
+		Id: mdbList.ID,
+		Name: mdbList.Name,
+		DomainPolicy: string(mdbList.DomainPolicy),
+	}
+}
+
+// getEmail fetches an email from list, or from the global email list if list is empty
+func getEmail(store mdb.EmailStore, list string, email string) (*mdb.EmailEntry, error) {
+	if list != "" {
+		return store.GetSubscription(list, email)
+	}
+	return store.GetEmail(email)
+}
+
 // emailResponse get email, convert to protocol buffer and return
-func emailResponse(db *sql.DB, email string) (*pb.EmailResponse, error) {
-	entry, err := mdb.GetEmail(db, email)
+func emailResponse(store mdb.EmailStore, list string, email string) (*pb.EmailResponse, error) {
+	entry, err := getEmail(store, list, email)
 	if err != nil {
 		return &pb.EmailResponse{}, err
 	}
 
 	if entry == nil {
-		return &pb.EmailResponse{}, nil
+		return &pb.EmailResponse{}, mdb.EmailNotFound(email)
 	}
 
 	// convert to protocol buffer
@@ -59,7 +148,7 @@ func emailResponse(db *sql.DB, email string) (*pb.EmailResponse, error) {
 // GetEmail gRPC handler for fetching an email
 func (s *MailServer) GetEmail(ctx context.Context, req *pb.GetEmailRequest) (*pb.EmailResponse, error) {
 	log.Printf("gRPC GetEmail: %v\n", req)
-	return emailResponse(s.db, req.EmailAddr)
+	return emailResponse(s.store, req.List, req.EmailAddr)
 }
 
 // GetEmailBatch gRPC handler for fetching a batch of emails
@@ -69,10 +158,25 @@ func (s *MailServer) GetEmailBatch(ctx context.Context, req *pb.GetEmailBatchReq
 	params := mdb.GetEmailBatchQueryParams{
 		Page: int(req.Page),
 		Count: int(req.Count),
+		Cursor: req.Cursor,
+		ConfirmedOnly: req.ConfirmedOnly,
+	}
+	if err := params.Validate(); err != nil {
+		return &pb.GetEmailBatchResponse{}, err
 	}
+	filters, err := mdb.ParseFilters(req.Filters)
+	if err != nil {
+		return &pb.GetEmailBatchResponse{}, err
+	}
+	params.Filters = filters
 
 	// query DB for emails
-	mdbEntries, err := mdb.GetEmailBatch(s.db, params)
+	var mdbEntries []mdb.EmailEntry
+	if req.List != "" {
+		mdbEntries, err = s.store.GetSubscriptionBatch(req.List, params)
+	} else {
+		mdbEntries, err = s.store.GetEmailBatch(params)
+	}
 	if err != nil {
 		return &pb.GetEmailBatchResponse{}, err
 	}
@@ -86,7 +190,70 @@ func (s *MailServer) GetEmailBatch(ctx context.Context, req *pb.GetEmailBatchReq
 		pbEntries = append(pbEntries, entry)
 	}
 
-	return &pb.GetEmailBatchResponse{EmailEntry: pbEntries}, nil
+	res := &pb.GetEmailBatchResponse{EmailEntry: pbEntries}
+	if params.UsesCursor() {
+		res.NextCursor = mdb.NextCursor(mdbEntries, params)
+	}
+
+	return res, nil
+}
+
+const (
+	// defaultStreamBatchSize is how many entries StreamEmails reads at a time when not set by the client
+	defaultStreamBatchSize = 500
+	// maxStreamBatchSize caps client batch sizes so a single read can't grow unbounded
+	maxStreamBatchSize = mdb.MaxBatchCount
+)
+
+// StreamEmails gRPC handler that streams every matching email, walking the table with a cursor
+func (s *MailServer) StreamEmails(req *pb.StreamEmailsRequest, stream pb.MailingListService_StreamEmailsServer) error {
+	log.Printf("gRPC StreamEmails: %v\n", req)
+
+	params := mdb.GetEmailBatchQueryParams{
+		Count: int(req.BatchSize),
+		ConfirmedOnly: req.ConfirmedOnly,
+		IncludeOptOut: req.IncludeOptedOut,
+	}
+	if params.Count <= 0 {
+		params.Count = defaultStreamBatchSize
+	}
+	if params.Count > maxStreamBatchSize {
+		params.Count = maxStreamBatchSize
+	}
+	filters, err := mdb.ParseFilters(req.Filters)
+	if err != nil {
+		return err
+	}
+	params.Filters = filters
+
+	for {
+		var mdbEntries []mdb.EmailEntry
+		if req.List != "" {
+			mdbEntries, err = s.store.GetSubscriptionBatch(req.List, params)
+		} else {
+			mdbEntries, err = s.store.GetEmailBatch(params)
+		}
+		if err != nil {
+			return err
+		}
+
+		for i := 0; i < len(mdbEntries); i++ {
+			// stop reading from the DB if the client has gone away
+			if err := stream.Context().Err(); err != nil {
+				return err
+			}
+			// Send blocks while the client's flow control window is full,
+			// so a slow reader holds us here rather than us buffering the table
+			if err := stream.Send(mdbEntryToPbEntry(&mdbEntries[i])); err != nil {
+				return err
+			}
+		}
+
+		params.Cursor = mdb.NextCursor(mdbEntries, params)
+		if params.Cursor == "" {
+			return nil
+		}
+	}
 }
 
 // CreateEmail gRPC handler for creating an email via gRPC
@@ -94,28 +261,73 @@ func (s *MailServer) CreateEmail(ctx context.Context, req *pb.CreateEmailRequest
 	log.Printf("gRPC CreateEmail: %v\n", req)
 
 	// create new email entry in DB
-	err := mdb.CreateEmail(s.db, req.EmailAddr)
+	if err := mdb.CreateWithAttributes(s.store, s.store, req.List, req.EmailAddr, pbAttributesToMdb(req.Attributes)); err != nil {
+		return &pb.EmailResponse{}, err
+	}
+
+	res, err := emailResponse(s.store, req.List, req.EmailAddr)
+	if err != nil {
+		return res, err
+	}
+
+	// new addresses must be confirmed with this token to complete double opt-in
+	res.ConfirmToken = s.tokens.ConfirmToken(req.EmailAddr, req.List)
+	res.UnsubscribeToken = s.tokens.UnsubscribeToken(req.EmailAddr, req.List)
+
+	return res, nil
+}
+
+// ConfirmEmail gRPC handler for completing double opt-in with a confirmation token
+func (s *MailServer) ConfirmEmail(ctx context.Context, req *pb.ConfirmEmailRequest) (*pb.EmailResponse, error) {
+	log.Printf("gRPC ConfirmEmail\n")
+
+	claims, err := s.tokens.Verify(req.Token, token.Confirm)
+	if err != nil {
+		return &pb.EmailResponse{}, err
+	}
+
+	entry, err := mdb.Confirm(s.store, claims.List, claims.Email, time.Now())
 	if err != nil {
 		return &pb.EmailResponse{}, err
 	}
 
-	return emailResponse(s.db, req.EmailAddr)
+	return &pb.EmailResponse{EmailEntry: mdbEntryToPbEntry(entry)}, nil
 }
 
 // UpdateEmail gRPC handler for creating an email via gRPC
 func (s *MailServer) UpdateEmail(ctx context.Context, req *pb.UpdateEmailRequest) (*pb.EmailResponse, error) {
 	log.Printf("gRPC UpdateEmail: %v\n", req)
 
+	if req.EmailEntry == nil {
+		return &pb.EmailResponse{}, status.Error(codes.InvalidArgument, "email_entry is required")
+	}
+
+	if req.UpdateMask != nil {
+		patch, err := pbMaskToPatch(req.EmailEntry, req.UpdateMask.Paths)
+		if err != nil {
+			return &pb.EmailResponse{}, err
+		}
+		if err := s.store.PatchEmail(req.List, req.EmailEntry.Email, patch); err != nil {
+			return &pb.EmailResponse{}, err
+		}
+		return emailResponse(s.store, req.List, req.EmailEntry.Email)
+	}
+
 	// convert to DB entry
 	entry := pbEntryToMdbEntry(req.EmailEntry)
 
 	// update email entry in DB
-	err := mdb.UpdateEmail(s.db, entry)
+	var err error
+	if req.List != "" {
+		err = s.store.UpdateSubscription(req.List, entry)
+	} else {
+		err = s.store.UpdateEmail(entry)
+	}
 	if err != nil {
 		return &pb.EmailResponse{}, err
 	}
 
-	return emailResponse(s.db, entry.Email)
+	return emailResponse(s.store, req.List, entry.Email)
 }
 
 // DeleteEmail gRPC handler for removing an email via gRPC
@@ -123,16 +335,135 @@ func (s *MailServer) DeleteEmail(ctx context.Context, req *pb.DeleteEmailRequest
 	log.Printf("gRPC DeleteEmail: %v\n", req)
 
 	// remove email entry in DB
-	err := mdb.DeleteEmail(s.db, req.EmailAddr)
+	var err error
+	if req.List != "" {
+		err = s.store.Unsubscribe(req.List, req.EmailAddr)
+	} else {
+		err = s.store.DeleteEmail(req.EmailAddr)
+	}
 	if err != nil {
 		return &pb.EmailResponse{}, err
 	}
 
-	return emailResponse(s.db, req.EmailAddr)
+	return emailResponse(s.store, req.List, req.EmailAddr)
 }
 
-// Serve serves the gRPC handlers
-func Serve(db *sql.DB, bind string) {
+// CreateList gRPC handler for creating a mailing list
+func (s *MailServer) CreateList(ctx context.Context, req *pb.CreateListRequest) (*pb.ListResponse, error) {
+	log.Printf("gRPC CreateList: %v\n", req)
+
+	if err := s.store.CreateList(req.Name, mdb.DomainPolicy(req.DomainPolicy)); err != nil {
+		return &pb.ListResponse{}, err
+	}
+
+	list, err := s.store.GetList(req.Name)
+	if err != nil || list == nil {
+		return &pb.ListResponse{}, err
+	}
+
+	return &pb.ListResponse{List: mdbListToPbList(list)}, nil
+}
+
+// GetLists gRPC handler for fetching every mailing list
+func (s *MailServer) GetLists(ctx context.Context, req *pb.GetListsRequest) (*pb.GetListsResponse, error) {
+	log.Printf("gRPC GetLists: %v\n", req)
+
+	mdbLists, err := s.store.GetLists()
+	if err != nil {
+		return &pb.GetListsResponse{}, err
+	}
+
+	pbLists := make([]*pb.MailingList, 0, len(mdbLists))
+	for i := 0; i < len(mdbLists); i++ {
+		pbLists = append(pbLists, mdbListToPbList(&mdbLists[i]))
+	}
+
+	return &pb.GetListsResponse{Lists: pbLists}, nil
+}
+
+// CreateField gRPC handler for defining a custom attribute
+func (s *MailServer) CreateField(ctx context.Context, req *pb.CreateFieldRequest) (*pb.FieldResponse, error) {
+	log.Printf("gRPC CreateField: %v\n", req)
+
+	field := mdb.Field{Name: req.Name, Type: mdb.FieldType(req.Type), Description: req.Description}
+	if err := s.store.CreateField(field); err != nil {
+		return &pb.FieldResponse{}, err
+	}
+
+	fields, err := s.store.GetFields()
+	if err != nil {
+		return &pb.FieldResponse{}, err
+	}
+	for i := 0; i < len(fields); i++ {
+		if fields[i].Name == req.Name {
+			return &pb.FieldResponse{Field: mdbFieldToPbField(&fields[i])}, nil
+		}
+	}
+
+	return &pb.FieldResponse{}, nil
+}
+
+// GetFields gRPC handler for fetching every custom attribute
+func (s *MailServer) GetFields(ctx context.Context, req *pb.GetFieldsRequest) (*pb.GetFieldsResponse, error) {
+	log.Printf("gRPC GetFields: %v\n", req)
+
+	mdbFields, err := s.store.GetFields()
+	if err != nil {
+		return &pb.GetFieldsResponse{}, err
+	}
+
+	pbFields := make([]*pb.Field, 0, len(mdbFields))
+	for i := 0; i < len(mdbFields); i++ {
+		pbFields = append(pbFields, mdbFieldToPbField(&mdbFields[i]))
+	}
+
+	return &pb.GetFieldsResponse{Fields: pbFields}, nil
+}
+
+// methodScopes lists the API key scope each RPC needs, confirming only needs the confirmation token
+var methodScopes = map[string]auth.Scope{
+	"/proto.MailingListService/GetEmail": auth.Read,
+	"/proto.MailingListService/GetEmailBatch": auth.Read,
+	"/proto.MailingListService/StreamEmails": auth.Read,
+	"/proto.MailingListService/GetLists": auth.Read,
+	"/proto.MailingListService/GetFields": auth.Read,
+	"/proto.MailingListService/CreateEmail": auth.Write,
+	"/proto.MailingListService/UpdateEmail": auth.Write,
+	"/proto.MailingListService/DeleteEmail": auth.Write,
+	"/proto.MailingListService/ImportEmails": auth.Write,
+	"/proto.MailingListService/CreateList": auth.Admin,
+	"/proto.MailingListService/CreateField": auth.Admin,
+	"/proto.MailingListService/ConfirmEmail": auth.Public,
+}
+
+// serverOptions chains the interceptors every server runs, calls need an API key unless
+// authenticator is nil and are rate limited unless limiter is nil. keeper replays calls
+// repeated with an idempotency key.
+func serverOptions(authenticator *auth.Authenticator, limiter *ratelimit.Limiter, keeper *idempotency.Keeper) []grpc.ServerOption {
+	unary := []grpc.UnaryServerInterceptor{unaryErrorInterceptor, tlsconfig.UnaryServerInterceptor}
+	stream := []grpc.StreamServerInterceptor{streamErrorInterceptor, tlsconfig.StreamServerInterceptor}
+	if authenticator != nil {
+		// after the error interceptors so auth failures get status codes too
+		unary = append(unary, auth.UnaryServerInterceptor(authenticator, methodScopes))
+		stream = append(stream, auth.StreamServerInterceptor(authenticator, methodScopes))
+	}
+	if limiter != nil {
+		// after auth so clients with API keys are limited by key rather than address
+		unary = append(unary, ratelimit.UnaryServerInterceptor(limiter))
+		stream = append(stream, ratelimit.StreamServerInterceptor(limiter))
+	}
+	// streams aren't replayed, ImportEmails already skips emails that exist
+	unary = append(unary, unaryIdempotencyInterceptor(keeper))
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	}
+}
+
+// Serve serves the gRPC handlers, see serverOptions for how calls are checked.
+// Connections use TLS when tlsConfig is set. Calls from the gateway are also
+// served on gateway unless it's nil, in memory so they don't need TLS.
+func Serve(store mdb.SubscriberStore, tokens *token.Signer, authenticator *auth.Authenticator, limiter *ratelimit.Limiter, keeper *idempotency.Keeper, tlsConfig *tls.Config, bind string, gateway *loopback.Listener) {
 	// bind to address
 	listener, err := net.Listen("tcp", bind)
 	if err != nil {
@@ -140,8 +471,23 @@ func Serve(db *sql.DB, bind string) {
 	}
 
 	// create servers
-	gRPCServer := grpc.NewServer()
-	mailServer := MailServer{db: db}
+	opts := serverOptions(authenticator, limiter, keeper)
+	mailServer := MailServer{store: store, tokens: tokens}
+
+	if gateway != nil {
+		gatewayServer := grpc.NewServer(opts...)
+		pb.RegisterMailingListServiceServer(gatewayServer, &mailServer)
+		go func() {
+			if err := gatewayServer.Serve(gateway); err != nil {
+				log.Fatalf("gRPC gateway server error: %v\n", err)
+			}
+		}()
+	}
+
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	gRPCServer := grpc.NewServer(opts...)
 
 	// register servers
 	pb.RegisterMailingListServiceServer(gRPCServer, &mailServer)
@@ -151,4 +497,4 @@ func Serve(db *sql.DB, bind string) {
 	if err := gRPCServer.Serve(listener); err != nil {
 		log.Fatalf("gRPC server error: %v\n", err)
 	}
-}
\ No newline at end of file
+}
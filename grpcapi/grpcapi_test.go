@@ -0,0 +1,603 @@
+package grpcapi
+
+import (
+	"context"
+	"io"
+	"math"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IM-Deane/mailing-list/auth"
+	"github.com/IM-Deane/mailing-list/idempotency"
+	"github.com/IM-Deane/mailing-list/mdb"
+	pb "github.com/IM-Deane/mailing-list/proto"
+	"github.com/IM-Deane/mailing-list/ratelimit"
+	"github.com/IM-Deane/mailing-list/token"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// testClient serves the gRPC API from an empty memory store over an in-memory
+// connection, without API keys or rate limits
+func testClient(t *testing.T) (pb.MailingListServiceClient, *mdb.MemoryStore, *token.Signer) {
+	store := mdb.NewMemoryStore(mdb.Options{})
+	tokens := token.NewSigner([]byte("test secret"), time.Hour)
+	return dialServer(t, store, tokens, nil, nil), store, tokens
+}
+
+// dialServer serves the gRPC API from store over an in-memory connection, calls
+// need an API key unless authenticator is nil and are rate limited unless limiter is nil
+func dialServer(t *testing.T, store *mdb.MemoryStore, tokens *token.Signer, authenticator *auth.Authenticator, limiter *ratelimit.Limiter) pb.MailingListServiceClient {
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer(serverOptions(authenticator, limiter, idempotency.NewKeeper(store, time.Hour))...)
+	pb.RegisterMailingListServiceServer(server, &MailServer{store: store, tokens: tokens})
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufconn",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return pb.NewMailingListServiceClient(conn)
+}
+
+// testContext times out so a hung call fails the test instead of blocking it
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
+// expectStatus fails unless err is a status with code and an ErrorInfo with reason
+func expectStatus(t *testing.T, err error, code codes.Code, reason string) {
+	t.Helper()
+	st, ok := status.FromError(err)
+	if !ok || st.Code() != code {
+		t.Fatalf("got %v, want %v", err, code)
+	}
+	if reason == "" {
+		return
+	}
+	for _, detail := range st.Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			if info.Reason != reason {
+				t.Errorf("got reason %v, want %v", info.Reason, reason)
+			}
+			return
+		}
+	}
+	t.Errorf("got %v without an ErrorInfo, want reason %v", err, reason)
+}
+
+func TestCreateEmail(t *testing.T) {
+	client, store, _ := testClient(t)
+
+	res, err := client.CreateEmail(testContext(t), &pb.CreateEmailRequest{EmailAddr: "someone@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.EmailEntry.GetEmail() != "someone@example.com" || res.EmailEntry.GetId() <= 0 {
+		t.Errorf("got %v", res)
+	}
+	if res.ConfirmToken == "" || res.UnsubscribeToken == "" {
+		t.Errorf("got %v, want confirm and unsubscribe tokens", res)
+	}
+	if entry, err := store.GetEmail("someone@example.com"); err != nil || entry == nil {
+		t.Errorf("got %v, %v, the email wasn't stored", entry, err)
+	}
+}
+
+func TestCreateEmailWithAttributes(t *testing.T) {
+	client, _, _ := testClient(t)
+	ctx := testContext(t)
+
+	if _, err := client.CreateField(ctx, &pb.CreateFieldRequest{Name: "age", Type: "number"}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := client.CreateEmail(ctx, &pb.CreateEmailRequest{
+		EmailAddr: "someone@example.com",
+		Attributes: map[string]*structpb.Value{"age": structpb.NewNumberValue(31)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if age := res.EmailEntry.GetAttributes()["age"]; age.GetNumberValue() != 31 {
+		t.Errorf("got %v, want age 31", res)
+	}
+}
+
+func TestGetEmail(t *testing.T) {
+	client, store, _ := testClient(t)
+	if err := store.CreateEmail("someone@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := client.GetEmail(testContext(t), &pb.GetEmailRequest{EmailAddr: "SOMEONE@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.EmailEntry.GetEmail() != "someone@example.com" {
+		t.Errorf("got %v", res)
+	}
+}
+
+func TestUpdateEmail(t *testing.T) {
+	client, store, _ := testClient(t)
+	if err := store.CreateEmail("someone@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := client.UpdateEmail(testContext(t), &pb.UpdateEmailRequest{
+		EmailEntry: &pb.EmailEntry{Email: "someone@example.com", ConfirmedAt: 1700000000, OptOut: true},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.EmailEntry.GetConfirmedAt() != 1700000000 || !res.EmailEntry.GetOptOut() {
+		t.Errorf("got %v, want it confirmed and opted out", res)
+	}
+}
+
+func TestUpdateEmailWithMask(t *testing.T) {
+	client, store, _ := testClient(t)
+	confirmedAt := time.Unix(1700000000, 0)
+	if err := store.UpdateEmail(mdb.EmailEntry{Email: "someone@example.com", ConfirmedAt: &confirmedAt}); err != nil {
+		t.Fatal(err)
+	}
+
+	// only opt_out is in the mask, so the zero confirmed_at is ignored
+	res, err := client.UpdateEmail(testContext(t), &pb.UpdateEmailRequest{
+		EmailEntry: &pb.EmailEntry{Email: "someone@example.com", OptOut: true},
+		UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"opt_out"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.EmailEntry.GetConfirmedAt() != 1700000000 || !res.EmailEntry.GetOptOut() {
+		t.Errorf("got %v, want it still confirmed and opted out", res)
+	}
+}
+
+func TestEmailBatch(t *testing.T) {
+	client, store, _ := testClient(t)
+	ctx := testContext(t)
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		if err := store.CreateEmail(email); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, err := client.GetEmailBatch(ctx, &pb.GetEmailBatchRequest{Count: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.EmailEntry) != 2 || res.NextCursor == "" {
+		t.Fatalf("got %v, want two entries and a cursor", res)
+	}
+
+	res, err = client.GetEmailBatch(ctx, &pb.GetEmailBatchRequest{Count: 2, Cursor: res.NextCursor})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.EmailEntry) != 1 || res.EmailEntry[0].Email != "c@example.com" || res.NextCursor != "" {
+		t.Errorf("got %v, want only c@example.com", res)
+	}
+
+	// the older page/count pagination has no cursor
+	res, err = client.GetEmailBatch(ctx, &pb.GetEmailBatchRequest{Page: 1, Count: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.EmailEntry) != 3 || res.NextCursor != "" {
+		t.Errorf("got %v, want every email", res)
+	}
+}
+
+// streamEmails returns the addresses StreamEmails sends for req
+func streamEmails(t *testing.T, client pb.MailingListServiceClient, req *pb.StreamEmailsRequest) []string {
+	t.Helper()
+	stream, err := client.StreamEmails(testContext(t), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var emails []string
+	for {
+		entry, err := stream.Recv()
+		if err == io.EOF {
+			return emails
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		emails = append(emails, entry.Email)
+	}
+}
+
+func TestStreamEmails(t *testing.T) {
+	client, store, _ := testClient(t)
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		if err := store.CreateEmail(email); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.DeleteEmail("b@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	confirmedAt := time.Unix(1700000000, 0)
+	if err := store.UpdateEmail(mdb.EmailEntry{Email: "c@example.com", ConfirmedAt: &confirmedAt}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		req *pb.StreamEmailsRequest
+		want string
+	}{
+		// a batch smaller than the table makes the stream page through it
+		{"subscribed", &pb.StreamEmailsRequest{BatchSize: 1}, "a@example.com,c@example.com"},
+		{"default batch size", &pb.StreamEmailsRequest{}, "a@example.com,c@example.com"},
+		// a batch size past the limit is capped instead of sizing a read by it
+		{"batch size over the limit", &pb.StreamEmailsRequest{BatchSize: math.MaxInt32}, "a@example.com,c@example.com"},
+		{"include opted out", &pb.StreamEmailsRequest{BatchSize: 2, IncludeOptedOut: true}, "a@example.com,b@example.com,c@example.com"},
+		{"confirmed only", &pb.StreamEmailsRequest{BatchSize: 1, ConfirmedOnly: true}, "c@example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := strings.Join(streamEmails(t, client, tt.req), ","); got != tt.want {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestImportEmails(t *testing.T) {
+	client, store, _ := testClient(t)
+	if err := store.CreateEmail("existing@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	stream, err := client.ImportEmails(testContext(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, email := range []string{"new@example.com", "existing@example.com", "not an address"} {
+		if err := stream.Send(&pb.ImportEmailsRequest{EmailEntry: &pb.EmailEntry{Email: email}, BatchSize: 2}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses := map[string]pb.ImportStatus{}
+	var summary *pb.ImportSummary
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, result := range res.Results {
+			statuses[result.Email] = result.Status
+		}
+		if res.Summary != nil {
+			summary = res.Summary
+		}
+	}
+
+	want := map[string]pb.ImportStatus{
+		"new@example.com": pb.ImportStatus_IMPORT_STATUS_CREATED,
+		"existing@example.com": pb.ImportStatus_IMPORT_STATUS_ALREADY_EXISTS,
+		"not an address": pb.ImportStatus_IMPORT_STATUS_INVALID,
+	}
+	for email, status := range want {
+		if statuses[email] != status {
+			t.Errorf("%v got %v, want %v", email, statuses[email], status)
+		}
+	}
+	if summary.GetTotal() != 3 || summary.GetCreated() != 1 || summary.GetAlreadyExists() != 1 || summary.GetInvalid() != 1 {
+		t.Errorf("got summary %v", summary)
+	}
+}
+
+func TestOptOut(t *testing.T) {
+	client, store, _ := testClient(t)
+	ctx := testContext(t)
+	if err := store.CreateEmail("someone@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := client.DeleteEmail(ctx, &pb.DeleteEmailRequest{EmailAddr: "someone@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.EmailEntry.GetOptOut() {
+		t.Errorf("got %v, want it opted out", res)
+	}
+
+	batch, err := client.GetEmailBatch(ctx, &pb.GetEmailBatchRequest{Page: 1, Count: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.EmailEntry) != 0 {
+		t.Errorf("got %v, opted out emails should be left out", batch)
+	}
+}
+
+func TestListEmails(t *testing.T) {
+	client, store, _ := testClient(t)
+	ctx := testContext(t)
+
+	list, err := client.CreateList(ctx, &pb.CreateListRequest{Name: "news"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.List.GetName() != "news" || list.List.GetId() <= 0 {
+		t.Errorf("got %v", list)
+	}
+
+	if _, err := client.CreateEmail(ctx, &pb.CreateEmailRequest{EmailAddr: "someone@example.com", List: "news"}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := client.DeleteEmail(ctx, &pb.DeleteEmailRequest{EmailAddr: "someone@example.com", List: "news"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.EmailEntry.GetOptOut() {
+		t.Errorf("got %v, want it unsubscribed from the list", res)
+	}
+	if global, err := store.GetEmail("someone@example.com"); err != nil || global == nil || global.OptOut {
+		t.Errorf("got %+v, %v, the global entry should still be opted in", global, err)
+	}
+
+	lists, err := client.GetLists(ctx, &pb.GetListsRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lists.Lists) != 1 {
+		t.Errorf("got %v, want the news list", lists)
+	}
+}
+
+func TestConfirmEmail(t *testing.T) {
+	client, _, _ := testClient(t)
+	ctx := testContext(t)
+
+	created, err := client.CreateEmail(ctx, &pb.CreateEmailRequest{EmailAddr: "someone@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := client.ConfirmEmail(ctx, &pb.ConfirmEmailRequest{Token: created.ConfirmToken})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.EmailEntry.GetConfirmedAt() <= 0 {
+		t.Errorf("got %v, want it confirmed", res)
+	}
+}
+
+func TestIdempotentCreate(t *testing.T) {
+	client, store, _ := testClient(t)
+	ctx := metadata.AppendToOutgoingContext(testContext(t), idempotency.MetadataKey, "create-1")
+
+	first, err := client.CreateEmail(ctx, &pb.CreateEmailRequest{EmailAddr: "someone@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a retry replays the first response instead of failing with AlreadyExists
+	var header metadata.MD
+	second, err := client.CreateEmail(ctx, &pb.CreateEmailRequest{EmailAddr: "someone@example.com"}, grpc.Header(&header))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.EmailEntry.GetId() != first.EmailEntry.GetId() || second.ConfirmToken != first.ConfirmToken {
+		t.Errorf("got %v, want the first response %v", second, first)
+	}
+	if replayed := header.Get(idempotency.ReplayedMetadataKey); len(replayed) == 0 {
+		t.Error("replayed response isn't marked as replayed")
+	}
+
+	// the same key can't be used for another request
+	_, err = client.CreateEmail(ctx, &pb.CreateEmailRequest{EmailAddr: "other@example.com"})
+	expectStatus(t, err, codes.InvalidArgument, "IDEMPOTENCY_KEY_REUSED")
+
+	entries, err := store.GetEmailBatch(mdb.GetEmailBatchQueryParams{Count: 10})
+	if err != nil || len(entries) != 1 {
+		t.Errorf("got %v, %v, want a single email", entries, err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	client, store, tokens := testClient(t)
+	if err := store.CreateEmail("existing@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CreateList("news", mdb.DomainPolicyDefault); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		call func(ctx context.Context) error
+		code codes.Code
+		reason string
+	}{
+		{"duplicate email", func(ctx context.Context) error {
+			_, err := client.CreateEmail(ctx, &pb.CreateEmailRequest{EmailAddr: "EXISTING@example.com"})
+			return err
+		}, codes.AlreadyExists, "EMAIL_ALREADY_EXISTS"},
+		{"invalid email", func(ctx context.Context) error {
+			_, err := client.CreateEmail(ctx, &pb.CreateEmailRequest{EmailAddr: "not an address"})
+			return err
+		}, codes.InvalidArgument, "INVALID_EMAIL"},
+		{"missing email", func(ctx context.Context) error {
+			_, err := client.GetEmail(ctx, &pb.GetEmailRequest{EmailAddr: "nobody@example.com"})
+			return err
+		}, codes.NotFound, "EMAIL_NOT_FOUND"},
+		{"update without an entry", func(ctx context.Context) error {
+			_, err := client.UpdateEmail(ctx, &pb.UpdateEmailRequest{})
+			return err
+		}, codes.InvalidArgument, ""},
+		{"patch missing email", func(ctx context.Context) error {
+			_, err := client.UpdateEmail(ctx, &pb.UpdateEmailRequest{
+				EmailEntry: &pb.EmailEntry{Email: "nobody@example.com", OptOut: true},
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"opt_out"}},
+			})
+			return err
+		}, codes.NotFound, "EMAIL_NOT_FOUND"},
+		{"unknown update mask path", func(ctx context.Context) error {
+			_, err := client.UpdateEmail(ctx, &pb.UpdateEmailRequest{
+				EmailEntry: &pb.EmailEntry{Email: "existing@example.com"},
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"domain_flag"}},
+			})
+			return err
+		}, codes.InvalidArgument, ""},
+		{"batch without count", func(ctx context.Context) error {
+			_, err := client.GetEmailBatch(ctx, &pb.GetEmailBatchRequest{})
+			return err
+		}, codes.InvalidArgument, "INVALID_ARGUMENT"},
+		{"invalid cursor", func(ctx context.Context) error {
+			_, err := client.GetEmailBatch(ctx, &pb.GetEmailBatchRequest{Count: 10, Cursor: "nope"})
+			return err
+		}, codes.InvalidArgument, "INVALID_ARGUMENT"},
+		{"unknown attribute", func(ctx context.Context) error {
+			_, err := client.CreateEmail(ctx, &pb.CreateEmailRequest{
+				EmailAddr: "new@example.com",
+				Attributes: map[string]*structpb.Value{"age": structpb.NewNumberValue(1)},
+			})
+			return err
+		}, codes.InvalidArgument, "INVALID_ATTRIBUTE"},
+		{"missing list", func(ctx context.Context) error {
+			_, err := client.CreateEmail(ctx, &pb.CreateEmailRequest{EmailAddr: "new@example.com", List: "missing"})
+			return err
+		}, codes.NotFound, "LIST_NOT_FOUND"},
+		{"invalid filter", func(ctx context.Context) error {
+			_, err := client.GetEmailBatch(ctx, &pb.GetEmailBatchRequest{Count: 10, Filters: []string{"nope"}})
+			return err
+		}, codes.InvalidArgument, "INVALID_ARGUMENT"},
+		{"duplicate list", func(ctx context.Context) error {
+			_, err := client.CreateList(ctx, &pb.CreateListRequest{Name: "news"})
+			return err
+		}, codes.AlreadyExists, "LIST_ALREADY_EXISTS"},
+		{"invalid confirm token", func(ctx context.Context) error {
+			_, err := client.ConfirmEmail(ctx, &pb.ConfirmEmailRequest{Token: "nope"})
+			return err
+		}, codes.InvalidArgument, "INVALID_TOKEN"},
+		{"unsubscribe token used to confirm", func(ctx context.Context) error {
+			_, err := client.ConfirmEmail(ctx, &pb.ConfirmEmailRequest{Token: tokens.UnsubscribeToken("existing@example.com", "")})
+			return err
+		}, codes.InvalidArgument, "INVALID_TOKEN"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectStatus(t, tt.call(testContext(t)), tt.code, tt.reason)
+		})
+	}
+}
+
+func TestAuth(t *testing.T) {
+	store := mdb.NewMemoryStore(mdb.Options{})
+	tokens := token.NewSigner([]byte("test secret"), time.Hour)
+	if err := store.CreateEmail("someone@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	keys := make(map[auth.Scope]string)
+	for _, scope := range []auth.Scope{auth.Read, auth.Write} {
+		key, record, err := auth.GenerateKey(string(scope), []auth.Scope{scope})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.CreateAPIKey(record); err != nil {
+			t.Fatal(err)
+		}
+		keys[scope] = key
+	}
+	client := dialServer(t, store, tokens, auth.NewAuthenticator(store), nil)
+
+	// withKey sends key with the call
+	withKey := func(key string) context.Context {
+		return metadata.AppendToOutgoingContext(testContext(t), "authorization", "Bearer "+key)
+	}
+
+	_, err := client.GetEmail(testContext(t), &pb.GetEmailRequest{EmailAddr: "someone@example.com"})
+	expectStatus(t, err, codes.Unauthenticated, "API_KEY_REQUIRED")
+	_, err = client.GetEmail(withKey("mlk_abc_def"), &pb.GetEmailRequest{EmailAddr: "someone@example.com"})
+	expectStatus(t, err, codes.Unauthenticated, "API_KEY_INVALID")
+	_, err = client.CreateEmail(withKey(keys[auth.Read]), &pb.CreateEmailRequest{EmailAddr: "new@example.com"})
+	expectStatus(t, err, codes.PermissionDenied, "INSUFFICIENT_SCOPE")
+	_, err = client.CreateList(withKey(keys[auth.Write]), &pb.CreateListRequest{Name: "news"})
+	expectStatus(t, err, codes.PermissionDenied, "INSUFFICIENT_SCOPE")
+
+	if _, err := client.GetEmail(withKey(keys[auth.Read]), &pb.GetEmailRequest{EmailAddr: "someone@example.com"}); err != nil {
+		t.Errorf("got %v reading with a read key", err)
+	}
+	if _, err := client.CreateEmail(withKey(keys[auth.Write]), &pb.CreateEmailRequest{EmailAddr: "new@example.com"}); err != nil {
+		t.Errorf("got %v creating with a write key", err)
+	}
+
+	// streams are checked too
+	stream, err := client.StreamEmails(testContext(t), &pb.StreamEmailsRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	expectStatus(t, err, codes.Unauthenticated, "API_KEY_REQUIRED")
+
+	// confirming only needs the token
+	_, err = client.ConfirmEmail(testContext(t), &pb.ConfirmEmailRequest{Token: tokens.ConfirmToken("someone@example.com", "")})
+	if err != nil {
+		t.Errorf("got %v confirming without a key", err)
+	}
+
+	// without an authenticator, as with --no-auth, every call goes through
+	open := dialServer(t, store, tokens, nil, nil)
+	if _, err := open.CreateList(testContext(t), &pb.CreateListRequest{Name: "news"}); err != nil {
+		t.Errorf("got %v without auth, want the call through", err)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	store := mdb.NewMemoryStore(mdb.Options{})
+	tokens := token.NewSigner([]byte("test secret"), time.Hour)
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), ratelimit.Rules{"CreateEmail": {Rate: 1.0 / 60, Burst: 1}})
+	client := dialServer(t, store, tokens, nil, limiter)
+
+	if _, err := client.CreateEmail(testContext(t), &pb.CreateEmailRequest{EmailAddr: "first@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := client.CreateEmail(testContext(t), &pb.CreateEmailRequest{EmailAddr: "second@example.com"})
+	expectStatus(t, err, codes.ResourceExhausted, "RATE_LIMITED")
+
+	// the status says when to retry
+	var delay time.Duration
+	for _, detail := range status.Convert(err).Details() {
+		if retry, ok := detail.(*errdetails.RetryInfo); ok {
+			delay = retry.RetryDelay.AsDuration()
+		}
+	}
+	if delay <= 0 || delay > time.Minute {
+		t.Errorf("got retry delay %v, want up to a minute", delay)
+	}
+
+	if _, err := client.GetEmail(testContext(t), &pb.GetEmailRequest{EmailAddr: "first@example.com"}); err != nil {
+		t.Errorf("got %v for an RPC without a rule", err)
+	}
+}
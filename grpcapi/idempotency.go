@@ -0,0 +1,118 @@
+package grpcapi
+
+import (
+	"context"
+	"log"
+
+	"github.com/IM-Deane/mailing-list/idempotency"
+	"github.com/IM-Deane/mailing-list/loopback"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+)
+
+// idempotentMethods are the RPCs that replay their first response when called again with the same idempotency key
+var idempotentMethods = map[string]bool{
+	"/proto.MailingListService/CreateEmail": true,
+	"/proto.MailingListService/UpdateEmail": true,
+	"/proto.MailingListService/CreateList": true,
+	"/proto.MailingListService/CreateField": true,
+}
+
+// retryable reports whether a call that failed with code is worth retrying, so its result shouldn't be recorded
+func retryable(code codes.Code) bool {
+	switch code {
+	case codes.Canceled, codes.Unknown, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted, codes.Internal, codes.Unavailable, codes.DataLoss:
+		return true
+	}
+	return false
+}
+
+// encodeResult stores a call's response, or its status when it failed, so it can be replayed
+func encodeResult(res interface{}, err error) ([]byte, error) {
+	var msg proto.Message = status.Convert(err).Proto()
+	if err == nil {
+		msg = res.(proto.Message)
+	}
+	result, err := anypb.New(msg)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(result)
+}
+
+// decodeResult returns the response or status error stored by encodeResult
+func decodeResult(stored []byte) (interface{}, error) {
+	var result anypb.Any
+	if err := proto.Unmarshal(stored, &result); err != nil {
+		return nil, err
+	}
+	msg, err := result.UnmarshalNew()
+	if err != nil {
+		return nil, err
+	}
+	if st, ok := msg.(*spb.Status); ok {
+		return nil, status.ErrorProto(st)
+	}
+	return msg, nil
+}
+
+// unaryIdempotencyInterceptor replays the first result of idempotentMethods called again with
+// the same idempotency-key metadata. It runs inside unaryErrorInterceptor, so it converts
+// errors with statusErr itself to record the status clients get.
+func unaryIdempotencyInterceptor(keeper *idempotency.Keeper) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		keys := md.Get(idempotency.MetadataKey)
+		if !idempotentMethods[info.FullMethod] || len(keys) == 0 {
+			return handler(ctx, req)
+		}
+		if !idempotency.ValidKey(keys[0]) {
+			return nil, idempotency.ErrInvalidKey
+		}
+
+		body, err := proto.MarshalOptions{Deterministic: true}.Marshal(req.(proto.Message))
+		if err != nil {
+			return nil, err
+		}
+		scopedKey := idempotency.Scope(ctx, loopback.PeerAddr(ctx), info.FullMethod, keys[0])
+		stored, err := keeper.Begin(scopedKey, idempotency.Fingerprint([]byte(info.FullMethod), body))
+		if err != nil {
+			return nil, err
+		}
+		if stored != nil {
+			if err := grpc.SetHeader(ctx, metadata.Pairs(idempotency.ReplayedMetadataKey, "true")); err != nil {
+				log.Println(err)
+			}
+			return decodeResult(stored)
+		}
+
+		completed := false
+		defer func() {
+			// also when the handler panics, so the key isn't stuck in progress
+			if !completed {
+				keeper.Release(scopedKey)
+			}
+		}()
+
+		res, err := handler(ctx, req)
+		err = statusErr(err)
+		if retryable(status.Code(err)) {
+			return res, err
+		}
+
+		result, encodeErr := encodeResult(res, err)
+		if encodeErr != nil {
+			log.Println(encodeErr)
+			return res, err
+		}
+		keeper.Complete(scopedKey, result)
+		completed = true
+
+		return res, err
+	}
+}
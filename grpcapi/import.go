@@ -0,0 +1,126 @@
+package grpcapi
+
+import (
+	"io"
+	"log"
+
+	"github.com/IM-Deane/mailing-list/mdb"
+	pb "github.com/IM-Deane/mailing-list/proto"
+)
+
+const (
+	// defaultImportBatchSize is how many emails ImportEmails inserts per transaction when not set by the client
+	defaultImportBatchSize = 1000
+	// maxImportBatchSize caps client batch sizes so a single transaction can't grow unbounded
+	maxImportBatchSize = 10000
+)
+
+// importStatuses maps mdb import outcomes to protocol buffer statuses
+var importStatuses = map[mdb.ImportStatus]pb.ImportStatus{
+	mdb.ImportCreated: pb.ImportStatus_IMPORT_STATUS_CREATED,
+	mdb.ImportAlreadyExists: pb.ImportStatus_IMPORT_STATUS_ALREADY_EXISTS,
+	mdb.ImportInvalid: pb.ImportStatus_IMPORT_STATUS_INVALID,
+	mdb.ImportSuppressed: pb.ImportStatus_IMPORT_STATUS_SUPPRESSED,
+}
+
+// addToSummary counts a batch of results in summary
+func addToSummary(summary *pb.ImportSummary, results []mdb.ImportResult) {
+	for _, result := range results {
+		summary.Total++
+		switch result.Status {
+		case mdb.ImportCreated:
+			summary.Created++
+		case mdb.ImportAlreadyExists:
+			summary.AlreadyExists++
+		case mdb.ImportInvalid:
+			summary.Invalid++
+		case mdb.ImportSuppressed:
+			summary.Suppressed++
+		}
+	}
+}
+
+// ImportEmails gRPC handler that bulk imports a stream of emails.
+// Emails are inserted in transactions of batch_size, after each one commits the
+// per-email results are sent back, and the final response carries a summary.
+func (s *MailServer) ImportEmails(stream pb.MailingListService_ImportEmailsServer) error {
+	log.Printf("gRPC ImportEmails\n")
+
+	batchSize := 0
+	list := ""
+	batch := make([]mdb.EmailEntry, 0)
+	summary := &pb.ImportSummary{}
+
+	// flush imports the current batch and sends its results
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		results, err := s.store.ImportEmails(list, batch)
+		if err != nil {
+			return err
+		}
+		addToSummary(summary, results)
+
+		res := &pb.ImportEmailsResponse{Results: make([]*pb.ImportEmailResult, 0, len(results))}
+		for _, result := range results {
+			res.Results = append(res.Results, &pb.ImportEmailResult{
+				Email: result.Email,
+				Status: importStatuses[result.Status],
+				Reason: result.Reason,
+			})
+		}
+
+		batch = batch[:0]
+		return stream.Send(res)
+	}
+
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		// batch size is set by the first request
+		if batchSize == 0 {
+			batchSize = int(req.BatchSize)
+			if batchSize <= 0 {
+				batchSize = defaultImportBatchSize
+			}
+			if batchSize > maxImportBatchSize {
+				batchSize = maxImportBatchSize
+			}
+			list = req.List
+		}
+
+		// a batch is imported into a single list
+		if req.List != list {
+			if err := flush(); err != nil {
+				return err
+			}
+			list = req.List
+		}
+
+		if req.EmailEntry == nil {
+			req.EmailEntry = &pb.EmailEntry{}
+		}
+		batch = append(batch, pbEntryToMdbEntry(req.EmailEntry))
+
+		if len(batch) >= batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return err
+	}
+
+	log.Printf("gRPC ImportEmails: %v\n", summary)
+	return stream.Send(&pb.ImportEmailsResponse{Summary: summary})
+}
@@ -0,0 +1,124 @@
+package idempotency
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+)
+
+// recordedResponse is an HTTP response as it's stored for replays
+type recordedResponse struct {
+	Status int `json:"status"`
+	Header http.Header `json:"header"`
+	Body []byte `json:"body"`
+}
+
+// recorder passes a response through while keeping a copy of it
+type recorder struct {
+	http.ResponseWriter
+	response recordedResponse
+	body bytes.Buffer
+	wroteHeader bool
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.wroteHeader = true
+		r.response.Status = status
+		r.response.Header = r.ResponseWriter.Header().Clone()
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	if !r.wroteHeader {
+		r.WriteHeader(http.StatusOK)
+	}
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+// Middleware replays the first response to POST, PUT and PATCH requests sent again with
+// the same Idempotency-Key header. Keys are scoped to the operation picked by operation,
+// e.g. the route. Server errors aren't recorded so those requests can be retried.
+// Requests that can't use their key are written by onError.
+func Middleware(k *Keeper, operation func(r *http.Request) string, onError func(w http.ResponseWriter, err error), next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key, ok := r.Header[http.CanonicalHeaderKey(Header)]
+		switch {
+		case !ok:
+			next.ServeHTTP(w, r)
+			return
+		case r.Method != http.MethodPost && r.Method != http.MethodPut && r.Method != http.MethodPatch:
+			// other methods are already idempotent, or don't change anything
+			next.ServeHTTP(w, r)
+			return
+		case !ValidKey(key[0]):
+			onError(w, ErrInvalidKey)
+			return
+		}
+
+		// the body is part of the fingerprint, then handed on to the handler
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			onError(w, err)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		scopedKey := Scope(r.Context(), r.RemoteAddr, operation(r), key[0])
+		stored, err := k.Begin(scopedKey, Fingerprint([]byte(r.Method), []byte(r.URL.RequestURI()), body))
+		if err != nil {
+			onError(w, err)
+			return
+		}
+		if stored != nil {
+			replay(w, stored)
+			return
+		}
+
+		rec := &recorder{ResponseWriter: w}
+		completed := false
+		defer func() {
+			// also when the handler panics, so the key isn't stuck in progress
+			if !completed {
+				k.Release(scopedKey)
+			}
+		}()
+
+		next.ServeHTTP(rec, r)
+
+		if !rec.wroteHeader || rec.response.Status >= http.StatusInternalServerError {
+			return
+		}
+		rec.response.Body = rec.body.Bytes()
+		recorded, err := json.Marshal(rec.response)
+		if err != nil {
+			log.Println(err)
+			return
+		}
+		k.Complete(scopedKey, recorded)
+		completed = true
+	})
+}
+
+// replay writes a recorded response
+func replay(w http.ResponseWriter, stored []byte) {
+	var response recordedResponse
+	if err := json.Unmarshal(stored, &response); err != nil {
+		log.Println(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	for name, values := range response.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(ReplayedHeader, "true")
+	w.WriteHeader(response.Status)
+	if _, err := w.Write(response.Body); err != nil {
+		log.Println(err)
+	}
+}
@@ -0,0 +1,148 @@
+// Package idempotency replays the first response to requests retried with the same idempotency key
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net"
+	"time"
+
+	"github.com/IM-Deane/mailing-list/auth"
+	"github.com/IM-Deane/mailing-list/mdb"
+)
+
+const (
+	// Header is the HTTP header clients send idempotency keys in
+	Header = "Idempotency-Key"
+	// MetadataKey is the gRPC metadata clients send idempotency keys in
+	MetadataKey = "idempotency-key"
+	// ReplayedHeader is set to true on replayed HTTP responses
+	ReplayedHeader = "Idempotent-Replayed"
+	// ReplayedMetadataKey is set to true in the header metadata of replayed gRPC responses
+	ReplayedMetadataKey = "idempotent-replayed"
+)
+
+// maxKeyLength is the longest idempotency key accepted, enough for a UUID or a hash
+const maxKeyLength = 255
+
+// Errors returned for requests that can't use their idempotency key, check for them with errors.Is
+var (
+	// ErrInvalidKey means the key is empty or too long
+	ErrInvalidKey = errors.New("idempotency key must be 1 to 255 characters")
+	// ErrInProgress means the first request with the key hasn't finished yet
+	ErrInProgress = errors.New("a request with this idempotency key is still being handled")
+	// ErrKeyReused means the key was first used for a different request
+	ErrKeyReused = errors.New("idempotency key was already used for a different request")
+)
+
+// Store keeps the first response for each key, mdb.IdempotencyStore satisfies it
+type Store interface {
+	ReserveIdempotencyKey(record mdb.IdempotencyRecord, expiredBefore time.Time) (*mdb.IdempotencyRecord, error)
+	CompleteIdempotencyKey(key string, response []byte) error
+	ReleaseIdempotencyKey(key string) error
+	DeleteExpiredIdempotencyKeys(expiredBefore time.Time) (int64, error)
+}
+
+// Keeper records responses in a Store and replays them for window after the first request
+type Keeper struct {
+	store Store
+	window time.Duration
+}
+
+// NewKeeper creates a Keeper that remembers keys for window
+func NewKeeper(store Store, window time.Duration) *Keeper {
+	return &Keeper{store: store, window: window}
+}
+
+// Scope prefixes a client's key with its API key and the operation it was sent to,
+// so clients can't replay each other's responses or reuse a key across operations.
+// Without an API key, e.g. when auth is off, the client is told apart by addr instead.
+func Scope(ctx context.Context, addr string, operation string, key string) string {
+	client := ""
+	if apiKey := auth.KeyFromContext(ctx); apiKey != nil {
+		client = "key:" + apiKey.Prefix
+	} else {
+		// retries come from a new port, so only the host is kept
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			addr = host
+		}
+		client = "ip:" + addr
+	}
+	return client + "|" + operation + "|" + key
+}
+
+// Fingerprint identifies a request from its parts, e.g. its method, path and body
+func Fingerprint(parts ...[]byte) string {
+	hash := sha256.New()
+	for _, part := range parts {
+		hash.Write(part)
+		// separate parts so moving bytes between them changes the fingerprint
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// ValidKey reports whether a key sent by a client can be used
+func ValidKey(key string) bool {
+	return key != "" && len(key) <= maxKeyLength
+}
+
+// Begin reserves scopedKey for the request with fingerprint. It returns the recorded
+// response if the key was already used for the same request, otherwise nil and the
+// caller handles the request, then calls Complete or Release.
+func (k *Keeper) Begin(scopedKey string, fingerprint string) ([]byte, error) {
+	now := time.Now()
+	existing, err := k.store.ReserveIdempotencyKey(mdb.IdempotencyRecord{
+		Key: scopedKey,
+		Fingerprint: fingerprint,
+		CreatedAt: now,
+	}, now.Add(-k.window))
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, nil
+	}
+
+	if existing.Fingerprint != fingerprint {
+		return nil, ErrKeyReused
+	}
+	if existing.Response == nil {
+		return nil, ErrInProgress
+	}
+	return existing.Response, nil
+}
+
+// Complete records the response to replay for scopedKey
+func (k *Keeper) Complete(scopedKey string, response []byte) {
+	if err := k.store.CompleteIdempotencyKey(scopedKey, response); err != nil {
+		log.Println(err)
+	}
+}
+
+// Release forgets scopedKey so the request can be retried, e.g. after an internal error
+func (k *Keeper) Release(scopedKey string) {
+	if err := k.store.ReleaseIdempotencyKey(scopedKey); err != nil {
+		log.Println(err)
+	}
+}
+
+// Expire deletes keys older than the window every interval, until stop is closed
+func (k *Keeper) Expire(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if _, err := k.store.DeleteExpiredIdempotencyKeys(time.Now().Add(-k.window)); err != nil {
+				log.Println(err)
+			}
+		}
+	}
+}
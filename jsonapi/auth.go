@@ -0,0 +1,47 @@
+package jsonapi
+
+import (
+	"net/http"
+
+	"github.com/IM-Deane/mailing-list/auth"
+	"github.com/IM-Deane/mailing-list/idempotency"
+	"github.com/IM-Deane/mailing-list/ratelimit"
+)
+
+// routeScope picks the API key scope a request needs.
+// Confirmation and unsubscribe links carry their own token and the document is public,
+// managing lists and fields needs admin, reading needs read and everything else needs write.
+func routeScope(r *http.Request) auth.Scope {
+	switch {
+	case r.URL.Path == "/v1/confirm", r.URL.Path == "/email/confirm", r.URL.Path == "/unsubscribe", r.URL.Path == "/openapi.json":
+		return auth.Public
+	case r.URL.Path == "/list/create", (r.URL.Path == "/v1/lists" || r.URL.Path == "/v1/fields") && r.Method == http.MethodPost:
+		return auth.Admin
+	case r.Method == http.MethodGet, r.Method == http.MethodHead:
+		return auth.Read
+	}
+	return auth.Write
+}
+
+// requireKey wraps next so requests need an API key with the scope routeScope picks
+func requireKey(authenticator *auth.Authenticator, next http.Handler) http.Handler {
+	return auth.Middleware(authenticator, routeScope, returnErr, next)
+}
+
+// rateLimit limits requests to the route at path with the rule named after
+// the request's method and path, e.g. "POST /v1/emails"
+func rateLimit(limiter *ratelimit.Limiter, path string, next http.Handler) http.Handler {
+	rule := func(r *http.Request) string {
+		return r.Method + " " + path
+	}
+	return ratelimit.Middleware(limiter, rule, returnErr, next)
+}
+
+// replayable replays responses to requests sent again with the same Idempotency-Key,
+// keys are scoped to the request's method and the route at path
+func replayable(keeper *idempotency.Keeper, path string, next http.Handler) http.Handler {
+	operation := func(r *http.Request) string {
+		return r.Method + " " + path
+	}
+	return idempotency.Middleware(keeper, operation, returnErr, next)
+}
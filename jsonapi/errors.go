@@ -0,0 +1,142 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/IM-Deane/mailing-list/auth"
+	"github.com/IM-Deane/mailing-list/idempotency"
+	"github.com/IM-Deane/mailing-list/mdb"
+	"github.com/IM-Deane/mailing-list/ratelimit"
+	"github.com/IM-Deane/mailing-list/token"
+)
+
+// problemTypePrefix is prepended to error codes to make problem type URIs
+const problemTypePrefix = "urn:mailinglist:error:"
+
+// problem is an RFC 7807 problem details body.
+// Code is a stable identifier clients can match on, the same one used in Type.
+type problem struct {
+	Type string `json:"type"`
+	Title string `json:"title"`
+	Status int `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Code string `json:"code"`
+	// InvalidParams lists the fields that failed validation
+	InvalidParams []invalidParam `json:"invalid_params,omitempty"`
+}
+
+// invalidParam is a single field that failed validation
+type invalidParam struct {
+	Name string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// requestError is a malformed request found by the handlers themselves
+type requestError string
+
+func (e requestError) Error() string {
+	return string(e)
+}
+
+// errorCode picks the HTTP status code for err along with its stable error code
+func errorCode(err error) (int, string) {
+	var mdbErr *mdb.Error
+	var validationErr *mdb.ValidationError
+	var attributeErr *mdb.AttributeError
+	var reqErr requestError
+	switch {
+	case errors.As(err, &validationErr):
+		return http.StatusUnprocessableEntity, "invalid_email"
+	case errors.As(err, &attributeErr):
+		return http.StatusUnprocessableEntity, "invalid_attribute"
+	case errors.As(err, &mdbErr) && errors.Is(err, mdb.ErrNotFound):
+		return http.StatusNotFound, mdbErr.Resource + "_not_found"
+	case errors.As(err, &mdbErr) && errors.Is(err, mdb.ErrAlreadyExists):
+		return http.StatusConflict, mdbErr.Resource + "_already_exists"
+	case errors.Is(err, mdb.ErrNotFound):
+		return http.StatusNotFound, "not_found"
+	case errors.Is(err, mdb.ErrAlreadyExists):
+		return http.StatusConflict, "already_exists"
+	case errors.Is(err, mdb.ErrInvalid), errors.As(err, &reqErr):
+		return http.StatusBadRequest, "invalid_request"
+	case errors.Is(err, token.ErrInvalid):
+		return http.StatusBadRequest, "invalid_token"
+	case errors.Is(err, token.ErrExpired):
+		return http.StatusBadRequest, "token_expired"
+	case errors.Is(err, auth.ErrMissingKey):
+		return http.StatusUnauthorized, "api_key_required"
+	case errors.Is(err, auth.ErrInvalidKey):
+		return http.StatusUnauthorized, "api_key_invalid"
+	case errors.Is(err, auth.ErrInsufficientScope):
+		return http.StatusForbidden, "insufficient_scope"
+	case errors.Is(err, ratelimit.ErrLimited):
+		return http.StatusTooManyRequests, "rate_limited"
+	case errors.Is(err, idempotency.ErrInvalidKey):
+		return http.StatusBadRequest, "invalid_idempotency_key"
+	case errors.Is(err, idempotency.ErrInProgress):
+		return http.StatusConflict, "idempotency_key_in_progress"
+	case errors.Is(err, idempotency.ErrKeyReused):
+		return http.StatusUnprocessableEntity, "idempotency_key_reused"
+	}
+	return http.StatusInternalServerError, "internal"
+}
+
+// newProblem builds the problem details for err.
+// Unexpected errors are logged and their message left out, it can contain database details.
+func newProblem(err error) problem {
+	status, code := errorCode(err)
+	p := problem{
+		Type: problemTypePrefix + code,
+		Title: http.StatusText(status),
+		Status: status,
+		Detail: err.Error(),
+		Code: code,
+	}
+	if status == http.StatusInternalServerError {
+		log.Println(err)
+		p.Detail = "internal error"
+	}
+
+	var validationErr *mdb.ValidationError
+	if errors.As(err, &validationErr) {
+		p.InvalidParams = []invalidParam{{Name: "Email", Reason: validationErr.Reason}}
+	}
+	var attributeErr *mdb.AttributeError
+	if errors.As(err, &attributeErr) {
+		p.InvalidParams = []invalidParam{{Name: "Attributes." + attributeErr.Field, Reason: attributeErr.Reason}}
+	}
+
+	return p
+}
+
+// writeProblem writes p as an application/problem+json response
+func writeProblem(w http.ResponseWriter, p problem) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(p.Status)
+	if err := json.NewEncoder(w).Encode(p); err != nil {
+		log.Println(err)
+	}
+}
+
+// allowMethods checks the request method, responding 405 with an Allow header if it isn't one of methods
+func allowMethods(w http.ResponseWriter, r *http.Request, methods ...string) bool {
+	for _, method := range methods {
+		if r.Method == method {
+			return true
+		}
+	}
+
+	w.Header().Set("Allow", strings.Join(methods, ", "))
+	writeProblem(w, problem{
+		Type: problemTypePrefix + "method_not_allowed",
+		Title: http.StatusText(http.StatusMethodNotAllowed),
+		Status: http.StatusMethodNotAllowed,
+		Detail: r.Method + " is not allowed, use " + strings.Join(methods, " or "),
+		Code: "method_not_allowed",
+	})
+	return false
+}
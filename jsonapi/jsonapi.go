@@ -2,14 +2,20 @@ package jsonapi
 
 import (
 	"bytes"
-	"database/sql"
+	"crypto/tls"
 	"encoding/json"
-	"errors"
 	"io"
 	"log"
 	"net/http"
+	"time"
 
+	"github.com/IM-Deane/mailing-list/auth"
+	"github.com/IM-Deane/mailing-list/idempotency"
 	"github.com/IM-Deane/mailing-list/mdb"
+	"github.com/IM-Deane/mailing-list/openapi"
+	"github.com/IM-Deane/mailing-list/ratelimit"
+	"github.com/IM-Deane/mailing-list/tlsconfig"
+	"github.com/IM-Deane/mailing-list/token"
 )
 
 // setJSONHeader adds a json header to response writer
@@ -17,183 +23,419 @@ func setJSONHeader(w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 }
 
-// fromJSON is a generic function that converts provided JSON to GO struct
-func fromJSON[T any](body io.Reader, target T) {
+// fromJSON is a generic function that converts provided JSON to GO struct.
+// An empty body leaves target unchanged.
+func fromJSON[T any](body io.Reader, target T) error {
 	buf := new(bytes.Buffer)
-	buf.ReadFrom(body)
+	if _, err := buf.ReadFrom(body); err != nil {
+		return err
+	}
+	if buf.Len() == 0 {
+		return nil
+	}
 	// converted bytes to target strcuct
-	json.Unmarshal(buf.Bytes(), &target)
+	if err := json.Unmarshal(buf.Bytes(), &target); err != nil {
+		return requestError("invalid JSON body: " + err.Error())
+	}
+	return nil
 }
 
 // returnJSON is a generic function that converts data encapsulated by 'withData'
 // and returns a JSON response
 func returnJSON[T any](w http.ResponseWriter, withData func() (T, error)) {
-	setJSONHeader(w)
-
-	data, serverErr := withData()
-
-	if serverErr != nil {
-		// server encountered
-		w.WriteHeader(500)
-		serverErrJSON, err := json.Marshal(&serverErr)
-		if err != nil {
-			// something went wrong
-			log.Println(err)
-			return
-		}
-		// return our custom server
-		w.Write(serverErrJSON)
+	data, err := withData()
+	if err != nil {
+		returnErr(w, err)
 		return
 	}
 
+	writeJSON(w, http.StatusOK, data)
+}
+
+// writeJSON writes data as a JSON response with the given status code
+func writeJSON(w http.ResponseWriter, status int, data interface{}) {
 	dataJSON, err := json.Marshal(&data)
 	if err != nil {
-		log.Println(err)
-		w.WriteHeader(500)
+		returnErr(w, err)
 		return
 	}
 
 	// return JSON response
+	setJSONHeader(w)
+	w.WriteHeader(status)
 	w.Write(dataJSON)
 }
 
-// returnErr returns an error response
-func returnErr(w http.ResponseWriter, err error, httpCode int) {
-	// can pass any type
-	returnJSON(w, func() (interface{}, error) {
-		errorMessage := struct {
-			Err string
-		}{
-			Err: err.Error(), // convert to string
+// returnErr returns err as a problem+json response, the status code depends on the kind of error
+func returnErr(w http.ResponseWriter, err error) {
+	writeProblem(w, newProblem(err))
+}
+
+// emailRequest is the JSON body accepted by the email handlers.
+// Setting List scopes the request to that mailing list's subscription.
+type emailRequest struct {
+	List string
+	mdb.EmailEntry
+}
+
+// emailBatchRequest is the JSON body accepted by GetEmailBatch
+type emailBatchRequest struct {
+	List string
+	mdb.GetEmailBatchQueryParams
+}
+
+// createEmailResponse is a new email entry along with the token needed to confirm it
+// and the token for the unsubscribe link in emails sent to it
+type createEmailResponse struct {
+	*mdb.EmailEntry
+	ConfirmToken string
+	UnsubscribeToken string
+}
+
+// emailBatchResponse is the JSON response for cursor paginated batches.
+// Page paginated batches return a plain array for compatibility.
+type emailBatchResponse struct {
+	Entries []mdb.EmailEntry
+	// empty once the last page has been returned
+	NextCursor string
+}
+
+// getEmail fetches an email from list, or from the global email list if list is empty
+func getEmail(store mdb.EmailStore, list string, email string) (*mdb.EmailEntry, error) {
+	var entry *mdb.EmailEntry
+	var err error
+	if list != "" {
+		entry, err = store.GetSubscription(list, email)
+	} else {
+		entry, err = store.GetEmail(email)
+	}
+	if err == nil && entry == nil {
+		return nil, mdb.EmailNotFound(email)
+	}
+	return entry, err
+}
+
+// createEmail adds email with its attributes to list, or to the global email list if list is empty,
+// and returns it with its confirm and unsubscribe tokens
+func createEmail(store mdb.EmailStore, fields mdb.FieldStore, tokens *token.Signer, list string, email string, attrs mdb.Attributes) (*createEmailResponse, error) {
+	if err := mdb.CreateWithAttributes(store, fields, list, email, attrs); err != nil {
+		return nil, err
+	}
+
+	entry, err := getEmail(store, list, email)
+	if err != nil {
+		return nil, err
+	}
+
+	return &createEmailResponse{
+		EmailEntry: entry,
+		ConfirmToken: tokens.ConfirmToken(email, list),
+		UnsubscribeToken: tokens.UnsubscribeToken(email, list),
+	}, nil
+}
+
+// updateEmail stores entry on list, or on the global email list if list is empty, and returns it
+func updateEmail(store mdb.EmailStore, list string, entry mdb.EmailEntry) (*mdb.EmailEntry, error) {
+	var err error
+	if list != "" {
+		err = store.UpdateSubscription(list, entry)
+	} else {
+		err = store.UpdateEmail(entry)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return getEmail(store, list, entry.Email)
+}
+
+// deleteEmail opts email out of list, or out of the global email list if list is empty, and returns it
+func deleteEmail(store mdb.EmailStore, list string, email string) (*mdb.EmailEntry, error) {
+	var err error
+	if list != "" {
+		err = store.Unsubscribe(list, email)
+	} else {
+		err = store.DeleteEmail(email)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return getEmail(store, list, email)
+}
+
+// emailBatch fetches a page of emails from list, or from the global email list if list is empty
+func emailBatch(store mdb.EmailStore, list string, params mdb.GetEmailBatchQueryParams) ([]mdb.EmailEntry, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	if list != "" {
+		return store.GetSubscriptionBatch(list, params)
+	}
+	return store.GetEmailBatch(params)
+}
+
+// CreateEmail adds email to DB and and returns a JSON response object
+// including a token to confirm the address with ConfirmEmail
+func CreateEmail(store mdb.EmailStore, fields mdb.FieldStore, tokens *token.Signer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, "POST") {
+			return
+		}
+		req := emailRequest{}
+		if err := fromJSON(r.Body, &req); err != nil {
+			returnErr(w, err)
+			return
 		}
-		w.WriteHeader(httpCode) // 4XX, 5XX, etc.
-		return errorMessage, nil
+
+		// get email as JSON
+		returnJSON(w, func() (interface{}, error) {
+			log.Printf("JSON CreateEmail: %v\n", req.Email)
+			return createEmail(store, fields, tokens, req.List, req.Email, req.Attributes)
+		})
 	})
 }
 
-// CreateEmail adds email to DB and and returns a JSON response object
-func CreateEmail(db *sql.DB) http.Handler {
+// ConfirmEmail completes double opt-in using the token from the "token" query parameter
+// and returns the confirmed email as a JSON response object
+func ConfirmEmail(store mdb.EmailStore, tokens *token.Signer) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != "POST" {
+		// GET so the link can be clicked straight from an email
+		if !allowMethods(w, r, "GET", "POST") {
 			return
 		}
-		entry := mdb.EmailEntry{}
-		fromJSON(r.Body, &entry)
 
-		if err := mdb.CreateEmail(db, entry.Email); err != nil {
-			returnErr(w, err, 400)
+		claims, err := tokens.Verify(r.URL.Query().Get("token"), token.Confirm)
+		if err != nil {
+			returnErr(w, err)
+			return
+		}
+
+		entry, err := mdb.Confirm(store, claims.List, claims.Email, time.Now())
+		if err != nil {
+			returnErr(w, err)
 			return
 		}
 
-		// get email as JSON
 		returnJSON(w, func() (interface{}, error) {
-			log.Printf("JSON CreateEmail: %v\n", entry.Email)
-			return mdb.GetEmail(db, entry.Email)
+			log.Printf("JSON ConfirmEmail: %v\n", claims.Email)
+			return entry, nil
 		})
 	})
 }
 
 // GetEmail fetches an email from the DB as a JSON response
-func GetEmail(db *sql.DB) http.Handler {
+func GetEmail(store mdb.EmailStore) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != "GET" {
+		if !allowMethods(w, r, "GET") {
+			return
+		}
+		req := emailRequest{}
+		if err := fromJSON(r.Body, &req); err != nil {
+			returnErr(w, err)
 			return
 		}
-		entry := mdb.EmailEntry{}
-		fromJSON(r.Body, &entry)
 
 		// get email as JSON
 		returnJSON(w, func() (interface{}, error) {
-			log.Printf("JSON GetEmail: %v\n", entry.Email)
-			return mdb.GetEmail(db, entry.Email)
+			log.Printf("JSON GetEmail: %v\n", req.Email)
+			return getEmail(store, req.List, req.Email)
 		})
 	})
 }
 
 // UpdateEmail updates email in DB and and returns a JSON response object
-func UpdateEmail(db *sql.DB) http.Handler {
+func UpdateEmail(store mdb.EmailStore) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != "PUT" {
+		if !allowMethods(w, r, "PUT") {
 			return
 		}
-		entry := mdb.EmailEntry{}
-		fromJSON(r.Body, &entry)
-
-		if err := mdb.UpdateEmail(db, entry); err != nil {
-			returnErr(w, err, 400)
+		req := emailRequest{}
+		if err := fromJSON(r.Body, &req); err != nil {
+			returnErr(w, err)
 			return
 		}
 
 		// get email as JSON
 		returnJSON(w, func() (interface{}, error) {
-			log.Printf("JSON UpdateEmail: %v\n", entry.Email)
-			return mdb.GetEmail(db, entry.Email)
+			log.Printf("JSON UpdateEmail: %v\n", req.Email)
+			return updateEmail(store, req.List, req.EmailEntry)
 		})
 	})
 }
 
 // DeleteEmail removes email from mailing list and returns a JSON response object
-func DeleteEmail(db *sql.DB) http.Handler {
+func DeleteEmail(store mdb.EmailStore) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != "POST" {
+		if !allowMethods(w, r, "POST") {
 			return
 		}
-		entry := mdb.EmailEntry{}
-		fromJSON(r.Body, &entry)
-
-		if err := mdb.DeleteEmail(db, entry.Email); err != nil {
-			returnErr(w, err, 400)
+		req := emailRequest{}
+		if err := fromJSON(r.Body, &req); err != nil {
+			returnErr(w, err)
 			return
 		}
 
 		// get email as JSON
 		returnJSON(w, func() (interface{}, error) {
-			log.Printf("JSON DeleteEmail: %v\n", entry.Email)
-			return mdb.GetEmail(db, entry.Email)
+			log.Printf("JSON DeleteEmail: %v\n", req.Email)
+			return deleteEmail(store, req.List, req.Email)
 		})
 	})
 }
 
 
 // GetEmailBatch fetches all emails in list as a JSON response
-func GetEmailBatch(db *sql.DB) http.Handler {
+func GetEmailBatch(store mdb.EmailStore) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != "GET" {
+		if !allowMethods(w, r, "GET") {
 			return
 		}
 
-		queryOptions := mdb.GetEmailBatchQueryParams{}
-		fromJSON(r.Body, &queryOptions)
-
-		if queryOptions.Count <= 0 || queryOptions.Page <= 0 {
-			returnErr(w, errors.New("page and Count fields are required and must be > 0"), 400)	
+		req := emailBatchRequest{}
+		if err := fromJSON(r.Body, &req); err != nil {
+			returnErr(w, err)
 			return
 		}
 
 		// return email list
 		returnJSON(w, func() (interface{}, error) {
-			log.Printf("JSON GetEmailBatch: %v\n", queryOptions)
-			return mdb.GetEmailBatch(db, queryOptions)
+			log.Printf("JSON GetEmailBatch: %v\n", req)
+			entries, err := emailBatch(store, req.List, req.GetEmailBatchQueryParams)
+			if err != nil || !req.UsesCursor() {
+				return entries, err
+			}
+			return emailBatchResponse{
+				Entries: entries,
+				NextCursor: mdb.NextCursor(entries, req.GetEmailBatchQueryParams),
+			}, nil
 		})
 	})
 }
 
+// CreateList adds a mailing list to DB and returns it as a JSON response object
+func CreateList(store mdb.ListStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, "POST") {
+			return
+		}
+		list := mdb.List{}
+		if err := fromJSON(r.Body, &list); err != nil {
+			returnErr(w, err)
+			return
+		}
+
+		if list.Name == "" {
+			returnErr(w, requestError("name field is required"))
+			return
+		}
 
-// Serve serves JSON handler functions
-func Serve(db *sql.DB, bind string) {
-	
-	// handlers
-	http.Handle("/email/create", CreateEmail(db))
-	http.Handle("/email/get", GetEmail(db))
-	http.Handle("/email/get_batch", GetEmailBatch(db))
-	http.Handle("/email/update", UpdateEmail(db))
-	http.Handle("/email/delete", DeleteEmail(db))
+		if err := store.CreateList(list.Name, list.DomainPolicy); err != nil {
+			returnErr(w, err)
+			return
+		}
+
+		// get list as JSON
+		returnJSON(w, func() (interface{}, error) {
+			log.Printf("JSON CreateList: %v\n", list.Name)
+			return store.GetList(list.Name)
+		})
+	})
+}
+
+// GetLists fetches every mailing list as a JSON response
+func GetLists(store mdb.ListStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, "GET") {
+			return
+		}
+
+		// return all lists
+		returnJSON(w, func() (interface{}, error) {
+			log.Printf("JSON GetLists\n")
+			return store.GetLists()
+		})
+	})
+}
+
+
+// notFound responds to unknown routes with a problem+json 404
+func notFound() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeProblem(w, problem{
+			Type: problemTypePrefix + "route_not_found",
+			Title: http.StatusText(http.StatusNotFound),
+			Status: http.StatusNotFound,
+			Detail: "no route for " + r.URL.Path,
+			Code: "route_not_found",
+		})
+	})
+}
+
+// routes lists every handler served by Serve with the OpenAPI path documenting it
+func routes(store mdb.SubscriberStore, tokens *token.Signer, spec *openapi.Document) []route {
+	return []route{
+		{emailsPath, "/v1/emails", Emails(store, store, tokens)},
+		{emailsPath + "/", "/v1/emails/{address}", EmailItem(store)},
+		{"/v1/confirm", "/v1/confirm", ConfirmEmail(store, tokens)},
+		{"/v1/lists", "/v1/lists", Lists(store)},
+		{"/v1/fields", "/v1/fields", Fields(store)},
+		{"/unsubscribe", "/unsubscribe", Unsubscribe(store, tokens)},
+		{"/openapi.json", "/openapi.json", OpenAPIDocument(spec)},
+
+		// deprecated RPC style routes, kept until clients move to /v1
+		{"/email/create", "/email/create", deprecated(CreateEmail(store, store, tokens), emailsPath)},
+		{"/email/confirm", "/email/confirm", deprecated(ConfirmEmail(store, tokens), "/v1/confirm")},
+		{"/email/get", "/email/get", deprecated(GetEmail(store), emailsPath)},
+		{"/email/get_batch", "/email/get_batch", deprecated(GetEmailBatch(store), emailsPath)},
+		{"/email/update", "/email/update", deprecated(UpdateEmail(store), emailsPath)},
+		{"/email/delete", "/email/delete", deprecated(DeleteEmail(store), emailsPath)},
+		{"/list/create", "/list/create", deprecated(CreateList(store), "/v1/lists")},
+		{"/list/get_all", "/list/get_all", deprecated(GetLists(store), "/v1/lists")},
+
+		{"/", "", notFound()},
+	}
+}
+
+// newMux routes every handler, requests need an API key unless authenticator is nil
+// and are rate limited unless limiter is nil. keeper replays requests repeated with an
+// Idempotency-Key.
+func newMux(store mdb.SubscriberStore, tokens *token.Signer, authenticator *auth.Authenticator, limiter *ratelimit.Limiter, keeper *idempotency.Keeper) *http.ServeMux {
+	spec := newSpec()
+	handlers := routes(store, tokens, spec)
+	// drift is caught by the tests, a running server only warns about it
+	if err := checkSpec(spec, handlers); err != nil {
+		log.Println(err)
+	}
+
+	mux := http.NewServeMux()
+	for _, r := range handlers {
+		handler := r.handler
+		if r.path != "" {
+			handler = replayable(keeper, r.path, handler)
+		}
+		if limiter != nil && r.path != "" {
+			handler = rateLimit(limiter, r.path, handler)
+		}
+		if authenticator != nil {
+			handler = requireKey(authenticator, handler)
+		}
+		mux.Handle(r.pattern, handler)
+	}
+
+	return mux
+}
+
+// Serve serves JSON handler functions, see newMux for how requests are checked.
+// Connections use TLS when tlsConfig is set.
+func Serve(store mdb.SubscriberStore, tokens *token.Signer, authenticator *auth.Authenticator, limiter *ratelimit.Limiter, keeper *idempotency.Keeper, tlsConfig *tls.Config, bind string) {
+	mux := newMux(store, tokens, authenticator, limiter, keeper)
 
 	log.Printf("JSON API server listening on: %v", bind)
 	
 	// init server
-	err := http.ListenAndServe(bind, nil)
+	err := tlsconfig.ListenAndServe(bind, mux, tlsConfig)
 	if err != nil {
 		log.Fatalf("JSON server error: %v", err)
 	}
-}
\ No newline at end of file
+}
@@ -0,0 +1,446 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IM-Deane/mailing-list/auth"
+	"github.com/IM-Deane/mailing-list/idempotency"
+	"github.com/IM-Deane/mailing-list/mdb"
+	"github.com/IM-Deane/mailing-list/ratelimit"
+	"github.com/IM-Deane/mailing-list/token"
+)
+
+// testServer serves the JSON API from an empty memory store, without API keys or rate limits
+func testServer(t *testing.T) (*httptest.Server, *mdb.MemoryStore, *token.Signer) {
+	store := mdb.NewMemoryStore(mdb.Options{})
+	tokens := token.NewSigner([]byte("test secret"), time.Hour)
+	srv := httptest.NewServer(newMux(store, tokens, nil, nil, idempotency.NewKeeper(store, time.Hour)))
+	t.Cleanup(srv.Close)
+	return srv, store, tokens
+}
+
+// request sends body to path and returns the response with its body read.
+// headers are name, value pairs.
+func request(t *testing.T, srv *httptest.Server, method string, path string, body string, headers ...string) (*http.Response, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(method, srv.URL+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	res, err := srv.Client().Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res, data
+}
+
+// decode unmarshals a response body, failing the test if the status isn't want
+func decode(t *testing.T, res *http.Response, data []byte, want int, target interface{}) {
+	t.Helper()
+	if res.StatusCode != want {
+		t.Fatalf("got status %v, want %v: %s", res.StatusCode, want, data)
+	}
+	if target == nil {
+		return
+	}
+	if err := json.Unmarshal(data, target); err != nil {
+		t.Fatalf("%v: %s", err, data)
+	}
+}
+
+func TestCreateEmail(t *testing.T) {
+	srv, store, _ := testServer(t)
+
+	res, data := request(t, srv, "POST", "/v1/emails", `{"Email": "Someone@Example.com"}`)
+	created := createEmailResponse{}
+	decode(t, res, data, http.StatusCreated, &created)
+
+	if created.EmailEntry == nil || created.Email != "Someone@example.com" || created.ID <= 0 {
+		t.Errorf("got %s", data)
+	}
+	if created.ConfirmToken == "" || created.UnsubscribeToken == "" {
+		t.Errorf("got %s, want confirm and unsubscribe tokens", data)
+	}
+	if location := res.Header.Get("Location"); location != "/v1/emails/Someone@example.com" {
+		t.Errorf("got Location %q", location)
+	}
+	if entry, err := store.GetEmail("someone@example.com"); err != nil || entry == nil {
+		t.Errorf("got %v, %v, the email wasn't stored", entry, err)
+	}
+}
+
+func TestCreateEmailWithAttributes(t *testing.T) {
+	srv, _, _ := testServer(t)
+
+	res, data := request(t, srv, "POST", "/v1/fields", `{"Name": "age", "Type": "number"}`)
+	decode(t, res, data, http.StatusCreated, nil)
+
+	res, data = request(t, srv, "POST", "/v1/emails", `{"Email": "someone@example.com", "Attributes": {"age": 31}}`)
+	created := createEmailResponse{}
+	decode(t, res, data, http.StatusCreated, &created)
+	if created.Attributes["age"] != float64(31) {
+		t.Errorf("got %s, want age 31", data)
+	}
+}
+
+func TestGetEmail(t *testing.T) {
+	srv, store, _ := testServer(t)
+	if err := store.CreateEmail("someone@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	res, data := request(t, srv, "GET", "/v1/emails/"+url.PathEscape("SOMEONE@example.com"), "")
+	entry := mdb.EmailEntry{}
+	decode(t, res, data, http.StatusOK, &entry)
+	if entry.Email != "someone@example.com" {
+		t.Errorf("got %s", data)
+	}
+}
+
+func TestUpdateEmail(t *testing.T) {
+	srv, store, _ := testServer(t)
+	if err := store.CreateEmail("someone@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	res, data := request(t, srv, "PATCH", "/v1/emails/someone@example.com", `{"ConfirmedAt": "2023-11-14T22:13:20Z"}`)
+	entry := mdb.EmailEntry{}
+	decode(t, res, data, http.StatusOK, &entry)
+	if entry.ConfirmedAt == nil || entry.ConfirmedAt.Unix() != 1700000000 || entry.OptOut {
+		t.Errorf("got %s, want it confirmed and still opted in", data)
+	}
+
+	// fields missing from the patch are kept
+	res, data = request(t, srv, "PATCH", "/v1/emails/someone@example.com", `{"OptOut": true}`)
+	decode(t, res, data, http.StatusOK, &entry)
+	if entry.ConfirmedAt == nil || entry.ConfirmedAt.Unix() != 1700000000 || !entry.OptOut {
+		t.Errorf("got %s, want it confirmed and opted out", data)
+	}
+}
+
+func TestEmailBatch(t *testing.T) {
+	srv, store, _ := testServer(t)
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		if err := store.CreateEmail(email); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, data := request(t, srv, "GET", "/v1/emails?count=2", "")
+	page := emailBatchResponse{}
+	decode(t, res, data, http.StatusOK, &page)
+	if len(page.Entries) != 2 || page.NextCursor == "" {
+		t.Fatalf("got %s, want two entries and a cursor", data)
+	}
+	if link := res.Header.Get("Link"); !strings.Contains(link, "cursor="+page.NextCursor) || !strings.Contains(link, `rel="next"`) {
+		t.Errorf("got Link %q", link)
+	}
+
+	res, data = request(t, srv, "GET", "/v1/emails?count=2&cursor="+page.NextCursor, "")
+	decode(t, res, data, http.StatusOK, &page)
+	if len(page.Entries) != 1 || page.Entries[0].Email != "c@example.com" || page.NextCursor != "" {
+		t.Errorf("got %s, want only c@example.com", data)
+	}
+	if link := res.Header.Get("Link"); link != "" {
+		t.Errorf("got Link %q on the last page", link)
+	}
+
+	// the deprecated route takes its parameters from the body, setting Page
+	// keeps the older pagination which returns a plain array
+	res, data = request(t, srv, "GET", "/email/get_batch", `{"Page": 2, "Count": 2}`)
+	var entries []mdb.EmailEntry
+	decode(t, res, data, http.StatusOK, &entries)
+	if len(entries) != 1 || entries[0].Email != "c@example.com" {
+		t.Errorf("got %s, want only c@example.com", data)
+	}
+	if res.Header.Get("Deprecation") == "" {
+		t.Error("deprecated route has no Deprecation header")
+	}
+	if link := res.Header.Get("Link"); link != `</v1/emails>; rel="successor-version"` {
+		t.Errorf("got Link %q, want the /v1 route", link)
+	}
+}
+
+func TestOptOut(t *testing.T) {
+	srv, store, tokens := testServer(t)
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		if err := store.CreateEmail(email); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	res, data := request(t, srv, "DELETE", "/v1/emails/a@example.com", "")
+	entry := mdb.EmailEntry{}
+	decode(t, res, data, http.StatusOK, &entry)
+	if !entry.OptOut {
+		t.Errorf("got %s, want it opted out", data)
+	}
+
+	// the unsubscribe link only asks for confirmation on GET, so prefetching it is harmless
+	unsubscribe := "/unsubscribe?token=" + tokens.UnsubscribeToken("b@example.com", "")
+	res, data = request(t, srv, "GET", unsubscribe, "")
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got status %v: %s", res.StatusCode, data)
+	}
+	if entry, err := store.GetEmail("b@example.com"); err != nil || entry.OptOut {
+		t.Errorf("got %+v, %v, GET shouldn't unsubscribe", entry, err)
+	}
+	res, data = request(t, srv, "POST", unsubscribe, "")
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("got status %v: %s", res.StatusCode, data)
+	}
+	if entry, err := store.GetEmail("b@example.com"); err != nil || !entry.OptOut {
+		t.Errorf("got %+v, %v after unsubscribing, want it opted out", entry, err)
+	}
+
+	res, data = request(t, srv, "POST", "/unsubscribe?token=nope", "")
+	if res.StatusCode != http.StatusBadRequest {
+		t.Errorf("got status %v for an invalid token: %s", res.StatusCode, data)
+	}
+
+	// opted out emails are left out of batches
+	res, data = request(t, srv, "GET", "/v1/emails?count=10", "")
+	page := emailBatchResponse{}
+	decode(t, res, data, http.StatusOK, &page)
+	if len(page.Entries) != 0 {
+		t.Errorf("got %s, want no entries", data)
+	}
+	res, data = request(t, srv, "GET", "/v1/emails?count=10&include_opted_out=true", "")
+	decode(t, res, data, http.StatusOK, &page)
+	if len(page.Entries) != 2 {
+		t.Errorf("got %s, want both entries", data)
+	}
+}
+
+func TestConfirmEmail(t *testing.T) {
+	srv, store, tokens := testServer(t)
+	if err := store.CreateEmail("someone@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	res, data := request(t, srv, "GET", "/v1/confirm?token="+tokens.ConfirmToken("someone@example.com", ""), "")
+	entry := mdb.EmailEntry{}
+	decode(t, res, data, http.StatusOK, &entry)
+	if entry.ConfirmedAt == nil || entry.ConfirmedAt.Unix() <= 0 {
+		t.Errorf("got %s, want it confirmed", data)
+	}
+}
+
+func TestListEmails(t *testing.T) {
+	srv, store, _ := testServer(t)
+
+	res, data := request(t, srv, "POST", "/v1/lists", `{"Name": "news"}`)
+	list := mdb.List{}
+	decode(t, res, data, http.StatusCreated, &list)
+	if list.Name != "news" || list.ID <= 0 {
+		t.Errorf("got %s", data)
+	}
+
+	res, data = request(t, srv, "POST", "/v1/emails?list=news", `{"Email": "someone@example.com"}`)
+	decode(t, res, data, http.StatusCreated, nil)
+	if location := res.Header.Get("Location"); location != "/v1/emails/someone@example.com?list=news" {
+		t.Errorf("got Location %q", location)
+	}
+
+	res, data = request(t, srv, "DELETE", "/v1/emails/someone@example.com?list=news", "")
+	entry := mdb.EmailEntry{}
+	decode(t, res, data, http.StatusOK, &entry)
+	if !entry.OptOut {
+		t.Errorf("got %s, want it unsubscribed from the list", data)
+	}
+	if global, err := store.GetEmail("someone@example.com"); err != nil || global == nil || global.OptOut {
+		t.Errorf("got %+v, %v, the global entry should still be opted in", global, err)
+	}
+
+	res, data = request(t, srv, "GET", "/v1/lists", "")
+	var lists []mdb.List
+	decode(t, res, data, http.StatusOK, &lists)
+	if len(lists) != 1 {
+		t.Errorf("got %s, want the news list", data)
+	}
+}
+
+func TestIdempotentCreate(t *testing.T) {
+	srv, store, _ := testServer(t)
+
+	res, first := request(t, srv, "POST", "/v1/emails", `{"Email": "someone@example.com"}`, idempotency.Header, "create-1")
+	decode(t, res, first, http.StatusCreated, nil)
+	// a retry replays the first response instead of failing with a conflict
+	res, second := request(t, srv, "POST", "/v1/emails", `{"Email": "someone@example.com"}`, idempotency.Header, "create-1")
+	decode(t, res, second, http.StatusCreated, nil)
+	if string(first) != string(second) {
+		t.Errorf("got %s, want the first response %s", second, first)
+	}
+
+	// the same key can't be used for another request
+	res, data := request(t, srv, "POST", "/v1/emails", `{"Email": "other@example.com"}`, idempotency.Header, "create-1")
+	decode(t, res, data, http.StatusUnprocessableEntity, nil)
+
+	entries, err := store.GetEmailBatch(mdb.GetEmailBatchQueryParams{Count: 10})
+	if err != nil || len(entries) != 1 {
+		t.Errorf("got %v, %v, want a single email", entries, err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	srv, store, tokens := testServer(t)
+	if err := store.CreateEmail("existing@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CreateList("news", mdb.DomainPolicyReject); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		method string
+		path string
+		body string
+		status int
+		code string
+	}{
+		{"duplicate email", "POST", "/v1/emails", `{"Email": "EXISTING@example.com"}`, http.StatusConflict, "email_already_exists"},
+		{"invalid email", "POST", "/v1/emails", `{"Email": "not an address"}`, http.StatusUnprocessableEntity, "invalid_email"},
+		{"unknown attribute", "POST", "/v1/emails", `{"Email": "new@example.com", "Attributes": {"age": 1}}`, http.StatusUnprocessableEntity, "invalid_attribute"},
+		{"malformed body", "POST", "/v1/emails", `{"Email": `, http.StatusBadRequest, "invalid_request"},
+		{"missing list", "POST", "/v1/emails?list=missing", `{"Email": "new@example.com"}`, http.StatusNotFound, "list_not_found"},
+		{"missing email", "GET", "/v1/emails/nobody@example.com", "", http.StatusNotFound, "email_not_found"},
+		{"update missing email", "PATCH", "/v1/emails/nobody@example.com", `{"OptOut": true}`, http.StatusNotFound, "email_not_found"},
+		{"batch without count", "GET", "/v1/emails?count=0", "", http.StatusBadRequest, "invalid_request"},
+		{"count too large", "GET", "/v1/emails?count=2147483647", "", http.StatusBadRequest, "invalid_request"},
+		{"deprecated count too large", "GET", "/email/get_batch", `{"Count": 2147483647}`, http.StatusBadRequest, "invalid_request"},
+		{"invalid count", "GET", "/v1/emails?count=ten", "", http.StatusBadRequest, "invalid_request"},
+		{"invalid cursor", "GET", "/v1/emails?count=10&cursor=nope", "", http.StatusBadRequest, "invalid_request"},
+		{"cursor with page", "GET", "/v1/emails?count=10&page=1&cursor=aWQ6MQ", "", http.StatusBadRequest, "invalid_request"},
+		{"invalid filter", "GET", "/v1/emails?count=10&filter=nope", "", http.StatusBadRequest, "invalid_request"},
+		{"duplicate list", "POST", "/v1/lists", `{"Name": "news"}`, http.StatusConflict, "list_already_exists"},
+		{"list without name", "POST", "/v1/lists", `{}`, http.StatusBadRequest, "invalid_request"},
+		{"invalid confirm token", "GET", "/v1/confirm?token=nope", "", http.StatusBadRequest, "invalid_token"},
+		{"unsubscribe token used to confirm", "GET", "/v1/confirm?token=" + tokens.UnsubscribeToken("existing@example.com", ""), "", http.StatusBadRequest, "invalid_token"},
+		{"deprecated route", "POST", "/email/create", `{"Email": "existing@example.com"}`, http.StatusConflict, "email_already_exists"},
+		{"method not allowed", "PUT", "/v1/emails", "", http.StatusMethodNotAllowed, "method_not_allowed"},
+		{"unknown route", "GET", "/v2/emails", "", http.StatusNotFound, "route_not_found"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, data := request(t, srv, tt.method, tt.path, tt.body)
+			p := problem{}
+			decode(t, res, data, tt.status, &p)
+			if p.Code != tt.code || p.Status != tt.status {
+				t.Errorf("got %s, want code %v", data, tt.code)
+			}
+			if contentType := res.Header.Get("Content-Type"); contentType != "application/problem+json" {
+				t.Errorf("got Content-Type %q", contentType)
+			}
+		})
+	}
+}
+
+func TestAuth(t *testing.T) {
+	store := mdb.NewMemoryStore(mdb.Options{})
+	tokens := token.NewSigner([]byte("test secret"), time.Hour)
+	if err := store.CreateEmail("someone@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	keys := make(map[auth.Scope]string)
+	for _, scope := range []auth.Scope{auth.Read, auth.Write} {
+		key, record, err := auth.GenerateKey(string(scope), []auth.Scope{scope})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := store.CreateAPIKey(record); err != nil {
+			t.Fatal(err)
+		}
+		keys[scope] = key
+	}
+	srv := httptest.NewServer(newMux(store, tokens, auth.NewAuthenticator(store), nil, idempotency.NewKeeper(store, time.Hour)))
+	t.Cleanup(srv.Close)
+
+	tests := []struct {
+		name string
+		method string
+		path string
+		body string
+		key string
+		status int
+		// code is the problem's code, empty when the request should go through
+		code string
+	}{
+		{"missing key", "GET", "/v1/emails/someone@example.com", "", "", http.StatusUnauthorized, "api_key_required"},
+		{"invalid key", "GET", "/v1/emails/someone@example.com", "", "mlk_abc_def", http.StatusUnauthorized, "api_key_invalid"},
+		{"read can't write", "POST", "/v1/emails", `{"Email": "new@example.com"}`, keys[auth.Read], http.StatusForbidden, "insufficient_scope"},
+		{"write can't manage lists", "POST", "/v1/lists", `{"Name": "news"}`, keys[auth.Write], http.StatusForbidden, "insufficient_scope"},
+		{"read", "GET", "/v1/emails/someone@example.com", "", keys[auth.Read], http.StatusOK, ""},
+		{"write", "POST", "/v1/emails", `{"Email": "new@example.com"}`, keys[auth.Write], http.StatusCreated, ""},
+		{"public document", "GET", "/openapi.json", "", "", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var headers []string
+			if tt.key != "" {
+				headers = []string{"Authorization", "Bearer " + tt.key}
+			}
+			res, data := request(t, srv, tt.method, tt.path, tt.body, headers...)
+			if tt.code == "" {
+				decode(t, res, data, tt.status, nil)
+				return
+			}
+			p := problem{}
+			decode(t, res, data, tt.status, &p)
+			if p.Code != tt.code {
+				t.Errorf("got %s, want code %v", data, tt.code)
+			}
+			if challenged := res.Header.Get("WWW-Authenticate") != ""; challenged != (tt.status == http.StatusUnauthorized) {
+				t.Errorf("got WWW-Authenticate %q for status %v", res.Header.Get("WWW-Authenticate"), tt.status)
+			}
+		})
+	}
+
+	// testServer has no authenticator, as with --no-auth, so every request goes through
+	open, _, _ := testServer(t)
+	res, data := request(t, open, "POST", "/v1/lists", `{"Name": "news"}`)
+	decode(t, res, data, http.StatusCreated, nil)
+}
+
+func TestRateLimit(t *testing.T) {
+	store := mdb.NewMemoryStore(mdb.Options{})
+	tokens := token.NewSigner([]byte("test secret"), time.Hour)
+	limiter := ratelimit.NewLimiter(ratelimit.NewMemoryStore(), ratelimit.Rules{"POST /v1/emails": {Rate: 1.0 / 60, Burst: 1}})
+	srv := httptest.NewServer(newMux(store, tokens, nil, limiter, idempotency.NewKeeper(store, time.Hour)))
+	t.Cleanup(srv.Close)
+
+	res, data := request(t, srv, "POST", "/v1/emails", `{"Email": "first@example.com"}`)
+	decode(t, res, data, http.StatusCreated, nil)
+
+	res, data = request(t, srv, "POST", "/v1/emails", `{"Email": "second@example.com"}`)
+	p := problem{}
+	decode(t, res, data, http.StatusTooManyRequests, &p)
+	if p.Code != "rate_limited" {
+		t.Errorf("got %s, want code rate_limited", data)
+	}
+	if retryAfter, err := strconv.Atoi(res.Header.Get("Retry-After")); err != nil || retryAfter <= 0 || retryAfter > 60 {
+		t.Errorf("got Retry-After %q, want up to 60 seconds", res.Header.Get("Retry-After"))
+	}
+
+	// other routes aren't limited
+	res, data = request(t, srv, "GET", "/v1/emails/first@example.com", "")
+	decode(t, res, data, http.StatusOK, nil)
+}
@@ -0,0 +1,223 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/IM-Deane/mailing-list/openapi"
+)
+
+// schemaBuilder generates schemas from Go types, so the document always matches
+// what the handlers actually encode and decode
+type schemaBuilder struct {
+	// names of the types published under components/schemas
+	names map[reflect.Type]string
+	schemas map[string]*openapi.Schema
+}
+
+func newSchemaBuilder() *schemaBuilder {
+	return &schemaBuilder{names: map[reflect.Type]string{}, schemas: map[string]*openapi.Schema{}}
+}
+
+// component publishes the type of v under name and returns a reference to it
+func (b *schemaBuilder) component(name string, v interface{}) *openapi.Schema {
+	t := reflect.TypeOf(v)
+	b.names[t] = name
+	b.schemas[name] = b.object(t)
+	return openapi.Ref(name)
+}
+
+// schemaOf describes t, using a reference when t is a published component
+func (b *schemaBuilder) schemaOf(t reflect.Type) *openapi.Schema {
+	if name, ok := b.names[t]; ok {
+		return openapi.Ref(name)
+	}
+
+	switch {
+	case t == reflect.TypeOf(time.Time{}):
+		return &openapi.Schema{Type: "string", Format: "date-time"}
+	case t.Kind() == reflect.Ptr:
+		return b.schemaOf(t.Elem())
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &openapi.Schema{Type: "string"}
+	case reflect.Bool:
+		return &openapi.Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Uint32:
+		return &openapi.Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint64:
+		return &openapi.Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &openapi.Schema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &openapi.Schema{Type: "array", Items: b.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &openapi.Schema{Type: "object", AdditionalProperties: b.schemaOf(t.Elem())}
+	case reflect.Struct:
+		return b.object(t)
+	}
+
+	// interface{} and anything else can hold any value
+	return &openapi.Schema{}
+}
+
+// object describes a struct the way encoding/json encodes it, embedded structs are flattened
+func (b *schemaBuilder) object(t reflect.Type) *openapi.Schema {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	s := &openapi.Schema{Type: "object", Properties: map[string]*openapi.Schema{}}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, omitEmpty, ok := jsonName(f)
+		if !ok {
+			continue
+		}
+
+		if f.Anonymous && name == "" {
+			embedded := b.object(f.Type)
+			for prop, propSchema := range embedded.Properties {
+				s.Properties[prop] = propSchema
+			}
+			s.Required = append(s.Required, embedded.Required...)
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		propSchema := b.schemaOf(f.Type)
+		if f.Type.Kind() == reflect.Ptr && propSchema.Ref == "" {
+			propSchema.Nullable = true
+		}
+		s.Properties[name] = propSchema
+		if !omitEmpty && f.Type.Kind() != reflect.Ptr {
+			s.Required = append(s.Required, name)
+		}
+	}
+	sort.Strings(s.Required)
+
+	return s
+}
+
+// jsonName reads a field's json tag, ok is false for fields encoding/json skips.
+// name is empty when the tag doesn't rename the field.
+func jsonName(f reflect.StructField) (string, bool, bool) {
+	if !f.IsExported() && !f.Anonymous {
+		return "", false, false
+	}
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", false, false
+	}
+	parts := strings.Split(tag, ",")
+	omitEmpty := false
+	for _, opt := range parts[1:] {
+		if opt == "omitempty" {
+			omitEmpty = true
+		}
+	}
+	return parts[0], omitEmpty, true
+}
+
+// route is a handler along with the path that documents it in the OpenAPI document
+type route struct {
+	pattern string
+	// path is the OpenAPI path, empty for routes that aren't part of the API
+	path string
+	handler http.Handler
+}
+
+// allowedMethods asks a handler which methods it accepts, by sending it a method
+// nothing accepts and reading the Allow header of the 405 response
+func allowedMethods(h http.Handler, path string) []string {
+	// any address will do for path parameters, the handlers reject the method before using it
+	target := strings.NewReplacer("{address}", "someone@example.com").Replace(path)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("TRACE", target, nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		return nil
+	}
+
+	var methods []string
+	for _, method := range strings.Split(rec.Header().Get("Allow"), ",") {
+		methods = append(methods, strings.ToLower(strings.TrimSpace(method)))
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// checkSpec compares the document against the routes that are served.
+// Every route must be documented with exactly the methods its handler accepts,
+// and every documented path must be served.
+func checkSpec(spec *openapi.Document, routes []route) error {
+	var drift []string
+	served := map[string]bool{}
+	for _, r := range routes {
+		if r.path == "" {
+			continue
+		}
+		served[r.path] = true
+
+		item, ok := spec.Paths[r.path]
+		if !ok {
+			drift = append(drift, fmt.Sprintf("%v is served but not documented", r.path))
+			continue
+		}
+
+		var documented []string
+		for method := range item {
+			documented = append(documented, method)
+		}
+		sort.Strings(documented)
+
+		accepted := allowedMethods(r.handler, r.path)
+		if strings.Join(documented, ",") != strings.Join(accepted, ",") {
+			drift = append(drift, fmt.Sprintf("%v accepts %v but documents %v", r.path, accepted, documented))
+		}
+	}
+
+	for path := range spec.Paths {
+		if !served[path] {
+			drift = append(drift, fmt.Sprintf("%v is documented but not served", path))
+		}
+	}
+
+	if len(drift) > 0 {
+		sort.Strings(drift)
+		return fmt.Errorf("OpenAPI document doesn't match the handlers: %v", strings.Join(drift, "; "))
+	}
+	return nil
+}
+
+// OpenAPIDocument serves the OpenAPI document describing the JSON API
+func OpenAPIDocument(spec *openapi.Document) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, "GET") {
+			return
+		}
+
+		log.Printf("JSON OpenAPI\n")
+		writeJSON(w, http.StatusOK, spec)
+	})
+}
+
+// OpenAPI checks the OpenAPI document against the handlers and returns it as indented JSON
+func OpenAPI() ([]byte, error) {
+	spec := newSpec()
+	// the handlers reject the probe's method before touching the store or signer
+	if err := checkSpec(spec, routes(nil, nil, spec)); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(spec, "", "  ")
+}
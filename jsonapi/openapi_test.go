@@ -0,0 +1,383 @@
+package jsonapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IM-Deane/mailing-list/mdb"
+	"github.com/IM-Deane/mailing-list/openapi"
+	"github.com/IM-Deane/mailing-list/token"
+)
+
+// testSecret signs the tokens sent by probe, each probe's signer uses it too
+var testSecret = []byte("test secret")
+
+// probeResult is how a handler responded to a probe. Bodies aren't compared as
+// they hold fresh tokens and timestamps, only the status, problem details and
+// how many emails a batch returned.
+type probeResult struct {
+	status int
+	code string
+	detail string
+	emails int
+}
+
+// invalidBody reports whether the handler failed to decode the request body
+func (r probeResult) invalidBody() bool {
+	return r.code == "invalid_request" && strings.HasPrefix(r.detail, "invalid JSON body")
+}
+
+// probe sends a request to the handler documented under path. Every probe gets
+// a fresh store holding someone@example.com so probes don't see each other's writes.
+func probe(t *testing.T, spec *openapi.Document, method string, path string, query url.Values, body string) probeResult {
+	t.Helper()
+	store := mdb.NewMemoryStore(mdb.Options{})
+	if err := store.CreateEmail("someone@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	var handler http.Handler
+	for _, r := range routes(store, token.NewSigner(testSecret, time.Hour), spec) {
+		if r.path == path {
+			handler = r.handler
+		}
+	}
+	if handler == nil {
+		t.Fatalf("%v isn't served", path)
+	}
+
+	target := strings.NewReplacer("{address}", "someone@example.com").Replace(path)
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(strings.ToUpper(method), target, strings.NewReader(body)))
+
+	res := probeResult{status: rec.Code}
+	p := problem{}
+	if json.Unmarshal(rec.Body.Bytes(), &p) == nil {
+		res.code, res.detail = p.Code, p.Detail
+	}
+	// page paginated batches are plain arrays
+	var entries []json.RawMessage
+	batch := emailBatchResponse{}
+	if json.Unmarshal(rec.Body.Bytes(), &entries) == nil {
+		res.emails = len(entries)
+	} else if json.Unmarshal(rec.Body.Bytes(), &batch) == nil {
+		res.emails = len(batch.Entries)
+	}
+	return res
+}
+
+// resolve follows a reference to components/schemas
+func resolve(spec *openapi.Document, s *openapi.Schema) *openapi.Schema {
+	if s.Ref == "" {
+		return s
+	}
+	return spec.Components.Schemas[strings.TrimPrefix(s.Ref, "#/components/schemas/")]
+}
+
+// exampleValue is a valid value for s, objects only get their required properties
+func exampleValue(spec *openapi.Document, s *openapi.Schema) interface{} {
+	s = resolve(spec, s)
+	switch s.Type {
+	case "object":
+		v := map[string]interface{}{}
+		for _, name := range s.Required {
+			v[name] = exampleValue(spec, s.Properties[name])
+		}
+		return v
+	case "string":
+		switch {
+		case s.Format == "email":
+			return "new@example.com"
+		case s.Format == "date-time":
+			return "2024-01-02T03:04:05Z"
+		case len(s.Enum) > 0:
+			return s.Enum[len(s.Enum)-1]
+		}
+		return "example"
+	case "integer", "number":
+		return 0
+	case "boolean":
+		return false
+	case "array":
+		return []interface{}{}
+	}
+	return nil
+}
+
+// wrongType is a JSON value decoding into s has to reject, nil if s accepts anything
+func wrongType(spec *openapi.Document, s *openapi.Schema) interface{} {
+	switch resolve(spec, s).Type {
+	case "":
+		return nil
+	case "string":
+		return 1
+	}
+	return "wrong"
+}
+
+// invalidQueryValue is a query parameter value the handlers reject, an unknown
+// list, cursor or filter, a bad token or something that isn't a number or boolean
+func invalidQueryValue(s *openapi.Schema) string {
+	if s.Type == "integer" || s.Type == "boolean" {
+		return "wrong"
+	}
+	return "missing"
+}
+
+// requiredQuery sets the required query parameters of an operation to valid values
+func requiredQuery(t *testing.T, path string, op *openapi.Operation) url.Values {
+	signer := token.NewSigner(testSecret, time.Hour)
+	q := url.Values{}
+	for _, p := range op.Parameters {
+		if p.In != "query" || !p.Required {
+			continue
+		}
+		switch {
+		case p.Name == "token" && path == "/unsubscribe":
+			q.Set(p.Name, signer.UnsubscribeToken("someone@example.com", ""))
+		case p.Name == "token":
+			q.Set(p.Name, signer.ConfirmToken("someone@example.com", ""))
+		default:
+			t.Fatalf("no valid value for the %v parameter of %v", p.Name, path)
+		}
+	}
+	return q
+}
+
+// pathParams matches the parameters of an OpenAPI path
+var pathParams = regexp.MustCompile(`\{(\w+)\}`)
+
+// inputDrift probes the handlers for the query parameters and body properties they read
+// and returns where they differ from the document. A parameter is read when setting it
+// to an invalid value changes the response, a body property when a value of the wrong
+// type fails decoding.
+func inputDrift(t *testing.T, spec *openapi.Document) []string {
+	// every query parameter documented anywhere, operations must ignore the ones they don't document
+	queryParams := map[string]openapi.Parameter{}
+	for _, item := range spec.Paths {
+		for _, op := range item {
+			for _, p := range op.Parameters {
+				if p.In == "query" {
+					queryParams[p.Name] = p
+				}
+			}
+		}
+	}
+
+	var drift []string
+	for path, item := range spec.Paths {
+		for method, op := range item {
+			name := strings.ToUpper(method) + " " + path
+			query := requiredQuery(t, path, op)
+			var body []byte
+			if op.RequestBody != nil {
+				body, _ = json.Marshal(exampleValue(spec, op.RequestBody.Content["application/json"].Schema))
+			}
+			baseline := probe(t, spec, method, path, query, string(body))
+
+			documented := map[string]bool{}
+			var inPath []string
+			for _, p := range op.Parameters {
+				switch p.In {
+				case "query":
+					documented[p.Name] = true
+				case "path":
+					inPath = append(inPath, p.Name)
+				}
+			}
+			var want []string
+			for _, m := range pathParams.FindAllStringSubmatch(path, -1) {
+				want = append(want, m[1])
+			}
+			sort.Strings(inPath)
+			if fmt.Sprint(inPath) != fmt.Sprint(want) {
+				drift = append(drift, fmt.Sprintf("%v documents path parameters %v but its path has %v", name, inPath, want))
+			}
+
+			for param, p := range queryParams {
+				q := url.Values{}
+				for k, v := range query {
+					q[k] = v
+				}
+				q.Set(param, invalidQueryValue(p.Schema))
+				read := probe(t, spec, method, path, q, string(body)) != baseline
+				if documented[param] && !read {
+					drift = append(drift, fmt.Sprintf("%v ignores its %v parameter", name, param))
+				}
+				if !documented[param] && read {
+					drift = append(drift, fmt.Sprintf("%v reads the undocumented %v parameter", name, param))
+				}
+			}
+
+			// the deprecated GET routes read JSON bodies, but OpenAPI 3.0 has no way to document them
+			if method == "get" {
+				if op.RequestBody != nil {
+					drift = append(drift, fmt.Sprintf("%v documents a request body", name))
+				}
+				continue
+			}
+
+			reads := probe(t, spec, method, path, query, "{").invalidBody()
+			if op.RequestBody == nil {
+				if reads {
+					drift = append(drift, fmt.Sprintf("%v reads an undocumented request body", name))
+				}
+				continue
+			}
+			if !reads {
+				drift = append(drift, fmt.Sprintf("%v documents a request body it doesn't read", name))
+				continue
+			}
+			schema := resolve(spec, op.RequestBody.Content["application/json"].Schema)
+			for prop, s := range schema.Properties {
+				wrong := wrongType(spec, s)
+				if wrong == nil {
+					continue
+				}
+				b, _ := json.Marshal(map[string]interface{}{prop: wrong})
+				if !probe(t, spec, method, path, query, string(b)).invalidBody() {
+					drift = append(drift, fmt.Sprintf("%v documents body property %v it doesn't decode", name, prop))
+				}
+			}
+		}
+	}
+
+	sort.Strings(drift)
+	return drift
+}
+
+func TestCheckSpecMatchesHandlers(t *testing.T) {
+	spec := newSpec()
+	if err := checkSpec(spec, routes(nil, nil, spec)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckSpecReportsDrift(t *testing.T) {
+	tests := []struct {
+		name string
+		// edit breaks the document
+		edit func(spec *openapi.Document)
+		want string
+	}{
+		{
+			name: "missing path",
+			edit: func(spec *openapi.Document) { delete(spec.Paths, "/v1/lists") },
+			want: "/v1/lists is served but not documented",
+		},
+		{
+			name: "missing method",
+			edit: func(spec *openapi.Document) { delete(spec.Paths["/v1/emails/{address}"], "delete") },
+			want: "/v1/emails/{address} accepts [delete get patch] but documents [get patch]",
+		},
+		{
+			name: "extra method",
+			edit: func(spec *openapi.Document) { spec.Paths["/v1/fields"]["delete"] = spec.Paths["/v1/fields"]["get"] },
+			want: "/v1/fields accepts [get post] but documents [delete get post]",
+		},
+		{
+			name: "path that isn't served",
+			edit: func(spec *openapi.Document) { spec.Paths["/v1/gone"] = spec.Paths["/v1/lists"] },
+			want: "/v1/gone is documented but not served",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := newSpec()
+			handlers := routes(nil, nil, spec)
+			tt.edit(spec)
+
+			err := checkSpec(spec, handlers)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %q doesn't mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestSpecMatchesHandlerInputs(t *testing.T) {
+	if drift := inputDrift(t, newSpec()); len(drift) > 0 {
+		t.Errorf("OpenAPI document doesn't match the handlers:\n%v", strings.Join(drift, "\n"))
+	}
+}
+
+func TestInputDrift(t *testing.T) {
+	tests := []struct {
+		name string
+		// edit breaks the document
+		edit func(spec *openapi.Document)
+		want string
+	}{
+		{
+			name: "undocumented parameter",
+			edit: func(spec *openapi.Document) {
+				op := spec.Paths["/v1/emails/{address}"]["get"]
+				op.Parameters = op.Parameters[:1]
+			},
+			want: "GET /v1/emails/{address} reads the undocumented list parameter",
+		},
+		{
+			name: "ignored parameter",
+			edit: func(spec *openapi.Document) {
+				spec.Paths["/v1/lists"]["get"].WithParams(queryParam("count", &openapi.Schema{Type: "integer"}, ""))
+			},
+			want: "GET /v1/lists ignores its count parameter",
+		},
+		{
+			name: "path parameter",
+			edit: func(spec *openapi.Document) {
+				spec.Paths["/v1/lists"]["get"].WithParams(openapi.Parameter{Name: "name", In: "path", Required: true, Schema: &openapi.Schema{Type: "string"}})
+			},
+			want: "GET /v1/lists documents path parameters [name] but its path has []",
+		},
+		{
+			name: "body on GET",
+			edit: func(spec *openapi.Document) { spec.Paths["/v1/lists"]["get"].WithBody(openapi.Ref("NewList")) },
+			want: "GET /v1/lists documents a request body",
+		},
+		{
+			name: "undocumented body",
+			edit: func(spec *openapi.Document) { spec.Paths["/v1/lists"]["post"].RequestBody = nil },
+			want: "POST /v1/lists reads an undocumented request body",
+		},
+		{
+			name: "body that isn't read",
+			edit: func(spec *openapi.Document) { spec.Paths["/v1/emails/{address}"]["delete"].WithBody(openapi.Ref("EmailPatch")) },
+			want: "DELETE /v1/emails/{address} documents a request body it doesn't read",
+		},
+		{
+			name: "body property that isn't decoded",
+			edit: func(spec *openapi.Document) {
+				spec.Components.Schemas["NewField"].Properties["Owner"] = &openapi.Schema{Type: "string"}
+			},
+			want: "POST /v1/fields documents body property Owner it doesn't decode",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec := newSpec()
+			tt.edit(spec)
+
+			drift := inputDrift(t, spec)
+			if !strings.Contains(strings.Join(drift, "\n"), tt.want) {
+				t.Errorf("drift %q doesn't mention %q", drift, tt.want)
+			}
+		})
+	}
+}
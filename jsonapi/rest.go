@@ -0,0 +1,296 @@
+package jsonapi
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IM-Deane/mailing-list/mdb"
+	"github.com/IM-Deane/mailing-list/token"
+)
+
+const (
+	// emailsPath is the /v1 email collection, items live under emailsPath + "/{address}"
+	emailsPath = "/v1/emails"
+	// defaultBatchCount is the page size when a GET /v1/emails request doesn't set count,
+	// larger counts than mdb.MaxBatchCount are rejected by GetEmailBatchQueryParams.Validate
+	defaultBatchCount = 100
+)
+
+// deprecatedSince is the unix time (2026-10-17 UTC) of the release that replaced the RPC style
+// /email/* and /list/* routes with /v1. It's sent in their Deprecation header so it must stay
+// fixed, don't move it when cutting later releases.
+const deprecatedSince = 1792195200
+
+// emailPatch is the JSON body accepted by PATCH /v1/emails/{address}, fields left out keep their value.
+// A zero ConfirmedAt ("1970-01-01T00:00:00Z") marks the email as unconfirmed.
+// Attributes are merged into the email's, a null value removes one.
+type emailPatch struct {
+	ConfirmedAt *time.Time
+	OptOut *bool
+	Attributes mdb.Attributes `json:",omitempty"`
+}
+
+// deprecated marks every response from h as deprecated (RFC 9745) and links to its replacement
+func deprecated(h http.Handler, successor string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", fmt.Sprintf("@%d", deprecatedSince))
+		w.Header().Set("Link", fmt.Sprintf("<%v>; rel=\"successor-version\"", successor))
+		h.ServeHTTP(w, r)
+	})
+}
+
+// queryBool reads an optional boolean query parameter
+func queryBool(q url.Values, name string) (bool, error) {
+	value := q.Get(name)
+	if value == "" {
+		return false, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, requestError(fmt.Sprintf("%v must be true or false", name))
+	}
+	return b, nil
+}
+
+// queryInt reads an optional integer query parameter, returning fallback when it isn't set
+func queryInt(q url.Values, name string, fallback int) (int, error) {
+	value := q.Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, requestError(fmt.Sprintf("%v must be a number", name))
+	}
+	return n, nil
+}
+
+// batchParams reads the pagination and filter query parameters of GET /v1/emails
+func batchParams(q url.Values) (mdb.GetEmailBatchQueryParams, error) {
+	params := mdb.GetEmailBatchQueryParams{Cursor: q.Get("cursor")}
+
+	var err error
+	if params.Count, err = queryInt(q, "count", defaultBatchCount); err != nil {
+		return params, err
+	}
+	if params.Page, err = queryInt(q, "page", 0); err != nil {
+		return params, err
+	}
+	if params.ConfirmedOnly, err = queryBool(q, "confirmed_only"); err != nil {
+		return params, err
+	}
+	if params.IncludeOptOut, err = queryBool(q, "include_opted_out"); err != nil {
+		return params, err
+	}
+	if params.Filters, err = mdb.ParseFilters(q["filter"]); err != nil {
+		return params, err
+	}
+
+	return params, nil
+}
+
+// emailLocation is the URL of an email's item route
+func emailLocation(email string, list string) string {
+	location := emailsPath + "/" + url.PathEscape(email)
+	if list != "" {
+		location += "?list=" + url.QueryEscape(list)
+	}
+	return location
+}
+
+// Emails serves the /v1/emails collection.
+//
+// GET lists emails, filtered and paginated by the list, count, cursor, page,
+// confirmed_only, include_opted_out and filter query parameters. The next page
+// is linked in the Link header as well as returned in NextCursor.
+//
+// POST creates an email from a {"Email", "List", "Attributes"} body and responds 201 with its Location.
+func Emails(store mdb.EmailStore, fields mdb.FieldStore, tokens *token.Signer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, "GET", "POST") {
+			return
+		}
+
+		if r.Method == "POST" {
+			req := emailRequest{List: r.URL.Query().Get("list")}
+			if err := fromJSON(r.Body, &req); err != nil {
+				returnErr(w, err)
+				return
+			}
+
+			log.Printf("JSON CreateEmail: %v\n", req.Email)
+			res, err := createEmail(store, fields, tokens, req.List, req.Email, req.Attributes)
+			if err != nil {
+				returnErr(w, err)
+				return
+			}
+
+			w.Header().Set("Location", emailLocation(res.Email, req.List))
+			writeJSON(w, http.StatusCreated, res)
+			return
+		}
+
+		q := r.URL.Query()
+		params, err := batchParams(q)
+		if err != nil {
+			returnErr(w, err)
+			return
+		}
+
+		log.Printf("JSON GetEmailBatch: %v\n", params)
+		entries, err := emailBatch(store, q.Get("list"), params)
+		if err != nil {
+			returnErr(w, err)
+			return
+		}
+
+		res := emailBatchResponse{Entries: entries}
+		if params.UsesCursor() {
+			res.NextCursor = mdb.NextCursor(entries, params)
+		}
+		if res.NextCursor != "" {
+			next := r.URL.Query()
+			next.Set("cursor", res.NextCursor)
+			w.Header().Set("Link", fmt.Sprintf("<%v?%v>; rel=\"next\"", emailsPath, next.Encode()))
+		}
+
+		writeJSON(w, http.StatusOK, res)
+	})
+}
+
+// EmailItem serves /v1/emails/{address}, scoped to a mailing list with the list query parameter.
+//
+// GET fetches the email, PATCH updates the ConfirmedAt, OptOut and Attributes
+// fields present in the body, and DELETE opts the email out.
+func EmailItem(store mdb.EmailStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		email, err := url.PathUnescape(strings.TrimPrefix(r.URL.EscapedPath(), emailsPath+"/"))
+		if err != nil || email == "" || strings.Contains(email, "/") {
+			notFound().ServeHTTP(w, r)
+			return
+		}
+		if !allowMethods(w, r, "GET", "PATCH", "DELETE") {
+			return
+		}
+		list := r.URL.Query().Get("list")
+
+		switch r.Method {
+		case "GET":
+			returnJSON(w, func() (interface{}, error) {
+				log.Printf("JSON GetEmail: %v\n", email)
+				return getEmail(store, list, email)
+			})
+
+		case "PATCH":
+			patch := emailPatch{}
+			if err := fromJSON(r.Body, &patch); err != nil {
+				returnErr(w, err)
+				return
+			}
+
+			returnJSON(w, func() (interface{}, error) {
+				log.Printf("JSON PatchEmail: %v\n", email)
+				// the store applies the patch in one update so concurrent patches don't undo each other
+				if err := store.PatchEmail(list, email, mdb.EmailPatch(patch)); err != nil {
+					return nil, err
+				}
+				return getEmail(store, list, email)
+			})
+
+		case "DELETE":
+			returnJSON(w, func() (interface{}, error) {
+				log.Printf("JSON DeleteEmail: %v\n", email)
+				return deleteEmail(store, list, email)
+			})
+		}
+	})
+}
+
+// Lists serves the /v1/lists collection, GET fetches every mailing list and
+// POST creates one from a {"Name", "DomainPolicy"} body
+func Lists(store mdb.ListStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, "GET", "POST") {
+			return
+		}
+
+		if r.Method == "GET" {
+			returnJSON(w, func() (interface{}, error) {
+				log.Printf("JSON GetLists\n")
+				return store.GetLists()
+			})
+			return
+		}
+
+		list := mdb.List{}
+		if err := fromJSON(r.Body, &list); err != nil {
+			returnErr(w, err)
+			return
+		}
+		if list.Name == "" {
+			returnErr(w, requestError("name field is required"))
+			return
+		}
+
+		log.Printf("JSON CreateList: %v\n", list.Name)
+		if err := store.CreateList(list.Name, list.DomainPolicy); err != nil {
+			returnErr(w, err)
+			return
+		}
+		created, err := store.GetList(list.Name)
+		if err != nil {
+			returnErr(w, err)
+			return
+		}
+
+		writeJSON(w, http.StatusCreated, created)
+	})
+}
+
+// Fields serves the /v1/fields collection of custom attributes, GET fetches every
+// field and POST defines one from a {"Name", "Type", "Description"} body
+func Fields(store mdb.FieldStore) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, "GET", "POST") {
+			return
+		}
+
+		if r.Method == "GET" {
+			returnJSON(w, func() (interface{}, error) {
+				log.Printf("JSON GetFields\n")
+				return store.GetFields()
+			})
+			return
+		}
+
+		field := mdb.Field{}
+		if err := fromJSON(r.Body, &field); err != nil {
+			returnErr(w, err)
+			return
+		}
+
+		log.Printf("JSON CreateField: %v\n", field.Name)
+		if err := store.CreateField(field); err != nil {
+			returnErr(w, err)
+			return
+		}
+		fields, err := store.GetFields()
+		if err != nil {
+			returnErr(w, err)
+			return
+		}
+		for _, created := range fields {
+			if created.Name == field.Name {
+				writeJSON(w, http.StatusCreated, created)
+				return
+			}
+		}
+
+		returnErr(w, fmt.Errorf("field %v missing after it was created", field.Name))
+	})
+}
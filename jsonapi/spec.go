@@ -0,0 +1,202 @@
+package jsonapi
+
+import (
+	"github.com/IM-Deane/mailing-list/mdb"
+	"github.com/IM-Deane/mailing-list/openapi"
+)
+
+// problemResponse is documented on every operation for the problem+json errors from returnErr
+var problemResponse = openapi.Response{
+	Description: "Error, see the code field for the reason",
+	Content: map[string]openapi.MediaType{"application/problem+json": {Schema: openapi.Ref("Problem")}},
+}
+
+// newOperation documents an operation, every operation can also fail with a problem+json error
+func newOperation(summary string, responses map[string]openapi.Response) *openapi.Operation {
+	responses["default"] = problemResponse
+	return &openapi.Operation{Summary: summary, Responses: responses}
+}
+
+// deprecate marks an RPC style operation as replaced by a /v1 route
+func deprecate(o *openapi.Operation, successor string) *openapi.Operation {
+	return o.Deprecate("Deprecated, use " + successor + " instead.")
+}
+
+// getBody notes the JSON body a deprecated GET route reads, OpenAPI 3.0 doesn't allow documenting it as a requestBody
+func getBody(o *openapi.Operation, schema string) *openapi.Operation {
+	o.Description += " Reads a JSON " + schema + " body."
+	return o
+}
+
+// queryParam is an optional query string parameter
+func queryParam(name string, s *openapi.Schema, description string) openapi.Parameter {
+	return openapi.Parameter{Name: name, In: "query", Description: description, Schema: s}
+}
+
+// unsubscribeOperation documents the /unsubscribe page, which is HTML even when it fails
+func unsubscribeOperation(summary string, description string, tokenParam openapi.Parameter) *openapi.Operation {
+	html := map[string]openapi.MediaType{"text/html": {Schema: &openapi.Schema{Type: "string"}}}
+	return &openapi.Operation{
+		Summary: summary,
+		Parameters: []openapi.Parameter{tokenParam},
+		Responses: map[string]openapi.Response{
+			"200": {Description: description, Content: html},
+			"400": {Description: "HTML page explaining the link is invalid", Content: html},
+		},
+	}
+}
+
+// newSpec builds the OpenAPI document for the routes registered by Serve
+func newSpec() *openapi.Document {
+	b := newSchemaBuilder()
+
+	b.component("Problem", problem{})
+	entry := b.component("EmailEntry", mdb.EmailEntry{})
+	b.schemas["EmailEntry"].Properties["ConfirmedAt"].Description = "when the address was confirmed, 1970-01-01T00:00:00Z if it hasn't been"
+	b.schemas["EmailEntry"].Properties["DomainFlag"].Description = "why the address' domain was flagged at signup, empty if it passed"
+	b.schemas["EmailEntry"].Properties["Attributes"].Description = "custom attributes by field name, see /v1/fields. Updates merge them, null removes one."
+	b.schemas["EmailEntry"].Properties["Attributes"].AdditionalProperties.Description = "a string, number, boolean or YYYY-MM-DD date depending on the field's type"
+	created := b.component("CreatedEmail", createEmailResponse{})
+	b.schemas["CreatedEmail"].Properties["ConfirmToken"].Description = "token for /v1/confirm"
+	b.schemas["CreatedEmail"].Properties["UnsubscribeToken"].Description = "token for /unsubscribe"
+	batch := b.component("EmailBatch", emailBatchResponse{})
+	b.schemas["EmailBatch"].Properties["NextCursor"].Description = "cursor for the next page, empty once the last page has been returned"
+	list := b.component("List", mdb.List{})
+	b.schemas["List"].Properties["DomainPolicy"].Enum = []string{
+		string(mdb.DomainPolicyDefault), string(mdb.DomainPolicyReject), string(mdb.DomainPolicyFlag),
+	}
+	b.schemas["List"].Properties["DomainPolicy"].Description = "overrides the server's --domain-policy for signups to this list"
+	emailReq := b.component("EmailRequest", emailRequest{})
+	b.schemas["EmailRequest"].Properties["List"].Description = "mailing list to scope the request to, the global email list if empty"
+	b.component("EmailBatchRequest", emailBatchRequest{})
+	patch := b.component("EmailPatch", emailPatch{})
+	b.schemas["EmailPatch"].Description = "fields left out keep their current value"
+	b.schemas["NewEmail"] = &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{"Email": {Type: "string", Format: "email"}, "List": {Type: "string"}, "Attributes": b.schemas["EmailEntry"].Properties["Attributes"]},
+		Required: []string{"Email"},
+	}
+	newEmail := openapi.Ref("NewEmail")
+	field := b.component("Field", mdb.Field{})
+	b.schemas["Field"].Properties["Type"].Enum = []string{
+		string(mdb.FieldString), string(mdb.FieldNumber), string(mdb.FieldBool), string(mdb.FieldDate),
+	}
+	b.schemas["Field"].Properties["Name"].Description = "lowercase letters, digits and underscores starting with a letter"
+	b.schemas["NewField"] = &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{"Name": b.schemas["Field"].Properties["Name"], "Type": b.schemas["Field"].Properties["Type"], "Description": {Type: "string"}},
+		Required: []string{"Name", "Type"},
+	}
+	newField := openapi.Ref("NewField")
+	b.schemas["NewList"] = &openapi.Schema{
+		Type: "object",
+		Properties: map[string]*openapi.Schema{"Name": {Type: "string"}, "DomainPolicy": b.schemas["List"].Properties["DomainPolicy"]},
+		Required: []string{"Name"},
+	}
+	newList := openapi.Ref("NewList")
+
+	entries := &openapi.Schema{Type: "array", Items: entry}
+	lists := &openapi.Schema{Type: "array", Items: list}
+	fields := &openapi.Schema{Type: "array", Items: field}
+	ok := func(description string, s *openapi.Schema) map[string]openapi.Response {
+		return map[string]openapi.Response{"200": {Description: description, Content: openapi.JSONContent(s)}}
+	}
+	location := map[string]openapi.Header{"Location": {Description: "URL of the new resource", Schema: &openapi.Schema{Type: "string"}}}
+
+	listParam := queryParam("list", &openapi.Schema{Type: "string"}, "mailing list to scope the request to, the global email list if empty")
+	tokenParam := openapi.Parameter{Name: "token", In: "query", Required: true, Schema: &openapi.Schema{Type: "string"}}
+	addressParam := openapi.Parameter{Name: "address", In: "path", Required: true, Schema: &openapi.Schema{Type: "string", Format: "email"}}
+
+	confirm := func() openapi.PathItem {
+		op := func() *openapi.Operation {
+			return newOperation("Confirm an email with the token from CreatedEmail", ok("The confirmed email", entry)).WithParams(tokenParam)
+		}
+		return openapi.PathItem{"get": op(), "post": op()}
+	}
+
+	spec := &openapi.Document{
+		OpenAPI: "3.0.3",
+		Info: openapi.Info{
+			Title: "Mailing list JSON API",
+			Version: "1.0.0",
+			Description: "Manage mailing list subscribers. Errors are RFC 7807 problem details with a stable code.",
+		},
+		Paths: map[string]openapi.PathItem{
+			"/v1/emails": {
+				"get": newOperation("Page through emails", ok("A page of emails, the next page is also linked in the Link header", batch)).WithParams(
+					listParam,
+					queryParam("count", &openapi.Schema{Type: "integer", Format: "int32", Default: defaultBatchCount, Maximum: mdb.MaxBatchCount}, "page size"),
+					queryParam("cursor", &openapi.Schema{Type: "string"}, "NextCursor of the previous page"),
+					queryParam("page", &openapi.Schema{Type: "integer", Format: "int32"}, "page number, for page/count pagination instead of cursors"),
+					queryParam("confirmed_only", &openapi.Schema{Type: "boolean"}, "only return confirmed subscribers"),
+					queryParam("include_opted_out", &openapi.Schema{Type: "boolean"}, "also return emails that opted out"),
+					queryParam("filter", &openapi.Schema{Type: "array", Items: &openapi.Schema{Type: "string"}},
+						"only return emails whose attributes match, e.g. locale=en or age>=18. Repeat to match every filter."),
+				),
+				"post": newOperation("Create an email", map[string]openapi.Response{
+					"201": {Description: "The new email and its tokens", Headers: location, Content: openapi.JSONContent(created)},
+				}).WithParams(listParam).WithBody(newEmail),
+			},
+			"/v1/emails/{address}": {
+				"get": newOperation("Fetch an email", ok("The email", entry)).WithParams(addressParam, listParam),
+				"patch": newOperation("Update an email", ok("The updated email", entry)).WithParams(addressParam, listParam).WithBody(patch),
+				"delete": newOperation("Opt an email out", ok("The opted out email", entry)).WithParams(addressParam, listParam),
+			},
+			"/v1/confirm": confirm(),
+			"/v1/lists": {
+				"get": newOperation("Fetch every mailing list", ok("The mailing lists", lists)),
+				"post": newOperation("Create a mailing list", map[string]openapi.Response{
+					"201": {Description: "The new mailing list", Content: openapi.JSONContent(list)},
+				}).WithBody(newList),
+			},
+			"/v1/fields": {
+				"get": newOperation("Fetch every custom field", ok("The custom fields", fields)),
+				"post": newOperation("Define a custom field subscribers can have attributes for", map[string]openapi.Response{
+					"201": {Description: "The new field", Content: openapi.JSONContent(field)},
+				}).WithBody(newField),
+			},
+			"/unsubscribe": {
+				"get": unsubscribeOperation("Show the unsubscribe confirmation page", "HTML page asking to confirm", tokenParam),
+				"post": unsubscribeOperation("Opt out, also used for RFC 8058 one-click unsubscribe", "HTML page confirming the opt out", tokenParam),
+			},
+			"/openapi.json": {
+				"get": &openapi.Operation{
+					Summary: "This document",
+					Responses: map[string]openapi.Response{"200": {Description: "OpenAPI 3 document", Content: openapi.JSONContent(&openapi.Schema{Type: "object"})}},
+				},
+			},
+
+			"/email/create": {
+				"post": deprecate(newOperation("Create an email", ok("The new email and its tokens", created)).WithBody(emailReq), "POST /v1/emails"),
+			},
+			"/email/confirm": confirm(),
+			"/email/get": {
+				"get": getBody(deprecate(newOperation("Fetch an email", ok("The email", entry)), "GET /v1/emails/{address}"), "EmailRequest"),
+			},
+			"/email/get_batch": {
+				"get": getBody(deprecate(newOperation("Fetch a page of emails", ok("EmailBatch for cursor pagination, a plain array when Page is set", &openapi.Schema{
+					OneOf: []*openapi.Schema{batch, entries},
+				})), "GET /v1/emails"), "EmailBatchRequest"),
+			},
+			"/email/update": {
+				"put": deprecate(newOperation("Update an email", ok("The updated email", entry)).WithBody(emailReq), "PATCH /v1/emails/{address}"),
+			},
+			"/email/delete": {
+				"post": deprecate(newOperation("Opt an email out", ok("The opted out email", entry)).WithBody(emailReq), "DELETE /v1/emails/{address}"),
+			},
+			"/list/create": {
+				"post": deprecate(newOperation("Create a mailing list", ok("The new mailing list", list)).WithBody(newList), "POST /v1/lists"),
+			},
+			"/list/get_all": {
+				"get": deprecate(newOperation("Fetch every mailing list", ok("The mailing lists", lists)), "GET /v1/lists"),
+			},
+		},
+		Components: openapi.Components{Schemas: b.schemas},
+	}
+
+	for _, op := range spec.Paths["/email/confirm"] {
+		deprecate(op, "/v1/confirm")
+	}
+
+	return spec
+}
@@ -0,0 +1,96 @@
+package jsonapi
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/IM-Deane/mailing-list/mdb"
+	"github.com/IM-Deane/mailing-list/token"
+)
+
+// unsubscribePage is rendered for people following an unsubscribe link
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="utf-8">
+	<meta name="viewport" content="width=device-width, initial-scale=1">
+	<title>Unsubscribe</title>
+</head>
+<body style="font-family: sans-serif; max-width: 32em; margin: 4em auto;">
+{{if .Error}}
+	<h1>Invalid link</h1>
+	<p>This unsubscribe link is invalid. Please use the link from your most recent email.</p>
+{{else if .Done}}
+	<h1>You've been unsubscribed</h1>
+	<p><strong>{{.Email}}</strong> will no longer receive emails{{if .List}} from {{.List}}{{end}}.</p>
+{{else}}
+	<h1>Unsubscribe</h1>
+	<p>Stop sending emails{{if .List}} from {{.List}}{{end}} to <strong>{{.Email}}</strong>?</p>
+	<form method="POST">
+		<input type="hidden" name="token" value="{{.Token}}">
+		<button type="submit">Unsubscribe</button>
+	</form>
+{{end}}
+</body>
+</html>
+`))
+
+// unsubscribePageData is the data used to render unsubscribePage
+type unsubscribePageData struct {
+	Token string
+	Email string
+	List string
+	Done bool
+	Error bool
+}
+
+// Unsubscribe opts an address out using a signed token and renders a confirmation page.
+//
+// GET shows a page asking the person to confirm, so link scanners that prefetch
+// URLs can't unsubscribe anyone. POST performs the opt out, which also covers
+// RFC 8058 one-click unsubscribe where mail clients POST
+// "List-Unsubscribe=One-Click" to the URL from the List-Unsubscribe header.
+func Unsubscribe(store mdb.EmailStore, tokens *token.Signer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !allowMethods(w, r, "GET", "POST") {
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+
+		// one-click requests carry the token in the URL, the confirmation form in the body
+		tok := r.URL.Query().Get("token")
+		if tok == "" && r.Method == "POST" {
+			tok = r.PostFormValue("token")
+		}
+
+		claims, err := tokens.Verify(tok, token.Unsubscribe)
+		if err != nil {
+			w.WriteHeader(400)
+			unsubscribePage.Execute(w, unsubscribePageData{Error: true})
+			return
+		}
+
+		data := unsubscribePageData{Token: tok, Email: claims.Email, List: claims.List}
+
+		if r.Method == "GET" {
+			unsubscribePage.Execute(w, data)
+			return
+		}
+
+		log.Printf("JSON Unsubscribe: %v\n", claims.Email)
+		if claims.List != "" {
+			err = store.Unsubscribe(claims.List, claims.Email)
+		} else {
+			err = store.DeleteEmail(claims.Email)
+		}
+		if err != nil {
+			w.WriteHeader(500)
+			unsubscribePage.Execute(w, unsubscribePageData{Error: true})
+			return
+		}
+
+		data.Done = true
+		unsubscribePage.Execute(w, data)
+	})
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	pb "github.com/IM-Deane/mailing-list/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// errNotFound is returned when the requested email doesn't exist
+var errNotFound = errors.New("email not found")
+
+// timeout bounds each request, set with --timeout
+var timeout = 5 * time.Second
+
+// entryFromResponse returns the response's entry, or errNotFound if it has none.
+// Servers return a NotFound status instead, older ones return an empty response.
+func entryFromResponse(res *pb.EmailResponse, err error) (*pb.EmailEntry, error) {
+	if err != nil {
+		return nil, err
+	}
+	if res.EmailEntry == nil {
+		return nil, errNotFound
+	}
+	return res.EmailEntry, nil
+}
+
+// createEmail handles email creation on client, the response includes the confirm and unsubscribe tokens
+func createEmail(client pb.MailingListServiceClient, address string, list string, attrs map[string]*structpb.Value) (*pb.EmailResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	// if request finishes before the timeout we free up resources
+	defer cancel()
+
+	res, err := client.CreateEmail(ctx, &pb.CreateEmailRequest{EmailAddr: address, List: list, Attributes: attrs})
+	if _, err := entryFromResponse(res, err); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// confirmEmail handles completing double opt-in with a confirmation token on client
+func confirmEmail(client pb.MailingListServiceClient, confirmToken string) (*pb.EmailEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	// if request finishes before the timeout we free up resources
+	defer cancel()
+
+	return entryFromResponse(client.ConfirmEmail(ctx, &pb.ConfirmEmailRequest{Token: confirmToken}))
+}
+
+// getEmail handles fetching an email on client
+func getEmail(client pb.MailingListServiceClient, address string, list string) (*pb.EmailEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	// if request finishes before the timeout we free up resources
+	defer cancel()
+
+	return entryFromResponse(client.GetEmail(ctx, &pb.GetEmailRequest{EmailAddr: address, List: list}))
+}
+
+// getEmailBatch handles fetching a page of emails on client
+func getEmailBatch(client pb.MailingListServiceClient, req *pb.GetEmailBatchRequest) (*pb.GetEmailBatchResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	// if request finishes before the timeout we free up resources
+	defer cancel()
+
+	return client.GetEmailBatch(ctx, req)
+}
+
+// streamEmails handles streaming every matching email on client, calling fn for each one
+func streamEmails(client pb.MailingListServiceClient, req *pb.StreamEmailsRequest, fn func(*pb.EmailEntry) error) error {
+	// no timeout as exporting a large list can take a while
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.StreamEmails(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	for {
+		entry, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(entry); err != nil {
+			return err
+		}
+	}
+}
+
+// importEmails handles bulk importing addresses on client, calling fn with each batch of results
+func importEmails(client pb.MailingListServiceClient, list string, addresses []string, fn func(*pb.ImportEmailsResponse)) error {
+	// no timeout as importing a large list can take a while
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := client.ImportEmails(ctx)
+	if err != nil {
+		return err
+	}
+
+	// send every address then close our side of the stream
+	go func() {
+		for _, address := range addresses {
+			req := &pb.ImportEmailsRequest{EmailEntry: &pb.EmailEntry{Email: address}, List: list}
+			if err := stream.Send(req); err != nil {
+				return
+			}
+		}
+		stream.CloseSend()
+	}()
+
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		fn(res)
+	}
+}
+
+// updateEmail handles updating emails via client
+func updateEmail(client pb.MailingListServiceClient, entry *pb.EmailEntry, list string) (*pb.EmailEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	// if request finishes before the timeout we free up resources
+	defer cancel()
+
+	return entryFromResponse(client.UpdateEmail(ctx, &pb.UpdateEmailRequest{EmailEntry: entry, List: list}))
+}
+
+// deleteEmail handles opting emails out via client
+func deleteEmail(client pb.MailingListServiceClient, address string, list string) (*pb.EmailEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	// if request finishes before the timeout we free up resources
+	defer cancel()
+
+	return entryFromResponse(client.DeleteEmail(ctx, &pb.DeleteEmailRequest{EmailAddr: address, List: list}))
+}
+
+// createList handles creating a mailing list via client
+func createList(client pb.MailingListServiceClient, name string, domainPolicy string) (*pb.MailingList, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	// if request finishes before the timeout we free up resources
+	defer cancel()
+
+	res, err := client.CreateList(ctx, &pb.CreateListRequest{Name: name, DomainPolicy: domainPolicy})
+	if err != nil {
+		return nil, err
+	}
+
+	return res.List, nil
+}
+
+// getLists handles fetching every mailing list on client
+func getLists(client pb.MailingListServiceClient) ([]*pb.MailingList, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	// if request finishes before the timeout we free up resources
+	defer cancel()
+
+	res, err := client.GetLists(ctx, &pb.GetListsRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Lists, nil
+}
+
+// createField handles defining a custom field via client
+func createField(client pb.MailingListServiceClient, name string, fieldType string, description string) (*pb.Field, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	// if request finishes before the timeout we free up resources
+	defer cancel()
+
+	res, err := client.CreateField(ctx, &pb.CreateFieldRequest{Name: name, Type: fieldType, Description: description})
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Field, nil
+}
+
+// getFields handles fetching every custom field on client
+func getFields(client pb.MailingListServiceClient) ([]*pb.Field, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	// if request finishes before the timeout we free up resources
+	defer cancel()
+
+	res, err := client.GetFields(ctx, &pb.GetFieldsRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Fields, nil
+}
+
+// withIdempotencyKey sends key with every call, the server only uses it for creates and updates
+func withIdempotencyKey(key string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx = metadata.AppendToOutgoingContext(ctx, "idempotency-key", key)
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
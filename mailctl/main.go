@@ -0,0 +1,385 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IM-Deane/mailing-list/auth"
+	pb "github.com/IM-Deane/mailing-list/proto"
+	"github.com/IM-Deane/mailing-list/tlsconfig"
+	"github.com/alexflint/go-arg"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// exit codes returned by mailctl
+const (
+	exitError    = 1
+	exitUsage    = 2
+	exitNotFound = 3
+)
+
+// CreateCmd subscribes a new address
+type CreateCmd struct {
+	Address string   `arg:"positional,required" help:"email address to subscribe"`
+	List    string   `arg:"--list" help:"mailing list, defaults to the global list"`
+	Attrs   []string `arg:"--attr,separate" help:"custom attribute as name=value, repeat for more"`
+}
+
+// GetCmd fetches a single address
+type GetCmd struct {
+	Address string `arg:"positional,required" help:"email address to fetch"`
+	List    string `arg:"--list" help:"mailing list, defaults to the global list"`
+}
+
+// UpdateCmd changes the confirmation or opt-out state or the attributes of an address
+type UpdateCmd struct {
+	Address     string   `arg:"positional,required" help:"email address to update"`
+	List        string   `arg:"--list" help:"mailing list, defaults to the global list"`
+	OptOut      *bool    `arg:"--opt-out" help:"set the opt-out state, e.g. --opt-out=false"`
+	ConfirmedAt *string  `arg:"--confirmed-at" help:"confirmation time as RFC 3339, unix seconds or 'now', empty to clear"`
+	Attrs       []string `arg:"--attr,separate" help:"set a custom attribute as name=value, repeat for more"`
+	UnsetAttrs  []string `arg:"--unset-attr,separate" help:"remove a custom attribute, repeat for more"`
+}
+
+// DeleteCmd opts an address out
+type DeleteCmd struct {
+	Address string `arg:"positional,required" help:"email address to opt out"`
+	List    string `arg:"--list" help:"mailing list, defaults to the global list"`
+}
+
+// ListCmd fetches a page of addresses
+type ListCmd struct {
+	List          string   `arg:"--list" help:"mailing list, defaults to the global list"`
+	Count         int      `arg:"--count" help:"number of emails per page" default:"10"`
+	Page          int      `arg:"--page" help:"page number, leave unset to page with --cursor"`
+	Cursor        string   `arg:"--cursor" help:"cursor returned by the previous page"`
+	ConfirmedOnly bool     `arg:"--confirmed-only" help:"only return confirmed addresses"`
+	All           bool     `arg:"--all" help:"stream every matching address instead of a single page"`
+	IncludeOptOut bool     `arg:"--include-opted-out" help:"include opted out addresses, only used with --all"`
+	Filters       []string `arg:"--filter,separate" help:"only list addresses whose attributes match, e.g. locale=en or age>=18, repeat to match every filter"`
+}
+
+// ConfirmCmd completes double opt-in with a confirmation token
+type ConfirmCmd struct {
+	Token string `arg:"positional,required" help:"confirmation token returned by create"`
+}
+
+// ImportCmd bulk imports addresses
+type ImportCmd struct {
+	Addresses []string `arg:"positional,required" help:"email addresses to import"`
+	List      string   `arg:"--list" help:"mailing list, defaults to the global list"`
+}
+
+// FieldsCmd manages the custom fields addresses can have attributes for
+type FieldsCmd struct {
+	Create      string `arg:"--create" help:"define a field with this name instead of listing them"`
+	Type        string `arg:"--type" help:"type of the new field: string, number, bool or date" default:"string"`
+	Description string `arg:"--description" help:"what the new field holds"`
+}
+
+// ListsCmd manages mailing lists
+type ListsCmd struct {
+	Create       string `arg:"--create" help:"create a mailing list with this name instead of listing them"`
+	DomainPolicy string `arg:"--domain-policy" help:"reject or flag signups to the new list that fail the domain check, defaults to the server's policy"`
+}
+
+var args struct {
+	Create   *CreateCmd    `arg:"subcommand:create" help:"subscribe an email address"`
+	Get      *GetCmd       `arg:"subcommand:get" help:"fetch an email address"`
+	Update   *UpdateCmd    `arg:"subcommand:update" help:"update an email address"`
+	Delete   *DeleteCmd    `arg:"subcommand:delete" help:"opt an email address out"`
+	List     *ListCmd      `arg:"subcommand:list" help:"list email addresses"`
+	Confirm  *ConfirmCmd   `arg:"subcommand:confirm" help:"confirm an email address with a token"`
+	Import   *ImportCmd    `arg:"subcommand:import" help:"bulk import email addresses"`
+	Lists    *ListsCmd     `arg:"subcommand:lists" help:"show or create mailing lists"`
+	Fields   *FieldsCmd    `arg:"subcommand:fields" help:"show or define custom attribute fields"`
+	GRPCAddr string        `arg:"--grpc-addr,env:MAILINGLIST_GRPC_ADDR" help:"address of the gRPC server" default:":8081"`
+	APIKey   string        `arg:"--api-key,env:MAILINGLIST_API_KEY" help:"API key sent with every request"`
+	IdemKey  string        `arg:"--idempotency-key" help:"sent with create, update and lists --create so retrying them with the same key is safe"`
+	TLS      bool          `arg:"--tls,env:MAILINGLIST_TLS" help:"connect with TLS, implied by the other --tls flags"`
+	TLSCA    string        `arg:"--tls-ca,env:MAILINGLIST_TLS_CA" help:"CA bundle to verify the server with instead of the system's"`
+	TLSCert  string        `arg:"--tls-client-cert,env:MAILINGLIST_TLS_CLIENT_CERT" help:"client certificate for servers that verify them"`
+	TLSKey   string        `arg:"--tls-client-key,env:MAILINGLIST_TLS_CLIENT_KEY" help:"key of the client certificate"`
+	TLSName  string        `arg:"--tls-server-name" help:"name the server certificate must have, defaults to the host in --grpc-addr"`
+	Timeout  time.Duration `arg:"--timeout,env:MAILINGLIST_TIMEOUT" help:"timeout for each request" default:"5s"`
+	Output   string        `arg:"-o,--output,env:MAILINGLIST_OUTPUT" help:"output format: table, json, jsonl or yaml" default:"table"`
+	Columns  string        `arg:"--columns" help:"comma separated columns to show, e.g. email,opt_out"`
+}
+
+// parseConfirmedAt reads RFC 3339, unix seconds or "now", an empty value clears the confirmation
+func parseConfirmedAt(value string) (int64, error) {
+	value = strings.TrimSpace(value)
+	switch value {
+	case "", "0":
+		return 0, nil
+	case "now":
+		return time.Now().Unix(), nil
+	}
+
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return seconds, nil
+	}
+	at, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid confirmed time %q, expected RFC 3339, unix seconds or 'now'", value)
+	}
+
+	return at.Unix(), nil
+}
+
+// parseAttrs reads name=value attributes, the server converts values to their field's type
+func parseAttrs(specs []string) (map[string]*structpb.Value, error) {
+	attrs := make(map[string]*structpb.Value, len(specs))
+	for _, spec := range specs {
+		i := strings.Index(spec, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid attribute %q, expected name=value", spec)
+		}
+		attrs[spec[:i]] = structpb.NewStringValue(spec[i+1:])
+	}
+	return attrs, nil
+}
+
+// run executes the selected subcommand
+func run(p *arg.Parser, client pb.MailingListServiceClient, out *printer) error {
+	switch {
+	case args.Create != nil:
+		attrs, err := parseAttrs(args.Create.Attrs)
+		if err != nil {
+			return err
+		}
+		res, err := createEmail(client, args.Create.Address, args.Create.List, attrs)
+		if err != nil {
+			return err
+		}
+		r := append(entryRecord(res.EmailEntry),
+			field{"confirm_token", res.ConfirmToken},
+			field{"unsubscribe_token", res.UnsubscribeToken},
+		)
+		return out.printOne(r)
+
+	case args.Get != nil:
+		entry, err := getEmail(client, args.Get.Address, args.Get.List)
+		if err != nil {
+			return err
+		}
+		return out.printOne(entryRecord(entry))
+
+	case args.Update != nil:
+		cmd := args.Update
+		if cmd.OptOut == nil && cmd.ConfirmedAt == nil && len(cmd.Attrs) == 0 && len(cmd.UnsetAttrs) == 0 {
+			return errors.New("nothing to update, pass --opt-out, --confirmed-at, --attr or --unset-attr")
+		}
+		attrs, err := parseAttrs(cmd.Attrs)
+		if err != nil {
+			return err
+		}
+		for _, name := range cmd.UnsetAttrs {
+			attrs[name] = structpb.NewNullValue()
+		}
+
+		// start from the stored entry so unset flags keep their value
+		entry, err := getEmail(client, cmd.Address, cmd.List)
+		if err != nil {
+			return err
+		}
+		if cmd.OptOut != nil {
+			entry.OptOut = *cmd.OptOut
+		}
+		if cmd.ConfirmedAt != nil {
+			entry.ConfirmedAt, err = parseConfirmedAt(*cmd.ConfirmedAt)
+			if err != nil {
+				return err
+			}
+		}
+		// attributes are merged, so only send the ones being changed
+		entry.Attributes = attrs
+
+		entry, err = updateEmail(client, entry, cmd.List)
+		if err != nil {
+			return err
+		}
+		return out.printOne(entryRecord(entry))
+
+	case args.Delete != nil:
+		entry, err := deleteEmail(client, args.Delete.Address, args.Delete.List)
+		if err != nil {
+			return err
+		}
+		return out.printOne(entryRecord(entry))
+
+	case args.List != nil:
+		cmd := args.List
+		if cmd.All {
+			req := &pb.StreamEmailsRequest{
+				List:            cmd.List,
+				IncludeOptedOut: cmd.IncludeOptOut,
+				ConfirmedOnly:   cmd.ConfirmedOnly,
+				Filters:         cmd.Filters,
+			}
+			var entries []*pb.EmailEntry
+			err := streamEmails(client, req, func(entry *pb.EmailEntry) error {
+				entries = append(entries, entry)
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			return out.printList("emails", entryRecords(entries), nil)
+		}
+
+		req := &pb.GetEmailBatchRequest{
+			Page:          int32(cmd.Page),
+			Count:         int32(cmd.Count),
+			List:          cmd.List,
+			ConfirmedOnly: cmd.ConfirmedOnly,
+			Cursor:        cmd.Cursor,
+			Filters:       cmd.Filters,
+		}
+		res, err := getEmailBatch(client, req)
+		if err != nil {
+			return err
+		}
+		var meta record
+		if res.NextCursor != "" {
+			meta = record{{"next_cursor", res.NextCursor}}
+		}
+		return out.printList("emails", entryRecords(res.EmailEntry), meta)
+
+	case args.Confirm != nil:
+		entry, err := confirmEmail(client, args.Confirm.Token)
+		if err != nil {
+			return err
+		}
+		return out.printOne(entryRecord(entry))
+
+	case args.Import != nil:
+		var results []record
+		var meta record
+		err := importEmails(client, args.Import.List, args.Import.Addresses, func(res *pb.ImportEmailsResponse) {
+			for _, result := range res.Results {
+				results = append(results, importResultRecord(result))
+			}
+			if res.Summary != nil {
+				meta = record{{"summary", importSummaryRecord(res.Summary)}}
+			}
+		})
+		if err != nil {
+			return err
+		}
+		return out.printList("results", results, meta)
+
+	case args.Lists != nil:
+		if args.Lists.Create != "" {
+			list, err := createList(client, args.Lists.Create, args.Lists.DomainPolicy)
+			if err != nil {
+				return err
+			}
+			return out.printOne(listRecord(list))
+		}
+
+		lists, err := getLists(client)
+		if err != nil {
+			return err
+		}
+		records := make([]record, 0, len(lists))
+		for _, list := range lists {
+			records = append(records, listRecord(list))
+		}
+		return out.printList("lists", records, nil)
+
+	case args.Fields != nil:
+		if args.Fields.Create != "" {
+			f, err := createField(client, args.Fields.Create, args.Fields.Type, args.Fields.Description)
+			if err != nil {
+				return err
+			}
+			return out.printOne(fieldRecord(f))
+		}
+
+		fields, err := getFields(client)
+		if err != nil {
+			return err
+		}
+		records := make([]record, 0, len(fields))
+		for _, f := range fields {
+			records = append(records, fieldRecord(f))
+		}
+		return out.printList("fields", records, nil)
+
+	default:
+		p.WriteUsage(os.Stderr)
+		fmt.Fprintln(os.Stderr, "error: missing subcommand")
+		os.Exit(exitUsage)
+	}
+
+	return nil
+}
+
+func main() {
+	p, err := arg.NewParser(arg.Config{}, &args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(exitError)
+	}
+	switch err := p.Parse(os.Args[1:]); {
+	case err == arg.ErrHelp:
+		p.WriteHelp(os.Stdout)
+		return
+	case err != nil:
+		p.WriteUsage(os.Stderr)
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	timeout = args.Timeout
+
+	out, err := newPrinter(args.Output, args.Columns)
+	if err != nil {
+		p.WriteUsage(os.Stderr)
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		os.Exit(exitUsage)
+	}
+
+	// connect to gRPC server, over TLS if any of the TLS flags are set
+	creds := insecure.NewCredentials()
+	if args.TLS || args.TLSCA != "" || args.TLSCert != "" || args.TLSKey != "" || args.TLSName != "" {
+		config, err := tlsconfig.ClientConfig(args.TLSCA, args.TLSCert, args.TLSKey, args.TLSName)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			os.Exit(exitUsage)
+		}
+		creds = credentials.NewTLS(config)
+	}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	if args.APIKey != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(auth.Credentials(args.APIKey)))
+	}
+	if args.IdemKey != "" {
+		opts = append(opts, grpc.WithUnaryInterceptor(withIdempotencyKey(args.IdemKey)))
+	}
+	conn, err := grpc.Dial(args.GRPCAddr, opts...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "did not connect: %v\n", err)
+		os.Exit(exitError)
+	}
+	defer conn.Close()
+
+	client := pb.NewMailingListServiceClient(conn)
+
+	if err := run(p, client, out); err != nil {
+		fmt.Fprintf(os.Stderr, "error: %v\n", err)
+		if errors.Is(err, errNotFound) || status.Code(err) == codes.NotFound {
+			os.Exit(exitNotFound)
+		}
+		os.Exit(exitError)
+	}
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	pb "github.com/IM-Deane/mailing-list/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// output formats accepted by --output
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+	outputJSONL = "jsonl"
+	outputYAML  = "yaml"
+)
+
+// field is a single named value of a record
+type field struct {
+	Name  string
+	Value interface{}
+}
+
+// record is an ordered set of fields, so every format prints columns in the same order
+type record []field
+
+// MarshalJSON encodes the record as an object, keeping the field order
+func (r record) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, f := range r {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, err := json.Marshal(f.Name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(f.Value)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// get returns the value of the named field
+func (r record) get(name string) (interface{}, bool) {
+	for _, f := range r {
+		if f.Name == name {
+			return f.Value, true
+		}
+	}
+	return nil, false
+}
+
+// printer renders records to stdout in the format chosen with --output
+type printer struct {
+	format  string
+	columns []string
+	out     io.Writer
+	// errOut gets what doesn't fit the format, like the next cursor of a JSONL list
+	errOut io.Writer
+}
+
+// newPrinter checks the output format and column names
+func newPrinter(format string, columns string) (*printer, error) {
+	switch format {
+	case outputTable, outputJSON, outputJSONL, outputYAML:
+	default:
+		return nil, fmt.Errorf("unknown output format %q, expected table, json, jsonl or yaml", format)
+	}
+
+	p := printer{format: format, out: os.Stdout, errOut: os.Stderr}
+	for _, column := range strings.Split(columns, ",") {
+		if column = strings.TrimSpace(column); column != "" {
+			p.columns = append(p.columns, column)
+		}
+	}
+
+	return &p, nil
+}
+
+// selectColumns keeps only the columns picked with --columns
+func (p *printer) selectColumns(records []record) ([]record, error) {
+	if len(p.columns) == 0 {
+		return records, nil
+	}
+
+	selected := make([]record, 0, len(records))
+	for _, r := range records {
+		var s record
+		for _, column := range p.columns {
+			value, ok := r.get(column)
+			if !ok {
+				return nil, fmt.Errorf("unknown column %q", column)
+			}
+			s = append(s, field{column, value})
+		}
+		selected = append(selected, s)
+	}
+
+	return selected, nil
+}
+
+// printOne renders a single record
+func (p *printer) printOne(r record) error {
+	records, err := p.selectColumns([]record{r})
+	if err != nil {
+		return err
+	}
+
+	switch p.format {
+	case outputJSON:
+		return p.writeJSON(records[0], "  ")
+	case outputJSONL:
+		return p.writeJSON(records[0], "")
+	case outputYAML:
+		writeYAML(p.out, records[0], "")
+		return nil
+	}
+
+	return p.writeTable(records)
+}
+
+// printList renders a collection under name, meta holds extra values like the next cursor
+func (p *printer) printList(name string, records []record, meta record) error {
+	records, err := p.selectColumns(records)
+	if err != nil {
+		return err
+	}
+
+	switch p.format {
+	case outputJSON:
+		return p.writeJSON(append(record{{name, records}}, meta...), "  ")
+	case outputJSONL:
+		for _, r := range records {
+			if err := p.writeJSON(r, ""); err != nil {
+				return err
+			}
+		}
+		// keep stdout one record per line
+		for _, f := range meta {
+			fmt.Fprintf(p.errOut, "%v: %v\n", f.Name, formatValue(f.Value))
+		}
+		return nil
+	case outputYAML:
+		writeYAML(p.out, append(record{{name, records}}, meta...), "")
+		return nil
+	}
+
+	if err := p.writeTable(records); err != nil {
+		return err
+	}
+	for _, f := range meta {
+		if r, ok := f.Value.(record); ok {
+			fmt.Fprintf(p.out, "\n%v:\n", f.Name)
+			p.writeTable([]record{r})
+			continue
+		}
+		fmt.Fprintf(p.out, "\n%v: %v\n", f.Name, formatValue(f.Value))
+	}
+
+	return nil
+}
+
+// writeJSON encodes v on its own line
+func (p *printer) writeJSON(v interface{}, indent string) error {
+	enc := json.NewEncoder(p.out)
+	enc.SetIndent("", indent)
+	return enc.Encode(v)
+}
+
+// writeTable prints records as aligned columns with a header row
+func (p *printer) writeTable(records []record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	w := tabwriter.NewWriter(p.out, 0, 0, 2, ' ', 0)
+	names := make([]string, len(records[0]))
+	for i, f := range records[0] {
+		names[i] = strings.ToUpper(f.Name)
+	}
+	fmt.Fprintln(w, strings.Join(names, "\t"))
+
+	for _, r := range records {
+		values := make([]string, len(r))
+		for i, f := range r {
+			values[i] = formatValue(f.Value)
+		}
+		fmt.Fprintln(w, strings.Join(values, "\t"))
+	}
+
+	return w.Flush()
+}
+
+// formatValue renders a value for table cells, nil shows as "-" and records as name=value pairs
+func formatValue(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "-"
+	case record:
+		if len(value) == 0 {
+			return "-"
+		}
+		pairs := make([]string, len(value))
+		for i, f := range value {
+			pairs[i] = f.Name + "=" + formatValue(f.Value)
+		}
+		return strings.Join(pairs, ",")
+	}
+	return fmt.Sprint(value)
+}
+
+// writeYAML writes a record as a YAML mapping, strings are double quoted so they never need escaping rules of their own
+func writeYAML(w io.Writer, r record, indent string) {
+	for _, f := range r {
+		switch value := f.Value.(type) {
+		case record:
+			// a bare "name:" would read back as null
+			if len(value) == 0 {
+				fmt.Fprintf(w, "%v%v: {}\n", indent, f.Name)
+				continue
+			}
+			fmt.Fprintf(w, "%v%v:\n", indent, f.Name)
+			writeYAML(w, value, indent+"  ")
+		case []record:
+			if len(value) == 0 {
+				fmt.Fprintf(w, "%v%v: []\n", indent, f.Name)
+				continue
+			}
+			fmt.Fprintf(w, "%v%v:\n", indent, f.Name)
+			for _, item := range value {
+				// first field goes on the "- " line, the rest line up under it
+				var buf bytes.Buffer
+				writeYAML(&buf, item, indent+"  ")
+				lines := strings.TrimPrefix(buf.String(), indent+"  ")
+				fmt.Fprintf(w, "%v- %v", indent, lines)
+			}
+		default:
+			fmt.Fprintf(w, "%v%v: %v\n", indent, f.Name, yamlScalar(value))
+		}
+	}
+}
+
+// yamlScalar renders a single YAML value
+func yamlScalar(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case string:
+		// a JSON string is a valid YAML double quoted scalar
+		quoted, _ := json.Marshal(value)
+		return string(quoted)
+	}
+	return fmt.Sprint(value)
+}
+
+// entryRecord converts an entry, confirmed_at is RFC 3339 or nil when unconfirmed
+func entryRecord(entry *pb.EmailEntry) record {
+	var confirmedAt interface{}
+	if entry.ConfirmedAt != 0 {
+		confirmedAt = time.Unix(entry.ConfirmedAt, 0).UTC().Format(time.RFC3339)
+	}
+
+	return record{
+		{"id", entry.Id},
+		{"email", entry.Email},
+		{"confirmed_at", confirmedAt},
+		{"opt_out", entry.OptOut},
+		{"domain_flag", entry.DomainFlag},
+		{"attributes", attributesRecord(entry.Attributes)},
+	}
+}
+
+// attributesRecord converts custom attributes, ordered by name
+func attributesRecord(attrs map[string]*structpb.Value) record {
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	r := record{}
+	for _, name := range names {
+		r = append(r, field{name, attrs[name].AsInterface()})
+	}
+	return r
+}
+
+// fieldRecord converts a custom field
+func fieldRecord(f *pb.Field) record {
+	return record{
+		{"id", f.Id},
+		{"name", f.Name},
+		{"type", f.Type},
+		{"description", f.Description},
+	}
+}
+
+// entryRecords converts a batch of entries
+func entryRecords(entries []*pb.EmailEntry) []record {
+	records := make([]record, 0, len(entries))
+	for _, entry := range entries {
+		records = append(records, entryRecord(entry))
+	}
+	return records
+}
+
+// listRecord converts a mailing list
+func listRecord(list *pb.MailingList) record {
+	return record{
+		{"id", list.Id},
+		{"name", list.Name},
+		{"domain_policy", list.DomainPolicy},
+	}
+}
+
+// importStatusName turns IMPORT_STATUS_ALREADY_EXISTS into already_exists
+func importStatusName(status pb.ImportStatus) string {
+	return strings.ToLower(strings.TrimPrefix(status.String(), "IMPORT_STATUS_"))
+}
+
+// importResultRecord converts the outcome of a single imported address
+func importResultRecord(result *pb.ImportEmailResult) record {
+	return record{
+		{"email", result.Email},
+		{"status", importStatusName(result.Status)},
+		{"reason", result.Reason},
+	}
+}
+
+// importSummaryRecord converts the import totals
+func importSummaryRecord(summary *pb.ImportSummary) record {
+	return record{
+		{"total", summary.Total},
+		{"created", summary.Created},
+		{"already_exists", summary.AlreadyExists},
+		{"invalid", summary.Invalid},
+		{"suppressed", summary.Suppressed},
+	}
+}
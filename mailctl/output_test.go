@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pb "github.com/IM-Deane/mailing-list/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// testEntries returns a confirmed entry with attributes and an unconfirmed one without
+func testEntries(t *testing.T) []*pb.EmailEntry {
+	attrs := map[string]interface{}{"first_name": "Ann \"Annie\"", "age": 31, "vip": true}
+	attributes := make(map[string]*structpb.Value, len(attrs))
+	for name, value := range attrs {
+		v, err := structpb.NewValue(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		attributes[name] = v
+	}
+
+	return []*pb.EmailEntry{
+		{Id: 1, Email: "ann@example.com", ConfirmedAt: 1704164645, Attributes: attributes},
+		{Id: 2, Email: "bob@example.org", OptOut: true, DomainFlag: "disposable domain"},
+	}
+}
+
+// checkGolden compares got with testdata/name, rewriting it with -update
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	file := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(file, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestGoldenOutput(t *testing.T) {
+	entries := testEntries(t)
+	results := []record{
+		importResultRecord(&pb.ImportEmailResult{Email: "new@example.com", Status: pb.ImportStatus_IMPORT_STATUS_CREATED}),
+		importResultRecord(&pb.ImportEmailResult{Email: "not an address", Status: pb.ImportStatus_IMPORT_STATUS_INVALID, Reason: "invalid email address"}),
+	}
+	summary := importSummaryRecord(&pb.ImportSummary{Total: 2, Created: 1, Invalid: 1})
+
+	tests := []struct {
+		name    string
+		columns string
+		print   func(p *printer) error
+	}{
+		{"get", "", func(p *printer) error {
+			return p.printOne(entryRecord(entries[0]))
+		}},
+		{"list", "", func(p *printer) error {
+			return p.printList("emails", entryRecords(entries), record{{"next_cursor", "abc"}})
+		}},
+		{"empty", "", func(p *printer) error {
+			return p.printList("emails", entryRecords(nil), nil)
+		}},
+		{"import", "", func(p *printer) error {
+			return p.printList("results", results, record{{"summary", summary}})
+		}},
+		{"columns", "email, attributes", func(p *printer) error {
+			return p.printList("emails", entryRecords(entries), nil)
+		}},
+	}
+	for _, format := range []string{outputTable, outputJSON, outputJSONL, outputYAML} {
+		for _, tt := range tests {
+			t.Run(format+"/"+tt.name, func(t *testing.T) {
+				p, err := newPrinter(format, tt.columns)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var out, errOut bytes.Buffer
+				p.out, p.errOut = &out, &errOut
+				if err := tt.print(p); err != nil {
+					t.Fatal(err)
+				}
+
+				// what goes to stderr is part of the output too
+				if errOut.Len() > 0 {
+					out.WriteString("--- stderr\n")
+					out.Write(errOut.Bytes())
+				}
+				checkGolden(t, tt.name+"."+format+".golden", out.Bytes())
+			})
+		}
+	}
+}
+
+func TestPrinterErrors(t *testing.T) {
+	if _, err := newPrinter("xml", ""); err == nil {
+		t.Error("created a printer for an unknown format")
+	}
+
+	p, err := newPrinter(outputTable, "email,nickname")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	p.out = &out
+	if err := p.printOne(entryRecord(testEntries(t)[0])); err == nil || out.Len() != 0 {
+		t.Errorf("got %v, %q, want an unknown column error and no output", err, out.String())
+	}
+}
@@ -0,0 +1,130 @@
+package mdb
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+)
+
+const (
+	// maxAddressLength is the longest address that fits in an SMTP path (RFC 5321)
+	maxAddressLength = 254
+	// maxLocalPartLength is the longest local part allowed by RFC 5321
+	maxLocalPartLength = 64
+)
+
+// ValidationError reports an email address that failed validation
+type ValidationError struct {
+	Email string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid email %q: %v", e.Email, e.Reason)
+}
+
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrInvalid
+}
+
+// Options changes how a store handles email addresses
+type Options struct {
+	// ProviderNormalization also folds provider specific aliases into the canonical address,
+	// e.g. gmail ignores dots and +tags so f.oo+news@gmail.com is stored as foo@gmail.com
+	ProviderNormalization bool
+}
+
+// provider describes how a mail provider treats aliases of the same mailbox
+type provider struct {
+	// domain the provider's other domains are folded into
+	domain string
+	ignoreDots bool
+	ignorePlusTag bool
+}
+
+// providers that deliver aliases to the same mailbox, indexed by domain
+var providers = map[string]provider{
+	"gmail.com": {domain: "gmail.com", ignoreDots: true, ignorePlusTag: true},
+	"googlemail.com": {domain: "gmail.com", ignoreDots: true, ignorePlusTag: true},
+	"outlook.com": {domain: "outlook.com", ignorePlusTag: true},
+	"hotmail.com": {domain: "hotmail.com", ignorePlusTag: true},
+	"live.com": {domain: "live.com", ignorePlusTag: true},
+	"icloud.com": {domain: "icloud.com", ignorePlusTag: true},
+	"fastmail.com": {domain: "fastmail.com", ignorePlusTag: true},
+}
+
+// NormalizeAddress checks that email is a bare RFC 5322 address and returns it
+// with surrounding whitespace trimmed and the domain lowercased
+func NormalizeAddress(email string) (string, error) {
+	trimmed := strings.TrimSpace(email)
+	if trimmed == "" {
+		return "", &ValidationError{Email: email, Reason: "address is required"}
+	}
+	if len(trimmed) > maxAddressLength {
+		return "", &ValidationError{Email: email, Reason: fmt.Sprintf("address is longer than %v characters", maxAddressLength)}
+	}
+
+	addr, err := mail.ParseAddress(trimmed)
+	if err != nil {
+		return "", &ValidationError{Email: email, Reason: strings.TrimPrefix(err.Error(), "mail: ")}
+	}
+	// ParseAddress also accepts display names like "Name <a@b.com>"
+	if addr.Address != trimmed {
+		return "", &ValidationError{Email: email, Reason: "expected a bare email address"}
+	}
+
+	at := strings.LastIndex(trimmed, "@")
+	local, domain := trimmed[:at], trimmed[at+1:]
+	if len(local) > maxLocalPartLength {
+		return "", &ValidationError{Email: email, Reason: fmt.Sprintf("local part is longer than %v characters", maxLocalPartLength)}
+	}
+	// valid syntax, but nobody outside our network can receive mail at a dotless domain
+	if !strings.Contains(domain, ".") || strings.HasPrefix(domain, "[") {
+		return "", &ValidationError{Email: email, Reason: "domain must be a fully qualified domain name"}
+	}
+
+	// domains are case-insensitive, the local part is left as the subscriber typed it
+	return local + "@" + strings.ToLower(domain), nil
+}
+
+// ValidateAddress checks that email is a valid bare email address
+func ValidateAddress(email string) error {
+	_, err := NormalizeAddress(email)
+	return err
+}
+
+// CanonicalAddress returns the form of a normalized address used for uniqueness.
+// Local parts are compared case-insensitively, as every major provider does.
+func CanonicalAddress(address string, opts Options) string {
+	canonical := strings.ToLower(address)
+	if !opts.ProviderNormalization {
+		return canonical
+	}
+
+	at := strings.LastIndex(canonical, "@")
+	local, domain := canonical[:at], canonical[at+1:]
+	p, ok := providers[domain]
+	if !ok {
+		return canonical
+	}
+
+	if p.ignorePlusTag {
+		if i := strings.Index(local, "+"); i > 0 {
+			local = local[:i]
+		}
+	}
+	if p.ignoreDots {
+		local = strings.ReplaceAll(local, ".", "")
+	}
+
+	return local + "@" + p.domain
+}
+
+// address normalizes email and returns it along with its canonical form
+func (o Options) address(email string) (string, string, error) {
+	address, err := NormalizeAddress(email)
+	if err != nil {
+		return "", "", err
+	}
+	return address, CanonicalAddress(address, o), nil
+}
@@ -0,0 +1,571 @@
+package mdb
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldType is the type of a custom field's values
+type FieldType string
+
+const (
+	// FieldString holds text, e.g. a first name
+	FieldString FieldType = "string"
+	// FieldNumber holds a number, stored as a float64
+	FieldNumber FieldType = "number"
+	// FieldBool holds true or false
+	FieldBool FieldType = "bool"
+	// FieldDate holds a calendar date written as YYYY-MM-DD
+	FieldDate FieldType = "date"
+)
+
+// dateLayout is how date values are written, it also sorts them as text
+const dateLayout = "2006-01-02"
+
+// fieldNamePattern matches field names, they're used in filters so they stay simple
+var fieldNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+
+// Validate checks that t is a known field type
+func (t FieldType) Validate() error {
+	switch t {
+	case FieldString, FieldNumber, FieldBool, FieldDate:
+		return nil
+	}
+	return invalidError(fmt.Sprintf("unknown field type %q, expected string, number, bool or date", string(t)))
+}
+
+// Field is a custom attribute subscribers can have, such as first_name or signup_source
+type Field struct {
+	ID int64
+	Name string
+	Type FieldType
+	Description string
+}
+
+// Validate checks the field's name and type
+func (f Field) Validate() error {
+	if !fieldNamePattern.MatchString(f.Name) {
+		return invalidError(fmt.Sprintf("invalid field name %q, expected lowercase letters, digits and underscores starting with a letter", f.Name))
+	}
+	return f.Type.Validate()
+}
+
+// Attributes are a subscriber's custom field values by field name. Values are a string,
+// float64 or bool, dates are YYYY-MM-DD strings. When updating, a nil value removes the
+// attribute and attributes that aren't listed are left unchanged.
+type Attributes map[string]interface{}
+
+// AttributeError reports an attribute value that doesn't match its field's type
+type AttributeError struct {
+	Field string
+	Reason string
+}
+
+func (e *AttributeError) Error() string {
+	return fmt.Sprintf("invalid attribute %q: %v", e.Field, e.Reason)
+}
+
+func (e *AttributeError) Is(target error) bool {
+	return target == ErrInvalid
+}
+
+// encode converts value to the text it's stored as. Strings are also accepted for
+// numbers, bools and dates so values can come from CSV files and command lines.
+func (f Field) encode(value interface{}) (string, error) {
+	invalid := func(format string, args ...interface{}) (string, error) {
+		return "", &AttributeError{Field: f.Name, Reason: fmt.Sprintf(format, args...)}
+	}
+
+	s, isString := value.(string)
+	switch f.Type {
+	case FieldString:
+		if !isString {
+			return invalid("expected a string")
+		}
+		return s, nil
+	case FieldNumber:
+		n, ok := value.(float64)
+		if isString {
+			var err error
+			n, err = strconv.ParseFloat(strings.TrimSpace(s), 64)
+			ok = err == nil
+		}
+		if !ok {
+			return invalid("expected a number")
+		}
+		return strconv.FormatFloat(n, 'g', -1, 64), nil
+	case FieldBool:
+		b, ok := value.(bool)
+		if isString {
+			var err error
+			b, err = strconv.ParseBool(strings.TrimSpace(s))
+			ok = err == nil
+		}
+		if !ok {
+			return invalid("expected true or false")
+		}
+		return strconv.FormatBool(b), nil
+	case FieldDate:
+		if !isString {
+			return invalid("expected a date as YYYY-MM-DD")
+		}
+		t, err := time.Parse(dateLayout, strings.TrimSpace(s))
+		if err != nil {
+			return invalid("expected a date as YYYY-MM-DD")
+		}
+		return t.Format(dateLayout), nil
+	}
+	return invalid("unknown field type %q", string(f.Type))
+}
+
+// decode converts a stored value back to its type
+func (f Field) decode(stored string) interface{} {
+	switch f.Type {
+	case FieldNumber:
+		if n, err := strconv.ParseFloat(stored, 64); err == nil {
+			return n
+		}
+	case FieldBool:
+		if b, err := strconv.ParseBool(stored); err == nil {
+			return b
+		}
+	}
+	return stored
+}
+
+// attributeValue is an encoded attribute ready to be stored, a nil value removes it
+type attributeValue struct {
+	field Field
+	value *string
+}
+
+// encodeAttributes checks attrs against the defined fields and encodes them for storage
+func encodeAttributes(fields []Field, attrs Attributes) ([]attributeValue, error) {
+	byName := make(map[string]Field, len(fields))
+	for _, field := range fields {
+		byName[field.Name] = field
+	}
+
+	// sorted so the first invalid attribute reported doesn't depend on map order
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	values := make([]attributeValue, 0, len(attrs))
+	for _, name := range names {
+		field, ok := byName[name]
+		if !ok {
+			return nil, &AttributeError{Field: name, Reason: "no field with this name is defined"}
+		}
+		if attrs[name] == nil {
+			values = append(values, attributeValue{field: field})
+			continue
+		}
+		encoded, err := field.encode(attrs[name])
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, attributeValue{field: field, value: &encoded})
+	}
+	return values, nil
+}
+
+// CheckAttributes reports the first attribute in attrs that isn't a defined field
+// or doesn't match its field's type, so callers can check before changing anything
+func CheckAttributes(fields []Field, attrs Attributes) error {
+	_, err := encodeAttributes(fields, attrs)
+	return err
+}
+
+// filter operators, two character ones first so they're matched before their prefixes
+var filterOps = []string{"!=", "<=", ">=", "=", "<", ">"}
+
+// AttributeFilter selects subscribers by an attribute, e.g. {"age", ">=", "18"}.
+// Every operator works on numbers and dates, strings and bools only support = and !=.
+// != also matches subscribers without the attribute.
+type AttributeFilter struct {
+	Field string
+	Op string
+	Value string
+}
+
+// String writes the filter back in the form ParseFilter reads
+func (f AttributeFilter) String() string {
+	return f.Field + f.Op + f.Value
+}
+
+// ParseFilter parses a filter such as "locale=en" or "signed_up>=2024-01-01"
+func ParseFilter(s string) (AttributeFilter, error) {
+	for i := 0; i < len(s); i++ {
+		for _, op := range filterOps {
+			if strings.HasPrefix(s[i:], op) {
+				f := AttributeFilter{Field: strings.TrimSpace(s[:i]), Op: op, Value: strings.TrimSpace(s[i+len(op):])}
+				if f.Field == "" {
+					break
+				}
+				return f, nil
+			}
+		}
+	}
+	return AttributeFilter{}, invalidError(fmt.Sprintf("invalid filter %q, expected <field><op><value> with op one of = != < <= > >=", s))
+}
+
+// ParseFilters parses filters with ParseFilter
+func ParseFilters(specs []string) ([]AttributeFilter, error) {
+	filters := make([]AttributeFilter, 0, len(specs))
+	for _, spec := range specs {
+		f, err := ParseFilter(spec)
+		if err != nil {
+			return nil, err
+		}
+		filters = append(filters, f)
+	}
+	return filters, nil
+}
+
+// resolvedFilter is a filter checked against its field, with the value encoded like stored values
+type resolvedFilter struct {
+	field Field
+	op string
+	value string
+}
+
+// resolveFilters checks filters against the defined fields
+func resolveFilters(fields []Field, filters []AttributeFilter) ([]resolvedFilter, error) {
+	byName := make(map[string]Field, len(fields))
+	for _, field := range fields {
+		byName[field.Name] = field
+	}
+
+	resolved := make([]resolvedFilter, 0, len(filters))
+	for _, f := range filters {
+		field, ok := byName[f.Field]
+		if !ok {
+			return nil, invalidError(fmt.Sprintf("can't filter by %v, no field with this name is defined", f.Field))
+		}
+		if !containsString(filterOps, f.Op) {
+			return nil, invalidError(fmt.Sprintf("unknown filter operator %q", f.Op))
+		}
+		if f.Op != "=" && f.Op != "!=" && field.Type != FieldNumber && field.Type != FieldDate {
+			return nil, invalidError(fmt.Sprintf("field %v is a %v, only = and != can filter it", field.Name, field.Type))
+		}
+		value, err := field.encode(f.Value)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, resolvedFilter{field: field, op: f.Op, value: value})
+	}
+	return resolved, nil
+}
+
+// containsString reports whether s is one of values
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// matches reports whether stored, the encoded value or nil if missing, passes the filter
+func (f resolvedFilter) matches(stored *string) bool {
+	if stored == nil {
+		return f.op == "!="
+	}
+
+	cmp := strings.Compare(*stored, f.value)
+	if f.field.Type == FieldNumber {
+		a, _ := strconv.ParseFloat(*stored, 64)
+		b, _ := strconv.ParseFloat(f.value, 64)
+		switch {
+		case a < b:
+			cmp = -1
+		case a > b:
+			cmp = 1
+		default:
+			cmp = 0
+		}
+	}
+
+	switch f.op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	}
+	return cmp >= 0
+}
+
+// condition is the filter as a WHERE condition on emails, with its args
+func (f resolvedFilter) condition() (string, []interface{}) {
+	if f.op == "!=" {
+		// subscribers without the attribute don't equal the value either
+		return `NOT EXISTS (
+			SELECT 1 FROM email_attributes
+			WHERE email_attributes.email_id = emails.id AND email_attributes.field_id = ? AND email_attributes.value = ?)`,
+			[]interface{}{f.field.ID, f.value}
+	}
+
+	value := "email_attributes.value"
+	var arg interface{} = f.value
+	if f.field.Type == FieldNumber {
+		// numbers are stored as text, compare them as numbers
+		value = "CAST(email_attributes.value AS DOUBLE PRECISION)"
+		arg, _ = strconv.ParseFloat(f.value, 64)
+	}
+	return `EXISTS (
+			SELECT 1 FROM email_attributes
+			WHERE email_attributes.email_id = emails.id AND email_attributes.field_id = ? AND ` + value + " " + f.op + " ?)",
+		[]interface{}{f.field.ID, arg}
+}
+
+// CreateField adds a custom field subscribers can have values for
+func (s *SQLStore) CreateField(field Field) error {
+	if err := field.Validate(); err != nil {
+		return err
+	}
+
+	_, err := s.exec(`
+		INSERT INTO
+			fields(name, type, description)
+		VALUES
+			(?, ?, ?)`, field.Name, string(field.Type), field.Description)
+	if s.dialect.isUniqueViolation(err) {
+		return newError(ErrAlreadyExists, "field", field.Name, "field %v already exists", field.Name)
+	}
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+// GetFields fetches every custom field ordered by creation
+func (s *SQLStore) GetFields() ([]Field, error) {
+	rows, err := s.query(`
+		SELECT
+			id, name, type, description
+		FROM
+			fields
+		ORDER BY id ASC`)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	// close DB connection on error or end of func
+	defer rows.Close()
+
+	fields := make([]Field, 0)
+	for rows.Next() {
+		var field Field
+		if err := rows.Scan(&field.ID, &field.Name, &field.Type, &field.Description); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		fields = append(fields, field)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return fields, nil
+}
+
+// SetAttributes changes an existing email's attributes, see Attributes
+func (s *SQLStore) SetAttributes(email string, attrs Attributes) error {
+	address, canonical, err := s.opts.address(email)
+	if err != nil {
+		return err
+	}
+	values, err := s.encodeAttributes(attrs)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	// no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	emailID, err := s.emailID(tx, canonical)
+	if err == sql.ErrNoRows {
+		return EmailNotFound(address)
+	} else if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	if err := s.writeAttributes(tx, emailID, values); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// encodeAttributes checks attrs against the stored fields, skipping the lookup when there are none
+func (s *SQLStore) encodeAttributes(attrs Attributes) ([]attributeValue, error) {
+	if len(attrs) == 0 {
+		return nil, nil
+	}
+	fields, err := s.GetFields()
+	if err != nil {
+		return nil, err
+	}
+	return encodeAttributes(fields, attrs)
+}
+
+// emailID looks up the id of the email with a canonical address inside tx
+func (s *SQLStore) emailID(tx *sql.Tx, canonical string) (int64, error) {
+	var id int64
+	err := tx.QueryRow(s.dialect.rebind(`SELECT id FROM emails WHERE canonical = ?`), canonical).Scan(&id)
+	return id, err
+}
+
+// writeAttributes stores or removes an email's attribute values inside tx
+func (s *SQLStore) writeAttributes(tx *sql.Tx, emailID int64, values []attributeValue) error {
+	for _, v := range values {
+		var err error
+		if v.value == nil {
+			_, err = s.txExec(tx, `
+				DELETE FROM email_attributes
+				WHERE email_id = ? AND field_id = ?`, emailID, v.field.ID)
+		} else {
+			_, err = s.txExec(tx, `
+				INSERT INTO
+					email_attributes(email_id, field_id, value)
+				VALUES
+					(?, ?, ?)
+				ON CONFLICT(email_id, field_id) DO UPDATE SET
+					value=excluded.value`, emailID, v.field.ID, *v.value)
+		}
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+	}
+	return nil
+}
+
+// loadAttributes fills in the attributes of entries with a single query
+func (s *SQLStore) loadAttributes(entries []EmailEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	placeholders := make([]string, len(entries))
+	args := make([]interface{}, len(entries))
+	index := make(map[int64]int, len(entries))
+	for i, entry := range entries {
+		placeholders[i] = "?"
+		args[i] = entry.ID
+		index[entry.ID] = i
+	}
+
+	rows, err := s.query(`
+		SELECT
+			email_attributes.email_id, fields.name, fields.type, email_attributes.value
+		FROM
+			email_attributes
+			JOIN fields ON fields.id = email_attributes.field_id
+		WHERE
+			email_attributes.email_id IN (`+strings.Join(placeholders, ", ")+`)`, args...)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	// close DB connection on error or end of func
+	defer rows.Close()
+
+	for rows.Next() {
+		var emailID int64
+		var field Field
+		var value string
+		if err := rows.Scan(&emailID, &field.Name, &field.Type, &value); err != nil {
+			log.Println(err)
+			return err
+		}
+		entry := &entries[index[emailID]]
+		if entry.Attributes == nil {
+			entry.Attributes = Attributes{}
+		}
+		entry.Attributes[field.Name] = field.decode(value)
+	}
+
+	return rows.Err()
+}
+
+// filterConditions turns filters into WHERE conditions on emails for batchQuery
+func (s *SQLStore) filterConditions(filters []AttributeFilter) ([]string, []interface{}, error) {
+	if len(filters) == 0 {
+		return nil, nil, nil
+	}
+	fields, err := s.GetFields()
+	if err != nil {
+		return nil, nil, err
+	}
+	resolved, err := resolveFilters(fields, filters)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	conditions := make([]string, 0, len(resolved))
+	var args []interface{}
+	for _, f := range resolved {
+		condition, conditionArgs := f.condition()
+		conditions = append(conditions, condition)
+		args = append(args, conditionArgs...)
+	}
+	return conditions, args, nil
+}
+
+// CreateWithAttributes adds email to list, or to the global email list if list is empty, with
+// its attributes from fields. They're checked first so an invalid one doesn't leave the email without them.
+func CreateWithAttributes(store EmailStore, fields FieldStore, list string, email string, attrs Attributes) error {
+	if len(attrs) > 0 {
+		defined, err := fields.GetFields()
+		if err != nil {
+			return err
+		}
+		if err := CheckAttributes(defined, attrs); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	if list != "" {
+		err = store.Subscribe(list, email)
+	} else {
+		err = store.CreateEmail(email)
+	}
+	if err != nil {
+		return err
+	}
+
+	if len(attrs) == 0 {
+		return nil
+	}
+	return fields.SetAttributes(email, attrs)
+}
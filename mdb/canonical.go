@@ -0,0 +1,271 @@
+package mdb
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+)
+
+// canonicalModeSetting is the settings row recording how emails.canonical was computed
+const canonicalModeSetting = "canonical_mode"
+
+// canonicalMode names how o computes canonical addresses, see CanonicalAddress
+func (o Options) canonicalMode() string {
+	if o.ProviderNormalization {
+		return "provider"
+	}
+	return "case"
+}
+
+// DuplicateGroup is a set of emails that shared a canonical address. They're merged into
+// the oldest one, the first in IDs, and the others are deleted: see mergeDuplicates.
+type DuplicateGroup struct {
+	Canonical string
+	IDs []int64
+	Emails []string
+}
+
+// emailState is the confirmation and opt out state of an email or subscription
+type emailState struct {
+	confirmedAt int64
+	optOut bool
+}
+
+// merge combines two states, keeping the latest confirmation and any opt out so a
+// merged subscriber is never emailed after opting out with one of their addresses
+func (e emailState) merge(other emailState) emailState {
+	if other.confirmedAt > e.confirmedAt {
+		e.confirmedAt = other.confirmedAt
+	}
+	e.optOut = e.optOut || other.optOut
+	return e
+}
+
+// syncCanonical recomputes emails.canonical when the store's options differ from the ones
+// it was computed with, returning the groups of emails that shared an address and were merged
+func (s *SQLStore) syncCanonical() ([]DuplicateGroup, error) {
+	var mode string
+	err := s.db.QueryRow(s.dialect.rebind(`SELECT value FROM settings WHERE name = ?`), canonicalModeSetting).Scan(&mode)
+	if err != nil && err != sql.ErrNoRows {
+		log.Println(err)
+		return nil, err
+	}
+	if mode == s.opts.canonicalMode() {
+		return nil, nil
+	}
+
+	// no mode yet means the canonical values were only backfilled by the migration
+	if mode != "" {
+		log.Printf("canonical addresses were computed with %v normalization, recomputing them with %v normalization\n", mode, s.opts.canonicalMode())
+	}
+	return s.recanonicalize()
+}
+
+// canonicalOf returns the canonical form of a stored email. Rows written before addresses
+// were validated may not parse, those only have their case folded.
+func (s *SQLStore) canonicalOf(email string) string {
+	_, canonical, err := s.opts.address(email)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(email))
+	}
+	return canonical
+}
+
+// recanonicalize recomputes every canonical address, merges the emails that share one and
+// records the mode, in one transaction
+func (s *SQLStore) recanonicalize() ([]DuplicateGroup, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	// no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`SELECT id, email, confirmed_at, opt_out FROM emails ORDER BY id`)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	// groups in the order of their oldest email, rows are read in id order
+	var groups []*DuplicateGroup
+	byCanonical := make(map[string]*DuplicateGroup)
+	states := make(map[int64]emailState)
+	for rows.Next() {
+		var id int64
+		var email string
+		var state emailState
+		if err := rows.Scan(&id, &email, &state.confirmedAt, &state.optOut); err != nil {
+			rows.Close()
+			log.Println(err)
+			return nil, err
+		}
+		states[id] = state
+		canonical := s.canonicalOf(email)
+		group, ok := byCanonical[canonical]
+		if !ok {
+			group = &DuplicateGroup{Canonical: canonical}
+			byCanonical[canonical] = group
+			groups = append(groups, group)
+		}
+		group.IDs = append(group.IDs, id)
+		group.Emails = append(group.Emails, email)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	// clear every value first so rows can swap canonical addresses without a unique violation
+	if _, err := s.txExec(tx, `UPDATE emails SET canonical = NULL`); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	var duplicates []DuplicateGroup
+	for _, group := range groups {
+		if _, err := s.txExec(tx, `UPDATE emails SET canonical = ? WHERE id = ?`, group.Canonical, group.IDs[0]); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		if len(group.IDs) > 1 {
+			if err := s.mergeDuplicates(tx, *group, states); err != nil {
+				return nil, err
+			}
+			duplicates = append(duplicates, *group)
+		}
+	}
+
+	_, err = s.txExec(tx, `
+		INSERT INTO
+			settings(name, value)
+		VALUES
+			(?, ?)
+		ON CONFLICT(name) DO UPDATE SET
+			value = excluded.value`, canonicalModeSetting, s.opts.canonicalMode())
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return duplicates, nil
+}
+
+// mergeDuplicates merges the emails of group into its oldest one and deletes the others, so
+// none are left that can't be looked up by address. The oldest email keeps its address and
+// domain flag and takes the merged state of the group, subscriptions to the same list are
+// merged the same way, and attributes it doesn't have are taken from the next oldest email
+// that has them.
+func (s *SQLStore) mergeDuplicates(tx *sql.Tx, group DuplicateGroup, states map[int64]emailState) error {
+	keep := group.IDs[0]
+
+	state := states[keep]
+	subscriptions := make(map[int64]emailState)
+	// list ids in the order they were first seen, so the writes are repeatable
+	var lists []int64
+	for _, id := range group.IDs {
+		state = state.merge(states[id])
+
+		subs, err := s.subscriptionStates(tx, id)
+		if err != nil {
+			return err
+		}
+		for listID, sub := range subs {
+			existing, ok := subscriptions[listID]
+			if !ok {
+				lists = append(lists, listID)
+				existing = sub
+			}
+			subscriptions[listID] = existing.merge(sub)
+		}
+	}
+
+	if _, err := s.txExec(tx, `UPDATE emails SET confirmed_at = ?, opt_out = ? WHERE id = ?`, state.confirmedAt, state.optOut, keep); err != nil {
+		log.Println(err)
+		return err
+	}
+
+	for _, id := range group.IDs[1:] {
+		// the oldest email's values win, then the next oldest's and so on
+		_, err := s.txExec(tx, `
+			INSERT INTO
+				email_attributes(email_id, field_id, value)
+			SELECT
+				CAST(? AS BIGINT), field_id, value
+			FROM
+				email_attributes
+			WHERE
+				email_id = ?
+			ON CONFLICT(email_id, field_id) DO NOTHING`, keep, id)
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+
+		for _, query := range []string{
+			`DELETE FROM email_attributes WHERE email_id = ?`,
+			`DELETE FROM subscriptions WHERE email_id = ?`,
+			`DELETE FROM emails WHERE id = ?`,
+		} {
+			if _, err := s.txExec(tx, query, id); err != nil {
+				log.Println(err)
+				return err
+			}
+		}
+	}
+
+	for _, listID := range lists {
+		sub := subscriptions[listID]
+		_, err := s.txExec(tx, `
+			INSERT INTO
+				subscriptions(list_id, email_id, confirmed_at, opt_out)
+			VALUES
+				(?, ?, ?, ?)
+			ON CONFLICT(list_id, email_id) DO UPDATE SET
+				confirmed_at=excluded.confirmed_at,
+				opt_out=excluded.opt_out`, listID, keep, sub.confirmedAt, sub.optOut)
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// subscriptionStates reads the state of every subscription of an email by list id
+func (s *SQLStore) subscriptionStates(tx *sql.Tx, emailID int64) (map[int64]emailState, error) {
+	rows, err := tx.Query(s.dialect.rebind(`SELECT list_id, confirmed_at, opt_out FROM subscriptions WHERE email_id = ?`), emailID)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	// close DB connection on error or end of func
+	defer rows.Close()
+
+	states := make(map[int64]emailState)
+	for rows.Next() {
+		var listID int64
+		var state emailState
+		if err := rows.Scan(&listID, &state.confirmedAt, &state.optOut); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		states[listID] = state
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return states, nil
+}
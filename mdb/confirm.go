@@ -0,0 +1,44 @@
+package mdb
+
+import (
+	"time"
+)
+
+// Confirm stamps confirmed_at on an email, or on its subscription to list if list isn't empty.
+// Entries that are already confirmed keep their original timestamp.
+func Confirm(store EmailStore, list string, email string, at time.Time) (*EmailEntry, error) {
+	var entry *EmailEntry
+	var err error
+	if list != "" {
+		entry, err = store.GetSubscription(list, email)
+	} else {
+		entry, err = store.GetEmail(email)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, EmailNotFound(email)
+	}
+
+	if entry.ConfirmedAt != nil && entry.ConfirmedAt.Unix() > 0 {
+		return entry, nil
+	}
+
+	// stored with second precision, match it so the returned entry is accurate
+	t := time.Unix(at.Unix(), 0)
+	entry.ConfirmedAt = &t
+	// the attributes haven't changed, leave them out so they aren't written again
+	update := *entry
+	update.Attributes = nil
+	if list != "" {
+		err = store.UpdateSubscription(list, update)
+	} else {
+		err = store.UpdateEmail(update)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return entry, nil
+}
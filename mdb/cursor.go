@@ -0,0 +1,75 @@
+package mdb
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MaxBatchCount is the most emails a single batch query can ask for
+const MaxBatchCount = 1000
+
+// ErrInvalidCursor is returned when a batch cursor wasn't produced by EncodeCursor
+var ErrInvalidCursor error = invalidError("invalid cursor")
+
+// EncodeCursor makes an opaque cursor that continues after the entry with the given id
+func EncodeCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("id:" + strconv.FormatInt(id, 10)))
+}
+
+// DecodeCursor returns the id a cursor continues after. An empty cursor starts from the beginning.
+func DecodeCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, ErrInvalidCursor
+	}
+	if !strings.HasPrefix(string(data), "id:") {
+		return 0, ErrInvalidCursor
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(string(data), "id:"), 10, 64)
+	if err != nil || id < 0 {
+		return 0, ErrInvalidCursor
+	}
+
+	return id, nil
+}
+
+// NextCursor returns the cursor for the page after entries,
+// or an empty string when entries is the last page
+func NextCursor(entries []EmailEntry, params GetEmailBatchQueryParams) string {
+	if len(entries) == 0 || len(entries) < params.Count {
+		return ""
+	}
+	return EncodeCursor(entries[len(entries)-1].ID)
+}
+
+// UsesCursor reports whether params use keyset pagination.
+// Setting Page selects the older page/count pagination instead.
+func (p GetEmailBatchQueryParams) UsesCursor() bool {
+	return p.Page == 0
+}
+
+// Validate checks that params describe a valid batch query
+func (p GetEmailBatchQueryParams) Validate() error {
+	if p.Count <= 0 {
+		return invalidError("count must be > 0")
+	}
+	if p.Count > MaxBatchCount {
+		return invalidError(fmt.Sprintf("count must be at most %v", MaxBatchCount))
+	}
+	if p.Page < 0 {
+		return invalidError("page must be > 0 when set")
+	}
+	if p.Page > 0 && p.Cursor != "" {
+		return invalidError("cursor can't be combined with page")
+	}
+	if _, err := DecodeCursor(p.Cursor); err != nil {
+		return err
+	}
+	return nil
+}
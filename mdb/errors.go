@@ -0,0 +1,62 @@
+package mdb
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Kinds of errors returned by stores, check for them with errors.Is
+var (
+	// ErrNotFound means the email or list doesn't exist
+	ErrNotFound = errors.New("not found")
+	// ErrAlreadyExists means the email or list is already stored
+	ErrAlreadyExists = errors.New("already exists")
+	// ErrInvalid means the request itself is invalid, e.g. a malformed address or cursor
+	ErrInvalid = errors.New("invalid")
+)
+
+// Error is an error of a known kind about a single email or list
+type Error struct {
+	// Kind is ErrNotFound, ErrAlreadyExists or ErrInvalid
+	Kind error
+	// Resource is "email" or "list"
+	Resource string
+	// Name is the email address or list name
+	Name string
+	msg string
+}
+
+func (e *Error) Error() string {
+	return e.msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Kind
+}
+
+// newError creates an error of kind about the named resource
+func newError(kind error, resource string, name string, format string, args ...interface{}) *Error {
+	return &Error{Kind: kind, Resource: resource, Name: name, msg: fmt.Sprintf(format, args...)}
+}
+
+// EmailNotFound is returned when an email that has to exist doesn't
+func EmailNotFound(email string) error {
+	return newError(ErrNotFound, "email", email, "email %v not found", email)
+}
+
+// listNotFound is returned when an operation refers to a missing list
+func listNotFound(list string) error {
+	return newError(ErrNotFound, "list", list, "list %v does not exist", list)
+}
+
+// invalidError is an ErrInvalid with its own message, for invalid requests
+// that aren't about a single email or list
+type invalidError string
+
+func (e invalidError) Error() string {
+	return string(e)
+}
+
+func (e invalidError) Is(target error) bool {
+	return target == ErrInvalid
+}
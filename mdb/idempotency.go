@@ -0,0 +1,112 @@
+package mdb
+
+import (
+	"log"
+	"time"
+)
+
+// IdempotencyRecord is the first response to a request sent with an idempotency key
+type IdempotencyRecord struct {
+	// Key is the idempotency key, scoped by the caller so clients can't see each other's responses
+	Key string
+	// Fingerprint identifies the request the key was first used with
+	Fingerprint string
+	// Response is nil while the first request is still being handled
+	Response []byte
+	CreatedAt time.Time
+}
+
+// ReserveIdempotencyKey records that the request for record.Key is being handled. If the key
+// was already used after expiredBefore nothing is recorded and the existing record is returned.
+func (s *SQLStore) ReserveIdempotencyKey(record IdempotencyRecord, expiredBefore time.Time) (*IdempotencyRecord, error) {
+	// an expired key can be used again
+	_, err := s.exec(`
+		DELETE FROM idempotency_keys
+		WHERE idempotency_key = ? AND created_at < ?`, record.Key, expiredBefore.Unix())
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	_, err = s.exec(`
+		INSERT INTO
+			idempotency_keys(idempotency_key, fingerprint, created_at)
+		VALUES
+			(?, ?, ?)`, record.Key, record.Fingerprint, record.CreatedAt.Unix())
+	if err == nil {
+		return nil, nil
+	}
+	if !s.dialect.isUniqueViolation(err) {
+		log.Println(err)
+		return nil, err
+	}
+
+	rows, err := s.query(`
+		SELECT
+			idempotency_key, fingerprint, response, created_at
+		FROM
+			idempotency_keys
+		WHERE
+			idempotency_key = ?`, record.Key)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	// close DB connection if any error occurs
+	defer rows.Close()
+
+	for rows.Next() {
+		var existing IdempotencyRecord
+		var createdAt int64
+		if err := rows.Scan(&existing.Key, &existing.Fingerprint, &existing.Response, &createdAt); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		existing.CreatedAt = time.Unix(createdAt, 0)
+		return &existing, nil
+	}
+
+	// released between the insert and the select, the client can retry
+	return nil, newError(ErrAlreadyExists, "idempotency_key", record.Key, "idempotency key %v is in use", record.Key)
+}
+
+// CompleteIdempotencyKey stores the response to replay for a reserved key
+func (s *SQLStore) CompleteIdempotencyKey(key string, response []byte) error {
+	_, err := s.exec(`
+		UPDATE idempotency_keys
+		SET response = ?
+		WHERE idempotency_key = ?`, response, key)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+// ReleaseIdempotencyKey forgets a reserved key, e.g. when its request failed in a way worth retrying
+func (s *SQLStore) ReleaseIdempotencyKey(key string) error {
+	_, err := s.exec(`
+		DELETE FROM idempotency_keys
+		WHERE idempotency_key = ?`, key)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+// DeleteExpiredIdempotencyKeys forgets keys created before expiredBefore, returning how many were deleted
+func (s *SQLStore) DeleteExpiredIdempotencyKeys(expiredBefore time.Time) (int64, error) {
+	res, err := s.exec(`
+		DELETE FROM idempotency_keys
+		WHERE created_at < ?`, expiredBefore.Unix())
+	if err != nil {
+		log.Println(err)
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,235 @@
+package mdb
+
+import (
+	"errors"
+	"log"
+)
+
+// ImportStatus is the outcome of importing a single address
+type ImportStatus string
+
+const (
+	// ImportCreated means the address was added
+	ImportCreated ImportStatus = "created"
+	// ImportAlreadyExists means the address was already on the list and was left unchanged
+	ImportAlreadyExists ImportStatus = "already_exists"
+	// ImportInvalid means the address isn't a valid email address or one of its attributes is invalid
+	ImportInvalid ImportStatus = "invalid"
+	// ImportSuppressed means the address previously opted out, so it wasn't re-added
+	ImportSuppressed ImportStatus = "suppressed"
+)
+
+// ImportResult reports what happened to one imported address
+type ImportResult struct {
+	Email string
+	Status ImportStatus
+	// Reason explains invalid results
+	Reason string
+}
+
+// importReason is the reason reported for an invalid address
+func importReason(err error) string {
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		return validationErr.Reason
+	}
+	return err.Error()
+}
+
+// ImportEmails adds entries to the global email list, or to list if it isn't empty, in a single transaction.
+// Existing addresses are left unchanged and addresses that opted out are never re-added.
+// New addresses are stored with their attributes.
+func (s *SQLStore) ImportEmails(list string, entries []EmailEntry) ([]ImportResult, error) {
+	// fields are only looked up when an entry has attributes
+	var fields []Field
+	for _, entry := range entries {
+		if len(entry.Attributes) > 0 {
+			var err error
+			if fields, err = s.GetFields(); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	// no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	var listID int64
+	if list != "" {
+		row := tx.QueryRow(s.dialect.rebind(`SELECT id FROM lists WHERE name = ?`), list)
+		if err := row.Scan(&listID); err != nil {
+			log.Println(err)
+			return nil, listNotFound(list)
+		}
+	}
+
+	results := make([]ImportResult, 0, len(entries))
+	for _, entry := range entries {
+		result := ImportResult{Email: entry.Email}
+		address, canonical, err := s.opts.address(entry.Email)
+		var values []attributeValue
+		if err == nil {
+			values, err = encodeAttributes(fields, entry.Attributes)
+		}
+		if err != nil {
+			result.Status = ImportInvalid
+			result.Reason = importReason(err)
+			results = append(results, result)
+			continue
+		}
+
+		confirmedAt := int64(0)
+		if entry.ConfirmedAt != nil {
+			confirmedAt = entry.ConfirmedAt.Unix()
+		}
+
+		// look up existing state, on the list's subscription or the global email list
+		query := `SELECT opt_out FROM emails WHERE canonical = ?`
+		args := []interface{}{canonical}
+		if list != "" {
+			query = `
+				SELECT subscriptions.opt_out
+				FROM subscriptions JOIN emails ON emails.id = subscriptions.email_id
+				WHERE subscriptions.list_id = ? AND emails.canonical = ?`
+			args = []interface{}{listID, canonical}
+		}
+		rows, err := tx.Query(s.dialect.rebind(query), args...)
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		exists, optOut := false, false
+		if rows.Next() {
+			exists = true
+			err = rows.Scan(&optOut)
+		}
+		rows.Close()
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+
+		switch {
+		case exists && optOut:
+			result.Status = ImportSuppressed
+		case exists:
+			result.Status = ImportAlreadyExists
+		case list == "":
+			_, err = s.txExec(tx, `
+				INSERT INTO
+					emails(email, canonical, confirmed_at, opt_out)
+				VALUES
+					(?, ?, ?, ?)`, address, canonical, confirmedAt, entry.OptOut)
+			result.Status = ImportCreated
+		default:
+			_, err = s.txExec(tx, `
+				INSERT INTO
+					emails(email, canonical, confirmed_at, opt_out)
+				VALUES
+					(?, ?, 0, false)
+				ON CONFLICT(canonical) DO NOTHING`, address, canonical)
+			if err == nil {
+				_, err = s.txExec(tx, `
+					INSERT INTO
+						subscriptions(list_id, email_id, confirmed_at, opt_out)
+					SELECT
+						?, id, ?, ?
+					FROM
+						emails
+					WHERE
+						canonical = ?`, listID, confirmedAt, entry.OptOut, canonical)
+			}
+			result.Status = ImportCreated
+		}
+		if err == nil && result.Status == ImportCreated && len(values) > 0 {
+			var emailID int64
+			if emailID, err = s.emailID(tx, canonical); err == nil {
+				err = s.writeAttributes(tx, emailID, values)
+			}
+		}
+		if err != nil {
+			log.Println(err)
+			return nil, err
+		}
+
+		results = append(results, result)
+	}
+
+	if err := tx.Commit(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// ImportEmails adds entries to the global email list, or to list if it isn't empty.
+// Existing addresses are left unchanged and addresses that opted out are never re-added.
+// New addresses are stored with their attributes.
+func (m *MemoryStore) ImportEmails(list string, entries []EmailEntry) ([]ImportResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var subs map[string]EmailEntry
+	if list != "" {
+		l, ok := m.lists[list]
+		if !ok {
+			return nil, listNotFound(list)
+		}
+		subs = m.subscriptions[l.ID]
+	}
+
+	results := make([]ImportResult, 0, len(entries))
+	for _, entry := range entries {
+		result := ImportResult{Email: entry.Email}
+		address, canonical, err := m.opts.address(entry.Email)
+		var values []attributeValue
+		if err == nil {
+			values, err = encodeAttributes(m.fieldList(), entry.Attributes)
+		}
+		if err != nil {
+			result.Status = ImportInvalid
+			result.Reason = importReason(err)
+			results = append(results, result)
+			continue
+		}
+
+		existing, exists := m.emails[canonical]
+		if list != "" {
+			existing, exists = subs[canonical]
+		}
+
+		switch {
+		case exists && existing.OptOut:
+			result.Status = ImportSuppressed
+		case exists:
+			result.Status = ImportAlreadyExists
+		case list == "":
+			m.nextID++
+			entry.ID = m.nextID
+			entry.Email = address
+			// attributes are kept apart from the entry
+			entry.Attributes = nil
+			m.emails[canonical] = copyEntry(entry)
+			m.writeAttributes(canonical, values)
+			result.Status = ImportCreated
+		default:
+			// the list exists, the email isn't subscribed and its attributes were checked above
+			if err := m.upsertSubscription(list, entry, false); err != nil {
+				return nil, err
+			}
+			result.Status = ImportCreated
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
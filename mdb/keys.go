@@ -0,0 +1,143 @@
+package mdb
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+	"time"
+)
+
+// APIKey is a key clients authenticate with. Only a hash of its secret is stored,
+// the key itself is shown once when it's created.
+type APIKey struct {
+	ID int64
+	Name string
+	// Prefix identifies the key without revealing it
+	Prefix string
+	// Hash is the hex encoded SHA-256 of the key's secret
+	Hash string
+	// Scopes granted to the key, e.g. read, write or admin
+	Scopes []string
+	CreatedAt time.Time
+	// RevokedAt is nil while the key is active
+	RevokedAt *time.Time
+}
+
+// apiKeyNotFound is returned when an operation refers to a missing API key
+func apiKeyNotFound(prefix string) error {
+	return newError(ErrNotFound, "api_key", prefix, "API key %v not found", prefix)
+}
+
+// CreateAPIKey stores a new API key
+func (s *SQLStore) CreateAPIKey(key APIKey) error {
+	_, err := s.exec(`
+		INSERT INTO
+			api_keys(name, prefix, hash, scopes, created_at)
+		VALUES
+			(?, ?, ?, ?, ?)`, key.Name, key.Prefix, key.Hash, strings.Join(key.Scopes, ","), key.CreatedAt.Unix())
+	if s.dialect.isUniqueViolation(err) {
+		return newError(ErrAlreadyExists, "api_key", key.Prefix, "API key %v already exists", key.Prefix)
+	}
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+// apiKeyFromRow builds an API key from a DB row
+func apiKeyFromRow(rows *sql.Rows) (*APIKey, error) {
+	var key APIKey
+	var scopes string
+	var createdAt, revokedAt int64
+	if err := rows.Scan(&key.ID, &key.Name, &key.Prefix, &key.Hash, &scopes, &createdAt, &revokedAt); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	if scopes != "" {
+		key.Scopes = strings.Split(scopes, ",")
+	}
+	key.CreatedAt = time.Unix(createdAt, 0)
+	if revokedAt != 0 {
+		t := time.Unix(revokedAt, 0)
+		key.RevokedAt = &t
+	}
+
+	return &key, nil
+}
+
+// GetAPIKey fetches an API key by its prefix, returning nil if it doesn't exist
+func (s *SQLStore) GetAPIKey(prefix string) (*APIKey, error) {
+	rows, err := s.query(`
+		SELECT
+			id, name, prefix, hash, scopes, created_at, revoked_at
+		FROM
+			api_keys
+		WHERE
+			prefix = ?`, prefix)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	// close DB connection if any error occurs
+	defer rows.Close()
+
+	for rows.Next() {
+		return apiKeyFromRow(rows)
+	}
+
+	return nil, nil
+}
+
+// GetAPIKeys fetches every API key, including revoked ones, ordered by creation
+func (s *SQLStore) GetAPIKeys() ([]APIKey, error) {
+	rows, err := s.query(`
+		SELECT
+			id, name, prefix, hash, scopes, created_at, revoked_at
+		FROM
+			api_keys
+		ORDER BY id ASC`)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	// close DB connection on error or end of func
+	defer rows.Close()
+
+	keys := make([]APIKey, 0)
+	for rows.Next() {
+		key, err := apiKeyFromRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *key)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// RevokeAPIKey stops an API key from authenticating, revoking a key twice keeps the first time
+func (s *SQLStore) RevokeAPIKey(prefix string) error {
+	res, err := s.exec(`
+		UPDATE api_keys
+		SET revoked_at = CASE WHEN revoked_at = 0 THEN ? ELSE revoked_at END
+		WHERE prefix = ?`, time.Now().Unix(), prefix)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return apiKeyNotFound(prefix)
+	}
+
+	return nil
+}
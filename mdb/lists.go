@@ -0,0 +1,289 @@
+package mdb
+
+import (
+	"fmt"
+	"log"
+)
+
+// DomainPolicy decides what happens to signups from domains that fail the domain check
+type DomainPolicy string
+
+const (
+	// DomainPolicyDefault uses the server's default policy
+	DomainPolicyDefault DomainPolicy = ""
+	// DomainPolicyReject refuses the signup
+	DomainPolicyReject DomainPolicy = "reject"
+	// DomainPolicyFlag accepts the signup and records why its domain failed on the email
+	DomainPolicyFlag DomainPolicy = "flag"
+)
+
+// Validate checks that p is a known policy
+func (p DomainPolicy) Validate() error {
+	switch p {
+	case DomainPolicyDefault, DomainPolicyReject, DomainPolicyFlag:
+		return nil
+	}
+	return invalidError(fmt.Sprintf("unknown domain policy %q, expected reject or flag", string(p)))
+}
+
+// List is a named mailing list that emails can subscribe to
+type List struct {
+	ID int64
+	Name string
+	DomainPolicy DomainPolicy
+}
+
+// CreateList adds a new named mailing list
+func (s *SQLStore) CreateList(name string, policy DomainPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+
+	_, err := s.exec(`
+		INSERT INTO
+			lists(name, domain_policy)
+		VALUES
+			(?, ?)`, name, string(policy))
+	if s.dialect.isUniqueViolation(err) {
+		return newError(ErrAlreadyExists, "list", name, "list %v already exists", name)
+	}
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+// GetList fetches a mailing list by name
+func (s *SQLStore) GetList(name string) (*List, error) {
+	rows, err := s.query(`
+		SELECT
+			id, name, domain_policy
+		FROM
+			lists
+		WHERE
+			name = ?`, name)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	// close DB connection if any error occurs
+	defer rows.Close()
+
+	for rows.Next() {
+		var list List
+		if err := rows.Scan(&list.ID, &list.Name, &list.DomainPolicy); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		return &list, nil
+	}
+
+	return nil, nil
+}
+
+// GetLists fetches every mailing list ordered by creation
+func (s *SQLStore) GetLists() ([]List, error) {
+	rows, err := s.query(`
+		SELECT
+			id, name, domain_policy
+		FROM
+			lists
+		ORDER BY id ASC`)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	// close DB connection on error or end of func
+	defer rows.Close()
+
+	lists := make([]List, 0)
+	for rows.Next() {
+		var list List
+		if err := rows.Scan(&list.ID, &list.Name, &list.DomainPolicy); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		lists = append(lists, list)
+	}
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return lists, nil
+}
+
+// upsertSubscription makes sure the email exists then creates or updates its subscription to list.
+// When update is false an existing subscription is left alone and reported as an error.
+// The entry's attributes belong to the email, so they're merged whatever the list.
+func (s *SQLStore) upsertSubscription(list string, entry EmailEntry, update bool) error {
+	address, canonical, err := s.opts.address(entry.Email)
+	if err != nil {
+		return err
+	}
+	values, err := s.encodeAttributes(entry.Attributes)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	// no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	// subscribers are always tracked in the emails table
+	_, err = s.txExec(tx, `
+		INSERT INTO
+			emails(email, canonical, confirmed_at, opt_out)
+		VALUES
+			(?, ?, 0, false)
+		ON CONFLICT(canonical) DO NOTHING`, address, canonical)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	confirmedAt := int64(0)
+	if entry.ConfirmedAt != nil {
+		confirmedAt = entry.ConfirmedAt.Unix()
+	}
+
+	query := `
+		INSERT INTO
+			subscriptions(list_id, email_id, confirmed_at, opt_out)
+		SELECT
+			lists.id, emails.id, ?, ?
+		FROM
+			lists, emails
+		WHERE
+			lists.name = ? AND emails.canonical = ?`
+	if update {
+		query += `
+		ON CONFLICT(list_id, email_id) DO UPDATE SET
+			confirmed_at=excluded.confirmed_at,
+			opt_out=excluded.opt_out`
+	}
+
+	res, err := s.txExec(tx, query, confirmedAt, entry.OptOut, list, canonical)
+	if s.dialect.isUniqueViolation(err) {
+		return newError(ErrAlreadyExists, "email", address, "email %v is already subscribed to %v", address, list)
+	}
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	// the email row always exists by now, so nothing inserted means the list is missing
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return listNotFound(list)
+	}
+
+	if len(values) > 0 {
+		emailID, err := s.emailID(tx, canonical)
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+		if err := s.writeAttributes(tx, emailID, values); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Subscribe adds an email to a list, creating the email entry if needed
+func (s *SQLStore) Subscribe(list string, email string) error {
+	return s.upsertSubscription(list, EmailEntry{Email: email}, false)
+}
+
+// GetSubscription fetches an email with its confirmation and opt out state for a list
+func (s *SQLStore) GetSubscription(list string, email string) (*EmailEntry, error) {
+	_, canonical, err := s.opts.address(email)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.query(`
+		SELECT
+			emails.id, emails.email, subscriptions.confirmed_at, subscriptions.opt_out, emails.domain_flag
+		FROM
+			subscriptions
+			JOIN emails ON emails.id = subscriptions.email_id
+			JOIN lists ON lists.id = subscriptions.list_id
+		WHERE
+			lists.name = ? AND emails.canonical = ?`, list, canonical)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	// close DB connection if any error occurs
+	defer rows.Close()
+
+	for rows.Next() {
+		entry, err := emailEntryFromRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		return s.withAttributes(rows, entry)
+	}
+
+	return nil, nil
+}
+
+// UpdateSubscription updates an email's state for a list or subscribes it if needed
+func (s *SQLStore) UpdateSubscription(list string, entry EmailEntry) error {
+	return s.upsertSubscription(list, entry, true)
+}
+
+// Unsubscribe soft deletes an email from a list, see DeleteEmail
+func (s *SQLStore) Unsubscribe(list string, email string) error {
+	_, canonical, err := s.opts.address(email)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.exec(`
+		UPDATE subscriptions
+		SET opt_out=true
+		WHERE
+			list_id = (SELECT id FROM lists WHERE name = ?) AND
+			email_id = (SELECT id FROM emails WHERE canonical = ?)`, list, canonical)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+// GetSubscriptionBatch fetches a page of emails currently subscribed to a list
+func (s *SQLStore) GetSubscriptionBatch(list string, params GetEmailBatchQueryParams) ([]EmailEntry, error) {
+	conditions, args, err := s.filterConditions(params.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	query, args, err := batchQuery(`
+		SELECT
+			emails.id, emails.email, subscriptions.confirmed_at, subscriptions.opt_out, emails.domain_flag
+		FROM
+			subscriptions
+			JOIN emails ON emails.id = subscriptions.email_id
+			JOIN lists ON lists.id = subscriptions.list_id`,
+		append([]string{"lists.name = ?"}, conditions...), append([]interface{}{list}, args...), params, "subscriptions")
+	if err != nil {
+		return nil, err
+	}
+
+	return s.queryEntries(query, args)
+}
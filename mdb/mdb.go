@@ -3,9 +3,8 @@ package mdb
 import (
 	"database/sql"
 	"log"
+	"strings"
 	"time"
-
-	"github.com/mattn/go-sqlite3"
 )
 
 // EmailEntry schema for email entry database
@@ -14,28 +13,44 @@ type EmailEntry struct {
 	Email string
 	ConfirmedAt *time.Time
 	OptOut bool
+	// DomainFlag is why the address' domain was flagged at signup, empty if it passed
+	DomainFlag string
+	// Attributes are the subscriber's custom field values, see Field
+	Attributes Attributes `json:",omitempty"`
 }
 
-func TryCreate(db *sql.DB) {
-	_, err := db.Exec(`
-		CREATE TABLE emails (
-			id INTEGER PRIMARY KEY,
-			email TEXT UNIQUE,
-			confirmed_at INTEGER,
-			opt_out INTEGER
-		);
-	`)
-	if err != nil {
-		if sqlError, ok := err.(sqlite3.Error); ok {
-			if sqlError.Code != 1 {
-				// code 1 == table already exists
-				log.Fatal(sqlError)
-			}
-		} else {
-			// handle other errors
-			log.Fatal(err)
-		}
-	}
+// SQLStore is a Store backed by a SQL database.
+// Use NewSQLiteStore or NewPostgresStore to create one.
+type SQLStore struct {
+	db *sql.DB
+	dialect dialect
+	opts Options
+}
+
+var _ Store = (*SQLStore)(nil)
+
+// dialect captures the differences between the SQL databases we support
+type dialect struct {
+	name string
+	// rebind rewrites ? placeholders into the form the driver expects
+	rebind func(query string) string
+	// isUniqueViolation reports whether err was caused by a UNIQUE or PRIMARY KEY constraint
+	isUniqueViolation func(err error) bool
+}
+
+// exec runs a statement using the store's placeholder syntax
+func (s *SQLStore) exec(query string, args ...interface{}) (sql.Result, error) {
+	return s.db.Exec(s.dialect.rebind(query), args...)
+}
+
+// txExec runs a statement inside a transaction using the store's placeholder syntax
+func (s *SQLStore) txExec(tx *sql.Tx, query string, args ...interface{}) (sql.Result, error) {
+	return tx.Exec(s.dialect.rebind(query), args...)
+}
+
+// query runs a query using the store's placeholder syntax
+func (s *SQLStore) query(query string, args ...interface{}) (*sql.Rows, error) {
+	return s.db.Query(s.dialect.rebind(query), args...)
 }
 
 // emailEntryFromRow build an email entry from provided DB row
@@ -44,9 +59,10 @@ func emailEntryFromRow(row *sql.Rows) (*EmailEntry, error) {
 	var email string
 	var confirmedAt int64
 	var optOut bool
+	var domainFlag string
 	
 	// get data from row
-	err := row.Scan(&id, &email, &confirmedAt, &optOut)
+	err := row.Scan(&id, &email, &confirmedAt, &optOut, &domainFlag)
 	if err != nil {
 		log.Println(err)
 		return nil, err
@@ -55,17 +71,47 @@ func emailEntryFromRow(row *sql.Rows) (*EmailEntry, error) {
 	// convert time format
 	t := time.Unix(confirmedAt, 0)
 
-	return &EmailEntry{ID: id, Email: email, ConfirmedAt: &t, OptOut: optOut}, nil
+	return &EmailEntry{ID: id, Email: email, ConfirmedAt: &t, OptOut: optOut, DomainFlag: domainFlag}, nil
+}
+
+
+// emailEntriesFromRows reads every row into a slice of email entries
+func emailEntriesFromRows(rows *sql.Rows) ([]EmailEntry, error) {
+	emails := make([]EmailEntry, 0)
+
+	for rows.Next() {
+		email, err := emailEntryFromRow(rows)
+		if err != nil {
+			// cancel iteration as we don't want a partial list
+			return nil, err
+		}
+		emails = append(emails, *email)
+	}
+	// an error mid-scan would otherwise look like a short last page
+	if err := rows.Err(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	return emails, nil
 }
 
 
 // CreateEmail adds new entry to email table
-func CreateEmail(db *sql.DB, email string) error {
-	_, err := db.Exec(`
+func (s *SQLStore) CreateEmail(email string) error {
+	address, canonical, err := s.opts.address(email)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.exec(`
 		INSERT INTO
-			emails(email, confirmed_at, opt_out)
+			emails(email, canonical, confirmed_at, opt_out)
 		VALUES
-			(?, 0, false)`, email)
+			(?, ?, 0, false)`, address, canonical)
+	if s.dialect.isUniqueViolation(err) {
+		return newError(ErrAlreadyExists, "email", address, "email %v already exists", address)
+	}
 	if err != nil {
 		log.Println(err)
 		return err
@@ -76,14 +122,19 @@ func CreateEmail(db *sql.DB, email string) error {
 
 
 // GetEmail fetches email entry from DB
-func GetEmail(db *sql.DB, email string) (*EmailEntry, error) {
-	rows, err := db.Query(`
+func (s *SQLStore) GetEmail(email string) (*EmailEntry, error) {
+	_, canonical, err := s.opts.address(email)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.query(`
 		SELECT
-			id, email, confirmed_at, opt_out
+			id, email, confirmed_at, opt_out, domain_flag
 		FROM
 			emails
 		WHERE
-			email = ?`, email)
+			canonical = ?`, canonical)
 
 	if err != nil {
 		log.Println(err)
@@ -95,44 +146,116 @@ func GetEmail(db *sql.DB, email string) (*EmailEntry, error) {
 
 	// read new row from DB
 	for rows.Next() {
-		return emailEntryFromRow(rows)
+		entry, err := emailEntryFromRow(rows)
+		if err != nil {
+			return nil, err
+		}
+		return s.withAttributes(rows, entry)
 	}
 
 	return nil, nil
 }
 
+// withAttributes loads entry's attributes once rows, the query that found it, is closed
+func (s *SQLStore) withAttributes(rows *sql.Rows, entry *EmailEntry) (*EmailEntry, error) {
+	rows.Close()
+
+	entries := []EmailEntry{*entry}
+	if err := s.loadAttributes(entries); err != nil {
+		return nil, err
+	}
+	return &entries[0], nil
+}
+
 
-// UpdateEmail updates a given email entry or creates a new one if it doesn't exist
-func UpdateEmail(db *sql.DB, entry EmailEntry) error {
-	t := entry.ConfirmedAt.Unix()
+// UpdateEmail updates a given email entry or creates a new one if it doesn't exist.
+// Its attributes are merged into the stored ones, see Attributes.
+func (s *SQLStore) UpdateEmail(entry EmailEntry) error {
+	address, canonical, err := s.opts.address(entry.Email)
+	if err != nil {
+		return err
+	}
+	t := int64(0)
+	if entry.ConfirmedAt != nil {
+		t = entry.ConfirmedAt.Unix()
+	}
+	// check the attributes first so an invalid one doesn't leave the email half updated
+	values, err := s.encodeAttributes(entry.Attributes)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	// no-op once the transaction has been committed
+	defer tx.Rollback()
 
 	// UPSERT email (try to create new entry, if it exists update instead)
-	_, err := db.Exec(`
+	_, err = s.txExec(tx, `
 		INSERT INTO
-			emails(email, confirmed_at, opt_out)
+			emails(email, canonical, confirmed_at, opt_out)
 		VALUES
-			(?, ?, ?)
-		ON CONFLICT(EMAIL) DO UPDATE SET
+			(?, ?, ?, ?)
+		ON CONFLICT(canonical) DO UPDATE SET
 			confirmed_at=?,
-			opt_out=?`, entry.Email, t, entry.OptOut, t, entry.OptOut)
+			opt_out=?`, address, canonical, t, entry.OptOut, t, entry.OptOut)
 	if err != nil {
 		log.Println(err)
 		return err
 	}
 
-	return nil
+	if len(values) > 0 {
+		emailID, err := s.emailID(tx, canonical)
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+		if err := s.writeAttributes(tx, emailID, values); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
 
 // DeleteEmail soft deletes email from mailing list
 // NOTE: we keep the record to avoid edgecase where we
 // send an email to someone that's already opted out (ie. spam).
-func DeleteEmail(db *sql.DB, email string) error {
+func (s *SQLStore) DeleteEmail(email string) error {
+	_, canonical, err := s.opts.address(email)
+	if err != nil {
+		return err
+	}
+
 	// setting opt_out=true removes that email from the mailing list
-	_, err := db.Exec(`
+	_, err = s.exec(`
 		UPDATE emails
 		SET opt_out=true
-		WHERE email=?`, email)
+		WHERE canonical=?`, canonical)
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	return nil
+}
+
+// FlagEmail records why an email's domain was flagged
+func (s *SQLStore) FlagEmail(email string, reason string) error {
+	_, canonical, err := s.opts.address(email)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.exec(`
+		UPDATE emails
+		SET domain_flag=?
+		WHERE canonical=?`, reason, canonical)
 	if err != nil {
 		log.Println(err)
 		return err
@@ -141,46 +264,93 @@ func DeleteEmail(db *sql.DB, email string) error {
 	return nil
 }
 
+// GetEmailBatchQueryParams selects a page of emails.
+// Pages are fetched either by Cursor (leave Page unset, pass the previous page's
+// NextCursor) or by Page for the older LIMIT/OFFSET pagination.
 type GetEmailBatchQueryParams struct {
 	Page int
 	Count int
+	// Cursor continues after the last entry of a previous page, see NextCursor
+	Cursor string
+	// ConfirmedOnly excludes subscribers that haven't confirmed their address
+	ConfirmedOnly bool
+	// IncludeOptOut includes emails that have opted out, used for full exports
+	IncludeOptOut bool
+	// Filters only include emails whose attributes match all of them
+	Filters []AttributeFilter `json:",omitempty"`
+}
+
+// batchQuery adds the WHERE clause, ordering and paging for params to query.
+// conditions and args are the caller's own filters, columns are qualified with table.
+func batchQuery(query string, conditions []string, args []interface{}, params GetEmailBatchQueryParams, table string) (string, []interface{}, error) {
+	if !params.IncludeOptOut {
+		conditions = append(conditions, table+".opt_out = false")
+	}
+	if params.ConfirmedOnly {
+		conditions = append(conditions, table+".confirmed_at > 0")
+	}
+
+	if params.UsesCursor() {
+		// keyset pagination stays fast and stable no matter how deep we page
+		afterID, err := DecodeCursor(params.Cursor)
+		if err != nil {
+			return "", nil, err
+		}
+		conditions = append(conditions, "emails.id > ?")
+		args = append(args, afterID)
+	}
+
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	if !params.UsesCursor() {
+		query += " ORDER BY emails.id ASC LIMIT ? OFFSET ?"
+		return query, append(args, params.Count, (params.Page-1)*params.Count), nil
+	}
+	query += " ORDER BY emails.id ASC LIMIT ?"
+	return query, append(args, params.Count), nil
 }
 
 // GetEmailBatch fetches all users currently subscribed to mailing list
-func GetEmailBatch(db *sql.DB, params GetEmailBatchQueryParams) ([]EmailEntry, error) {
+func (s *SQLStore) GetEmailBatch(params GetEmailBatchQueryParams) ([]EmailEntry, error) {
 	var empty []EmailEntry
 
-	// get current users offset by current page
-	rows, err := db.Query(`
+	conditions, args, err := s.filterConditions(params.Filters)
+	if err != nil {
+		return empty, err
+	}
+
+	// get current users after the cursor or offset by current page
+	query, args, err := batchQuery(`
 		SELECT
-			id, email, confirmed_at, opt_out
+			id, email, confirmed_at, opt_out, domain_flag
 		FROM
-			emails
-		WHERE
-			opt_out = false
-		ORDER BY id ASC
-		LIMIT ? OFFSET ?`, params.Count, (params.Page-1)*params.Count)
-
+			emails`, conditions, args, params, "emails")
 	if err != nil {
-		log.Println(err)
 		return empty, err
 	}
 
-	// close DB connection on error or end of func
-	defer rows.Close()
+	return s.queryEntries(query, args)
+}
 
-	// create slice of email list
-	emails := make([]EmailEntry, 0, params.Count)
+// queryEntries runs a batch query then loads the attributes of the emails it found
+func (s *SQLStore) queryEntries(query string, args []interface{}) ([]EmailEntry, error) {
+	rows, err := s.query(query, args...)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
 
-	// read rows from DB
-	for rows.Next() {
-		email, err := emailEntryFromRow(rows)
-		if err != nil {
-			// cancel iteration as we don't want a partial list
-			return nil, err
-		}
-		emails = append(emails, *email)
+	emails, err := emailEntriesFromRows(rows)
+	// close DB connection before loading the attributes
+	rows.Close()
+	if err != nil {
+		return nil, err
 	}
 
+	if err := s.loadAttributes(emails); err != nil {
+		return nil, err
+	}
 	return emails, nil
 }
\ No newline at end of file
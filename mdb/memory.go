@@ -0,0 +1,652 @@
+package mdb
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store that keeps everything in memory.
+// It's safe for concurrent use and is meant for tests and ephemeral deployments,
+// all data is lost when the process exits.
+type MemoryStore struct {
+	mu sync.RWMutex
+	opts Options
+	nextID int64
+	// emails indexed by canonical address, mirrors the UNIQUE index on emails.canonical
+	emails map[string]EmailEntry
+
+	nextListID int64
+	// lists indexed by name
+	lists map[string]List
+	// subscriptions indexed by list ID then canonical address, entries hold per-list state
+	subscriptions map[int64]map[string]EmailEntry
+
+	nextFieldID int64
+	// custom fields indexed by name
+	fields map[string]Field
+	// encoded attribute values indexed by canonical address then field name
+	attributes map[string]map[string]string
+
+	nextAPIKeyID int64
+	// API keys indexed by prefix
+	apiKeys map[string]APIKey
+
+	// idempotency records indexed by key
+	idempotencyKeys map[string]IdempotencyRecord
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore(opts Options) *MemoryStore {
+	return &MemoryStore{
+		opts: opts,
+		emails: make(map[string]EmailEntry),
+		lists: make(map[string]List),
+		subscriptions: make(map[int64]map[string]EmailEntry),
+		fields: make(map[string]Field),
+		attributes: make(map[string]map[string]string),
+		apiKeys: make(map[string]APIKey),
+		idempotencyKeys: make(map[string]IdempotencyRecord),
+	}
+}
+
+// copyEntry returns a copy of entry that doesn't share its ConfirmedAt pointer,
+// so callers can't modify stored entries
+func copyEntry(entry EmailEntry) EmailEntry {
+	t := time.Unix(0, 0)
+	if entry.ConfirmedAt != nil {
+		t = time.Unix(entry.ConfirmedAt.Unix(), 0)
+	}
+	entry.ConfirmedAt = &t
+	return entry
+}
+
+// CreateEmail adds new entry to the email list
+func (m *MemoryStore) CreateEmail(email string) error {
+	address, canonical, err := m.opts.address(email)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.emails[canonical]; ok {
+		return newError(ErrAlreadyExists, "email", address, "email %v already exists", address)
+	}
+
+	m.nextID++
+	m.emails[canonical] = copyEntry(EmailEntry{ID: m.nextID, Email: address})
+
+	return nil
+}
+
+// GetEmail fetches an email entry, returning nil if it doesn't exist
+func (m *MemoryStore) GetEmail(email string) (*EmailEntry, error) {
+	_, canonical, err := m.opts.address(email)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entry, ok := m.emails[canonical]
+	if !ok {
+		return nil, nil
+	}
+
+	entry = copyEntry(entry)
+	entry.Attributes = m.attributesOf(canonical)
+	return &entry, nil
+}
+
+// UpdateEmail updates a given email entry or creates a new one if it doesn't exist
+func (m *MemoryStore) UpdateEmail(entry EmailEntry) error {
+	address, canonical, err := m.opts.address(entry.Email)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	values, err := encodeAttributes(m.fieldList(), entry.Attributes)
+	if err != nil {
+		return err
+	}
+
+	existing, ok := m.emails[canonical]
+	if !ok {
+		m.nextID++
+		existing = EmailEntry{ID: m.nextID, Email: address}
+	}
+
+	// like the SQL UPSERT only the confirmation and opt out state can change
+	existing.ConfirmedAt = entry.ConfirmedAt
+	existing.OptOut = entry.OptOut
+	m.emails[canonical] = copyEntry(existing)
+	m.writeAttributes(canonical, values)
+
+	return nil
+}
+
+// DeleteEmail soft deletes email from mailing list
+func (m *MemoryStore) DeleteEmail(email string) error {
+	_, canonical, err := m.opts.address(email)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if entry, ok := m.emails[canonical]; ok {
+		entry.OptOut = true
+		m.emails[canonical] = entry
+	}
+
+	return nil
+}
+
+// FlagEmail records why an email's domain was flagged
+func (m *MemoryStore) FlagEmail(email string, reason string) error {
+	_, canonical, err := m.opts.address(email)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if entry, ok := m.emails[canonical]; ok {
+		entry.DomainFlag = reason
+		m.emails[canonical] = entry
+	}
+
+	// subscriptions carry their own copy of the email
+	for _, subs := range m.subscriptions {
+		if entry, ok := subs[canonical]; ok {
+			entry.DomainFlag = reason
+			subs[canonical] = entry
+		}
+	}
+
+	return nil
+}
+
+// GetEmailBatch fetches all users currently subscribed to mailing list
+func (m *MemoryStore) GetEmailBatch(params GetEmailBatchQueryParams) ([]EmailEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	filters, err := resolveFilters(m.fieldList(), params.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	// collect users matching the filters
+	subscribed := make([]EmailEntry, 0, len(m.emails))
+	for canonical, entry := range m.emails {
+		if m.matchesBatch(canonical, entry, filters, params) {
+			entry.Attributes = m.attributesOf(canonical)
+			subscribed = append(subscribed, entry)
+		}
+	}
+
+	return pageEntries(subscribed, params)
+}
+
+// matchesBatch reports whether entry, stored under canonical, should be included in a batch query
+func (m *MemoryStore) matchesBatch(canonical string, entry EmailEntry, filters []resolvedFilter, params GetEmailBatchQueryParams) bool {
+	if entry.OptOut && !params.IncludeOptOut {
+		return false
+	}
+	if params.ConfirmedOnly && (entry.ConfirmedAt == nil || entry.ConfirmedAt.Unix() <= 0) {
+		return false
+	}
+	for _, f := range filters {
+		var stored *string
+		if value, ok := m.attributes[canonical][f.field.Name]; ok {
+			stored = &value
+		}
+		if !f.matches(stored) {
+			return false
+		}
+	}
+	return true
+}
+
+// pageEntries orders entries by id and returns copies of the requested page
+func pageEntries(entries []EmailEntry, params GetEmailBatchQueryParams) ([]EmailEntry, error) {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ID < entries[j].ID
+	})
+
+	emails := make([]EmailEntry, 0)
+	if params.Count <= 0 {
+		return emails, nil
+	}
+
+	offset := (params.Page - 1) * params.Count
+	if params.UsesCursor() {
+		afterID, err := DecodeCursor(params.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		// start at the first entry after the cursor
+		offset = sort.Search(len(entries), func(i int) bool {
+			return entries[i].ID > afterID
+		})
+	}
+	if offset < 0 || offset >= len(entries) {
+		return emails, nil
+	}
+	end := offset + params.Count
+	if end > len(entries) {
+		end = len(entries)
+	}
+
+	for _, entry := range entries[offset:end] {
+		emails = append(emails, copyEntry(entry))
+	}
+
+	return emails, nil
+}
+
+// CreateList adds a new named mailing list
+func (m *MemoryStore) CreateList(name string, policy DomainPolicy) error {
+	if err := policy.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.lists[name]; ok {
+		return newError(ErrAlreadyExists, "list", name, "list %v already exists", name)
+	}
+
+	m.nextListID++
+	m.lists[name] = List{ID: m.nextListID, Name: name, DomainPolicy: policy}
+	m.subscriptions[m.nextListID] = make(map[string]EmailEntry)
+
+	return nil
+}
+
+// GetList fetches a mailing list by name
+func (m *MemoryStore) GetList(name string) (*List, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	list, ok := m.lists[name]
+	if !ok {
+		return nil, nil
+	}
+
+	return &list, nil
+}
+
+// GetLists fetches every mailing list ordered by creation
+func (m *MemoryStore) GetLists() ([]List, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	lists := make([]List, 0, len(m.lists))
+	for _, list := range m.lists {
+		lists = append(lists, list)
+	}
+	sort.Slice(lists, func(i, j int) bool {
+		return lists[i].ID < lists[j].ID
+	})
+
+	return lists, nil
+}
+
+// upsertSubscription makes sure the email exists then creates or updates its subscription to list.
+// The caller must hold the write lock.
+func (m *MemoryStore) upsertSubscription(list string, entry EmailEntry, update bool) error {
+	address, canonical, err := m.opts.address(entry.Email)
+	if err != nil {
+		return err
+	}
+
+	l, ok := m.lists[list]
+	if !ok {
+		return listNotFound(list)
+	}
+	values, err := encodeAttributes(m.fieldList(), entry.Attributes)
+	if err != nil {
+		return err
+	}
+
+	subs := m.subscriptions[l.ID]
+	existing, subscribed := subs[canonical]
+	if subscribed && !update {
+		return newError(ErrAlreadyExists, "email", address, "email %v is already subscribed to %v", address, list)
+	}
+
+	if !subscribed {
+		// subscribers are always tracked in the emails table
+		e, ok := m.emails[canonical]
+		if !ok {
+			m.nextID++
+			e = copyEntry(EmailEntry{ID: m.nextID, Email: address})
+			m.emails[canonical] = e
+		}
+		existing = EmailEntry{ID: e.ID, Email: e.Email, DomainFlag: e.DomainFlag}
+	}
+
+	existing.ConfirmedAt = entry.ConfirmedAt
+	existing.OptOut = entry.OptOut
+	subs[canonical] = copyEntry(existing)
+	m.writeAttributes(canonical, values)
+
+	return nil
+}
+
+// Subscribe adds an email to a list, creating the email entry if needed
+func (m *MemoryStore) Subscribe(list string, email string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.upsertSubscription(list, EmailEntry{Email: email}, false)
+}
+
+// GetSubscription fetches an email with its confirmation and opt out state for a list
+func (m *MemoryStore) GetSubscription(list string, email string) (*EmailEntry, error) {
+	_, canonical, err := m.opts.address(email)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	l, ok := m.lists[list]
+	if !ok {
+		return nil, nil
+	}
+
+	entry, ok := m.subscriptions[l.ID][canonical]
+	if !ok {
+		return nil, nil
+	}
+
+	entry = copyEntry(entry)
+	entry.Attributes = m.attributesOf(canonical)
+	return &entry, nil
+}
+
+// UpdateSubscription updates an email's state for a list or subscribes it if needed
+func (m *MemoryStore) UpdateSubscription(list string, entry EmailEntry) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.upsertSubscription(list, entry, true)
+}
+
+// Unsubscribe soft deletes an email from a list
+func (m *MemoryStore) Unsubscribe(list string, email string) error {
+	_, canonical, err := m.opts.address(email)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	l, ok := m.lists[list]
+	if !ok {
+		return nil
+	}
+
+	if entry, ok := m.subscriptions[l.ID][canonical]; ok {
+		entry.OptOut = true
+		m.subscriptions[l.ID][canonical] = entry
+	}
+
+	return nil
+}
+
+// GetSubscriptionBatch fetches a page of emails currently subscribed to a list
+func (m *MemoryStore) GetSubscriptionBatch(list string, params GetEmailBatchQueryParams) ([]EmailEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	filters, err := resolveFilters(m.fieldList(), params.Filters)
+	if err != nil {
+		return nil, err
+	}
+
+	subscribed := make([]EmailEntry, 0)
+	if l, ok := m.lists[list]; ok {
+		for canonical, entry := range m.subscriptions[l.ID] {
+			if m.matchesBatch(canonical, entry, filters, params) {
+				entry.Attributes = m.attributesOf(canonical)
+				subscribed = append(subscribed, entry)
+			}
+		}
+	}
+
+	return pageEntries(subscribed, params)
+}
+
+// CreateField adds a custom field subscribers can have values for
+func (m *MemoryStore) CreateField(field Field) error {
+	if err := field.Validate(); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.fields[field.Name]; ok {
+		return newError(ErrAlreadyExists, "field", field.Name, "field %v already exists", field.Name)
+	}
+
+	m.nextFieldID++
+	field.ID = m.nextFieldID
+	m.fields[field.Name] = field
+
+	return nil
+}
+
+// GetFields fetches every custom field ordered by creation
+func (m *MemoryStore) GetFields() ([]Field, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.fieldList(), nil
+}
+
+// fieldList returns the custom fields ordered by creation. The caller must hold the lock.
+func (m *MemoryStore) fieldList() []Field {
+	fields := make([]Field, 0, len(m.fields))
+	for _, field := range m.fields {
+		fields = append(fields, field)
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return fields[i].ID < fields[j].ID
+	})
+	return fields
+}
+
+// SetAttributes changes an existing email's attributes, see Attributes
+func (m *MemoryStore) SetAttributes(email string, attrs Attributes) error {
+	address, canonical, err := m.opts.address(email)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	values, err := encodeAttributes(m.fieldList(), attrs)
+	if err != nil {
+		return err
+	}
+	if _, ok := m.emails[canonical]; !ok {
+		return EmailNotFound(address)
+	}
+	m.writeAttributes(canonical, values)
+
+	return nil
+}
+
+// writeAttributes stores or removes the attribute values of the email at canonical.
+// The caller must hold the write lock.
+func (m *MemoryStore) writeAttributes(canonical string, values []attributeValue) {
+	for _, v := range values {
+		if v.value == nil {
+			delete(m.attributes[canonical], v.field.Name)
+			continue
+		}
+		if m.attributes[canonical] == nil {
+			m.attributes[canonical] = make(map[string]string)
+		}
+		m.attributes[canonical][v.field.Name] = *v.value
+	}
+}
+
+// attributesOf decodes the attributes of the email at canonical, nil if it has none.
+// The caller must hold the lock.
+func (m *MemoryStore) attributesOf(canonical string) Attributes {
+	if len(m.attributes[canonical]) == 0 {
+		return nil
+	}
+	attrs := make(Attributes, len(m.attributes[canonical]))
+	for name, value := range m.attributes[canonical] {
+		attrs[name] = m.fields[name].decode(value)
+	}
+	return attrs
+}
+
+// CreateAPIKey stores a new API key
+func (m *MemoryStore) CreateAPIKey(key APIKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.apiKeys[key.Prefix]; ok {
+		return newError(ErrAlreadyExists, "api_key", key.Prefix, "API key %v already exists", key.Prefix)
+	}
+
+	m.nextAPIKeyID++
+	key.ID = m.nextAPIKeyID
+	key.Scopes = append([]string(nil), key.Scopes...)
+	key.RevokedAt = nil
+	m.apiKeys[key.Prefix] = key
+
+	return nil
+}
+
+// GetAPIKey fetches an API key by its prefix, returning nil if it doesn't exist
+func (m *MemoryStore) GetAPIKey(prefix string) (*APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	key, ok := m.apiKeys[prefix]
+	if !ok {
+		return nil, nil
+	}
+	key.Scopes = append([]string(nil), key.Scopes...)
+
+	return &key, nil
+}
+
+// GetAPIKeys fetches every API key, including revoked ones, ordered by creation
+func (m *MemoryStore) GetAPIKeys() ([]APIKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	keys := make([]APIKey, 0, len(m.apiKeys))
+	for _, key := range m.apiKeys {
+		key.Scopes = append([]string(nil), key.Scopes...)
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].ID < keys[j].ID
+	})
+
+	return keys, nil
+}
+
+// RevokeAPIKey stops an API key from authenticating, revoking a key twice keeps the first time
+func (m *MemoryStore) RevokeAPIKey(prefix string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key, ok := m.apiKeys[prefix]
+	if !ok {
+		return apiKeyNotFound(prefix)
+	}
+	if key.RevokedAt == nil {
+		t := time.Unix(time.Now().Unix(), 0)
+		key.RevokedAt = &t
+		m.apiKeys[prefix] = key
+	}
+
+	return nil
+}
+
+// ReserveIdempotencyKey records that the request for record.Key is being handled. If the key
+// was already used after expiredBefore nothing is recorded and the existing record is returned.
+func (m *MemoryStore) ReserveIdempotencyKey(record IdempotencyRecord, expiredBefore time.Time) (*IdempotencyRecord, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	existing, ok := m.idempotencyKeys[record.Key]
+	if ok && !existing.CreatedAt.Before(expiredBefore) {
+		existing.Response = append([]byte(nil), existing.Response...)
+		return &existing, nil
+	}
+
+	record.CreatedAt = time.Unix(record.CreatedAt.Unix(), 0)
+	record.Response = nil
+	m.idempotencyKeys[record.Key] = record
+
+	return nil, nil
+}
+
+// CompleteIdempotencyKey stores the response to replay for a reserved key
+func (m *MemoryStore) CompleteIdempotencyKey(key string, response []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	record, ok := m.idempotencyKeys[key]
+	if !ok {
+		return nil
+	}
+	record.Response = append([]byte(nil), response...)
+	m.idempotencyKeys[key] = record
+
+	return nil
+}
+
+// ReleaseIdempotencyKey forgets a reserved key so the request can be retried
+func (m *MemoryStore) ReleaseIdempotencyKey(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.idempotencyKeys, key)
+
+	return nil
+}
+
+// DeleteExpiredIdempotencyKeys forgets keys created before expiredBefore, returning how many were deleted
+func (m *MemoryStore) DeleteExpiredIdempotencyKeys(expiredBefore time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var deleted int64
+	for key, record := range m.idempotencyKeys {
+		if record.CreatedAt.Before(expiredBefore) {
+			delete(m.idempotencyKeys, key)
+			deleted++
+		}
+	}
+
+	return deleted, nil
+}
@@ -0,0 +1,199 @@
+package mdb
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//go:embed migrations/sqlite/*.sql migrations/postgres/*.sql
+var migrationFiles embed.FS
+
+// Migration is a single forward schema change loaded from the migrations directory
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// MigrationStatus reports whether a migration has been applied to a database
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Migrations returns the store's embedded migrations ordered by version.
+// Each dialect has its own directory of files named <version>_<name>.sql,
+// e.g. migrations/sqlite/0001_create_emails.sql
+func (s *SQLStore) Migrations() ([]Migration, error) {
+	files, err := fs.Glob(migrationFiles, path.Join("migrations", s.dialect.name, "*.sql"))
+	if err != nil {
+		return nil, err
+	}
+
+	migrations := make([]Migration, 0, len(files))
+	for _, file := range files {
+		base := strings.TrimSuffix(path.Base(file), ".sql")
+		versionStr, name, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %v: expected <version>_<name>.sql", file)
+		}
+		version, err := strconv.Atoi(versionStr)
+		if err != nil {
+			return nil, fmt.Errorf("migration %v: invalid version: %w", file, err)
+		}
+
+		contents, err := migrationFiles.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: version, Name: name, SQL: string(contents)})
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	// versions must be unique or the apply order is ambiguous
+	for i := 1; i < len(migrations); i++ {
+		if migrations[i].Version == migrations[i-1].Version {
+			return nil, fmt.Errorf("duplicate migration version %v", migrations[i].Version)
+		}
+	}
+
+	return migrations, nil
+}
+
+// createSchemaVersionTable creates the table used to track applied migrations
+func (s *SQLStore) createSchemaVersionTable() error {
+	_, err := s.exec(`
+		CREATE TABLE IF NOT EXISTS schema_version (
+			version INTEGER PRIMARY KEY,
+			name TEXT NOT NULL,
+			applied_at BIGINT NOT NULL
+		);
+	`)
+	return err
+}
+
+// GetMigrationStatus lists every known migration along with when it was applied (nil if pending)
+func (s *SQLStore) GetMigrationStatus() ([]MigrationStatus, error) {
+	migrations, err := s.Migrations()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.createSchemaVersionTable(); err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	rows, err := s.query(`SELECT version, applied_at FROM schema_version`)
+	if err != nil {
+		log.Println(err)
+		return nil, err
+	}
+
+	// close DB connection on error or end of func
+	defer rows.Close()
+
+	applied := make(map[int]time.Time)
+	for rows.Next() {
+		var version int
+		var appliedAt int64
+		if err := rows.Scan(&version, &appliedAt); err != nil {
+			log.Println(err)
+			return nil, err
+		}
+		applied[version] = time.Unix(appliedAt, 0)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		status := MigrationStatus{Migration: m}
+		if t, ok := applied[m.Version]; ok {
+			status.AppliedAt = &t
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// Migrate applies all pending migrations in version order and returns the ones it applied.
+// When dryRun is true nothing is written and the pending migrations are returned instead.
+// Canonical addresses are then recomputed if the store's Options changed since they were
+// written, emails that end up sharing one are merged and logged, see DuplicateGroup.
+func (s *SQLStore) Migrate(dryRun bool) ([]Migration, error) {
+	statuses, err := s.GetMigrationStatus()
+	if err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			pending = append(pending, status.Migration)
+		}
+	}
+
+	if dryRun {
+		return pending, nil
+	}
+
+	applied := make([]Migration, 0, len(pending))
+	for _, m := range pending {
+		if err := s.applyMigration(m); err != nil {
+			return applied, fmt.Errorf("migration %04d_%v: %w", m.Version, m.Name, err)
+		}
+		log.Printf("applied migration %04d_%v\n", m.Version, m.Name)
+		applied = append(applied, m)
+	}
+
+	duplicates, err := s.syncCanonical()
+	if err != nil {
+		return applied, fmt.Errorf("canonical addresses: %w", err)
+	}
+	for _, group := range duplicates {
+		log.Printf("merged %v emails sharing the canonical address %v into id %v: ids %v, emails %v\n",
+			len(group.IDs), group.Canonical, group.IDs[0], group.IDs, strings.Join(group.Emails, ", "))
+	}
+
+	return applied, nil
+}
+
+// applyMigration runs a single migration and records it in one transaction
+// so a failed migration never leaves a half applied schema behind
+func (s *SQLStore) applyMigration(m Migration) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	// no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(m.SQL); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(s.dialect.rebind(`
+		INSERT INTO
+			schema_version(version, name, applied_at)
+		VALUES
+			(?, ?, ?)`), m.Version, m.Name, time.Now().Unix())
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
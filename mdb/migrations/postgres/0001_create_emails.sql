@@ -0,0 +1,7 @@
+-- emails is the original mailing list table, matching the SQLite schema
+CREATE TABLE IF NOT EXISTS emails (
+	id BIGSERIAL PRIMARY KEY,
+	email TEXT UNIQUE,
+	confirmed_at BIGINT,
+	opt_out BOOLEAN
+);
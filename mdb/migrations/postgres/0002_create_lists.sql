@@ -0,0 +1,14 @@
+-- lists are named mailing lists, e.g. newsletter or product-updates
+CREATE TABLE lists (
+	id BIGSERIAL PRIMARY KEY,
+	name TEXT UNIQUE NOT NULL
+);
+
+-- subscriptions link emails to lists, each with its own confirmation and opt out state
+CREATE TABLE subscriptions (
+	list_id BIGINT NOT NULL REFERENCES lists(id),
+	email_id BIGINT NOT NULL REFERENCES emails(id),
+	confirmed_at BIGINT NOT NULL DEFAULT 0,
+	opt_out BOOLEAN NOT NULL DEFAULT false,
+	PRIMARY KEY (list_id, email_id)
+);
@@ -0,0 +1,15 @@
+-- canonical is the normalized form of email used for uniqueness, so addresses
+-- that only differ by case or surrounding whitespace map to a single row. It
+-- replaces the UNIQUE constraint on email.
+ALTER TABLE emails ADD COLUMN canonical TEXT;
+ALTER TABLE emails DROP CONSTRAINT IF EXISTS emails_email_key;
+
+-- backfill existing rows, only the oldest row of each group of duplicates gets
+-- a canonical value here. This only folds case, Migrate recomputes every
+-- canonical value with the store's options afterwards and merges each group of
+-- duplicates into its oldest row
+UPDATE emails
+SET canonical = lower(trim(email))
+WHERE id IN (SELECT min(id) FROM emails GROUP BY lower(trim(email)));
+
+CREATE UNIQUE INDEX emails_canonical ON emails(canonical);
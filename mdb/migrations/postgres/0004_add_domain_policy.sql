@@ -0,0 +1,6 @@
+-- domain_policy decides what happens to signups from domains that fail the
+-- domain check, 'reject' or 'flag', empty uses the server's default
+ALTER TABLE lists ADD COLUMN domain_policy TEXT NOT NULL DEFAULT '';
+
+-- domain_flag records why an accepted address' domain was flagged
+ALTER TABLE emails ADD COLUMN domain_flag TEXT NOT NULL DEFAULT '';
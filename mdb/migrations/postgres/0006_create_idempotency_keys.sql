@@ -0,0 +1,12 @@
+-- idempotency_keys records the first response to requests sent with an
+-- Idempotency-Key so retries get the same response. fingerprint identifies
+-- the request the key was first used with, response is NULL while that
+-- request is still being handled.
+CREATE TABLE idempotency_keys (
+	idempotency_key TEXT PRIMARY KEY,
+	fingerprint TEXT NOT NULL,
+	response BYTEA,
+	created_at BIGINT NOT NULL
+);
+
+CREATE INDEX idempotency_keys_created_at ON idempotency_keys(created_at);
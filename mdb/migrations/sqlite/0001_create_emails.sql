@@ -0,0 +1,8 @@
+-- emails is the original mailing list table. IF NOT EXISTS keeps this
+-- migration safe to run against databases created before versioning.
+CREATE TABLE IF NOT EXISTS emails (
+	id INTEGER PRIMARY KEY,
+	email TEXT UNIQUE,
+	confirmed_at INTEGER,
+	opt_out INTEGER
+);
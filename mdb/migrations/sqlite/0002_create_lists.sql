@@ -0,0 +1,14 @@
+-- lists are named mailing lists, e.g. newsletter or product-updates
+CREATE TABLE lists (
+	id INTEGER PRIMARY KEY,
+	name TEXT UNIQUE NOT NULL
+);
+
+-- subscriptions link emails to lists, each with its own confirmation and opt out state
+CREATE TABLE subscriptions (
+	list_id INTEGER NOT NULL REFERENCES lists(id),
+	email_id INTEGER NOT NULL REFERENCES emails(id),
+	confirmed_at INTEGER NOT NULL DEFAULT 0,
+	opt_out INTEGER NOT NULL DEFAULT 0,
+	PRIMARY KEY (list_id, email_id)
+);
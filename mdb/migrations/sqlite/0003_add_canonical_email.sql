@@ -0,0 +1,27 @@
+-- canonical is the normalized form of email used for uniqueness, so addresses
+-- that only differ by case or surrounding whitespace map to a single row. It
+-- replaces the UNIQUE constraint on email, which SQLite can only drop by
+-- rebuilding the table. Ids are copied as is and foreign keys aren't enforced,
+-- so subscriptions keep pointing at the same rows.
+CREATE TABLE emails_canonical_new (
+	id INTEGER PRIMARY KEY,
+	email TEXT,
+	confirmed_at INTEGER,
+	opt_out INTEGER,
+	canonical TEXT
+);
+
+-- backfill existing rows, only the oldest row of each group of duplicates gets
+-- a canonical value here. This only folds case, Migrate recomputes every
+-- canonical value with the store's options afterwards and merges each group of
+-- duplicates into its oldest row
+INSERT INTO emails_canonical_new(id, email, confirmed_at, opt_out, canonical)
+SELECT
+	id, email, confirmed_at, opt_out,
+	CASE WHEN id IN (SELECT min(id) FROM emails GROUP BY lower(trim(email))) THEN lower(trim(email)) END
+FROM emails;
+
+DROP TABLE emails;
+ALTER TABLE emails_canonical_new RENAME TO emails;
+
+CREATE UNIQUE INDEX emails_canonical ON emails(canonical);
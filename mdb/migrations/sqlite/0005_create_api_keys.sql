@@ -0,0 +1,12 @@
+-- api_keys authenticate clients of the JSON and gRPC APIs. Only a SHA-256
+-- hash of each key's secret is stored, prefix identifies the key. scopes is a
+-- comma separated list, revoked_at is 0 while the key is active.
+CREATE TABLE api_keys (
+	id INTEGER PRIMARY KEY,
+	name TEXT NOT NULL,
+	prefix TEXT UNIQUE NOT NULL,
+	hash TEXT NOT NULL,
+	scopes TEXT NOT NULL,
+	created_at INTEGER NOT NULL,
+	revoked_at INTEGER NOT NULL DEFAULT 0
+);
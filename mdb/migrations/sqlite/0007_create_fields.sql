@@ -0,0 +1,20 @@
+-- fields define the custom attributes subscribers can have, e.g. first_name.
+-- type is string, number, bool or date and decides how values are checked
+-- and compared.
+CREATE TABLE fields (
+	id INTEGER PRIMARY KEY,
+	name TEXT UNIQUE NOT NULL,
+	type TEXT NOT NULL,
+	description TEXT NOT NULL DEFAULT ''
+);
+
+-- email_attributes hold each email's field values as text, numbers are
+-- compared after casting and dates are written as YYYY-MM-DD so they sort.
+CREATE TABLE email_attributes (
+	email_id INTEGER NOT NULL REFERENCES emails(id),
+	field_id INTEGER NOT NULL REFERENCES fields(id),
+	value TEXT NOT NULL,
+	PRIMARY KEY (email_id, field_id)
+);
+
+CREATE INDEX email_attributes_field_value ON email_attributes(field_id, value);
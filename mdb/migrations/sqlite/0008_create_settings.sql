@@ -0,0 +1,6 @@
+-- settings records how the data was written, so a store opened with different
+-- options can tell, e.g. canonical_mode is how emails.canonical was computed
+CREATE TABLE settings (
+	name TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
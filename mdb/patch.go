@@ -0,0 +1,142 @@
+package mdb
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+	"time"
+)
+
+// EmailPatch lists the fields PatchEmail changes, nil fields keep their value.
+// Attributes are merged into the email's, see Attributes.
+type EmailPatch struct {
+	ConfirmedAt *time.Time
+	OptOut *bool
+	Attributes Attributes
+}
+
+// PatchEmail changes the fields set in patch on an existing email, or on its subscription
+// to list if list isn't empty, in a single transaction so concurrent patches to other
+// fields aren't lost
+func (s *SQLStore) PatchEmail(list string, email string, patch EmailPatch) error {
+	address, canonical, err := s.opts.address(email)
+	if err != nil {
+		return err
+	}
+	// check the attributes first so an invalid one doesn't leave the email half updated
+	values, err := s.encodeAttributes(patch.Attributes)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	// no-op once the transaction has been committed
+	defer tx.Rollback()
+
+	emailID, err := s.emailID(tx, canonical)
+	if err == sql.ErrNoRows {
+		return EmailNotFound(address)
+	} else if err != nil {
+		log.Println(err)
+		return err
+	}
+
+	// only the patched columns are written, so the update never overwrites a value read earlier
+	var set []string
+	var args []interface{}
+	if patch.ConfirmedAt != nil {
+		set = append(set, "confirmed_at = ?")
+		args = append(args, patch.ConfirmedAt.Unix())
+	}
+	if patch.OptOut != nil {
+		set = append(set, "opt_out = ?")
+		args = append(args, *patch.OptOut)
+	}
+
+	if list == "" {
+		if len(set) > 0 {
+			_, err = s.txExec(tx, `UPDATE emails SET `+strings.Join(set, ", ")+` WHERE id = ?`, append(args, emailID)...)
+			if err != nil {
+				log.Println(err)
+				return err
+			}
+		}
+	} else {
+		var listID int64
+		err := tx.QueryRow(s.dialect.rebind(`SELECT id FROM lists WHERE name = ?`), list).Scan(&listID)
+		if err == sql.ErrNoRows {
+			return listNotFound(list)
+		} else if err != nil {
+			log.Println(err)
+			return err
+		}
+
+		// without columns to set the update still tells us whether the subscription exists
+		if len(set) == 0 {
+			set = append(set, "opt_out = opt_out")
+		}
+		res, err := s.txExec(tx, `
+			UPDATE subscriptions
+			SET `+strings.Join(set, ", ")+`
+			WHERE list_id = ? AND email_id = ?`, append(args, listID, emailID)...)
+		if err != nil {
+			log.Println(err)
+			return err
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return EmailNotFound(address)
+		}
+	}
+
+	if err := s.writeAttributes(tx, emailID, values); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// PatchEmail changes the fields set in patch on an existing email, or on its subscription
+// to list if list isn't empty
+func (m *MemoryStore) PatchEmail(list string, email string, patch EmailPatch) error {
+	address, canonical, err := m.opts.address(email)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	values, err := encodeAttributes(m.fieldList(), patch.Attributes)
+	if err != nil {
+		return err
+	}
+
+	entries := m.emails
+	if list != "" {
+		l, ok := m.lists[list]
+		if !ok {
+			return listNotFound(list)
+		}
+		entries = m.subscriptions[l.ID]
+	}
+
+	entry, ok := entries[canonical]
+	if !ok {
+		return EmailNotFound(address)
+	}
+	if patch.ConfirmedAt != nil {
+		entry.ConfirmedAt = patch.ConfirmedAt
+	}
+	if patch.OptOut != nil {
+		entry.OptOut = *patch.OptOut
+	}
+	entries[canonical] = copyEntry(entry)
+	m.writeAttributes(canonical, values)
+
+	return nil
+}
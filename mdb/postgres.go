@@ -0,0 +1,40 @@
+package mdb
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+// uniqueViolation is the SQLSTATE postgres reports for UNIQUE and PRIMARY KEY violations
+const uniqueViolation = "23505"
+
+// postgresDialect numbers placeholders as $1, $2, ...
+var postgresDialect = dialect{
+	name: "postgres",
+	rebind: func(query string) string {
+		var b strings.Builder
+		n := 0
+		for _, r := range query {
+			if r == '?' {
+				n++
+				b.WriteString("$" + strconv.Itoa(n))
+				continue
+			}
+			b.WriteRune(r)
+		}
+		return b.String()
+	},
+	isUniqueViolation: func(err error) bool {
+		var pqErr *pq.Error
+		return errors.As(err, &pqErr) && pqErr.Code == uniqueViolation
+	},
+}
+
+// NewPostgresStore creates a PostgreSQL backed store using an open DB connection
+func NewPostgresStore(db *sql.DB, opts Options) *SQLStore {
+	return &SQLStore{db: db, dialect: postgresDialect, opts: opts}
+}
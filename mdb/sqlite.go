@@ -0,0 +1,29 @@
+package mdb
+
+import (
+	"database/sql"
+	"errors"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// sqliteDialect uses ? placeholders as-is
+var sqliteDialect = dialect{
+	name: "sqlite",
+	rebind: func(query string) string {
+		return query
+	},
+	isUniqueViolation: func(err error) bool {
+		var sqliteErr sqlite3.Error
+		if !errors.As(err, &sqliteErr) {
+			return false
+		}
+		return sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique ||
+			sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey
+	},
+}
+
+// NewSQLiteStore creates a SQLite backed store using an open DB connection
+func NewSQLiteStore(db *sql.DB, opts Options) *SQLStore {
+	return &SQLStore{db: db, dialect: sqliteDialect, opts: opts}
+}
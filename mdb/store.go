@@ -0,0 +1,100 @@
+package mdb
+
+import "time"
+
+// Store is every operation a mailing list backend supports, SQLStore and MemoryStore
+// satisfy it. Code that only needs some of them depends on the smaller interfaces below.
+type Store interface {
+	EmailStore
+	ListStore
+	FieldStore
+	KeyStore
+	IdempotencyStore
+}
+
+// SubscriberStore is what the API servers serve: emails, lists and custom fields.
+// Any type satisfying it can be passed to jsonapi.Serve and grpcapi.Serve.
+type SubscriberStore interface {
+	EmailStore
+	ListStore
+	FieldStore
+}
+
+// EmailStore keeps email addresses on the global email list and their subscriptions to named lists
+type EmailStore interface {
+	// CreateEmail adds a new email to the mailing list
+	CreateEmail(email string) error
+	// GetEmail fetches an email entry, returning nil if it doesn't exist
+	GetEmail(email string) (*EmailEntry, error)
+	// UpdateEmail updates an email entry or creates it if it doesn't exist, merging its attributes
+	UpdateEmail(entry EmailEntry) error
+	// PatchEmail changes only the fields set in patch on an existing email, or on its subscription
+	// to list if list isn't empty, as a single update
+	PatchEmail(list string, email string, patch EmailPatch) error
+	// DeleteEmail opts an email out of the mailing list
+	DeleteEmail(email string) error
+	// FlagEmail records why an email's domain was flagged
+	FlagEmail(email string, reason string) error
+	// GetEmailBatch fetches a page of subscribed emails
+	GetEmailBatch(params GetEmailBatchQueryParams) ([]EmailEntry, error)
+
+	// Subscribe adds an email to a list, creating the email entry if needed
+	Subscribe(list string, email string) error
+	// GetSubscription fetches an email with its state for a list, returning nil if it isn't subscribed
+	GetSubscription(list string, email string) (*EmailEntry, error)
+	// UpdateSubscription updates an email's state for a list or subscribes it if needed
+	UpdateSubscription(list string, entry EmailEntry) error
+	// Unsubscribe opts an email out of a list
+	Unsubscribe(list string, email string) error
+	// GetSubscriptionBatch fetches a page of emails subscribed to a list
+	GetSubscriptionBatch(list string, params GetEmailBatchQueryParams) ([]EmailEntry, error)
+
+	// ImportEmails adds many emails to a list (or the global list if list is empty) at once,
+	// reporting the outcome for each one
+	ImportEmails(list string, entries []EmailEntry) ([]ImportResult, error)
+}
+
+// ListStore keeps the named mailing lists
+type ListStore interface {
+	// CreateList adds a new named mailing list with the policy for domains that fail the domain check
+	CreateList(name string, policy DomainPolicy) error
+	// GetList fetches a mailing list by name, returning nil if it doesn't exist
+	GetList(name string) (*List, error)
+	// GetLists fetches every mailing list
+	GetLists() ([]List, error)
+}
+
+// FieldStore keeps the custom attributes subscribers can have
+type FieldStore interface {
+	// CreateField defines a custom attribute subscribers can have
+	CreateField(field Field) error
+	// GetFields fetches every custom field
+	GetFields() ([]Field, error)
+	// SetAttributes merges attributes into an existing email's, see Attributes
+	SetAttributes(email string, attrs Attributes) error
+}
+
+// KeyStore keeps the API keys clients authenticate with
+type KeyStore interface {
+	// CreateAPIKey stores a new API key
+	CreateAPIKey(key APIKey) error
+	// GetAPIKey fetches an API key by its prefix, returning nil if it doesn't exist
+	GetAPIKey(prefix string) (*APIKey, error)
+	// GetAPIKeys fetches every API key, including revoked ones
+	GetAPIKeys() ([]APIKey, error)
+	// RevokeAPIKey stops an API key from authenticating
+	RevokeAPIKey(prefix string) error
+}
+
+// IdempotencyStore keeps the first response to requests sent with an idempotency key
+type IdempotencyStore interface {
+	// ReserveIdempotencyKey records that a request with an idempotency key is being handled,
+	// returning the existing record instead if the key was used after expiredBefore
+	ReserveIdempotencyKey(record IdempotencyRecord, expiredBefore time.Time) (*IdempotencyRecord, error)
+	// CompleteIdempotencyKey stores the response to replay for a reserved key
+	CompleteIdempotencyKey(key string, response []byte) error
+	// ReleaseIdempotencyKey forgets a reserved key so the request can be retried
+	ReleaseIdempotencyKey(key string) error
+	// DeleteExpiredIdempotencyKeys forgets keys created before expiredBefore
+	DeleteExpiredIdempotencyKeys(expiredBefore time.Time) (int64, error)
+}
@@ -0,0 +1,1002 @@
+package mdb
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// postgresDSNEnv names the database the postgres tests run against, they're skipped without it
+const postgresDSNEnv = "MAILINGLIST_TEST_POSTGRES_DSN"
+
+// migrated runs every migration on store and returns it
+func migrated(t *testing.T, store *SQLStore) *SQLStore {
+	if _, err := store.Migrate(false); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+// openSQLite opens an empty SQLite database in a temporary file
+func openSQLite(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), "list.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// newSQLiteStore opens a migrated SQLite store in a temporary file
+func newSQLiteStore(t *testing.T, opts Options) *SQLStore {
+	return migrated(t, NewSQLiteStore(openSQLite(t), opts))
+}
+
+// openPostgres opens a postgres database in an empty schema of its own, which is
+// dropped when the test ends. The test is skipped unless postgresDSNEnv is set.
+func openPostgres(t *testing.T) *sql.DB {
+	dsn := os.Getenv(postgresDSNEnv)
+	if dsn == "" {
+		t.Skipf("%v isn't set", postgresDSNEnv)
+	}
+
+	admin, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { admin.Close() })
+
+	schema := fmt.Sprintf("mdb_test_%d", time.Now().UnixNano())
+	if _, err := admin.Exec("CREATE SCHEMA " + schema); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if _, err := admin.Exec("DROP SCHEMA " + schema + " CASCADE"); err != nil {
+			t.Error(err)
+		}
+	})
+
+	// lib/pq sends parameters it doesn't know as run-time settings
+	if strings.Contains(dsn, "://") {
+		separator := "?"
+		if strings.Contains(dsn, "?") {
+			separator = "&"
+		}
+		dsn += separator + "search_path=" + schema
+	} else {
+		dsn += " search_path=" + schema
+	}
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// newPostgresStore opens a migrated postgres store, see openPostgres
+func newPostgresStore(t *testing.T, opts Options) *SQLStore {
+	return migrated(t, NewPostgresStore(openPostgres(t), opts))
+}
+
+// storeKinds creates a fresh, empty store of each kind the suite runs against
+var storeKinds = []struct {
+	name string
+	open func(t *testing.T, opts Options) Store
+}{
+	{"sqlite", func(t *testing.T, opts Options) Store { return newSQLiteStore(t, opts) }},
+	{"memory", func(t *testing.T, opts Options) Store { return NewMemoryStore(opts) }},
+	{"postgres", func(t *testing.T, opts Options) Store { return newPostgresStore(t, opts) }},
+}
+
+// storeTests is the suite every store has to pass, each test gets an empty store
+var storeTests = []struct {
+	name string
+	opts Options
+	test func(t *testing.T, store Store)
+}{
+	{name: "create and get email", test: testCreateEmail},
+	{name: "duplicate email", test: testDuplicateEmail},
+	{name: "invalid email", test: testInvalidEmail},
+	{name: "provider normalization", opts: Options{ProviderNormalization: true}, test: testProviderNormalization},
+	{name: "update email", test: testUpdateEmail},
+	{name: "patch email", test: testPatchEmail},
+	{name: "delete email", test: testDeleteEmail},
+	{name: "flag email", test: testFlagEmail},
+	{name: "new rows get increasing ids", test: testIDs},
+	{name: "page pagination", test: testPagePagination},
+	{name: "cursor pagination", test: testCursorPagination},
+	{name: "confirmed only", test: testConfirmedOnly},
+	{name: "lists", test: testLists},
+	{name: "subscriptions", test: testSubscriptions},
+	{name: "fields and attributes", test: testAttributes},
+	{name: "attribute filters", test: testAttributeFilters},
+	{name: "import", test: testImport},
+	{name: "import into list", test: testImportIntoList},
+	{name: "api keys", test: testAPIKeys},
+	{name: "idempotency keys", test: testIdempotencyKeys},
+}
+
+func TestStores(t *testing.T) {
+	for _, kind := range storeKinds {
+		t.Run(kind.name, func(t *testing.T) {
+			for _, tt := range storeTests {
+				t.Run(tt.name, func(t *testing.T) {
+					tt.test(t, kind.open(t, tt.opts))
+				})
+			}
+		})
+	}
+}
+
+// mustGetEmail fetches an email that has to exist
+func mustGetEmail(t *testing.T, store Store, email string) *EmailEntry {
+	t.Helper()
+	entry, err := store.GetEmail(email)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry == nil {
+		t.Fatalf("%v not found", email)
+	}
+	return entry
+}
+
+// batchEmails returns the addresses of a batch in order
+func batchEmails(t *testing.T, entries []EmailEntry, err error) []string {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	emails := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		emails = append(emails, entry.Email)
+	}
+	return emails
+}
+
+// expectEmails fails unless got lists exactly the want addresses, in order
+func expectEmails(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func testCreateEmail(t *testing.T, store Store) {
+	if err := store.CreateEmail(" Someone@Example.COM "); err != nil {
+		t.Fatal(err)
+	}
+
+	// stored trimmed with the domain lowercased, found whatever the case
+	entry := mustGetEmail(t, store, "someone@example.com")
+	if entry.Email != "Someone@example.com" {
+		t.Errorf("got email %q, want Someone@example.com", entry.Email)
+	}
+	if entry.ID <= 0 {
+		t.Errorf("got id %v, want a positive id", entry.ID)
+	}
+	if entry.OptOut {
+		t.Error("new email is opted out")
+	}
+	if entry.ConfirmedAt != nil && entry.ConfirmedAt.Unix() != 0 {
+		t.Errorf("new email is confirmed at %v", entry.ConfirmedAt)
+	}
+
+	missing, err := store.GetEmail("nobody@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if missing != nil {
+		t.Errorf("got %v for a missing email", missing)
+	}
+}
+
+// the unique index on emails.canonical is mapped to ErrAlreadyExists in every dialect
+func testDuplicateEmail(t *testing.T, store Store) {
+	if err := store.CreateEmail("someone@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	// addresses that only differ by case are the same subscriber
+	err := store.CreateEmail("SOMEONE@example.com")
+	if !errors.Is(err, ErrAlreadyExists) {
+		t.Fatalf("got %v, want ErrAlreadyExists", err)
+	}
+	var mdbErr *Error
+	if !errors.As(err, &mdbErr) || mdbErr.Resource != "email" {
+		t.Errorf("got %#v, want an email Error", err)
+	}
+}
+
+func testInvalidEmail(t *testing.T, store Store) {
+	for _, email := range []string{"", "not an address", "Name <someone@example.com>", "someone@localhost"} {
+		if err := store.CreateEmail(email); !errors.Is(err, ErrInvalid) {
+			t.Errorf("CreateEmail(%q) got %v, want ErrInvalid", email, err)
+		}
+	}
+}
+
+func testProviderNormalization(t *testing.T, store Store) {
+	if err := store.CreateEmail("Some.One+news@gmail.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	entry := mustGetEmail(t, store, "someone@googlemail.com")
+	if entry.Email != "Some.One+news@gmail.com" {
+		t.Errorf("got email %q, the address should be kept as it was written", entry.Email)
+	}
+	if err := store.CreateEmail("someone@gmail.com"); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("got %v, want ErrAlreadyExists", err)
+	}
+}
+
+func testUpdateEmail(t *testing.T, store Store) {
+	confirmedAt := time.Unix(1700000000, 0)
+	// updating a missing email creates it
+	if err := store.UpdateEmail(EmailEntry{Email: "someone@example.com", ConfirmedAt: &confirmedAt}); err != nil {
+		t.Fatal(err)
+	}
+
+	entry := mustGetEmail(t, store, "someone@example.com")
+	if entry.ConfirmedAt == nil || !entry.ConfirmedAt.Equal(confirmedAt) {
+		t.Errorf("got confirmed at %v, want %v", entry.ConfirmedAt, confirmedAt)
+	}
+
+	entry.OptOut = true
+	if err := store.UpdateEmail(*entry); err != nil {
+		t.Fatal(err)
+	}
+	updated := mustGetEmail(t, store, "someone@example.com")
+	if !updated.OptOut || updated.ID != entry.ID {
+		t.Errorf("got %+v, want %+v", updated, entry)
+	}
+}
+
+func testPatchEmail(t *testing.T, store Store) {
+	if err := store.CreateField(Field{Name: "vip", Type: FieldBool}); err != nil {
+		t.Fatal(err)
+	}
+	confirmedAt := time.Unix(1700000000, 0)
+	if err := store.UpdateEmail(EmailEntry{Email: "someone@example.com", ConfirmedAt: &confirmedAt}); err != nil {
+		t.Fatal(err)
+	}
+
+	// fields left out of the patch keep their value
+	optOut := true
+	if err := store.PatchEmail("", "someone@example.com", EmailPatch{OptOut: &optOut}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.PatchEmail("", "someone@example.com", EmailPatch{Attributes: Attributes{"vip": true}}); err != nil {
+		t.Fatal(err)
+	}
+	entry := mustGetEmail(t, store, "someone@example.com")
+	if !entry.OptOut || entry.ConfirmedAt.Unix() != confirmedAt.Unix() || entry.Attributes["vip"] != true {
+		t.Errorf("got %+v after patching, want opted out, confirmed at %v and vip", entry, confirmedAt.Unix())
+	}
+
+	if err := store.PatchEmail("", "nobody@example.com", EmailPatch{OptOut: &optOut}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v for a missing email, want ErrNotFound", err)
+	}
+	if err := store.PatchEmail("", "someone@example.com", EmailPatch{Attributes: Attributes{"vip": "yes"}}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("got %v for an invalid attribute, want ErrInvalid", err)
+	}
+
+	// with a list only the subscription changes
+	if err := store.CreateList("news", DomainPolicyDefault); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Subscribe("news", "other@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.PatchEmail("news", "other@example.com", EmailPatch{ConfirmedAt: &confirmedAt}); err != nil {
+		t.Fatal(err)
+	}
+	sub, err := store.GetSubscription("news", "other@example.com")
+	if err != nil || sub == nil || sub.ConfirmedAt.Unix() != confirmedAt.Unix() || sub.OptOut {
+		t.Errorf("got %+v, %v after patching the subscription", sub, err)
+	}
+	if mustGetEmail(t, store, "other@example.com").ConfirmedAt.Unix() != 0 {
+		t.Error("patching a subscription confirmed the email on the global list")
+	}
+
+	if err := store.PatchEmail("news", "someone@example.com", EmailPatch{}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v for an email that isn't subscribed, want ErrNotFound", err)
+	}
+	if err := store.PatchEmail("missing", "other@example.com", EmailPatch{}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v for a missing list, want ErrNotFound", err)
+	}
+}
+
+func testDeleteEmail(t *testing.T, store Store) {
+	for _, email := range []string{"a@example.com", "b@example.com"} {
+		if err := store.CreateEmail(email); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.DeleteEmail("a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !mustGetEmail(t, store, "a@example.com").OptOut {
+		t.Error("deleted email isn't opted out")
+	}
+
+	entries, err := store.GetEmailBatch(GetEmailBatchQueryParams{Page: 1, Count: 10})
+	expectEmails(t, batchEmails(t, entries, err), "b@example.com")
+	entries, err = store.GetEmailBatch(GetEmailBatchQueryParams{Count: 10, IncludeOptOut: true})
+	expectEmails(t, batchEmails(t, entries, err), "a@example.com", "b@example.com")
+}
+
+// postgres has no LastInsertId, so ids come from its sequence
+func testFlagEmail(t *testing.T, store Store) {
+	if err := store.CreateList("news", DomainPolicyFlag); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CreateEmail("someone@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Subscribe("news", "someone@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.FlagEmail("someone@example.com", "no MX records"); err != nil {
+		t.Fatal(err)
+	}
+	if entry := mustGetEmail(t, store, "someone@example.com"); entry.DomainFlag != "no MX records" {
+		t.Errorf("got flag %q, want no MX records", entry.DomainFlag)
+	}
+	// the flag belongs to the address, so every list sees it
+	sub, err := store.GetSubscription("news", "someone@example.com")
+	if err != nil || sub == nil || sub.DomainFlag != "no MX records" {
+		t.Errorf("got %+v, %v, want the subscription flagged", sub, err)
+	}
+}
+
+func testIDs(t *testing.T, store Store) {
+	var last int64
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com"} {
+		if err := store.CreateEmail(email); err != nil {
+			t.Fatal(err)
+		}
+		id := mustGetEmail(t, store, email).ID
+		if id <= last {
+			t.Errorf("%v got id %v after %v", email, id, last)
+		}
+		last = id
+	}
+}
+
+func testPagePagination(t *testing.T, store Store) {
+	for _, email := range []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com"} {
+		if err := store.CreateEmail(email); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// pages start at 1 and are ordered by id
+	entries, err := store.GetEmailBatch(GetEmailBatchQueryParams{Page: 1, Count: 2})
+	expectEmails(t, batchEmails(t, entries, err), "a@example.com", "b@example.com")
+	entries, err = store.GetEmailBatch(GetEmailBatchQueryParams{Page: 3, Count: 2})
+	expectEmails(t, batchEmails(t, entries, err), "e@example.com")
+	entries, err = store.GetEmailBatch(GetEmailBatchQueryParams{Page: 4, Count: 2})
+	expectEmails(t, batchEmails(t, entries, err))
+}
+
+func testCursorPagination(t *testing.T, store Store) {
+	want := []string{"a@example.com", "b@example.com", "c@example.com", "d@example.com", "e@example.com"}
+	for _, email := range want {
+		if err := store.CreateEmail(email); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []string
+	params := GetEmailBatchQueryParams{Count: 2}
+	for pages := 0; ; pages++ {
+		if pages > len(want) {
+			t.Fatal("cursor never reached the last page")
+		}
+		entries, err := store.GetEmailBatch(params)
+		got = append(got, batchEmails(t, entries, err)...)
+		params.Cursor = NextCursor(entries, params)
+		if params.Cursor == "" {
+			break
+		}
+	}
+	expectEmails(t, got, want...)
+
+	// unlike offsets, opting out an email before the cursor doesn't skip a row on the next page
+	entries, err := store.GetEmailBatch(GetEmailBatchQueryParams{Count: 2})
+	first := batchEmails(t, entries, err)
+	if err := store.DeleteEmail("a@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	entries, err = store.GetEmailBatch(GetEmailBatchQueryParams{Count: 2, Cursor: NextCursor(entries, GetEmailBatchQueryParams{Count: 2})})
+	expectEmails(t, append(first, batchEmails(t, entries, err)...), want[:4]...)
+
+	if _, err := store.GetEmailBatch(GetEmailBatchQueryParams{Count: 2, Cursor: "not a cursor"}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("got %v for an invalid cursor, want ErrInvalid", err)
+	}
+}
+
+func testConfirmedOnly(t *testing.T, store Store) {
+	confirmedAt := time.Unix(1700000000, 0)
+	if err := store.UpdateEmail(EmailEntry{Email: "confirmed@example.com", ConfirmedAt: &confirmedAt}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CreateEmail("pending@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := store.GetEmailBatch(GetEmailBatchQueryParams{Page: 1, Count: 10, ConfirmedOnly: true})
+	expectEmails(t, batchEmails(t, entries, err), "confirmed@example.com")
+}
+
+func testLists(t *testing.T, store Store) {
+	if err := store.CreateList("news", DomainPolicyFlag); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CreateList("offers", DomainPolicyReject); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CreateList("news", DomainPolicyReject); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("got %v for a duplicate list, want ErrAlreadyExists", err)
+	}
+	if err := store.CreateList("bad", DomainPolicy("maybe")); !errors.Is(err, ErrInvalid) {
+		t.Errorf("got %v for an invalid policy, want ErrInvalid", err)
+	}
+
+	list, err := store.GetList("news")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list == nil || list.Name != "news" || list.ID <= 0 || list.DomainPolicy != DomainPolicyFlag {
+		t.Errorf("got %+v, want news with the flag policy", list)
+	}
+
+	missing, err := store.GetList("missing")
+	if err != nil || missing != nil {
+		t.Errorf("got %v, %v for a missing list, want nil, nil", missing, err)
+	}
+
+	lists, err := store.GetLists()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, l := range lists {
+		names = append(names, l.Name)
+	}
+	expectEmails(t, names, "news", "offers")
+}
+
+func testSubscriptions(t *testing.T, store Store) {
+	if err := store.CreateList("news", DomainPolicyDefault); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Subscribe("news", "someone@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Subscribe("news", "someone@example.com"); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("got %v subscribing twice, want ErrAlreadyExists", err)
+	}
+	if err := store.Subscribe("missing", "someone@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v for a missing list, want ErrNotFound", err)
+	}
+
+	// subscribing creates the email on the global list too
+	mustGetEmail(t, store, "someone@example.com")
+
+	sub, err := store.GetSubscription("news", "someone@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub == nil || sub.Email != "someone@example.com" || sub.OptOut {
+		t.Fatalf("got subscription %+v", sub)
+	}
+
+	// the list keeps its own state
+	if err := store.Unsubscribe("news", "someone@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if sub, err := store.GetSubscription("news", "someone@example.com"); err != nil || sub == nil || !sub.OptOut {
+		t.Errorf("got %+v, %v after unsubscribing, want an opted out subscription", sub, err)
+	}
+	if mustGetEmail(t, store, "someone@example.com").OptOut {
+		t.Error("unsubscribing from a list opted out of the global list")
+	}
+
+	confirmedAt := time.Unix(1700000000, 0)
+	if err := store.UpdateSubscription("news", EmailEntry{Email: "other@example.com", ConfirmedAt: &confirmedAt}); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := store.GetSubscriptionBatch("news", GetEmailBatchQueryParams{Page: 1, Count: 10})
+	expectEmails(t, batchEmails(t, entries, err), "other@example.com")
+	entries, err = store.GetSubscriptionBatch("news", GetEmailBatchQueryParams{Count: 10, IncludeOptOut: true})
+	expectEmails(t, batchEmails(t, entries, err), "someone@example.com", "other@example.com")
+
+	none, err := store.GetSubscription("news", "nobody@example.com")
+	if err != nil || none != nil {
+		t.Errorf("got %v, %v for an email that isn't subscribed, want nil, nil", none, err)
+	}
+}
+
+func testAttributes(t *testing.T, store Store) {
+	for _, field := range []Field{{Name: "first_name", Type: FieldString}, {Name: "age", Type: FieldNumber}, {Name: "vip", Type: FieldBool}} {
+		if err := store.CreateField(field); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.CreateField(Field{Name: "age", Type: FieldString}); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("got %v for a duplicate field, want ErrAlreadyExists", err)
+	}
+	if err := store.CreateField(Field{Name: "Bad Name", Type: FieldString}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("got %v for an invalid field, want ErrInvalid", err)
+	}
+
+	attrs := Attributes{"first_name": "Ann", "age": float64(31)}
+	if err := CreateWithAttributes(store, store, "", "ann@example.com", attrs); err != nil {
+		t.Fatal(err)
+	}
+	entry := mustGetEmail(t, store, "ann@example.com")
+	if entry.Attributes["first_name"] != "Ann" || entry.Attributes["age"] != float64(31) {
+		t.Errorf("got attributes %v, want %v", entry.Attributes, attrs)
+	}
+
+	// attributes are merged, nil removes one
+	if err := store.SetAttributes("ann@example.com", Attributes{"vip": true, "age": nil}); err != nil {
+		t.Fatal(err)
+	}
+	entry = mustGetEmail(t, store, "ann@example.com")
+	if _, ok := entry.Attributes["age"]; ok || entry.Attributes["vip"] != true || entry.Attributes["first_name"] != "Ann" {
+		t.Errorf("got attributes %v after merging", entry.Attributes)
+	}
+
+	if err := store.SetAttributes("ann@example.com", Attributes{"age": "old"}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("got %v for a value of the wrong type, want ErrInvalid", err)
+	}
+	if err := store.SetAttributes("ann@example.com", Attributes{"missing": "x"}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("got %v for an unknown field, want ErrInvalid", err)
+	}
+	if err := store.SetAttributes("nobody@example.com", Attributes{"vip": true}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v for a missing email, want ErrNotFound", err)
+	}
+}
+
+func testAttributeFilters(t *testing.T, store Store) {
+	if err := store.CreateField(Field{Name: "age", Type: FieldNumber}); err != nil {
+		t.Fatal(err)
+	}
+	for email, age := range map[string]float64{"a@example.com": 17, "b@example.com": 18, "c@example.com": 40} {
+		if err := CreateWithAttributes(store, store, "", email, Attributes{"age": age}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.CreateEmail("d@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	filters, err := ParseFilters([]string{"age>=18"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := store.GetEmailBatch(GetEmailBatchQueryParams{Count: 10, Filters: filters})
+	got := batchEmails(t, entries, err)
+	// map order decides the ids, so compare as a set
+	if len(got) != 2 || !strings.Contains(strings.Join(got, ","), "b@example.com") || !strings.Contains(strings.Join(got, ","), "c@example.com") {
+		t.Errorf("got %v, want b@example.com and c@example.com", got)
+	}
+
+	filters, err = ParseFilters([]string{"missing=1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetEmailBatch(GetEmailBatchQueryParams{Count: 10, Filters: filters}); !errors.Is(err, ErrInvalid) {
+		t.Errorf("got %v for a filter on an unknown field, want ErrInvalid", err)
+	}
+}
+
+// importStatuses returns the status of each result by email
+func importStatuses(t *testing.T, results []ImportResult, err error) map[string]ImportStatus {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+	statuses := make(map[string]ImportStatus, len(results))
+	for _, result := range results {
+		statuses[result.Email] = result.Status
+	}
+	return statuses
+}
+
+func testImport(t *testing.T, store Store) {
+	if err := store.CreateField(Field{Name: "age", Type: FieldNumber}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CreateEmail("existing@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CreateEmail("gone@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.DeleteEmail("gone@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := store.ImportEmails("", []EmailEntry{
+		{Email: "new@example.com", Attributes: Attributes{"age": "42"}},
+		{Email: "existing@example.com"},
+		{Email: "gone@example.com"},
+		{Email: "not an address"},
+		{Email: "Someone <named@example.com>"},
+		{Email: "typo@example.com", Attributes: Attributes{"age": "old"}},
+	})
+	statuses := importStatuses(t, results, err)
+	want := map[string]ImportStatus{
+		"new@example.com": ImportCreated,
+		"existing@example.com": ImportAlreadyExists,
+		"gone@example.com": ImportSuppressed,
+		"not an address": ImportInvalid,
+		"Someone <named@example.com>": ImportInvalid,
+		"typo@example.com": ImportInvalid,
+	}
+	if len(results) != len(want) {
+		t.Errorf("got %v results, want %v", len(results), len(want))
+	}
+	for email, status := range want {
+		if statuses[email] != status {
+			t.Errorf("%v got %v, want %v", email, statuses[email], status)
+		}
+	}
+
+	if entry := mustGetEmail(t, store, "gone@example.com"); !entry.OptOut {
+		t.Errorf("got %+v, a suppressed email should stay opted out", entry)
+	}
+
+	// string values are converted to the field's type
+	if age := mustGetEmail(t, store, "new@example.com").Attributes["age"]; age != float64(42) {
+		t.Errorf("got age %#v, want 42", age)
+	}
+	if entry, err := store.GetEmail("typo@example.com"); err != nil || entry != nil {
+		t.Errorf("got %v, %v, an invalid entry shouldn't be imported", entry, err)
+	}
+}
+
+func testImportIntoList(t *testing.T, store Store) {
+	if _, err := store.ImportEmails("missing", []EmailEntry{{Email: "a@example.com"}}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v for a missing list, want ErrNotFound", err)
+	}
+
+	if err := store.CreateList("news", DomainPolicyDefault); err != nil {
+		t.Fatal(err)
+	}
+	results, err := store.ImportEmails("news", []EmailEntry{{Email: "a@example.com"}, {Email: "b@example.com", OptOut: true}})
+	statuses := importStatuses(t, results, err)
+	if statuses["a@example.com"] != ImportCreated || statuses["b@example.com"] != ImportCreated {
+		t.Errorf("got %v, want both created", statuses)
+	}
+
+	entries, err := store.GetSubscriptionBatch("news", GetEmailBatchQueryParams{Count: 10})
+	expectEmails(t, batchEmails(t, entries, err), "a@example.com")
+}
+
+func testAPIKeys(t *testing.T, store Store) {
+	key := APIKey{Name: "ci", Prefix: "abc123", Hash: "hash", Scopes: []string{"read", "write"}, CreatedAt: time.Unix(1700000000, 0)}
+	if err := store.CreateAPIKey(key); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CreateAPIKey(key); !errors.Is(err, ErrAlreadyExists) {
+		t.Errorf("got %v for a duplicate prefix, want ErrAlreadyExists", err)
+	}
+
+	got, err := store.GetAPIKey("abc123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil || got.Name != "ci" || got.Hash != "hash" || strings.Join(got.Scopes, ",") != "read,write" || got.RevokedAt != nil {
+		t.Fatalf("got %+v, want %+v", got, key)
+	}
+
+	if err := store.RevokeAPIKey("abc123"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := store.GetAPIKey("abc123"); err != nil || got.RevokedAt == nil {
+		t.Errorf("got %+v, %v after revoking, want a revoked key", got, err)
+	}
+	if err := store.RevokeAPIKey("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("got %v for a missing key, want ErrNotFound", err)
+	}
+
+	keys, err := store.GetAPIKeys()
+	if err != nil || len(keys) != 1 {
+		t.Errorf("got %v, %v, want the revoked key", keys, err)
+	}
+	if missing, err := store.GetAPIKey("missing"); err != nil || missing != nil {
+		t.Errorf("got %v, %v for a missing key, want nil, nil", missing, err)
+	}
+}
+
+func testIdempotencyKeys(t *testing.T, store Store) {
+	now := time.Unix(1700000000, 0)
+	window := now.Add(-time.Hour)
+	record := IdempotencyRecord{Key: "k1", Fingerprint: "POST /v1/emails", CreatedAt: now}
+
+	existing, err := store.ReserveIdempotencyKey(record, window)
+	if err != nil || existing != nil {
+		t.Fatalf("got %v, %v reserving a new key, want nil, nil", existing, err)
+	}
+
+	// the key's primary key is mapped to the existing record
+	existing, err = store.ReserveIdempotencyKey(record, window)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if existing == nil || existing.Fingerprint != record.Fingerprint || existing.Response != nil {
+		t.Fatalf("got %+v, want the pending record", existing)
+	}
+
+	if err := store.CompleteIdempotencyKey("k1", []byte("response")); err != nil {
+		t.Fatal(err)
+	}
+	existing, err = store.ReserveIdempotencyKey(record, window)
+	if err != nil || existing == nil || string(existing.Response) != "response" {
+		t.Fatalf("got %+v, %v, want the completed record", existing, err)
+	}
+
+	// a released key can be reserved again
+	if err := store.ReleaseIdempotencyKey("k1"); err != nil {
+		t.Fatal(err)
+	}
+	if existing, err := store.ReserveIdempotencyKey(record, window); err != nil || existing != nil {
+		t.Errorf("got %v, %v after releasing, want nil, nil", existing, err)
+	}
+
+	// so can an expired one
+	if existing, err := store.ReserveIdempotencyKey(record, now.Add(time.Second)); err != nil || existing != nil {
+		t.Errorf("got %v, %v for an expired key, want nil, nil", existing, err)
+	}
+
+	n, err := store.DeleteExpiredIdempotencyKeys(now.Add(time.Second))
+	if err != nil || n != 1 {
+		t.Errorf("got %v, %v deleting expired keys, want 1", n, err)
+	}
+}
+
+// sqlStoreKinds creates each SQL store for the tests of dialect specific SQL
+var sqlStoreKinds = []struct {
+	name string
+	open func(t *testing.T, opts Options) *SQLStore
+	// openDB and newStore create the store without migrating it
+	openDB func(t *testing.T) *sql.DB
+	newStore func(db *sql.DB, opts Options) *SQLStore
+}{
+	{"sqlite", newSQLiteStore, openSQLite, NewSQLiteStore},
+	{"postgres", newPostgresStore, openPostgres, NewPostgresStore},
+}
+
+func TestValidateBatchParams(t *testing.T) {
+	tests := []struct {
+		name string
+		params GetEmailBatchQueryParams
+		valid bool
+	}{
+		{"cursor", GetEmailBatchQueryParams{Count: 10, Cursor: EncodeCursor(5)}, true},
+		{"page", GetEmailBatchQueryParams{Page: 2, Count: 10}, true},
+		{"largest count", GetEmailBatchQueryParams{Count: MaxBatchCount}, true},
+		{"no count", GetEmailBatchQueryParams{}, false},
+		{"count too large", GetEmailBatchQueryParams{Count: MaxBatchCount + 1}, false},
+		{"huge count", GetEmailBatchQueryParams{Count: 2147483647}, false},
+		{"negative page", GetEmailBatchQueryParams{Page: -1, Count: 10}, false},
+		{"cursor with page", GetEmailBatchQueryParams{Page: 1, Count: 10, Cursor: EncodeCursor(5)}, false},
+		{"invalid cursor", GetEmailBatchQueryParams{Count: 10, Cursor: "nope"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.params.Validate()
+			if tt.valid && err != nil {
+				t.Errorf("got %v, want valid", err)
+			}
+			if !tt.valid && !errors.Is(err, ErrInvalid) {
+				t.Errorf("got %v, want ErrInvalid", err)
+			}
+		})
+	}
+}
+
+func TestRebind(t *testing.T) {
+	query := `SELECT id FROM emails WHERE email = ? AND opt_out = ? LIMIT ?`
+	tests := []struct {
+		dialect dialect
+		want string
+	}{
+		{sqliteDialect, query},
+		{postgresDialect, `SELECT id FROM emails WHERE email = $1 AND opt_out = $2 LIMIT $3`},
+	}
+
+	for _, tt := range tests {
+		if got := tt.dialect.rebind(query); got != tt.want {
+			t.Errorf("%v rebind got %q, want %q", tt.dialect.name, got, tt.want)
+		}
+	}
+}
+
+func TestDialects(t *testing.T) {
+	for _, kind := range sqlStoreKinds {
+		t.Run(kind.name, func(t *testing.T) {
+			store := kind.open(t, Options{})
+
+			// rebound placeholders work against the real database
+			if _, err := store.exec(`INSERT INTO emails(email, canonical, confirmed_at, opt_out) VALUES (?, ?, ?, ?)`, "someone@example.com", "someone@example.com", 0, false); err != nil {
+				t.Fatal(err)
+			}
+			var count int
+			row := store.db.QueryRow(store.dialect.rebind(`SELECT COUNT(*) FROM emails WHERE email = ?`), "someone@example.com")
+			if err := row.Scan(&count); err != nil {
+				t.Fatal(err)
+			}
+			if count != 1 {
+				t.Errorf("got %v rows, want 1", count)
+			}
+
+			// only constraint errors are unique violations
+			_, err := store.exec(`INSERT INTO emails(email, canonical, confirmed_at, opt_out) VALUES (?, ?, ?, ?)`, "Someone@example.com", "someone@example.com", 0, false)
+			if err == nil || !store.dialect.isUniqueViolation(err) {
+				t.Errorf("got %v inserting a duplicate, want a unique violation", err)
+			}
+			_, err = store.exec(`INSERT INTO missing_table(name) VALUES (?)`, "news")
+			if err == nil || store.dialect.isUniqueViolation(err) {
+				t.Errorf("got %v for a missing table, want an error that isn't a unique violation", err)
+			}
+			if store.dialect.isUniqueViolation(nil) {
+				t.Error("nil is a unique violation")
+			}
+		})
+	}
+}
+
+func TestCanonicalModeChange(t *testing.T) {
+	for _, kind := range sqlStoreKinds {
+		t.Run(kind.name, func(t *testing.T) {
+			store := kind.open(t, Options{})
+			for _, email := range []string{"Some.One@gmail.com", "someone+news@gmail.com", "other@example.com"} {
+				if err := store.CreateEmail(email); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := store.CreateList("news", DomainPolicyDefault); err != nil {
+				t.Fatal(err)
+			}
+			for _, field := range []Field{{Name: "first_name", Type: FieldString}, {Name: "age", Type: FieldNumber}} {
+				if err := store.CreateField(field); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// the newer alias is confirmed, opted out of the global list, subscribed and has attributes
+			confirmedAt := time.Unix(1700000000, 0)
+			if err := store.UpdateEmail(EmailEntry{Email: "someone+news@gmail.com", ConfirmedAt: &confirmedAt, OptOut: true}); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.Subscribe("news", "someone+news@gmail.com"); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.SetAttributes("Some.One@gmail.com", Attributes{"first_name": "Ann"}); err != nil {
+				t.Fatal(err)
+			}
+			if err := store.SetAttributes("someone+news@gmail.com", Attributes{"first_name": "Annie", "age": float64(31)}); err != nil {
+				t.Fatal(err)
+			}
+
+			// reopening with provider normalization merges the gmail aliases
+			store.opts = Options{ProviderNormalization: true}
+			duplicates, err := store.syncCanonical()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(duplicates) != 1 || duplicates[0].Canonical != "someone@gmail.com" || len(duplicates[0].IDs) != 2 {
+				t.Fatalf("got duplicates %+v, want the two gmail addresses", duplicates)
+			}
+
+			entry := mustGetEmail(t, store, "someone@googlemail.com")
+			if entry.Email != "Some.One@gmail.com" {
+				t.Errorf("got %v, the oldest email should be kept", entry.Email)
+			}
+			if entry.ConfirmedAt == nil || !entry.ConfirmedAt.Equal(confirmedAt) || !entry.OptOut {
+				t.Errorf("got %+v, want the latest confirmation and the opt out", entry)
+			}
+			if entry.Attributes["first_name"] != "Ann" || entry.Attributes["age"] != float64(31) {
+				t.Errorf("got attributes %v, want the oldest email's to win", entry.Attributes)
+			}
+			if sub, err := store.GetSubscription("news", "Some.One@gmail.com"); err != nil || sub == nil || sub.Email != "Some.One@gmail.com" {
+				t.Errorf("got %+v, %v, want the subscription moved to the kept email", sub, err)
+			}
+			var count int
+			if err := store.db.QueryRow(`SELECT COUNT(*) FROM emails`).Scan(&count); err != nil || count != 2 {
+				t.Errorf("got %v, %v emails, want the duplicate deleted", count, err)
+			}
+
+			// nothing changes while the mode stays the same
+			if duplicates, err := store.syncCanonical(); err != nil || duplicates != nil {
+				t.Errorf("got %v, %v syncing again, want nil, nil", duplicates, err)
+			}
+
+			// merging is one way, switching back doesn't bring the alias back
+			store.opts = Options{}
+			if duplicates, err := store.syncCanonical(); err != nil || len(duplicates) != 0 {
+				t.Errorf("got %v, %v switching back, want no duplicates", duplicates, err)
+			}
+			mustGetEmail(t, store, "some.one@gmail.com")
+			if entry, err := store.GetEmail("someone+news@gmail.com"); err != nil || entry != nil {
+				t.Errorf("got %v, %v, want the merged alias gone", entry, err)
+			}
+		})
+	}
+}
+
+func TestMigrateDuplicates(t *testing.T) {
+	for _, kind := range sqlStoreKinds {
+		t.Run(kind.name, func(t *testing.T) {
+			db := kind.openDB(t)
+			store := kind.newStore(db, Options{})
+
+			// a database from before versioning, when email was only unique as written
+			legacy, err := store.Migrations()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := db.Exec(legacy[0].SQL); err != nil {
+				t.Fatal(err)
+			}
+			rows := []struct {
+				email string
+				confirmedAt int64
+				optOut bool
+			}{
+				{"Foo@example.com", 1700000000, false},
+				{"bar@example.com", 0, false},
+				{"foo@example.com", 0, true},
+				{" FOO@example.com ", 1800000000, false},
+			}
+			for _, row := range rows {
+				if _, err := store.exec(`INSERT INTO emails(email, confirmed_at, opt_out) VALUES (?, ?, ?)`, row.email, row.confirmedAt, row.optOut); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			migrated(t, store)
+
+			entry := mustGetEmail(t, store, "foo@example.com")
+			if entry.Email != "Foo@example.com" || entry.ConfirmedAt == nil || entry.ConfirmedAt.Unix() != 1800000000 || !entry.OptOut {
+				t.Errorf("got %+v, want the oldest email with the latest confirmation and the opt out", entry)
+			}
+			mustGetEmail(t, store, "bar@example.com")
+
+			// no rows are left that can't be looked up by address
+			var count, orphans int
+			if err := db.QueryRow(`SELECT COUNT(*), COUNT(*) - COUNT(canonical) FROM emails`).Scan(&count, &orphans); err != nil {
+				t.Fatal(err)
+			}
+			if count != 2 || orphans != 0 {
+				t.Errorf("got %v emails, %v without a canonical address, want 2 and 0", count, orphans)
+			}
+
+			// the canonical address replaced the unique constraint on email
+			if err := store.CreateEmail("FOO@example.com"); !errors.Is(err, ErrAlreadyExists) {
+				t.Errorf("got %v, want ErrAlreadyExists", err)
+			}
+			if _, err := store.exec(`INSERT INTO emails(email, canonical, confirmed_at, opt_out) VALUES (?, ?, ?, ?)`, "bar@example.com", "elsewhere", 0, false); err != nil {
+				t.Errorf("got %v, want email to no longer be unique on its own", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+// Package openapi models the parts of an OpenAPI 3 document the HTTP APIs describe themselves with
+package openapi
+
+// Document is an OpenAPI 3 document
+type Document struct {
+	OpenAPI string `json:"openapi"`
+	Info Info `json:"info"`
+	Paths map[string]PathItem `json:"paths"`
+	Components Components `json:"components"`
+}
+
+type Info struct {
+	Title string `json:"title"`
+	Version string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem maps lowercase HTTP methods to their operation
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string `json:"operationId,omitempty"`
+	Summary string `json:"summary"`
+	Description string `json:"description,omitempty"`
+	Deprecated bool `json:"deprecated,omitempty"`
+	Parameters []Parameter `json:"parameters,omitempty"`
+	RequestBody *RequestBody `json:"requestBody,omitempty"`
+	Responses map[string]Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name string `json:"name"`
+	In string `json:"in"`
+	Description string `json:"description,omitempty"`
+	Required bool `json:"required,omitempty"`
+	Schema *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool `json:"required,omitempty"`
+	Content map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string `json:"description"`
+	Headers map[string]Header `json:"headers,omitempty"`
+	Content map[string]MediaType `json:"content,omitempty"`
+}
+
+type Header struct {
+	Description string `json:"description"`
+	Schema *Schema `json:"schema"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// Schema is the subset of JSON Schema used by OpenAPI 3.0
+type Schema struct {
+	Ref string `json:"$ref,omitempty"`
+	Type string `json:"type,omitempty"`
+	Format string `json:"format,omitempty"`
+	Description string `json:"description,omitempty"`
+	Nullable bool `json:"nullable,omitempty"`
+	Enum []string `json:"enum,omitempty"`
+	Default interface{} `json:"default,omitempty"`
+	Maximum int `json:"maximum,omitempty"`
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *Schema `json:"additionalProperties,omitempty"`
+	Required []string `json:"required,omitempty"`
+	Items *Schema `json:"items,omitempty"`
+	OneOf []*Schema `json:"oneOf,omitempty"`
+}
+
+// Ref refers to a schema under components/schemas
+func Ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// JSONContent is a JSON body with schema s
+func JSONContent(s *Schema) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: s}}
+}
+
+// WithParams adds parameters to an operation
+func (o *Operation) WithParams(params ...Parameter) *Operation {
+	o.Parameters = append(o.Parameters, params...)
+	return o
+}
+
+// WithBody adds a JSON request body to an operation
+func (o *Operation) WithBody(s *Schema) *Operation {
+	o.RequestBody = &RequestBody{Content: JSONContent(s)}
+	return o
+}
+
+// Deprecate marks an operation as deprecated, description says what to use instead
+func (o *Operation) Deprecate(description string) *Operation {
+	o.Deprecated = true
+	o.Description = description
+	return o
+}
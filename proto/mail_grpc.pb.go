@@ -24,9 +24,19 @@ const _ = grpc.SupportPackageIsVersion7
 type MailingListServiceClient interface {
 	CreateEmail(ctx context.Context, in *CreateEmailRequest, opts ...grpc.CallOption) (*EmailResponse, error)
 	GetEmail(ctx context.Context, in *GetEmailRequest, opts ...grpc.CallOption) (*EmailResponse, error)
+	// changes the fields in update_mask, or without one replaces the whole entry: unset
+	// fields are cleared except attributes, which are merged
 	UpdateEmail(ctx context.Context, in *UpdateEmailRequest, opts ...grpc.CallOption) (*EmailResponse, error)
 	DeleteEmail(ctx context.Context, in *DeleteEmailRequest, opts ...grpc.CallOption) (*EmailResponse, error)
+	ConfirmEmail(ctx context.Context, in *ConfirmEmailRequest, opts ...grpc.CallOption) (*EmailResponse, error)
 	GetEmailBatch(ctx context.Context, in *GetEmailBatchRequest, opts ...grpc.CallOption) (*GetEmailBatchResponse, error)
+	StreamEmails(ctx context.Context, in *StreamEmailsRequest, opts ...grpc.CallOption) (MailingListService_StreamEmailsClient, error)
+	// the HTTP body is one JSON request per line
+	ImportEmails(ctx context.Context, opts ...grpc.CallOption) (MailingListService_ImportEmailsClient, error)
+	CreateList(ctx context.Context, in *CreateListRequest, opts ...grpc.CallOption) (*ListResponse, error)
+	GetLists(ctx context.Context, in *GetListsRequest, opts ...grpc.CallOption) (*GetListsResponse, error)
+	CreateField(ctx context.Context, in *CreateFieldRequest, opts ...grpc.CallOption) (*FieldResponse, error)
+	GetFields(ctx context.Context, in *GetFieldsRequest, opts ...grpc.CallOption) (*GetFieldsResponse, error)
 }
 
 type mailingListServiceClient struct {
@@ -73,6 +83,15 @@ func (c *mailingListServiceClient) DeleteEmail(ctx context.Context, in *DeleteEm
 	return out, nil
 }
 
+func (c *mailingListServiceClient) ConfirmEmail(ctx context.Context, in *ConfirmEmailRequest, opts ...grpc.CallOption) (*EmailResponse, error) {
+	out := new(EmailResponse)
+	err := c.cc.Invoke(ctx, "/proto.MailingListService/ConfirmEmail", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (c *mailingListServiceClient) GetEmailBatch(ctx context.Context, in *GetEmailBatchRequest, opts ...grpc.CallOption) (*GetEmailBatchResponse, error) {
 	out := new(GetEmailBatchResponse)
 	err := c.cc.Invoke(ctx, "/proto.MailingListService/GetEmailBatch", in, out, opts...)
@@ -82,15 +101,124 @@ func (c *mailingListServiceClient) GetEmailBatch(ctx context.Context, in *GetEma
 	return out, nil
 }
 
+func (c *mailingListServiceClient) StreamEmails(ctx context.Context, in *StreamEmailsRequest, opts ...grpc.CallOption) (MailingListService_StreamEmailsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &MailingListService_ServiceDesc.Streams[0], "/proto.MailingListService/StreamEmails", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &mailingListServiceStreamEmailsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type MailingListService_StreamEmailsClient interface {
+	Recv() (*EmailEntry, error)
+	grpc.ClientStream
+}
+
+type mailingListServiceStreamEmailsClient struct {
+	grpc.ClientStream
+}
+
+func (x *mailingListServiceStreamEmailsClient) Recv() (*EmailEntry, error) {
+	m := new(EmailEntry)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *mailingListServiceClient) ImportEmails(ctx context.Context, opts ...grpc.CallOption) (MailingListService_ImportEmailsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &MailingListService_ServiceDesc.Streams[1], "/proto.MailingListService/ImportEmails", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &mailingListServiceImportEmailsClient{stream}
+	return x, nil
+}
+
+type MailingListService_ImportEmailsClient interface {
+	Send(*ImportEmailsRequest) error
+	Recv() (*ImportEmailsResponse, error)
+	grpc.ClientStream
+}
+
+type mailingListServiceImportEmailsClient struct {
+	grpc.ClientStream
+}
+
+func (x *mailingListServiceImportEmailsClient) Send(m *ImportEmailsRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *mailingListServiceImportEmailsClient) Recv() (*ImportEmailsResponse, error) {
+	m := new(ImportEmailsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *mailingListServiceClient) CreateList(ctx context.Context, in *CreateListRequest, opts ...grpc.CallOption) (*ListResponse, error) {
+	out := new(ListResponse)
+	err := c.cc.Invoke(ctx, "/proto.MailingListService/CreateList", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mailingListServiceClient) GetLists(ctx context.Context, in *GetListsRequest, opts ...grpc.CallOption) (*GetListsResponse, error) {
+	out := new(GetListsResponse)
+	err := c.cc.Invoke(ctx, "/proto.MailingListService/GetLists", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mailingListServiceClient) CreateField(ctx context.Context, in *CreateFieldRequest, opts ...grpc.CallOption) (*FieldResponse, error) {
+	out := new(FieldResponse)
+	err := c.cc.Invoke(ctx, "/proto.MailingListService/CreateField", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mailingListServiceClient) GetFields(ctx context.Context, in *GetFieldsRequest, opts ...grpc.CallOption) (*GetFieldsResponse, error) {
+	out := new(GetFieldsResponse)
+	err := c.cc.Invoke(ctx, "/proto.MailingListService/GetFields", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // MailingListServiceServer is the server API for MailingListService service.
 // All implementations must embed UnimplementedMailingListServiceServer
 // for forward compatibility
 type MailingListServiceServer interface {
 	CreateEmail(context.Context, *CreateEmailRequest) (*EmailResponse, error)
 	GetEmail(context.Context, *GetEmailRequest) (*EmailResponse, error)
+	// changes the fields in update_mask, or without one replaces the whole entry: unset
+	// fields are cleared except attributes, which are merged
 	UpdateEmail(context.Context, *UpdateEmailRequest) (*EmailResponse, error)
 	DeleteEmail(context.Context, *DeleteEmailRequest) (*EmailResponse, error)
+	ConfirmEmail(context.Context, *ConfirmEmailRequest) (*EmailResponse, error)
 	GetEmailBatch(context.Context, *GetEmailBatchRequest) (*GetEmailBatchResponse, error)
+	StreamEmails(*StreamEmailsRequest, MailingListService_StreamEmailsServer) error
+	// the HTTP body is one JSON request per line
+	ImportEmails(MailingListService_ImportEmailsServer) error
+	CreateList(context.Context, *CreateListRequest) (*ListResponse, error)
+	GetLists(context.Context, *GetListsRequest) (*GetListsResponse, error)
+	CreateField(context.Context, *CreateFieldRequest) (*FieldResponse, error)
+	GetFields(context.Context, *GetFieldsRequest) (*GetFieldsResponse, error)
 	mustEmbedUnimplementedMailingListServiceServer()
 }
 
@@ -110,9 +238,30 @@ func (UnimplementedMailingListServiceServer) UpdateEmail(context.Context, *Updat
 func (UnimplementedMailingListServiceServer) DeleteEmail(context.Context, *DeleteEmailRequest) (*EmailResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DeleteEmail not implemented")
 }
+func (UnimplementedMailingListServiceServer) ConfirmEmail(context.Context, *ConfirmEmailRequest) (*EmailResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ConfirmEmail not implemented")
+}
 func (UnimplementedMailingListServiceServer) GetEmailBatch(context.Context, *GetEmailBatchRequest) (*GetEmailBatchResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetEmailBatch not implemented")
 }
+func (UnimplementedMailingListServiceServer) StreamEmails(*StreamEmailsRequest, MailingListService_StreamEmailsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamEmails not implemented")
+}
+func (UnimplementedMailingListServiceServer) ImportEmails(MailingListService_ImportEmailsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ImportEmails not implemented")
+}
+func (UnimplementedMailingListServiceServer) CreateList(context.Context, *CreateListRequest) (*ListResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateList not implemented")
+}
+func (UnimplementedMailingListServiceServer) GetLists(context.Context, *GetListsRequest) (*GetListsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLists not implemented")
+}
+func (UnimplementedMailingListServiceServer) CreateField(context.Context, *CreateFieldRequest) (*FieldResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateField not implemented")
+}
+func (UnimplementedMailingListServiceServer) GetFields(context.Context, *GetFieldsRequest) (*GetFieldsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetFields not implemented")
+}
 func (UnimplementedMailingListServiceServer) mustEmbedUnimplementedMailingListServiceServer() {}
 
 // UnsafeMailingListServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -198,6 +347,24 @@ func _MailingListService_DeleteEmail_Handler(srv interface{}, ctx context.Contex
 	return interceptor(ctx, in, info, handler)
 }
 
+func _MailingListService_ConfirmEmail_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConfirmEmailRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MailingListServiceServer).ConfirmEmail(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.MailingListService/ConfirmEmail",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MailingListServiceServer).ConfirmEmail(ctx, req.(*ConfirmEmailRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 func _MailingListService_GetEmailBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(GetEmailBatchRequest)
 	if err := dec(in); err != nil {
@@ -216,6 +383,125 @@ func _MailingListService_GetEmailBatch_Handler(srv interface{}, ctx context.Cont
 	return interceptor(ctx, in, info, handler)
 }
 
+func _MailingListService_StreamEmails_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamEmailsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MailingListServiceServer).StreamEmails(m, &mailingListServiceStreamEmailsServer{stream})
+}
+
+type MailingListService_StreamEmailsServer interface {
+	Send(*EmailEntry) error
+	grpc.ServerStream
+}
+
+type mailingListServiceStreamEmailsServer struct {
+	grpc.ServerStream
+}
+
+func (x *mailingListServiceStreamEmailsServer) Send(m *EmailEntry) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _MailingListService_ImportEmails_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(MailingListServiceServer).ImportEmails(&mailingListServiceImportEmailsServer{stream})
+}
+
+type MailingListService_ImportEmailsServer interface {
+	Send(*ImportEmailsResponse) error
+	Recv() (*ImportEmailsRequest, error)
+	grpc.ServerStream
+}
+
+type mailingListServiceImportEmailsServer struct {
+	grpc.ServerStream
+}
+
+func (x *mailingListServiceImportEmailsServer) Send(m *ImportEmailsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *mailingListServiceImportEmailsServer) Recv() (*ImportEmailsRequest, error) {
+	m := new(ImportEmailsRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _MailingListService_CreateList_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateListRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MailingListServiceServer).CreateList(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.MailingListService/CreateList",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MailingListServiceServer).CreateList(ctx, req.(*CreateListRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MailingListService_GetLists_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetListsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MailingListServiceServer).GetLists(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.MailingListService/GetLists",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MailingListServiceServer).GetLists(ctx, req.(*GetListsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MailingListService_CreateField_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateFieldRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MailingListServiceServer).CreateField(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.MailingListService/CreateField",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MailingListServiceServer).CreateField(ctx, req.(*CreateFieldRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _MailingListService_GetFields_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetFieldsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MailingListServiceServer).GetFields(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/proto.MailingListService/GetFields",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MailingListServiceServer).GetFields(ctx, req.(*GetFieldsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // MailingListService_ServiceDesc is the grpc.ServiceDesc for MailingListService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -239,11 +525,43 @@ var MailingListService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "DeleteEmail",
 			Handler:    _MailingListService_DeleteEmail_Handler,
 		},
+		{
+			MethodName: "ConfirmEmail",
+			Handler:    _MailingListService_ConfirmEmail_Handler,
+		},
 		{
 			MethodName: "GetEmailBatch",
 			Handler:    _MailingListService_GetEmailBatch_Handler,
 		},
+		{
+			MethodName: "CreateList",
+			Handler:    _MailingListService_CreateList_Handler,
+		},
+		{
+			MethodName: "GetLists",
+			Handler:    _MailingListService_GetLists_Handler,
+		},
+		{
+			MethodName: "CreateField",
+			Handler:    _MailingListService_CreateField_Handler,
+		},
+		{
+			MethodName: "GetFields",
+			Handler:    _MailingListService_GetFields_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamEmails",
+			Handler:       _MailingListService_StreamEmails_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "ImportEmails",
+			Handler:       _MailingListService_ImportEmails_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 	},
-	Streams:  []grpc.StreamDesc{},
 	Metadata: "Proto/mail.proto",
 }
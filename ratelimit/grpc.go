@@ -0,0 +1,36 @@
+package ratelimit
+
+import (
+	"context"
+	"strings"
+
+	"github.com/IM-Deane/mailing-list/loopback"
+	"google.golang.org/grpc"
+)
+
+// methodRule names the rule of a method like "/proto.MailingListService/CreateEmail" by its RPC, CreateEmail
+func methodRule(fullMethod string) string {
+	return fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+}
+
+// UnaryServerInterceptor limits each client's calls with the rule for their RPC,
+// so it should run after auth.UnaryServerInterceptor to tell clients apart by API key
+func UnaryServerInterceptor(l *Limiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := l.Allow(ctx, methodRule(info.FullMethod), client(ctx, loopback.PeerAddr(ctx))); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is UnaryServerInterceptor for streaming RPCs, each stream takes one token
+func StreamServerInterceptor(l *Limiter) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx := ss.Context()
+		if err := l.Allow(ctx, methodRule(info.FullMethod), client(ctx, loopback.PeerAddr(ctx))); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
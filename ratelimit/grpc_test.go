@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+)
+
+// testStream is a server stream with only a context
+type testStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s testStream) Context() context.Context {
+	return s.ctx
+}
+
+func TestInterceptors(t *testing.T) {
+	limiter, _ := testLimiter(t, "CreateEmail=1/m", "StreamEmails=1/m")
+	unary := UnaryServerInterceptor(limiter)
+	stream := StreamServerInterceptor(limiter)
+
+	// fromPeer is a call from a client at addr
+	fromPeer := func(addr string) context.Context {
+		return peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(addr), Port: 4321}})
+	}
+	call := func(ctx context.Context, method string) error {
+		_, err := unary(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, req interface{}) (interface{}, error) {
+			return nil, nil
+		})
+		return err
+	}
+	open := func(ctx context.Context, method string) error {
+		return stream(nil, testStream{ctx: ctx}, &grpc.StreamServerInfo{FullMethod: method}, func(srv interface{}, ss grpc.ServerStream) error {
+			return nil
+		})
+	}
+
+	// rules are named by the RPC without the service
+	if err := call(fromPeer("192.0.2.1"), "/proto.MailingListService/CreateEmail"); err != nil {
+		t.Fatal(err)
+	}
+	err := call(fromPeer("192.0.2.1"), "/proto.MailingListService/CreateEmail")
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Rule != "CreateEmail" || limitErr.RetryAfter <= 0 {
+		t.Errorf("got %v, want a LimitError for CreateEmail", err)
+	}
+	if err := call(fromPeer("192.0.2.2"), "/proto.MailingListService/CreateEmail"); err != nil {
+		t.Errorf("got %v for another client", err)
+	}
+	if err := call(fromPeer("192.0.2.1"), "/proto.MailingListService/GetEmail"); err != nil {
+		t.Errorf("got %v for an RPC without a rule", err)
+	}
+
+	// each stream takes one token
+	if err := open(fromPeer("192.0.2.1"), "/proto.MailingListService/StreamEmails"); err != nil {
+		t.Fatal(err)
+	}
+	if err := open(fromPeer("192.0.2.1"), "/proto.MailingListService/StreamEmails"); !errors.Is(err, ErrLimited) {
+		t.Errorf("got %v opening a second stream, want ErrLimited", err)
+	}
+}
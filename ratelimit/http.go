@@ -0,0 +1,39 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/IM-Deane/mailing-list/auth"
+)
+
+// client identifies who a request is from: its API key, or its IP address without one
+func client(ctx context.Context, addr string) string {
+	if key := auth.KeyFromContext(ctx); key != nil {
+		return "key:" + key.Prefix
+	}
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	return "ip:" + addr
+}
+
+// Middleware limits each client's requests with the rule picked by rule, so it should
+// run after auth.Middleware to tell clients apart by API key. Limited requests get a
+// Retry-After header and are written by onError.
+func Middleware(l *Limiter, rule func(r *http.Request) string, onError func(w http.ResponseWriter, err error), next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := l.Allow(r.Context(), rule(r), client(r.Context(), r.RemoteAddr)); err != nil {
+			var limitErr *LimitError
+			if errors.As(err, &limitErr) {
+				w.Header().Set("Retry-After", strconv.Itoa(RetryAfterSeconds(limitErr.RetryAfter)))
+			}
+			onError(w, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
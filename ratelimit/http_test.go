@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/IM-Deane/mailing-list/auth"
+	"github.com/IM-Deane/mailing-list/mdb"
+)
+
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+	if got := client(ctx, "192.0.2.1:4321"); got != "ip:192.0.2.1" {
+		t.Errorf("got %v, want the address without its port", got)
+	}
+	if got := client(ctx, "[2001:db8::1]:4321"); got != "ip:2001:db8::1" {
+		t.Errorf("got %v, want the IPv6 address without its port", got)
+	}
+	if got := client(ctx, "bufconn"); got != "ip:bufconn" {
+		t.Errorf("got %v, want addresses without a port kept", got)
+	}
+
+	// API keys identify clients wherever they connect from
+	keyCtx := auth.WithKey(ctx, &mdb.APIKey{Prefix: "abcd1234"})
+	if got := client(keyCtx, "192.0.2.1:4321"); got != "key:abcd1234" {
+		t.Errorf("got %v, want the key's prefix", got)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	limiter, clock := testLimiter(t, "POST /v1/emails=1/m")
+	rule := func(r *http.Request) string {
+		return r.Method + " " + r.URL.Path
+	}
+	var failure error
+	onError := func(w http.ResponseWriter, err error) {
+		failure = err
+		w.WriteHeader(http.StatusTooManyRequests)
+	}
+	handler := Middleware(limiter, rule, onError, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	// send makes a request from addr and returns the response
+	send := func(method string, addr string) *httptest.ResponseRecorder {
+		failure = nil
+		req := httptest.NewRequest(method, "/v1/emails", nil)
+		req.RemoteAddr = addr
+		res := httptest.NewRecorder()
+		handler.ServeHTTP(res, req)
+		return res
+	}
+
+	if res := send("POST", "192.0.2.1:1000"); res.Code != http.StatusNoContent {
+		t.Fatalf("got status %v for the first request", res.Code)
+	}
+
+	// the port changes with each connection but the client is the same
+	res := send("POST", "192.0.2.1:2000")
+	if res.Code != http.StatusTooManyRequests || !errors.Is(failure, ErrLimited) {
+		t.Fatalf("got status %v, error %v, want 429 from onError", res.Code, failure)
+	}
+	if got := res.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("got Retry-After %q, want 60", got)
+	}
+
+	// partial seconds are rounded up
+	clock.Advance(59500 * time.Millisecond)
+	if got := send("POST", "192.0.2.1:3000").Header().Get("Retry-After"); got != "1" {
+		t.Errorf("got Retry-After %q, want 1", got)
+	}
+
+	if res := send("GET", "192.0.2.1:1000"); res.Code != http.StatusNoContent {
+		t.Errorf("got status %v for a route without a rule", res.Code)
+	}
+	if res := send("POST", "192.0.2.2:1000"); res.Code != http.StatusNoContent {
+		t.Errorf("got status %v for another client", res.Code)
+	}
+}
@@ -0,0 +1,155 @@
+// Package ratelimit limits how often each client can call a route or RPC with token buckets
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrLimited is returned when a client has used up its requests, check for it with errors.Is
+var ErrLimited = errors.New("rate limit exceeded")
+
+// LimitError is an ErrLimited saying when the client can try again
+type LimitError struct {
+	Rule string
+	RetryAfter time.Duration
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("rate limit for %v exceeded, retry in %v", e.Rule, e.RetryAfter.Round(time.Second))
+}
+
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimited
+}
+
+// Limit is a token bucket refilled at Rate tokens a second, holding at most Burst.
+// Each request takes a token.
+type Limit struct {
+	Rate float64
+	Burst int
+}
+
+// Rules maps routes or RPCs to their limits. gRPC methods are named like "CreateEmail"
+// and HTTP routes like "POST /v1/emails". The "*" rule applies to anything not listed.
+type Rules map[string]Limit
+
+// Default is the rule for routes and RPCs that aren't listed
+const Default = "*"
+
+// units accepted after the slash in a rate
+var units = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+}
+
+// ParseRule parses a rule such as "CreateEmail=10/m", allowing 10 requests a minute.
+// The burst defaults to the number of requests, "CreateEmail=10/m:3" only allows 3 at once.
+func ParseRule(s string) (string, Limit, error) {
+	name, spec, ok := cutString(s, "=")
+	if !ok || strings.TrimSpace(name) == "" {
+		return "", Limit{}, fmt.Errorf("invalid rate limit %q, expected <route or RPC>=<requests>/<s, m or h>[:<burst>]", s)
+	}
+	name = strings.TrimSpace(name)
+
+	spec, burstSpec, hasBurst := cutString(spec, ":")
+	countSpec, unitSpec, ok := cutString(spec, "/")
+	unit, knownUnit := units[strings.TrimSpace(unitSpec)]
+	count, err := strconv.Atoi(strings.TrimSpace(countSpec))
+	if !ok || !knownUnit || err != nil || count <= 0 {
+		return "", Limit{}, fmt.Errorf("invalid rate %q for %v, expected e.g. 10/m", spec, name)
+	}
+
+	limit := Limit{Rate: float64(count) / unit.Seconds(), Burst: count}
+	if hasBurst {
+		limit.Burst, err = strconv.Atoi(strings.TrimSpace(burstSpec))
+		if err != nil || limit.Burst <= 0 {
+			return "", Limit{}, fmt.Errorf("invalid burst %q for %v", burstSpec, name)
+		}
+	}
+	return name, limit, nil
+}
+
+// Off turns rate limiting off when it's the only rule given to ParseRules
+const Off = "off"
+
+// ParseRules parses rules with ParseRule, returning nil rules for a single Off
+func ParseRules(specs []string) (Rules, error) {
+	if len(specs) == 1 && strings.TrimSpace(specs[0]) == Off {
+		return nil, nil
+	}
+
+	rules := Rules{}
+	for _, spec := range specs {
+		name, limit, err := ParseRule(spec)
+		if err != nil {
+			return nil, err
+		}
+		rules[name] = limit
+	}
+	return rules, nil
+}
+
+// cutString splits s around the first sep
+func cutString(s string, sep string) (string, string, bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// Store keeps the token buckets. The memory store is enough for a single server,
+// replicas sharing limits need a store backed by something they can all reach.
+type Store interface {
+	// Take takes a token from the bucket for key, refilling it for the time since it was last used.
+	// If the bucket is empty it returns false and how long until a token is available.
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (bool, time.Duration, error)
+}
+
+// Limiter applies rules to clients, keeping their buckets in a Store
+type Limiter struct {
+	store Store
+	rules Rules
+	// now is the clock buckets are refilled by, replaced in tests
+	now func() time.Time
+}
+
+// NewLimiter creates a limiter for rules keeping buckets in store
+func NewLimiter(store Store, rules Rules) *Limiter {
+	return &Limiter{store: store, rules: rules, now: time.Now}
+}
+
+// Allow takes a token for client from the bucket of rule, returning a LimitError
+// if it's empty. Routes and RPCs without a rule, or the default rule, aren't limited.
+// Store failures are logged and let the request through, so they don't take the API down.
+func (l *Limiter) Allow(ctx context.Context, rule string, client string) error {
+	limit, ok := l.rules[rule]
+	if !ok {
+		limit, ok = l.rules[Default]
+		if !ok {
+			return nil
+		}
+	}
+
+	allowed, retryAfter, err := l.store.Take(ctx, rule+"|"+client, limit, l.now())
+	if err != nil {
+		log.Println(err)
+		return nil
+	}
+	if !allowed {
+		return &LimitError{Rule: rule, RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// RetryAfterSeconds rounds a wait up to whole seconds for Retry-After headers
+func RetryAfterSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,203 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// testClock is a Limiter clock that only moves when told to
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.now = c.now.Add(d)
+}
+
+// testLimiter creates a limiter for rules, keeping buckets in memory, and the clock it uses
+func testLimiter(t *testing.T, specs ...string) (*Limiter, *testClock) {
+	rules, err := ParseRules(specs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := &testClock{now: time.Unix(1700000000, 0)}
+	limiter := NewLimiter(NewMemoryStore(), rules)
+	limiter.now = clock.Now
+	return limiter, clock
+}
+
+// failingStore fails every Take
+type failingStore struct{}
+
+func (failingStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (bool, time.Duration, error) {
+	return false, 0, errors.New("store unavailable")
+}
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		spec string
+		name string
+		limit Limit
+		// invalid is whether the spec should be rejected
+		invalid bool
+	}{
+		{spec: "CreateEmail=10/m", name: "CreateEmail", limit: Limit{Rate: 10.0 / 60, Burst: 10}},
+		{spec: "POST /v1/emails = 30/s", name: "POST /v1/emails", limit: Limit{Rate: 30, Burst: 30}},
+		{spec: "*=3600/h:5", name: "*", limit: Limit{Rate: 1, Burst: 5}},
+		{spec: "CreateEmail=10/m: 3 ", name: "CreateEmail", limit: Limit{Rate: 10.0 / 60, Burst: 3}},
+		{spec: "CreateEmail", invalid: true},
+		{spec: "=10/m", invalid: true},
+		{spec: "CreateEmail=10", invalid: true},
+		{spec: "CreateEmail=10/d", invalid: true},
+		{spec: "CreateEmail=ten/m", invalid: true},
+		{spec: "CreateEmail=0/m", invalid: true},
+		{spec: "CreateEmail=10/m:0", invalid: true},
+		{spec: "CreateEmail=10/m:many", invalid: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			name, limit, err := ParseRule(tt.spec)
+			if tt.invalid {
+				if err == nil {
+					t.Errorf("got %v %+v, want an error", name, limit)
+				}
+				return
+			}
+			if err != nil || name != tt.name || limit != tt.limit {
+				t.Errorf("got %q %+v, %v, want %q %+v", name, limit, err, tt.name, tt.limit)
+			}
+		})
+	}
+}
+
+func TestParseRules(t *testing.T) {
+	rules, err := ParseRules([]string{"CreateEmail=10/m", "*=100/m:20"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules) != 2 || rules["CreateEmail"].Burst != 10 || rules[Default].Burst != 20 {
+		t.Errorf("got %+v", rules)
+	}
+
+	if rules, err := ParseRules([]string{Off}); err != nil || rules != nil {
+		t.Errorf("got %v, %v for off, want nil, nil", rules, err)
+	}
+	// off can't be mixed with rules
+	if _, err := ParseRules([]string{Off, "CreateEmail=10/m"}); err == nil {
+		t.Error("parsed off along with a rule")
+	}
+	if _, err := ParseRules([]string{"CreateEmail=10/m", "nope"}); err == nil {
+		t.Error("parsed an invalid rule")
+	}
+}
+
+func TestRefill(t *testing.T) {
+	// 2 tokens a second, 3 at once
+	limiter, clock := testLimiter(t, "CreateEmail=120/m:3")
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		if err := limiter.Allow(ctx, "CreateEmail", "ip:192.0.2.1"); err != nil {
+			t.Fatalf("request %v: %v, want the burst allowed", i+1, err)
+		}
+	}
+	err := limiter.Allow(ctx, "CreateEmail", "ip:192.0.2.1")
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || !errors.Is(err, ErrLimited) {
+		t.Fatalf("got %v after the burst, want a LimitError", err)
+	}
+	if limitErr.Rule != "CreateEmail" || limitErr.RetryAfter != 500*time.Millisecond {
+		t.Errorf("got %+v, want a token in 500ms", limitErr)
+	}
+
+	// other clients have their own bucket
+	if err := limiter.Allow(ctx, "CreateEmail", "ip:192.0.2.2"); err != nil {
+		t.Errorf("got %v for another client", err)
+	}
+
+	// half a token isn't enough
+	clock.Advance(250 * time.Millisecond)
+	if err := limiter.Allow(ctx, "CreateEmail", "ip:192.0.2.1"); !errors.As(err, &limitErr) || limitErr.RetryAfter != 250*time.Millisecond {
+		t.Errorf("got %v, want a token in 250ms", err)
+	}
+	clock.Advance(250 * time.Millisecond)
+	if err := limiter.Allow(ctx, "CreateEmail", "ip:192.0.2.1"); err != nil {
+		t.Errorf("got %v once a token was refilled", err)
+	}
+
+	// the bucket never holds more than the burst
+	clock.Advance(time.Hour)
+	for i := 0; i < 3; i++ {
+		if err := limiter.Allow(ctx, "CreateEmail", "ip:192.0.2.1"); err != nil {
+			t.Fatalf("request %v: %v, want the burst allowed", i+1, err)
+		}
+	}
+	if err := limiter.Allow(ctx, "CreateEmail", "ip:192.0.2.1"); !errors.Is(err, ErrLimited) {
+		t.Errorf("got %v, want the refill capped at the burst", err)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	store := NewMemoryStore()
+	start := time.Unix(1700000000, 0)
+	limit := Limit{Rate: 1, Burst: 1}
+	if allowed, _, _ := store.Take(context.Background(), "a", limit, start); !allowed {
+		t.Fatal("the first request was limited")
+	}
+
+	// refilled buckets are forgotten once the sweep runs
+	if _, _, err := store.Take(context.Background(), "b", limit, start.Add(sweepInterval)); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := store.buckets["a"]; ok {
+		t.Error("a refilled bucket was kept")
+	}
+	if _, ok := store.buckets["b"]; !ok {
+		t.Error("a bucket in use was forgotten")
+	}
+}
+
+func TestAllow(t *testing.T) {
+	ctx := context.Background()
+
+	limiter, _ := testLimiter(t, "CreateEmail=1/m", "*=2/m")
+	if err := limiter.Allow(ctx, "CreateEmail", "ip:a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := limiter.Allow(ctx, "CreateEmail", "ip:a"); !errors.Is(err, ErrLimited) {
+		t.Errorf("got %v, want the rule applied", err)
+	}
+
+	// unlisted rules share the default limit, each with its own bucket
+	for i := 0; i < 2; i++ {
+		if err := limiter.Allow(ctx, "GetEmail", "ip:a"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := limiter.Allow(ctx, "GetEmail", "ip:a"); !errors.Is(err, ErrLimited) {
+		t.Errorf("got %v, want the default rule applied", err)
+	}
+	if err := limiter.Allow(ctx, "GetLists", "ip:a"); err != nil {
+		t.Errorf("got %v, want a bucket per rule", err)
+	}
+
+	// without a default rule, unlisted ones aren't limited
+	limiter, _ = testLimiter(t, "CreateEmail=1/m")
+	for i := 0; i < 5; i++ {
+		if err := limiter.Allow(ctx, "GetEmail", "ip:a"); err != nil {
+			t.Fatalf("got %v for a route without a rule", err)
+		}
+	}
+
+	// a failing store lets requests through
+	limiter = NewLimiter(failingStore{}, Rules{Default: {Rate: 1, Burst: 1}})
+	if err := limiter.Allow(ctx, "GetEmail", "ip:a"); err != nil {
+		t.Errorf("got %v, want store errors to let requests through", err)
+	}
+}
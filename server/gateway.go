@@ -0,0 +1,67 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/IM-Deane/mailing-list/gateway"
+	"github.com/IM-Deane/mailing-list/jsonapi"
+	"github.com/IM-Deane/mailing-list/loopback"
+	"github.com/IM-Deane/mailing-list/mdb"
+	pb "github.com/IM-Deane/mailing-list/proto"
+	"github.com/IM-Deane/mailing-list/tlsconfig"
+	"github.com/IM-Deane/mailing-list/token"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// JSON APIs accepted by --json-api
+const (
+	jsonAPIGateway = "gateway"
+	jsonAPILegacy = "legacy"
+)
+
+// mailService is the gRPC service the gateway transcodes to
+func mailService() protoreflect.ServiceDescriptor {
+	return pb.File_Proto_mail_proto.Services().ByName("MailingListService")
+}
+
+// gatewayOpenAPI returns the gateway's OpenAPI document as indented JSON
+func gatewayOpenAPI() ([]byte, error) {
+	// the document only needs the routes, nothing is called
+	gw, err := gateway.New(nil, mailService())
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(gw.OpenAPI(), "", "  ")
+}
+
+// serveGateway serves the HTTP/JSON gateway on bind, transcoding requests to the gRPC server
+// over grpcListener. HTTP clients are served with TLS when tlsConfig is set.
+func serveGateway(store mdb.EmailStore, tokens *token.Signer, grpcListener *loopback.Listener, tlsConfig *tls.Config, bind string) {
+	// the connection never leaves the process, so it doesn't need TLS
+	conn, err := grpc.Dial("loopback", grpc.WithTransportCredentials(insecure.NewCredentials()), grpcListener.DialOption())
+	if err != nil {
+		log.Fatalf("gateway error: %v", err)
+	}
+	defer conn.Close()
+
+	gw, err := gateway.New(conn, mailService())
+	if err != nil {
+		log.Fatalf("gateway error: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", gw)
+	mux.Handle("/openapi.json", gw.OpenAPIHandler())
+	// unsubscribe links are pages for people rather than part of the service
+	mux.Handle("/unsubscribe", jsonapi.Unsubscribe(store, tokens))
+
+	log.Printf("HTTP/JSON gateway listening on: %v", bind)
+	if err := tlsconfig.ListenAndServe(bind, mux, tlsConfig); err != nil {
+		log.Fatalf("gateway error: %v", err)
+	}
+}
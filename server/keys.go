@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/IM-Deane/mailing-list/auth"
+	"github.com/IM-Deane/mailing-list/mdb"
+)
+
+// KeysCmd manages the API keys clients authenticate with
+type KeysCmd struct {
+	Create *KeysCreateCmd `arg:"subcommand:create" help:"create an API key, it's only shown once"`
+	List *KeysListCmd `arg:"subcommand:list" help:"list API keys"`
+	Revoke *KeysRevokeCmd `arg:"subcommand:revoke" help:"revoke an API key"`
+}
+
+// KeysCreateCmd creates an API key
+type KeysCreateCmd struct {
+	Name string `arg:"--name,required" help:"what the key is for, e.g. the client using it"`
+	Scopes string `arg:"--scopes" help:"comma separated scopes: read, write and admin" default:"read"`
+}
+
+// KeysListCmd lists API keys
+type KeysListCmd struct {
+	All bool `arg:"--all" help:"also show revoked keys"`
+}
+
+// KeysRevokeCmd revokes an API key
+type KeysRevokeCmd struct {
+	Prefix string `arg:"positional,required" help:"prefix of the key, as shown by keys list"`
+}
+
+// runKeys runs a keys subcommand against store
+func runKeys(store mdb.KeyStore, cmd *KeysCmd) error {
+	switch {
+	case cmd.Create != nil:
+		scopes, err := auth.ParseScopes(cmd.Create.Scopes)
+		if err != nil {
+			return err
+		}
+		key, record, err := auth.GenerateKey(cmd.Create.Name, scopes)
+		if err != nil {
+			return err
+		}
+		if err := store.CreateAPIKey(record); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "created key %v, store it now as it can't be shown again\n", record.Prefix)
+		fmt.Println(key)
+	case cmd.List != nil:
+		keys, err := store.GetAPIKeys()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "PREFIX\tNAME\tSCOPES\tCREATED\tREVOKED")
+		for _, key := range keys {
+			if key.RevokedAt != nil && !cmd.List.All {
+				continue
+			}
+			revoked := "-"
+			if key.RevokedAt != nil {
+				revoked = key.RevokedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\n", key.Prefix, key.Name, strings.Join(key.Scopes, ","), key.CreatedAt.Format("2006-01-02 15:04:05"), revoked)
+		}
+		w.Flush()
+	case cmd.Revoke != nil:
+		if err := store.RevokeAPIKey(cmd.Revoke.Prefix); err != nil {
+			return err
+		}
+		fmt.Printf("revoked key %v\n", cmd.Revoke.Prefix)
+	default:
+		return fmt.Errorf("expected create, list or revoke")
+	}
+	return nil
+}
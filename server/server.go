@@ -1,26 +1,235 @@
 package main
 
 import (
+	"crypto/rand"
+	"crypto/tls"
 	"database/sql"
+	"fmt"
 	"log"
+	"net"
+	"os"
 	"sync"
+	"text/tabwriter"
+	"time"
 
+	"github.com/IM-Deane/mailing-list/auth"
+	"github.com/IM-Deane/mailing-list/domaincheck"
 	"github.com/IM-Deane/mailing-list/grpcapi"
+	"github.com/IM-Deane/mailing-list/idempotency"
 	"github.com/IM-Deane/mailing-list/jsonapi"
+	"github.com/IM-Deane/mailing-list/loopback"
 	"github.com/IM-Deane/mailing-list/mdb"
+	"github.com/IM-Deane/mailing-list/ratelimit"
+	"github.com/IM-Deane/mailing-list/tlsconfig"
+	"github.com/IM-Deane/mailing-list/token"
+	"github.com/IM-Deane/mailing-list/transfer"
 	"github.com/alexflint/go-arg"
 )
 
+// MigrateCmd reports schema versions and applies pending migrations
+type MigrateCmd struct {
+	DryRun bool `arg:"--dry-run" help:"only report pending migrations, don't apply them"`
+}
+
+// OpenAPICmd prints the OpenAPI document of the JSON API
+type OpenAPICmd struct {
+	Output string `arg:"-o,--output" help:"write the document to a file instead of stdout"`
+}
+
 var args struct {
-	DBPath string `arg:"env"MAILINGLIST_DB"`
-	BindJSON string `arg:"env"MAILINGLIST_BIND_JSON"`
-	BindGRPC string `arg:"env"MAILINGLIST_BIND_GRPC"`
+	Migrate *MigrateCmd `arg:"subcommand:migrate" help:"show schema versions and apply pending migrations"`
+	OpenAPI *OpenAPICmd `arg:"subcommand:openapi" help:"print the OpenAPI document of the JSON API, checking the legacy one against its handlers"`
+	Import *ImportCmd `arg:"subcommand:import" help:"import emails from a CSV or JSONL file"`
+	Export *ExportCmd `arg:"subcommand:export" help:"export emails to a CSV or JSONL file"`
+	Keys *KeysCmd `arg:"subcommand:keys" help:"create, list and revoke API keys"`
+	Store string `arg:"env:MAILINGLIST_STORE" help:"storage backend: sqlite, postgres or memory"`
+	DBPath string `arg:"env:MAILINGLIST_DB"`
+	PostgresDSN string `arg:"--postgres-dsn,env:MAILINGLIST_POSTGRES_DSN" help:"connection string used by the postgres store"`
+	BindJSON string `arg:"env:MAILINGLIST_BIND_JSON"`
+	JSONAPI string `arg:"--json-api,env:MAILINGLIST_JSON_API" help:"HTTP API served on the JSON address: gateway, or legacy for the hand-written JSON API" default:"gateway"`
+	BindGRPC string `arg:"env:MAILINGLIST_BIND_GRPC"`
+	TokenSecret string `arg:"--token-secret,env:MAILINGLIST_TOKEN_SECRET" help:"key used to sign confirmation tokens"`
+	ConfirmTTL time.Duration `arg:"--confirm-ttl,env:MAILINGLIST_CONFIRM_TTL" help:"how long confirmation tokens are valid" default:"48h"`
+	ProviderNormalization bool `arg:"--provider-normalization,env:MAILINGLIST_PROVIDER_NORMALIZATION" help:"treat provider aliases such as gmail dots and +tags as the same address"`
+	MXCheck bool `arg:"--mx-check,env:MAILINGLIST_MX_CHECK" help:"look up MX records and refuse signups from domains that can't receive email"`
+	DisposableDomains string `arg:"--disposable-domains,env:MAILINGLIST_DISPOSABLE_DOMAINS" help:"file of disposable domains to use instead of the bundled list"`
+	DomainPolicy string `arg:"--domain-policy,env:MAILINGLIST_DOMAIN_POLICY" help:"reject or flag signups that fail the domain check, lists can override it" default:"reject"`
+	NoAuth bool `arg:"--no-auth,env:MAILINGLIST_NO_AUTH" help:"serve without API keys, only for local development"`
+	TLSCert string `arg:"--tls-cert,env:MAILINGLIST_TLS_CERT" help:"PEM certificate served by both servers, enables TLS"`
+	TLSKey string `arg:"--tls-key,env:MAILINGLIST_TLS_KEY" help:"key of the --tls-cert certificate"`
+	TLSClientCA string `arg:"--tls-client-ca,env:MAILINGLIST_TLS_CLIENT_CA" help:"CA bundle client certificates are verified against"`
+	TLSClientAuth string `arg:"--tls-client-auth,env:MAILINGLIST_TLS_CLIENT_AUTH" help:"client certificates: none, optional or require, defaults to require with --tls-client-ca"`
+	TLSReload time.Duration `arg:"--tls-reload,env:MAILINGLIST_TLS_RELOAD" help:"how often the certificate files are checked for changes" default:"30s"`
+	IdempotencyWindow time.Duration `arg:"--idempotency-window,env:MAILINGLIST_IDEMPOTENCY_WINDOW" help:"how long responses are replayed for requests retried with the same idempotency key" default:"24h"`
+	RateLimits []string `arg:"--rate-limit,separate,env:MAILINGLIST_RATE_LIMIT" help:"per client limit for a route or RPC such as CreateEmail=30/m or \"POST /v1/emails=30/m:5\", repeatable, off to disable"`
+}
+
+// defaultRateLimits limit signups when --rate-limit isn't set, through gRPC,
+// the gateway (which calls CreateEmail) and the legacy JSON routes
+var defaultRateLimits = []string{
+	"CreateEmail=30/m",
+	"POST /v1/emails=30/m",
+	"POST /email/create=30/m",
+}
+
+// rateLimiter builds the limiter for the --rate-limit rules, nil when they're off
+func rateLimiter() (*ratelimit.Limiter, error) {
+	specs := args.RateLimits
+	if len(specs) == 0 {
+		specs = defaultRateLimits
+	}
+	rules, err := ratelimit.ParseRules(specs)
+	if err != nil {
+		return nil, err
+	}
+	if rules == nil {
+		log.Printf("rate limiting disabled")
+		return nil, nil
+	}
+	// buckets are per server, replicas each allow the full rate
+	return ratelimit.NewLimiter(ratelimit.NewMemoryStore(), rules), nil
+}
+
+// serverTLS loads the certificates set by the --tls flags and keeps them up to date,
+// returning nil when TLS is off
+func serverTLS() (*tls.Config, error) {
+	if args.TLSCert == "" && args.TLSKey == "" {
+		if args.TLSClientCA != "" || args.TLSClientAuth != "" {
+			return nil, fmt.Errorf("client certificates need --tls-cert and --tls-key")
+		}
+		return nil, nil
+	}
+	if args.TLSCert == "" || args.TLSKey == "" {
+		return nil, fmt.Errorf("TLS needs both --tls-cert and --tls-key")
+	}
+
+	clientAuth := args.TLSClientAuth
+	if clientAuth == "" {
+		clientAuth = tlsconfig.NoClientCert
+		if args.TLSClientCA != "" {
+			clientAuth = tlsconfig.RequireClientCert
+		}
+	}
+
+	reloader, err := tlsconfig.NewReloader(args.TLSCert, args.TLSKey, args.TLSClientCA)
+	if err != nil {
+		return nil, err
+	}
+	config, err := tlsconfig.ServerConfig(reloader, clientAuth)
+	if err != nil {
+		return nil, err
+	}
+
+	// pick up rotated certificates without a restart
+	go reloader.Watch(args.TLSReload, nil)
+	log.Printf("serving TLS with '%v', client certificates: %v", args.TLSCert, clientAuth)
+
+	return config, nil
+}
+
+// runMigrate prints the status of every migration, then applies the pending ones
+func runMigrate(store *mdb.SQLStore, cmd *MigrateCmd) error {
+	statuses, err := store.GetMigrationStatus()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATUS")
+	for _, status := range statuses {
+		state := "pending"
+		if status.AppliedAt != nil {
+			state = "applied " + status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%04d\t%v\t%v\n", status.Version, status.Name, state)
+	}
+	w.Flush()
+
+	if cmd.DryRun {
+		pending, err := store.Migrate(true)
+		if err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			fmt.Println("schema is up to date")
+			return nil
+		}
+		fmt.Printf("%v pending migration(s), dry run so nothing was applied\n", len(pending))
+		return nil
+	}
+
+	// runs even with nothing pending, canonical addresses may need recomputing
+	applied, err := store.Migrate(false)
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		fmt.Println("schema is up to date")
+		return nil
+	}
+	fmt.Printf("applied %v migration(s)\n", len(applied))
+
+	return nil
+}
+
+// runOpenAPI prints the OpenAPI document of the API picked with --json-api,
+// failing if the legacy document has drifted from its handlers
+func runOpenAPI(cmd *OpenAPICmd) error {
+	var doc []byte
+	var err error
+	if args.JSONAPI == jsonAPILegacy {
+		doc, err = jsonapi.OpenAPI()
+	} else {
+		doc, err = gatewayOpenAPI()
+	}
+	if err != nil {
+		return err
+	}
+	doc = append(doc, '\n')
+
+	if cmd.Output == "" {
+		_, err = os.Stdout.Write(doc)
+		return err
+	}
+	return os.WriteFile(cmd.Output, doc, 0644)
+}
+
+// domainCheckedStore wraps store so new signups have their domain checked as set by
+// --disposable-domains, --mx-check and --domain-policy
+func domainCheckedStore(store mdb.SubscriberStore) (*domaincheck.Store, error) {
+	blocklist := domaincheck.DefaultBlocklist()
+	if args.DisposableDomains != "" {
+		var err error
+		blocklist, err = domaincheck.LoadBlocklist(args.DisposableDomains)
+		if err != nil {
+			return nil, err
+		}
+		log.Printf("loaded %v disposable domains from '%v'", len(blocklist), args.DisposableDomains)
+	}
+	var resolver domaincheck.Resolver
+	if args.MXCheck {
+		resolver = net.DefaultResolver
+	}
+	return domaincheck.NewStore(store, domaincheck.NewChecker(resolver, blocklist), mdb.DomainPolicy(args.DomainPolicy))
 }
 
 func main() {
 	arg.MustParse(&args)
 
+	if args.JSONAPI != jsonAPIGateway && args.JSONAPI != jsonAPILegacy {
+		log.Fatalf("unknown JSON API '%v', expected gateway or legacy", args.JSONAPI)
+	}
+	if args.OpenAPI != nil {
+		if err := runOpenAPI(args.OpenAPI); err != nil {
+			log.Fatalf("openapi: %v", err)
+		}
+		return
+	}
+
 	// set defaults if env not provided
+	if args.Store == "" {
+		args.Store = "sqlite"
+	}
 	if args.DBPath == "" {
 		args.DBPath = "list.db"
 	}
@@ -31,25 +240,173 @@ func main() {
 		args.BindGRPC = ":8081"
 	}
 
+	// import and export can go through a running server instead of the store
+	if ok, err := runTransferOverGRPC(); ok {
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if args.Import != nil && args.Import.DryRun {
+		if err := runImport(args.Import, nil); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	opts := mdb.Options{ProviderNormalization: args.ProviderNormalization}
+
 	// connect to DB
-	log.Printf("using database '%v'", args.DBPath)
-	db, err := sql.Open("sqlite3", args.DBPath)
+	var sqlStore *mdb.SQLStore
+	switch args.Store {
+	case "sqlite":
+		log.Printf("using database '%v'", args.DBPath)
+		db, err := sql.Open("sqlite3", args.DBPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		// close once function finished
+		defer db.Close()
+		sqlStore = mdb.NewSQLiteStore(db, opts)
+	case "postgres":
+		if args.PostgresDSN == "" {
+			log.Fatal("--postgres-dsn is required when using the postgres store")
+		}
+		log.Printf("using postgres database")
+		db, err := sql.Open("postgres", args.PostgresDSN)
+		if err != nil {
+			log.Fatal(err)
+		}
+		// close once function finished
+		defer db.Close()
+		sqlStore = mdb.NewPostgresStore(db, opts)
+	case "memory":
+		log.Printf("using in-memory store, data will be lost on exit")
+	default:
+		log.Fatalf("unknown store '%v', expected sqlite, postgres or memory", args.Store)
+	}
+
+	if args.Migrate != nil {
+		if sqlStore == nil {
+			log.Fatalf("migrate: the %v store has no schema to migrate", args.Store)
+		}
+		if err := runMigrate(sqlStore, args.Migrate); err != nil {
+			log.Fatalf("migrate: %v", err)
+		}
+		return
+	}
+
+	if args.Keys != nil {
+		if sqlStore == nil {
+			log.Fatalf("keys: the %v store can't keep API keys", args.Store)
+		}
+		// make sure the api_keys table exists
+		if _, err := sqlStore.Migrate(false); err != nil {
+			log.Fatal(err)
+		}
+		if err := runKeys(sqlStore, args.Keys); err != nil {
+			log.Fatalf("keys: %v", err)
+		}
+		return
+	}
+
+	if args.Import != nil || args.Export != nil {
+		if sqlStore == nil {
+			log.Fatalf("the %v store can't be imported into or exported from, use --grpc-addr", args.Store)
+		}
+		// make sure the schema exists before reading or writing
+		if _, err := sqlStore.Migrate(false); err != nil {
+			log.Fatal(err)
+		}
+
+		var err error
+		if args.Import != nil {
+			// imports are signups too, so they get the same domain check as the APIs
+			var checkedStore *domaincheck.Store
+			if checkedStore, err = domainCheckedStore(sqlStore); err == nil {
+				err = runImport(args.Import, checkedStore)
+			}
+		} else {
+			err = runExport(args.Export, transfer.StoreSource{Store: sqlStore, Fields: sqlStore})
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	var store mdb.Store = mdb.NewMemoryStore(opts)
+	if sqlStore != nil {
+		// bring schema up to date before serving requests
+		if _, err := sqlStore.Migrate(false); err != nil {
+			log.Fatal(err)
+		}
+		store = sqlStore
+	}
+
+	// the APIs serve the checked store, keys and idempotency records come straight from the backend
+	checkedStore, err := domainCheckedStore(store)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	// close once function finished
-	defer db.Close()
+	secret := []byte(args.TokenSecret)
+	if len(secret) == 0 {
+		// links already sent would stop working after a restart or on another replica
+		if args.Store != "memory" {
+			log.Fatal("--token-secret (or MAILINGLIST_TOKEN_SECRET) is required, it signs the confirmation and unsubscribe links")
+		}
+		// the data is lost on exit anyway, so tokens only need to outlive it
+		log.Printf("no token secret set, generating a temporary one")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			log.Fatal(err)
+		}
+	}
+	tokens := token.NewSigner(secret, args.ConfirmTTL)
+
+	var authenticator *auth.Authenticator
+	if args.NoAuth {
+		log.Printf("API keys disabled, anyone who can reach the server can use it")
+	} else {
+		if sqlStore == nil {
+			log.Fatalf("the %v store can't keep API keys, use --no-auth", args.Store)
+		}
+		authenticator = auth.NewAuthenticator(store)
+	}
+
+	// forget idempotency keys once they can't be replayed
+	keeper := idempotency.NewKeeper(store, args.IdempotencyWindow)
+	go keeper.Expire(time.Hour, nil)
+
+	limiter, err := rateLimiter()
+	if err != nil {
+		log.Fatalf("rate limit: %v", err)
+	}
 
-	mdb.TryCreate(db)
+	tlsConfig, err := serverTLS()
+	if err != nil {
+		log.Fatalf("TLS: %v", err)
+	}
+
+	var gatewayListener *loopback.Listener
+	if args.JSONAPI == jsonAPIGateway {
+		// the gateway calls the gRPC server in memory rather than over the network
+		gatewayListener = loopback.NewListener()
+	}
 
 	var wg sync.WaitGroup
 
 	wg.Add(1)
 	// start JSON server
 	go func() {
-		log.Printf("starting JSON API server...\n")
-		jsonapi.Serve(db, args.BindJSON)
+		if args.JSONAPI == jsonAPILegacy {
+			log.Printf("starting JSON API server...\n")
+			jsonapi.Serve(checkedStore, tokens, authenticator, limiter, keeper, tlsConfig, args.BindJSON)
+		} else {
+			log.Printf("starting HTTP/JSON gateway...\n")
+			serveGateway(checkedStore, tokens, gatewayListener, tlsConfig, args.BindJSON)
+		}
 		wg.Done()
 	}()
 
@@ -57,9 +414,9 @@ func main() {
 	// start gRPC server
 	go func() {
 		log.Printf("starting gRPC API server...\n")
-		grpcapi.Serve(db, args.BindGRPC)
+		grpcapi.Serve(checkedStore, tokens, authenticator, limiter, keeper, tlsConfig, args.BindGRPC, gatewayListener)
 		wg.Done()
 	}()
 
 	wg.Wait()
-}
\ No newline at end of file
+}
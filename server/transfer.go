@@ -0,0 +1,236 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/IM-Deane/mailing-list/auth"
+	"github.com/IM-Deane/mailing-list/mdb"
+	pb "github.com/IM-Deane/mailing-list/proto"
+	"github.com/IM-Deane/mailing-list/tlsconfig"
+	"github.com/IM-Deane/mailing-list/transfer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// TLSClientArgs are the TLS options used by import and export with --grpc-addr
+type TLSClientArgs struct {
+	TLS bool `arg:"--tls,env:MAILINGLIST_TLS" help:"connect to --grpc-addr with TLS, implied by the other --tls flags"`
+	TLSCA string `arg:"--tls-ca,env:MAILINGLIST_TLS_CA" help:"CA bundle to verify the server with instead of the system's"`
+	TLSCert string `arg:"--tls-client-cert,env:MAILINGLIST_TLS_CLIENT_CERT" help:"client certificate for servers that verify them"`
+	TLSKey string `arg:"--tls-client-key,env:MAILINGLIST_TLS_CLIENT_KEY" help:"key of the client certificate"`
+	TLSServerName string `arg:"--tls-server-name" help:"name the server certificate must have, defaults to the host in --grpc-addr"`
+}
+
+// transportCredentials picks plaintext or TLS for the gRPC connection
+func (t TLSClientArgs) transportCredentials() (credentials.TransportCredentials, error) {
+	if !t.TLS && t.TLSCA == "" && t.TLSCert == "" && t.TLSKey == "" && t.TLSServerName == "" {
+		return insecure.NewCredentials(), nil
+	}
+	config, err := tlsconfig.ClientConfig(t.TLSCA, t.TLSCert, t.TLSKey, t.TLSServerName)
+	if err != nil {
+		return nil, err
+	}
+	return credentials.NewTLS(config), nil
+}
+
+// ImportCmd imports emails from a CSV or JSONL file
+type ImportCmd struct {
+	File string `arg:"positional,required" help:"file to import, - for stdin"`
+	Format string `help:"csv or jsonl, detected from the file extension by default"`
+	List string `help:"list to import into, defaults to the global email list"`
+	Columns string `help:"read fields from differently named columns, e.g. email=E-mail,opt_out=Unsubscribed"`
+	BatchSize int `arg:"--batch-size" default:"1000" help:"emails imported per transaction"`
+	DryRun bool `arg:"--dry-run" help:"only validate the file, nothing is imported"`
+	Rejects string `help:"write rejected rows to this CSV file"`
+	GRPCAddr string `arg:"--grpc-addr" help:"import through a running server's gRPC API instead of directly into the store"`
+	APIKey string `arg:"--api-key,env:MAILINGLIST_API_KEY" help:"API key with the write scope, used with --grpc-addr"`
+	TLSClientArgs
+}
+
+// ExportCmd exports emails to a CSV or JSONL file
+type ExportCmd struct {
+	File string `arg:"positional,required" help:"file to write, - for stdout"`
+	Format string `help:"csv or jsonl, detected from the file extension by default"`
+	List string `help:"list to export, defaults to the global email list"`
+	IncludeOptedOut bool `arg:"--include-opted-out" help:"also export emails that have opted out"`
+	ConfirmedOnly bool `arg:"--confirmed-only" help:"only export confirmed subscribers"`
+	Filters []string `arg:"--filter,separate" help:"only export addresses whose attributes match, e.g. locale=en or age>=18, repeat to match every filter"`
+	GRPCAddr string `arg:"--grpc-addr" help:"export through a running server's gRPC API instead of directly from the store"`
+	APIKey string `arg:"--api-key,env:MAILINGLIST_API_KEY" help:"API key with the read scope, used with --grpc-addr"`
+	TLSClientArgs
+}
+
+// dialGRPC connects to a running server for import and export
+func dialGRPC(addr string, apiKey string, tlsArgs TLSClientArgs) (*grpc.ClientConn, transfer.GRPCClient, error) {
+	creds, err := tlsArgs.transportCredentials()
+	if err != nil {
+		return nil, transfer.GRPCClient{}, err
+	}
+	opts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	if apiKey != "" {
+		opts = append(opts, grpc.WithPerRPCCredentials(auth.Credentials(apiKey)))
+	}
+	conn, err := grpc.Dial(addr, opts...)
+	if err != nil {
+		return nil, transfer.GRPCClient{}, err
+	}
+	return conn, transfer.GRPCClient{Client: pb.NewMailingListServiceClient(conn), Timeout: 10 * time.Minute}, nil
+}
+
+// runImport imports cmd.File into dest and prints a report. dest may be nil for dry runs.
+func runImport(cmd *ImportCmd, dest transfer.Destination) error {
+	format, err := transfer.ParseFormat(cmd.Format, cmd.File)
+	if err != nil {
+		return err
+	}
+	columns, err := transfer.ParseColumnMap(cmd.Columns)
+	if err != nil {
+		return err
+	}
+
+	var in io.Reader = os.Stdin
+	if cmd.File != "-" {
+		f, err := os.Open(cmd.File)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	reader, err := transfer.NewReader(in, format, columns)
+	if err != nil {
+		return err
+	}
+
+	report, err := transfer.Import(reader, dest, transfer.ImportOptions{
+		List: cmd.List,
+		BatchSize: cmd.BatchSize,
+		DryRun: cmd.DryRun,
+	})
+	if err != nil {
+		return err
+	}
+
+	if cmd.DryRun {
+		fmt.Printf("dry run: %v rows, %v valid, %v rejected\n", report.Rows, report.Rows-len(report.Rejects), len(report.Rejects))
+	} else {
+		fmt.Printf("%v rows: %v created, %v already existed, %v invalid, %v suppressed\n",
+			report.Rows, report.Created, report.AlreadyExists, report.Invalid, report.Suppressed)
+	}
+
+	if cmd.Rejects != "" {
+		return writeRejects(cmd.Rejects, report.Rejects)
+	}
+	for _, reject := range report.Rejects {
+		fmt.Printf("  rejected line %v %v: %v\n", reject.Line, reject.Email, reject.Reason)
+	}
+
+	return nil
+}
+
+// writeRejects saves rejected rows as a CSV file
+func writeRejects(path string, rejects []transfer.RowError) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	w := csv.NewWriter(f)
+	w.Write([]string{"line", "email", "reason"})
+	for _, reject := range rejects {
+		w.Write([]string{strconv.Itoa(reject.Line), reject.Email, reject.Reason})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return err
+	}
+
+	fmt.Printf("wrote %v rejected rows to %v\n", len(rejects), path)
+	return nil
+}
+
+// runExport writes every matching email from src to cmd.File
+func runExport(cmd *ExportCmd, src transfer.Source) error {
+	format, err := transfer.ParseFormat(cmd.Format, cmd.File)
+	if err != nil {
+		return err
+	}
+	filters, err := mdb.ParseFilters(cmd.Filters)
+	if err != nil {
+		return err
+	}
+
+	// every custom attribute gets a column, whether or not anyone has it set
+	fields, err := src.GetFields()
+	if err != nil {
+		return err
+	}
+	attributes := make([]string, 0, len(fields))
+	for _, field := range fields {
+		attributes = append(attributes, field.Name)
+	}
+
+	var out io.Writer = os.Stdout
+	if cmd.File != "-" {
+		f, err := os.Create(cmd.File)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	writer, err := transfer.NewWriter(out, format, attributes)
+	if err != nil {
+		return err
+	}
+
+	count, err := transfer.Export(src, writer, cmd.List, mdb.GetEmailBatchQueryParams{
+		IncludeOptOut: cmd.IncludeOptedOut,
+		ConfirmedOnly: cmd.ConfirmedOnly,
+		Filters: filters,
+	})
+	if err != nil {
+		return err
+	}
+
+	// keep stdout clean for the exported data
+	log.Printf("exported %v emails\n", count)
+	return nil
+}
+
+// runTransferOverGRPC runs an import or export against a running server, returning false
+// if the command should use the store directly instead
+func runTransferOverGRPC() (bool, error) {
+	addr, apiKey := "", ""
+	var tlsArgs TLSClientArgs
+	switch {
+	case args.Import != nil:
+		addr, apiKey, tlsArgs = args.Import.GRPCAddr, args.Import.APIKey, args.Import.TLSClientArgs
+	case args.Export != nil:
+		addr, apiKey, tlsArgs = args.Export.GRPCAddr, args.Export.APIKey, args.Export.TLSClientArgs
+	}
+	if addr == "" {
+		return false, nil
+	}
+
+	conn, client, err := dialGRPC(addr, apiKey, tlsArgs)
+	if err != nil {
+		return true, err
+	}
+	defer conn.Close()
+
+	if args.Import != nil {
+		return true, runImport(args.Import, client)
+	}
+	return true, runExport(args.Export, client)
+}
@@ -0,0 +1,107 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+)
+
+// Client certificate modes accepted by ServerConfig
+const (
+	// NoClientCert doesn't ask clients for a certificate
+	NoClientCert = "none"
+	// OptionalClientCert verifies the certificates clients send, but also lets clients without one connect
+	OptionalClientCert = "optional"
+	// RequireClientCert only lets clients with a certificate signed by the CA bundle connect
+	RequireClientCert = "require"
+)
+
+// ServerConfig builds a server config serving the reloader's current certificate.
+// Unless clientAuth is NoClientCert, client certificates are verified against the
+// reloader's CA bundle, so a rotated bundle is picked up along with the certificate.
+func ServerConfig(r *Reloader, clientAuth string) (*tls.Config, error) {
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			return r.Certificate(), nil
+		},
+	}
+
+	switch clientAuth {
+	case NoClientCert:
+		return config, nil
+	case OptionalClientCert:
+		config.ClientAuth = tls.RequestClientCert
+	case RequireClientCert:
+		config.ClientAuth = tls.RequireAnyClientCert
+	default:
+		return nil, fmt.Errorf("unknown client certificate mode '%v', expected none, optional or require", clientAuth)
+	}
+	if r.CAs() == nil {
+		return nil, fmt.Errorf("verifying client certificates needs a CA bundle")
+	}
+
+	// verified here rather than with ClientCAs, which can't change once the server starts
+	config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		return verifyClient(r.CAs(), rawCerts)
+	}
+	return config, nil
+}
+
+// verifyClient checks a client's certificate chain against cas
+func verifyClient(cas *x509.CertPool, rawCerts [][]byte) error {
+	if len(rawCerts) == 0 {
+		// only possible with OptionalClientCert
+		return nil
+	}
+
+	opts := x509.VerifyOptions{
+		Roots: cas,
+		Intermediates: x509.NewCertPool(),
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	var leaf *x509.Certificate
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			leaf = cert
+		} else {
+			opts.Intermediates.AddCert(cert)
+		}
+	}
+
+	_, err := leaf.Verify(opts)
+	return err
+}
+
+// ClientConfig builds a config for clients of the servers. Server certificates are
+// verified against caFile, or the system's CAs if it's empty. certFile and keyFile
+// are the client certificate sent to servers that ask for one, and are optional.
+func ClientConfig(caFile string, certFile string, keyFile string, serverName string) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12, ServerName: serverName}
+
+	if caFile != "" {
+		cas, err := LoadCAs(caFile)
+		if err != nil {
+			return nil, err
+		}
+		config.RootCAs = cas
+	}
+
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, errors.New("a client certificate needs both a certificate and a key file")
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
@@ -0,0 +1,124 @@
+package tlsconfig
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// testServer serves the common name of each client's certificate, or "anonymous",
+// over TLS with the given client certificate mode
+func testServer(t *testing.T, r *Reloader, clientAuth string) *httptest.Server {
+	config, err := ServerConfig(r, clientAuth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := "anonymous"
+		if id := IdentityFromContext(r.Context()); id != nil {
+			name = id.CommonName
+		}
+		fmt.Fprint(w, name)
+	})))
+	srv.TLS = config
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// get fetches srv's identity page with a client using config
+func get(srv *httptest.Server, caFile string, certFile string, keyFile string) (string, error) {
+	config, err := ClientConfig(caFile, certFile, keyFile, "localhost")
+	if err != nil {
+		return "", err
+	}
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: config}}
+	res, err := client.Get(srv.URL)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	return string(body), err
+}
+
+func TestClientVerification(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, dir, "ca")
+	other := newTestCA(t, dir, "other-ca")
+	certFile, keyFile := ca.serverCert(t, dir, "server")
+	clientCert, clientKey := ca.clientCert(t, dir, "alice")
+	strangerCert, strangerKey := other.clientCert(t, dir, "mallory")
+	// a certificate only meant for servers can't be used to log in
+	serverOnlyCert, serverOnlyKey := ca.serverCert(t, dir, "server-only")
+
+	r, err := NewReloader(certFile, keyFile, ca.file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		clientAuth string
+		certFile string
+		keyFile string
+		// want is the identity served, empty when the handshake should fail
+		want string
+	}{
+		{"required", RequireClientCert, clientCert, clientKey, "alice"},
+		{"required without a certificate", RequireClientCert, "", "", ""},
+		{"required from another CA", RequireClientCert, strangerCert, strangerKey, ""},
+		{"required with a server certificate", RequireClientCert, serverOnlyCert, serverOnlyKey, ""},
+		{"optional", OptionalClientCert, clientCert, clientKey, "alice"},
+		{"optional without a certificate", OptionalClientCert, "", "", "anonymous"},
+		{"optional from another CA", OptionalClientCert, strangerCert, strangerKey, ""},
+		{"none", NoClientCert, clientCert, clientKey, "anonymous"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := testServer(t, r, tt.clientAuth)
+			got, err := get(srv, ca.file, tt.certFile, tt.keyFile)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("got %q, want the handshake to fail", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("got %q, %v, want %q", got, err, tt.want)
+			}
+		})
+	}
+
+	// clients check the server against the CA too
+	srv := testServer(t, r, NoClientCert)
+	if _, err := get(srv, other.file, "", ""); err == nil {
+		t.Error("trusted a server signed by another CA")
+	}
+}
+
+func TestServerConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, dir, "ca")
+	certFile, keyFile := ca.serverCert(t, dir, "server")
+
+	withoutCAs, err := NewReloader(certFile, keyFile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ServerConfig(withoutCAs, NoClientCert); err != nil {
+		t.Errorf("got %v, want no CA bundle needed without client certificates", err)
+	}
+	if _, err := ServerConfig(withoutCAs, RequireClientCert); err == nil {
+		t.Error("verified client certificates without a CA bundle")
+	}
+	if _, err := ServerConfig(withoutCAs, "sometimes"); err == nil {
+		t.Error("accepted an unknown client certificate mode")
+	}
+
+	if _, err := ClientConfig("", certFile, "", "localhost"); err == nil {
+		t.Error("loaded a client certificate without its key")
+	}
+}
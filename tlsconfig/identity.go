@@ -0,0 +1,172 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/IM-Deane/mailing-list/loopback"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// IdentityMetadataKey is the metadata the gateway forwards its HTTP client's identity in
+const IdentityMetadataKey = "x-client-identity-bin"
+
+// Identity describes the verified certificate a client connected with
+type Identity struct {
+	// CommonName is the subject's common name
+	CommonName string
+	// DNSNames, EmailAddresses and URIs are the certificate's subject alternative names
+	DNSNames []string
+	EmailAddresses []string
+	URIs []string
+	// Fingerprint is the hex encoded SHA-256 of the certificate
+	Fingerprint string
+}
+
+// identityOf describes cert
+func identityOf(cert *x509.Certificate) *Identity {
+	sum := sha256.Sum256(cert.Raw)
+	id := &Identity{
+		CommonName: cert.Subject.CommonName,
+		DNSNames: cert.DNSNames,
+		EmailAddresses: cert.EmailAddresses,
+		Fingerprint: hex.EncodeToString(sum[:]),
+	}
+	for _, uri := range cert.URIs {
+		id.URIs = append(id.URIs, uri.String())
+	}
+	return id
+}
+
+// FromConnectionState returns the client's identity, nil if it didn't send a certificate.
+// The certificate can be trusted because ServerConfig verifies it during the handshake.
+func FromConnectionState(state tls.ConnectionState) *Identity {
+	if len(state.PeerCertificates) == 0 {
+		return nil
+	}
+	return identityOf(state.PeerCertificates[0])
+}
+
+type contextKey struct{}
+
+// WithIdentity returns a context carrying a client's identity
+func WithIdentity(ctx context.Context, id *Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// IdentityFromContext returns the identity of the client that made a request,
+// nil if it didn't connect with a certificate
+func IdentityFromContext(ctx context.Context) *Identity {
+	id, _ := ctx.Value(contextKey{}).(*Identity)
+	return id
+}
+
+// Middleware adds the identity of clients that connected with a certificate to the request context
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil {
+			if id := FromConnectionState(*r.TLS); id != nil {
+				r = r.WithContext(WithIdentity(r.Context(), id))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// peerIdentity returns the identity of the client of a gRPC call
+func peerIdentity(ctx context.Context) *Identity {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return nil
+	}
+	return FromConnectionState(info.State)
+}
+
+// ForwardIdentity sets the identity the gateway forwards to the gRPC server in md,
+// replacing any the HTTP client sent itself. A nil id forwards no identity.
+func ForwardIdentity(md metadata.MD, id *Identity) {
+	md.Delete(IdentityMetadataKey)
+	if id == nil {
+		return
+	}
+	encoded, err := json.Marshal(id)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	md.Set(IdentityMetadataKey, string(encoded))
+}
+
+// forwardedIdentity returns the identity the gateway forwarded with a call
+func forwardedIdentity(ctx context.Context) *Identity {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(IdentityMetadataKey)
+	if len(values) == 0 {
+		return nil
+	}
+	var id Identity
+	if err := json.Unmarshal([]byte(values[len(values)-1]), &id); err != nil {
+		log.Println(err)
+		return nil
+	}
+	return &id
+}
+
+// callIdentity returns the identity of the client of a gRPC call. Calls from the
+// gateway carry its HTTP client's identity, which is only trusted over the loopback.
+func callIdentity(ctx context.Context) *Identity {
+	if loopback.FromGateway(ctx) {
+		return forwardedIdentity(ctx)
+	}
+	return peerIdentity(ctx)
+}
+
+// UnaryServerInterceptor adds the identity of clients that connected with a certificate to the call context
+func UnaryServerInterceptor(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if id := callIdentity(ctx); id != nil {
+		ctx = WithIdentity(ctx, id)
+	}
+	return handler(ctx, req)
+}
+
+// identityStream carries the client's identity in the stream's context
+type identityStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *identityStream) Context() context.Context {
+	return s.ctx
+}
+
+// StreamServerInterceptor is UnaryServerInterceptor for streaming RPCs
+func StreamServerInterceptor(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if id := callIdentity(ss.Context()); id != nil {
+		ss = &identityStream{ServerStream: ss, ctx: WithIdentity(ss.Context(), id)}
+	}
+	return handler(srv, ss)
+}
+
+// ListenAndServe serves handler on bind, over TLS when config is set,
+// adding the identity of clients that connect with a certificate to requests
+func ListenAndServe(bind string, handler http.Handler, config *tls.Config) error {
+	server := &http.Server{Addr: bind, Handler: Middleware(handler), TLSConfig: config}
+	if config == nil {
+		return server.ListenAndServe()
+	}
+	// the certificate comes from config
+	return server.ListenAndServeTLS("", "")
+}
@@ -0,0 +1,192 @@
+package tlsconfig
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/IM-Deane/mailing-list/loopback"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// identityServer records the identity in the context of each call
+type identityServer struct {
+	healthpb.UnimplementedHealthServer
+	identities chan *Identity
+}
+
+func (s *identityServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	s.identities <- IdentityFromContext(ctx)
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+func (s *identityServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	s.identities <- IdentityFromContext(stream.Context())
+	return stream.Send(&healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING})
+}
+
+// identityClient serves an identityServer on listener with the identity interceptors
+// and returns a client dialed with opts, and the server to read identities from
+func identityClient(t *testing.T, listener net.Listener, serverOpts []grpc.ServerOption, opts ...grpc.DialOption) (healthpb.HealthClient, *identityServer) {
+	serverOpts = append(serverOpts, grpc.UnaryInterceptor(UnaryServerInterceptor), grpc.StreamInterceptor(StreamServerInterceptor))
+	server := grpc.NewServer(serverOpts...)
+	identities := &identityServer{identities: make(chan *Identity, 1)}
+	healthpb.RegisterHealthServer(server, identities)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial(listener.Addr().String(), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return healthpb.NewHealthClient(conn), identities
+}
+
+// callIdentities makes a unary call and opens a stream with ctx, returning the
+// identity the server saw for each
+func callIdentities(t *testing.T, ctx context.Context, client healthpb.HealthClient, server *identityServer) (*Identity, *Identity) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	unary := <-server.identities
+
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := stream.Recv(); err != nil {
+		t.Fatal(err)
+	}
+	return unary, <-server.identities
+}
+
+// readCert parses the certificate in a PEM file
+func readCert(t *testing.T, file string) *x509.Certificate {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		t.Fatalf("no PEM in %v", file)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func TestIdentityOf(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, dir, "ca")
+	certFile, _ := ca.clientCert(t, dir, "alice")
+
+	id := identityOf(readCert(t, certFile))
+	if id.CommonName != "alice" || len(id.DNSNames) != 1 || id.DNSNames[0] != "alice.example.com" {
+		t.Errorf("got names %+v", id)
+	}
+	if len(id.EmailAddresses) != 1 || id.EmailAddresses[0] != "alice@example.com" || len(id.URIs) != 1 || id.URIs[0] != "spiffe://example.com/alice" {
+		t.Errorf("got alternative names %+v", id)
+	}
+	if len(id.Fingerprint) != 64 {
+		t.Errorf("got fingerprint %q, want hex SHA-256", id.Fingerprint)
+	}
+
+	if id := FromConnectionState(tls.ConnectionState{}); id != nil {
+		t.Errorf("got %+v without a certificate", id)
+	}
+}
+
+func TestPeerIdentity(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, dir, "ca")
+	certFile, keyFile := ca.serverCert(t, dir, "server")
+	clientCert, clientKey := ca.clientCert(t, dir, "alice")
+
+	r, err := NewReloader(certFile, keyFile, ca.file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverConfig, err := ServerConfig(r, OptionalClientCert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverOpts := []grpc.ServerOption{grpc.Creds(credentials.NewTLS(serverConfig))}
+
+	config, err := ClientConfig(ca.file, clientCert, clientKey, "localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, server := identityClient(t, listener, serverOpts, grpc.WithTransportCredentials(credentials.NewTLS(config)))
+
+	// the peer's certificate wins over any identity it claims in metadata
+	ctx := metadata.AppendToOutgoingContext(context.Background(), IdentityMetadataKey, `{"CommonName": "admin"}`)
+	unary, stream := callIdentities(t, ctx, client, server)
+	for _, id := range []*Identity{unary, stream} {
+		if id == nil || id.CommonName != "alice" {
+			t.Errorf("got %+v, want alice's certificate", id)
+		}
+	}
+}
+
+func TestForwardedIdentity(t *testing.T) {
+	alice := &Identity{CommonName: "alice", Fingerprint: "abc"}
+
+	t.Run("from the gateway", func(t *testing.T) {
+		listener := loopback.NewListener()
+		client, server := identityClient(t, listener, nil, listener.DialOption(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+
+		// the gateway replaces whatever identity its HTTP client sent
+		md := metadata.Pairs(IdentityMetadataKey, `{"CommonName": "admin"}`)
+		ForwardIdentity(md, alice)
+		unary, stream := callIdentities(t, metadata.NewOutgoingContext(context.Background(), md), client, server)
+		for _, id := range []*Identity{unary, stream} {
+			if id == nil || id.CommonName != "alice" || id.Fingerprint != "abc" {
+				t.Errorf("got %+v, want the forwarded identity", id)
+			}
+		}
+
+		// and forwards none for clients without a certificate
+		md = metadata.Pairs(IdentityMetadataKey, `{"CommonName": "admin"}`)
+		ForwardIdentity(md, nil)
+		unary, stream = callIdentities(t, metadata.NewOutgoingContext(context.Background(), md), client, server)
+		if unary != nil || stream != nil {
+			t.Errorf("got %+v, %+v, want no identity", unary, stream)
+		}
+	})
+
+	t.Run("from anywhere else", func(t *testing.T) {
+		listener := bufconn.Listen(1024 * 1024)
+		dialer := grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		})
+		client, server := identityClient(t, listener, nil, dialer, grpc.WithTransportCredentials(insecure.NewCredentials()))
+
+		md := metadata.MD{}
+		ForwardIdentity(md, alice)
+		unary, stream := callIdentities(t, metadata.NewOutgoingContext(context.Background(), md), client, server)
+		if unary != nil || stream != nil {
+			t.Errorf("got %+v, %+v, want identities in metadata ignored", unary, stream)
+		}
+	})
+}
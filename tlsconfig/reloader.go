@@ -0,0 +1,160 @@
+// Package tlsconfig builds TLS configurations for the servers and clients,
+// reloading certificates when they're rotated on disk
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Reloader holds a certificate and optional CA bundle loaded from files,
+// and loads them again when the files change so rotated certificates are
+// picked up without a restart
+type Reloader struct {
+	certFile string
+	keyFile string
+	caFile string
+
+	mu sync.RWMutex
+	cert *tls.Certificate
+	pool *x509.CertPool
+	// modTimes of the files when they were last loaded
+	modTimes []time.Time
+}
+
+// NewReloader loads certFile and keyFile, and the PEM CA bundle in caFile if it's set
+func NewReloader(certFile string, keyFile string, caFile string) (*Reloader, error) {
+	r := &Reloader{certFile: certFile, keyFile: keyFile, caFile: caFile}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// files lists the files the reloader loads
+func (r *Reloader) files() []string {
+	files := []string{r.certFile, r.keyFile}
+	if r.caFile != "" {
+		files = append(files, r.caFile)
+	}
+	return files
+}
+
+// fileModTimes returns the modification time of each file
+func (r *Reloader) fileModTimes() ([]time.Time, error) {
+	var times []time.Time
+	for _, file := range r.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, err
+		}
+		times = append(times, info.ModTime())
+	}
+	return times, nil
+}
+
+// Reload loads the files again. The current certificate is kept if any of them fail to load.
+func (r *Reloader) Reload() error {
+	modTimes, err := r.fileModTimes()
+	if err != nil {
+		return err
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return err
+	}
+	cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return err
+	}
+
+	var pool *x509.CertPool
+	if r.caFile != "" {
+		pool, err = LoadCAs(r.caFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cert = &cert
+	r.pool = pool
+	r.modTimes = modTimes
+
+	return nil
+}
+
+// changed reports whether any file was modified since it was loaded
+func (r *Reloader) changed() bool {
+	modTimes, err := r.fileModTimes()
+	if err != nil {
+		// e.g. a file is being replaced, try again next time
+		return false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for i, t := range modTimes {
+		if !t.Equal(r.modTimes[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Watch checks the files every interval and reloads them when they change, until stop is closed.
+// Failed reloads are logged and the current certificate kept.
+func (r *Reloader) Watch(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+			if err := r.Reload(); err != nil {
+				log.Printf("failed to reload certificate '%v': %v", r.certFile, err)
+				continue
+			}
+			log.Printf("reloaded certificate '%v', expires %v", r.certFile, r.Certificate().Leaf.NotAfter.Format(time.RFC3339))
+		}
+	}
+}
+
+// Certificate returns the current certificate
+func (r *Reloader) Certificate() *tls.Certificate {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert
+}
+
+// CAs returns the current CA bundle, nil if the reloader doesn't have one
+func (r *Reloader) CAs() *x509.CertPool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.pool
+}
+
+// LoadCAs reads a bundle of PEM encoded CA certificates
+func LoadCAs(file string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in '%v'", file)
+	}
+	return pool, nil
+}
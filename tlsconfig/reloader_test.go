@@ -0,0 +1,227 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCA signs certificates for tests
+type testCA struct {
+	cert *x509.Certificate
+	key *ecdsa.PrivateKey
+	// file is the CA's certificate in PEM
+	file string
+}
+
+// serial numbers certificates so every one is different
+var serial int64
+
+// newTestCA creates a CA and writes its certificate to a file in dir
+func newTestCA(t *testing.T, dir string, name string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial++
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject: pkix.Name{CommonName: name},
+		NotBefore: time.Now().Add(-time.Hour),
+		NotAfter: time.Now().Add(time.Hour),
+		IsCA: true,
+		BasicConstraintsValid: true,
+		KeyUsage: x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := filepath.Join(dir, name+".pem")
+	writePEM(t, file, "CERTIFICATE", der)
+	return &testCA{cert: cert, key: key, file: file}
+}
+
+// issue signs a certificate from template, filling in its key and validity, and writes
+// it and its key to name.pem and name-key.pem in dir, returning their paths
+func (ca *testCA) issue(t *testing.T, dir string, name string, template *x509.Certificate) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial++
+	template.SerialNumber = big.NewInt(serial)
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile := filepath.Join(dir, name+".pem")
+	keyFile := filepath.Join(dir, name+"-key.pem")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+	return certFile, keyFile
+}
+
+// serverCert issues a certificate for localhost
+func (ca *testCA) serverCert(t *testing.T, dir string, name string) (string, string) {
+	return ca.issue(t, dir, name, &x509.Certificate{
+		Subject: pkix.Name{CommonName: "localhost"},
+		DNSNames: []string{"localhost"},
+		IPAddresses: []net.IP{net.IPv4(127, 0, 0, 1)},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+}
+
+// clientCert issues a client certificate with every kind of name an Identity has
+func (ca *testCA) clientCert(t *testing.T, dir string, name string) (string, string) {
+	uri, err := url.Parse("spiffe://example.com/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ca.issue(t, dir, name, &x509.Certificate{
+		Subject: pkix.Name{CommonName: name},
+		DNSNames: []string{name + ".example.com"},
+		EmailAddresses: []string{name + "@example.com"},
+		URIs: []*url.URL{uri},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+}
+
+// writePEM writes a single PEM block to file
+func writePEM(t *testing.T, file string, blockType string, der []byte) {
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(file, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// copyFile replaces dst with src, moving its modification time forward so the change is
+// seen even on file systems with coarse timestamps
+func copyFile(t *testing.T, src string, dst string) {
+	data, err := os.ReadFile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dst, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	later := info.ModTime().Add(time.Second)
+	if err := os.Chtimes(dst, later, later); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// leafSerial returns the serial number of a certificate file
+func leafSerial(t *testing.T, file string) *big.Int {
+	cert, err := tls.LoadX509KeyPair(file, file[:len(file)-len(".pem")]+"-key.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.SerialNumber
+}
+
+func TestReloader(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, dir, "ca")
+	certFile, keyFile := ca.serverCert(t, dir, "server")
+	rotatedCert, rotatedKey := ca.serverCert(t, dir, "rotated")
+
+	r, err := NewReloader(certFile, keyFile, ca.file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := r.Certificate()
+	if original.Leaf == nil || original.Leaf.SerialNumber.Cmp(leafSerial(t, certFile)) != 0 {
+		t.Fatalf("got certificate %+v, want the one in %v", original.Leaf, certFile)
+	}
+	if r.CAs() == nil {
+		t.Error("got no CA bundle")
+	}
+	if r.changed() {
+		t.Error("files changed right after loading them")
+	}
+
+	// a half written rotation keeps the current certificate
+	if err := os.WriteFile(certFile, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err == nil {
+		t.Error("loaded a broken certificate")
+	}
+	if r.Certificate() != original {
+		t.Error("a failed reload replaced the certificate")
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		r.Watch(10*time.Millisecond, stop)
+		close(done)
+	}()
+
+	copyFile(t, rotatedCert, certFile)
+	copyFile(t, rotatedKey, keyFile)
+	want := leafSerial(t, rotatedCert)
+	deadline := time.Now().Add(5 * time.Second)
+	for r.Certificate().Leaf.SerialNumber.Cmp(want) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the rotated certificate wasn't loaded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	close(stop)
+	<-done
+}
+
+func TestNewReloaderErrors(t *testing.T) {
+	dir := t.TempDir()
+	ca := newTestCA(t, dir, "ca")
+	certFile, keyFile := ca.serverCert(t, dir, "server")
+	_, otherKey := ca.serverCert(t, dir, "other")
+
+	if _, err := NewReloader(filepath.Join(dir, "missing.pem"), keyFile, ""); err == nil {
+		t.Error("loaded a missing certificate")
+	}
+	if _, err := NewReloader(certFile, otherKey, ""); err == nil {
+		t.Error("loaded a certificate with someone else's key")
+	}
+	if _, err := NewReloader(certFile, keyFile, keyFile); err == nil {
+		t.Error("loaded a CA bundle without certificates")
+	}
+	if r, err := NewReloader(certFile, keyFile, ""); err != nil || r.CAs() != nil {
+		t.Errorf("got %v, want no CA bundle without a file", err)
+	}
+}
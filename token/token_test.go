@@ -0,0 +1,104 @@
+package token
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRoundTrip(t *testing.T) {
+	signer := NewSigner([]byte("test secret"), time.Hour)
+
+	claims, err := signer.Verify(signer.ConfirmToken("someone@example.com", "news"), Confirm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Purpose != Confirm || claims.Email != "someone@example.com" || claims.List != "news" {
+		t.Errorf("got %+v", claims)
+	}
+	if expires := time.Unix(claims.ExpiresAt, 0); expires.Before(time.Now().Add(59*time.Minute)) || expires.After(time.Now().Add(time.Hour)) {
+		t.Errorf("got expiry %v, want an hour from now", expires)
+	}
+
+	// the global list has no list claim
+	claims, err = signer.Verify(signer.UnsubscribeToken("someone@example.com", ""), Unsubscribe)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Purpose != Unsubscribe || claims.Email != "someone@example.com" || claims.List != "" {
+		t.Errorf("got %+v", claims)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	expired := NewSigner([]byte("test secret"), -time.Minute)
+	if _, err := expired.Verify(expired.ConfirmToken("someone@example.com", ""), Confirm); !errors.Is(err, ErrExpired) {
+		t.Errorf("got %v for an expired confirmation, want ErrExpired", err)
+	}
+
+	// unsubscribe links in old emails keep working
+	token := expired.UnsubscribeToken("someone@example.com", "")
+	claims, err := expired.Verify(token, Unsubscribe)
+	if err != nil || claims.ExpiresAt != 0 {
+		t.Errorf("got %+v, %v, want an unsubscribe token that never expires", claims, err)
+	}
+
+	// expiry is only reported for tokens that are otherwise valid
+	signer := NewSigner([]byte("test secret"), time.Hour)
+	past := signer.Sign(Claims{Purpose: Confirm, Email: "someone@example.com", ExpiresAt: time.Now().Add(-time.Second).Unix()})
+	if _, err := signer.Verify(past, Confirm); !errors.Is(err, ErrExpired) {
+		t.Errorf("got %v, want ErrExpired", err)
+	}
+	if _, err := signer.Verify(past, Unsubscribe); !errors.Is(err, ErrInvalid) {
+		t.Errorf("got %v for the wrong purpose, want ErrInvalid", err)
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	signer := NewSigner([]byte("test secret"), time.Hour)
+	token := signer.ConfirmToken("someone@example.com", "")
+	payload, sig, _ := strings.Cut(token, ".")
+
+	// forged signs claims for another address with the original signature
+	forgedClaims := base64.RawURLEncoding.EncodeToString([]byte(`{"p":"confirm","e":"victim@example.com"}`))
+	// flipped changes one byte of the signature
+	rawSig, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rawSig[0] ^= 1
+	flipped := base64.RawURLEncoding.EncodeToString(rawSig)
+
+	tests := []struct {
+		name string
+		signer *Signer
+		token string
+		purpose Purpose
+	}{
+		{"empty", signer, "", Confirm},
+		{"no signature", signer, payload, Confirm},
+		{"signature isn't base64", signer, payload + ".!!!", Confirm},
+		{"tampered signature", signer, payload + "." + flipped, Confirm},
+		{"tampered claims", signer, forgedClaims + "." + sig, Confirm},
+		{"another secret", NewSigner([]byte("other secret"), time.Hour), token, Confirm},
+		{"confirmation used to unsubscribe", signer, token, Unsubscribe},
+		{"unsubscribe used to confirm", signer, signer.UnsubscribeToken("someone@example.com", ""), Confirm},
+		{"signed garbage", signer, signRaw(signer, "not json"), Confirm},
+		{"no email", signer, signer.Sign(Claims{Purpose: Confirm}), Confirm},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if claims, err := tt.signer.Verify(tt.token, tt.purpose); !errors.Is(err, ErrInvalid) || claims != nil {
+				t.Errorf("got %+v, %v, want ErrInvalid", claims, err)
+			}
+		})
+	}
+}
+
+// signRaw signs an arbitrary payload with s, as Sign only signs claims
+func signRaw(s *Signer, data string) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(data))
+	return payload + "." + base64.RawURLEncoding.EncodeToString(s.sign(payload))
+}
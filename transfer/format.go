@@ -0,0 +1,441 @@
+package transfer
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IM-Deane/mailing-list/mdb"
+)
+
+// Format is a file format emails can be imported from and exported to
+type Format string
+
+const (
+	// CSV files have a header row naming each column
+	CSV Format = "csv"
+	// JSONL files have one JSON object per line
+	JSONL Format = "jsonl"
+)
+
+// Fields that can be read from and written to files
+const (
+	FieldEmail = "email"
+	FieldConfirmedAt = "confirmed_at"
+	FieldOptOut = "opt_out"
+)
+
+// fields lists every field in the order they are exported
+var fields = []string{FieldEmail, FieldConfirmedAt, FieldOptOut}
+
+// AttributePrefix names the CSV column (or column mapping) of a custom attribute, e.g.
+// attributes.first_name. JSONL files keep them in an "attributes" object instead.
+const AttributePrefix = "attributes."
+
+// attributeKey is the JSONL key holding the custom attributes
+const attributeKey = "attributes"
+
+// ParseFormat converts a format name, or a file name's extension when name is empty, to a Format.
+// Files without an extension, like - for stdin, default to CSV.
+func ParseFormat(name string, fileName string) (Format, error) {
+	if name == "" {
+		name = strings.ToLower(strings.TrimPrefix(filepath.Ext(fileName), "."))
+	}
+	if name == "" {
+		return CSV, nil
+	}
+	switch name {
+	case "csv":
+		return CSV, nil
+	case "jsonl", "ndjson":
+		return JSONL, nil
+	}
+	return "", fmt.Errorf("unknown format '%v', expected csv or jsonl", name)
+}
+
+// ColumnMap maps fields to the column (CSV) or key (JSONL) they are read from
+type ColumnMap map[string]string
+
+// ParseColumnMap parses a mapping like "email=E-mail Address,opt_out=Unsubscribed".
+// Fields that aren't mapped are read from a column with the field's own name.
+// Attributes can be mapped too, e.g. "attributes.first_name=First Name".
+func ParseColumnMap(spec string) (ColumnMap, error) {
+	columns := ColumnMap{}
+	for _, field := range fields {
+		columns[field] = field
+	}
+	if spec == "" {
+		return columns, nil
+	}
+
+	for _, pair := range strings.Split(spec, ",") {
+		field, column, ok := strings.Cut(pair, "=")
+		field = strings.TrimSpace(field)
+		if !ok || column == "" {
+			return nil, fmt.Errorf("invalid column mapping '%v', expected field=column", pair)
+		}
+		_, known := columns[field]
+		if !known && !(strings.HasPrefix(field, AttributePrefix) && len(field) > len(AttributePrefix)) {
+			return nil, fmt.Errorf("unknown field '%v' in column mapping, expected one of %v or %v<name>", field, strings.Join(fields, ", "), AttributePrefix)
+		}
+		columns[field] = column
+	}
+
+	return columns, nil
+}
+
+// Row is an email read from a file
+type Row struct {
+	// Line is the 1-based line the row was read from, used in reports
+	Line int
+	Entry mdb.EmailEntry
+}
+
+// RowError is a row that couldn't be parsed. Reading can continue after one.
+type RowError struct {
+	Line int
+	Email string
+	Reason string
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("line %v: %v", e.Line, e.Reason)
+}
+
+// Reader reads rows from a file.
+// Read returns io.EOF once every row has been read, or a *RowError for a row that can't be parsed.
+type Reader interface {
+	Read() (*Row, error)
+}
+
+// NewReader creates a Reader for a file in the given format
+func NewReader(r io.Reader, format Format, columns ColumnMap) (Reader, error) {
+	switch format {
+	case CSV:
+		return newCSVReader(r, columns)
+	case JSONL:
+		return &jsonlReader{scanner: bufio.NewScanner(r), columns: columns}, nil
+	}
+	return nil, fmt.Errorf("unknown format '%v'", format)
+}
+
+// parseConfirmedAt accepts an RFC 3339 time, a unix timestamp or empty for unconfirmed
+func parseConfirmedAt(value string) (*time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		t := time.Unix(0, 0)
+		return &t, nil
+	}
+	if unix, err := strconv.ParseInt(value, 10, 64); err == nil {
+		t := time.Unix(unix, 0)
+		return &t, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %v '%v', expected RFC 3339 or a unix timestamp", FieldConfirmedAt, value)
+	}
+	return &t, nil
+}
+
+// parseOptOut accepts true/false style values or empty for false
+func parseOptOut(value string) (bool, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return false, nil
+	}
+	optOut, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %v '%v', expected true or false", FieldOptOut, value)
+	}
+	return optOut, nil
+}
+
+// attributeColumns returns the attribute names mapped to other columns, by attribute
+func (c ColumnMap) attributeColumns() map[string]string {
+	mapped := make(map[string]string)
+	for field, column := range c {
+		if strings.HasPrefix(field, AttributePrefix) {
+			mapped[strings.TrimPrefix(field, AttributePrefix)] = column
+		}
+	}
+	return mapped
+}
+
+// newRow builds a row from raw field values, returning a *RowError if they're invalid.
+// Attribute values are checked against their fields when the row is imported.
+func newRow(line int, email string, confirmedAt string, optOut string, attrs mdb.Attributes) (*Row, error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return nil, &RowError{Line: line, Reason: "missing " + FieldEmail}
+	}
+
+	t, err := parseConfirmedAt(confirmedAt)
+	if err != nil {
+		return nil, &RowError{Line: line, Email: email, Reason: err.Error()}
+	}
+	o, err := parseOptOut(optOut)
+	if err != nil {
+		return nil, &RowError{Line: line, Email: email, Reason: err.Error()}
+	}
+
+	return &Row{Line: line, Entry: mdb.EmailEntry{Email: email, ConfirmedAt: t, OptOut: o, Attributes: attrs}}, nil
+}
+
+// csvReader reads rows from a CSV file with a header row
+type csvReader struct {
+	reader *csv.Reader
+	// index of each field's column, -1 if the file doesn't have it
+	index map[string]int
+	// index of each attribute's column
+	attributes map[string]int
+}
+
+func newCSVReader(r io.Reader, columns ColumnMap) (*csvReader, error) {
+	reader := csv.NewReader(r)
+	// rows don't all need every column, missing trailing values are treated as empty
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("reading CSV header: %w", err)
+	}
+
+	index := make(map[string]int)
+	for _, field := range fields {
+		index[field] = -1
+		for i, name := range header {
+			if strings.EqualFold(strings.TrimSpace(name), columns[field]) {
+				index[field] = i
+			}
+		}
+	}
+	if index[FieldEmail] == -1 {
+		return nil, fmt.Errorf("CSV header has no '%v' column", columns[FieldEmail])
+	}
+
+	// attributes come from attributes.<name> columns and columns mapped to them
+	attributes := make(map[string]int)
+	mapped := columns.attributeColumns()
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		if len(name) > len(AttributePrefix) && strings.EqualFold(name[:len(AttributePrefix)], AttributePrefix) {
+			attributes[name[len(AttributePrefix):]] = i
+		}
+		for attribute, column := range mapped {
+			if strings.EqualFold(name, column) {
+				attributes[attribute] = i
+			}
+		}
+	}
+
+	return &csvReader{reader: reader, index: index, attributes: attributes}, nil
+}
+
+func (c *csvReader) Read() (*Row, error) {
+	record, err := c.reader.Read()
+	if err != nil {
+		if parseErr, ok := err.(*csv.ParseError); ok {
+			return nil, &RowError{Line: parseErr.Line, Reason: parseErr.Err.Error()}
+		}
+		return nil, err
+	}
+	line, _ := c.reader.FieldPos(0)
+
+	value := func(field string) string {
+		i := c.index[field]
+		if i < 0 || i >= len(record) {
+			return ""
+		}
+		return record[i]
+	}
+
+	// empty cells leave the attribute unset, values are converted to the field's type on import
+	var attrs mdb.Attributes
+	for name, i := range c.attributes {
+		if i < len(record) && strings.TrimSpace(record[i]) != "" {
+			if attrs == nil {
+				attrs = mdb.Attributes{}
+			}
+			attrs[name] = record[i]
+		}
+	}
+
+	return newRow(line, value(FieldEmail), value(FieldConfirmedAt), value(FieldOptOut), attrs)
+}
+
+// jsonlReader reads rows from a file with one JSON object per line
+type jsonlReader struct {
+	scanner *bufio.Scanner
+	columns ColumnMap
+	line int
+}
+
+func (j *jsonlReader) Read() (*Row, error) {
+	for j.scanner.Scan() {
+		j.line++
+		text := strings.TrimSpace(j.scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var object map[string]interface{}
+		if err := json.Unmarshal([]byte(text), &object); err != nil {
+			return nil, &RowError{Line: j.line, Reason: "invalid JSON: " + err.Error()}
+		}
+
+		// values may be strings, numbers or bools, normalize them to strings for parsing
+		value := func(field string) string {
+			switch v := object[j.columns[field]].(type) {
+			case nil:
+				return ""
+			case string:
+				return v
+			case float64:
+				return strconv.FormatInt(int64(v), 10)
+			default:
+				return fmt.Sprint(v)
+			}
+		}
+
+		// attributes keep their JSON types, mapped keys are added to them
+		var attrs mdb.Attributes
+		if nested, ok := object[attributeKey].(map[string]interface{}); ok {
+			for name, v := range nested {
+				if v != nil {
+					if attrs == nil {
+						attrs = mdb.Attributes{}
+					}
+					attrs[name] = v
+				}
+			}
+		}
+		for name, key := range j.columns.attributeColumns() {
+			if v, ok := object[key]; ok && v != nil {
+				if attrs == nil {
+					attrs = mdb.Attributes{}
+				}
+				attrs[name] = v
+			}
+		}
+
+		return newRow(j.line, value(FieldEmail), value(FieldConfirmedAt), value(FieldOptOut), attrs)
+	}
+
+	if err := j.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+// Writer writes email entries to a file
+type Writer interface {
+	Write(entry mdb.EmailEntry) error
+	// Flush writes any buffered data, call it once all entries are written
+	Flush() error
+}
+
+// NewWriter creates a Writer for a file in the given format. CSV files get an
+// attributes.<name> column for each of attributes, JSONL files write every attribute.
+func NewWriter(w io.Writer, format Format, attributes []string) (Writer, error) {
+	switch format {
+	case CSV:
+		header := append([]string{}, fields...)
+		for _, name := range attributes {
+			header = append(header, AttributePrefix+name)
+		}
+		return &csvWriter{writer: csv.NewWriter(w), header: header, attributes: attributes}, nil
+	case JSONL:
+		buf := bufio.NewWriter(w)
+		return &jsonlWriter{buf: buf, encoder: json.NewEncoder(buf)}, nil
+	}
+	return nil, fmt.Errorf("unknown format '%v'", format)
+}
+
+// formatConfirmedAt returns confirmed_at as RFC 3339, or empty if unconfirmed
+func formatConfirmedAt(entry mdb.EmailEntry) string {
+	if entry.ConfirmedAt == nil || entry.ConfirmedAt.Unix() <= 0 {
+		return ""
+	}
+	return entry.ConfirmedAt.UTC().Format(time.RFC3339)
+}
+
+// formatAttribute writes an attribute value as a CSV cell, empty if it isn't set
+func formatAttribute(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// csvWriter writes entries as CSV with a header row
+type csvWriter struct {
+	writer *csv.Writer
+	header []string
+	// attributes written after the fields, in header order
+	attributes []string
+	wroteHeader bool
+}
+
+func (c *csvWriter) Write(entry mdb.EmailEntry) error {
+	if !c.wroteHeader {
+		if err := c.writer.Write(c.header); err != nil {
+			return err
+		}
+		c.wroteHeader = true
+	}
+	record := []string{entry.Email, formatConfirmedAt(entry), strconv.FormatBool(entry.OptOut)}
+	for _, name := range c.attributes {
+		record = append(record, formatAttribute(entry.Attributes[name]))
+	}
+	return c.writer.Write(record)
+}
+
+func (c *csvWriter) Flush() error {
+	// an empty export still gets a header so it can be imported again
+	if !c.wroteHeader {
+		if err := c.writer.Write(c.header); err != nil {
+			return err
+		}
+	}
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+// jsonlWriter writes entries as one JSON object per line
+type jsonlWriter struct {
+	buf *bufio.Writer
+	encoder *json.Encoder
+}
+
+// jsonlRecord is the JSON object written for each entry
+type jsonlRecord struct {
+	Email string `json:"email"`
+	ConfirmedAt string `json:"confirmed_at,omitempty"`
+	OptOut bool `json:"opt_out"`
+	Attributes mdb.Attributes `json:"attributes,omitempty"`
+}
+
+func (j *jsonlWriter) Write(entry mdb.EmailEntry) error {
+	return j.encoder.Encode(jsonlRecord{
+		Email: entry.Email,
+		ConfirmedAt: formatConfirmedAt(entry),
+		OptOut: entry.OptOut,
+		Attributes: entry.Attributes,
+	})
+}
+
+func (j *jsonlWriter) Flush() error {
+	return j.buf.Flush()
+}
@@ -0,0 +1,205 @@
+package transfer
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readAll reads every row from a file, collecting rows and row errors separately
+func readAll(t *testing.T, format Format, columns ColumnMap, file string) ([]Row, []RowError) {
+	t.Helper()
+	reader, err := NewReader(strings.NewReader(file), format, columns)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rows []Row
+	var rejects []RowError
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return rows, rejects
+		}
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			rejects = append(rejects, *rowErr)
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, *row)
+	}
+}
+
+// mustParseColumnMap parses spec, failing the test if it's invalid
+func mustParseColumnMap(t *testing.T, spec string) ColumnMap {
+	t.Helper()
+	columns, err := ParseColumnMap(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return columns
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		fileName string
+		want Format
+	}{
+		{"", "emails.csv", CSV},
+		{"", "emails.JSONL", JSONL},
+		{"", "emails.ndjson", JSONL},
+		{"", "-", CSV},
+		{"jsonl", "emails.csv", JSONL},
+	}
+	for _, tt := range tests {
+		if got, err := ParseFormat(tt.name, tt.fileName); err != nil || got != tt.want {
+			t.Errorf("%q, %q: got %v, %v, want %v", tt.name, tt.fileName, got, err, tt.want)
+		}
+	}
+	if _, err := ParseFormat("", "emails.xlsx"); err == nil {
+		t.Error("parsed an unknown extension")
+	}
+}
+
+func TestParseColumnMap(t *testing.T) {
+	columns := mustParseColumnMap(t, "email=E-mail Address, opt_out=Unsubscribed,attributes.first_name=First Name")
+	if columns[FieldEmail] != "E-mail Address" || columns[FieldOptOut] != "Unsubscribed" || columns[AttributePrefix+"first_name"] != "First Name" {
+		t.Errorf("got %v", columns)
+	}
+	// unmapped fields keep their own name
+	if columns[FieldConfirmedAt] != FieldConfirmedAt {
+		t.Errorf("got %v for confirmed_at", columns[FieldConfirmedAt])
+	}
+
+	for _, spec := range []string{"email", "email=", "name=Name", "attributes.=Name"} {
+		if _, err := ParseColumnMap(spec); err == nil {
+			t.Errorf("parsed %q", spec)
+		}
+	}
+}
+
+func TestParseConfirmedAt(t *testing.T) {
+	tests := []struct {
+		value string
+		want int64
+	}{
+		{"2024-01-02T03:04:05Z", 1704164645},
+		{"2024-01-02T04:04:05+01:00", 1704164645},
+		{"1704164645", 1704164645},
+		{" 1704164645 ", 1704164645},
+		{"", 0},
+		{"  ", 0},
+	}
+	for _, tt := range tests {
+		got, err := parseConfirmedAt(tt.value)
+		if err != nil || got == nil || got.Unix() != tt.want {
+			t.Errorf("%q: got %v, %v, want %v", tt.value, got, err, time.Unix(tt.want, 0))
+		}
+	}
+	for _, value := range []string{"2024-01-02", "yesterday", "1.5"} {
+		if _, err := parseConfirmedAt(value); err == nil {
+			t.Errorf("parsed %q", value)
+		}
+	}
+}
+
+func TestColumnRemapping(t *testing.T) {
+	columns := mustParseColumnMap(t, "email=E-mail Address,opt_out=Unsubscribed,confirmed_at=Joined,attributes.first_name=First Name")
+
+	t.Run("csv", func(t *testing.T) {
+		// header names match regardless of case, unmapped columns are ignored
+		file := "Name,e-mail address,Unsubscribed,Joined,First Name,attributes.age\n" +
+			"Ann,ann@example.com,true,1704164645,Ann,31\n" +
+			"Bob,bob@example.com,,,,\n"
+		rows, rejects := readAll(t, CSV, columns, file)
+		if len(rejects) != 0 || len(rows) != 2 {
+			t.Fatalf("got %+v, rejects %+v", rows, rejects)
+		}
+		ann := rows[0].Entry
+		if ann.Email != "ann@example.com" || !ann.OptOut || ann.ConfirmedAt.Unix() != 1704164645 {
+			t.Errorf("got %+v", ann)
+		}
+		if ann.Attributes["first_name"] != "Ann" || ann.Attributes["age"] != "31" {
+			t.Errorf("got attributes %v", ann.Attributes)
+		}
+		if bob := rows[1].Entry; bob.OptOut || bob.ConfirmedAt.Unix() != 0 || bob.Attributes != nil {
+			t.Errorf("got %+v, want empty cells left unset", bob)
+		}
+	})
+
+	t.Run("jsonl", func(t *testing.T) {
+		file := `{"E-mail Address": "ann@example.com", "Unsubscribed": true, "Joined": 1704164645, "First Name": "Ann", "attributes": {"age": 31}}` + "\n" +
+			`{"E-mail Address": "bob@example.com", "Joined": "2024-01-02T03:04:05Z", "Unsubscribed": "false"}` + "\n"
+		rows, rejects := readAll(t, JSONL, columns, file)
+		if len(rejects) != 0 || len(rows) != 2 {
+			t.Fatalf("got %+v, rejects %+v", rows, rejects)
+		}
+		ann := rows[0].Entry
+		if ann.Email != "ann@example.com" || !ann.OptOut || ann.ConfirmedAt.Unix() != 1704164645 {
+			t.Errorf("got %+v", ann)
+		}
+		// attributes keep their JSON types
+		if ann.Attributes["first_name"] != "Ann" || ann.Attributes["age"] != float64(31) {
+			t.Errorf("got attributes %v", ann.Attributes)
+		}
+		if bob := rows[1].Entry; bob.OptOut || bob.ConfirmedAt.Unix() != 1704164645 {
+			t.Errorf("got %+v", bob)
+		}
+	})
+
+	if _, err := NewReader(strings.NewReader("email\nann@example.com\n"), CSV, columns); err == nil {
+		t.Error("read a CSV file without the mapped email column")
+	}
+}
+
+func TestRowErrors(t *testing.T) {
+	columns := mustParseColumnMap(t, "")
+
+	t.Run("csv", func(t *testing.T) {
+		file := "email,confirmed_at,opt_out\n" +
+			"ann@example.com,,\n" +
+			",,\n" +
+			"bob@example.com,yesterday,\n" +
+			"carol@example.com,,maybe\n" +
+			"\"dave@example.com,,\n"
+		rows, rejects := readAll(t, CSV, columns, file)
+		if len(rows) != 1 || rows[0].Line != 2 {
+			t.Errorf("got rows %+v, want ann's on line 2", rows)
+		}
+		expectLines(t, rejects, 3, 4, 5, 6)
+	})
+
+	t.Run("jsonl", func(t *testing.T) {
+		file := `{"email": "ann@example.com"}` + "\n" +
+			"\n" +
+			`{"email": ` + "\n" +
+			`{"opt_out": true}` + "\n" +
+			`{"email": "bob@example.com", "opt_out": "maybe"}` + "\n" +
+			`{"email": "carol@example.com"}` + "\n"
+		rows, rejects := readAll(t, JSONL, columns, file)
+		if len(rows) != 2 || rows[0].Line != 1 || rows[1].Line != 6 {
+			t.Errorf("got rows %+v, want lines 1 and 6", rows)
+		}
+		// blank lines are skipped but still counted
+		expectLines(t, rejects, 3, 4, 5)
+	})
+}
+
+// expectLines fails unless rejects are for exactly lines, in order, each with a reason
+func expectLines(t *testing.T, rejects []RowError, lines ...int) {
+	t.Helper()
+	if len(rejects) != len(lines) {
+		t.Fatalf("got rejects %+v, want lines %v", rejects, lines)
+	}
+	for i, reject := range rejects {
+		if reject.Line != lines[i] || reject.Reason == "" {
+			t.Errorf("got reject %+v, want line %v with a reason", reject, lines[i])
+		}
+	}
+}
@@ -0,0 +1,351 @@
+package transfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/IM-Deane/mailing-list/mdb"
+	pb "github.com/IM-Deane/mailing-list/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Destination is where imported emails are written. mdb.EmailStore satisfies it.
+type Destination interface {
+	ImportEmails(list string, entries []mdb.EmailEntry) ([]mdb.ImportResult, error)
+}
+
+// Source is where exported emails are read from
+type Source interface {
+	// ExportEmails calls fn for every email matching params, in id order
+	ExportEmails(list string, params mdb.GetEmailBatchQueryParams, fn func(mdb.EmailEntry) error) error
+	// GetFields returns the custom attributes, which become export columns
+	GetFields() ([]mdb.Field, error)
+}
+
+// ImportOptions control how a file is imported
+type ImportOptions struct {
+	// List to import into, empty for the global email list
+	List string
+	// BatchSize is the number of emails imported per transaction
+	BatchSize int
+	// DryRun only parses and validates the file, nothing is imported
+	DryRun bool
+}
+
+// ImportReport summarizes an import
+type ImportReport struct {
+	Rows int
+	Created int
+	AlreadyExists int
+	Invalid int
+	Suppressed int
+	// Rejects are rows that weren't imported and why
+	Rejects []RowError
+}
+
+// Import reads every row from r and imports it into dest in batches.
+// Rows that can't be parsed, are invalid or were suppressed are listed in the report's rejects.
+func Import(r Reader, dest Destination, opts ImportOptions) (*ImportReport, error) {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 1000
+	}
+
+	report := &ImportReport{}
+	batch := make([]Row, 0, opts.BatchSize)
+
+	// flush imports the current batch and adds the results to the report
+	flush := func() error {
+		if len(batch) == 0 || opts.DryRun {
+			batch = batch[:0]
+			return nil
+		}
+
+		entries := make([]mdb.EmailEntry, 0, len(batch))
+		for _, row := range batch {
+			entries = append(entries, row.Entry)
+		}
+
+		results, err := dest.ImportEmails(opts.List, entries)
+		if err != nil {
+			return err
+		}
+
+		for i, result := range results {
+			reject := RowError{Line: batch[i].Line, Email: result.Email, Reason: result.Reason}
+			switch result.Status {
+			case mdb.ImportCreated:
+				report.Created++
+			case mdb.ImportAlreadyExists:
+				report.AlreadyExists++
+			case mdb.ImportInvalid:
+				report.Invalid++
+				report.Rejects = append(report.Rejects, reject)
+			case mdb.ImportSuppressed:
+				report.Suppressed++
+				reject.Reason = "previously opted out"
+				report.Rejects = append(report.Rejects, reject)
+			}
+		}
+
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		var rowErr *RowError
+		if errors.As(err, &rowErr) {
+			report.Rows++
+			report.Invalid++
+			report.Rejects = append(report.Rejects, *rowErr)
+			continue
+		}
+		if err != nil {
+			return report, err
+		}
+		report.Rows++
+
+		// a dry run can only check what's in the file, not what's in the store
+		if opts.DryRun {
+			if err := mdb.ValidateAddress(row.Entry.Email); err != nil {
+				reason := err.Error()
+				var validationErr *mdb.ValidationError
+				if errors.As(err, &validationErr) {
+					reason = validationErr.Reason
+				}
+				report.Invalid++
+				report.Rejects = append(report.Rejects, RowError{Line: row.Line, Email: row.Entry.Email, Reason: reason})
+			}
+			continue
+		}
+
+		batch = append(batch, *row)
+		if len(batch) >= opts.BatchSize {
+			if err := flush(); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return report, err
+	}
+
+	// parse errors are found before batch results, report everything in file order
+	sort.SliceStable(report.Rejects, func(i, j int) bool {
+		return report.Rejects[i].Line < report.Rejects[j].Line
+	})
+
+	return report, nil
+}
+
+// Export writes every email from src matching params to w and returns how many were written
+func Export(src Source, w Writer, list string, params mdb.GetEmailBatchQueryParams) (int, error) {
+	count := 0
+	err := src.ExportEmails(list, params, func(entry mdb.EmailEntry) error {
+		count++
+		return w.Write(entry)
+	})
+	if err != nil {
+		return count, err
+	}
+
+	return count, w.Flush()
+}
+
+// StoreSource exports emails directly from a store, walking it with a cursor
+type StoreSource struct {
+	Store mdb.EmailStore
+	// Fields are the custom attributes exported with each email
+	Fields mdb.FieldStore
+}
+
+// GetFields returns the store's custom attributes
+func (s StoreSource) GetFields() ([]mdb.Field, error) {
+	return s.Fields.GetFields()
+}
+
+// ExportEmails calls fn for every email matching params, fetching params.Count at a time
+func (s StoreSource) ExportEmails(list string, params mdb.GetEmailBatchQueryParams, fn func(mdb.EmailEntry) error) error {
+	params.Page = 0
+	params.Cursor = ""
+	if params.Count <= 0 {
+		params.Count = 500
+	}
+
+	for {
+		var entries []mdb.EmailEntry
+		var err error
+		if list != "" {
+			entries, err = s.Store.GetSubscriptionBatch(list, params)
+		} else {
+			entries, err = s.Store.GetEmailBatch(params)
+		}
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			if err := fn(entry); err != nil {
+				return err
+			}
+		}
+
+		params.Cursor = mdb.NextCursor(entries, params)
+		if params.Cursor == "" {
+			return nil
+		}
+	}
+}
+
+// GRPCClient imports and exports emails through a running server's gRPC API
+type GRPCClient struct {
+	Client pb.MailingListServiceClient
+	// Timeout for each import batch or the whole export
+	Timeout time.Duration
+}
+
+// pbEntryToMdbEntry converts a protocol buffer entry to a mailing database entry
+func pbEntryToMdbEntry(pbEntry *pb.EmailEntry) mdb.EmailEntry {
+	t := time.Unix(pbEntry.ConfirmedAt, 0)
+	var attrs mdb.Attributes
+	if len(pbEntry.Attributes) > 0 {
+		attrs = make(mdb.Attributes, len(pbEntry.Attributes))
+		for name, value := range pbEntry.Attributes {
+			attrs[name] = value.AsInterface()
+		}
+	}
+	return mdb.EmailEntry{ID: pbEntry.Id, Email: pbEntry.Email, ConfirmedAt: &t, OptOut: pbEntry.OptOut, Attributes: attrs}
+}
+
+// mdbAttributesToPb converts attribute values to protocol buffer values
+func mdbAttributesToPb(attrs mdb.Attributes) (map[string]*structpb.Value, error) {
+	if len(attrs) == 0 {
+		return nil, nil
+	}
+	pbAttrs := make(map[string]*structpb.Value, len(attrs))
+	for name, value := range attrs {
+		pbValue, err := structpb.NewValue(value)
+		if err != nil {
+			return nil, fmt.Errorf("attribute %v: %w", name, err)
+		}
+		pbAttrs[name] = pbValue
+	}
+	return pbAttrs, nil
+}
+
+// importStatuses maps protocol buffer import statuses to mdb outcomes
+var importStatuses = map[pb.ImportStatus]mdb.ImportStatus{
+	pb.ImportStatus_IMPORT_STATUS_CREATED: mdb.ImportCreated,
+	pb.ImportStatus_IMPORT_STATUS_ALREADY_EXISTS: mdb.ImportAlreadyExists,
+	pb.ImportStatus_IMPORT_STATUS_INVALID: mdb.ImportInvalid,
+	pb.ImportStatus_IMPORT_STATUS_SUPPRESSED: mdb.ImportSuppressed,
+}
+
+// ImportEmails imports entries as one ImportEmails stream, so they share a transaction on the server
+func (g GRPCClient) ImportEmails(list string, entries []mdb.EmailEntry) ([]mdb.ImportResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), g.Timeout)
+	defer cancel()
+
+	stream, err := g.Client.ImportEmails(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		attrs, err := mdbAttributesToPb(entry.Attributes)
+		if err != nil {
+			return nil, err
+		}
+		pbEntry := &pb.EmailEntry{Email: entry.Email, OptOut: entry.OptOut, Attributes: attrs}
+		if entry.ConfirmedAt != nil {
+			pbEntry.ConfirmedAt = entry.ConfirmedAt.Unix()
+		}
+		req := &pb.ImportEmailsRequest{EmailEntry: pbEntry, List: list, BatchSize: int32(len(entries))}
+		if err := stream.Send(req); err != nil {
+			return nil, err
+		}
+	}
+	if err := stream.CloseSend(); err != nil {
+		return nil, err
+	}
+
+	results := make([]mdb.ImportResult, 0, len(entries))
+	for {
+		res, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, result := range res.Results {
+			results = append(results, mdb.ImportResult{
+				Email: result.Email,
+				Status: importStatuses[result.Status],
+				Reason: result.Reason,
+			})
+		}
+	}
+
+	return results, nil
+}
+
+// GetFields fetches the server's custom attributes with the GetFields RPC
+func (g GRPCClient) GetFields() ([]mdb.Field, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), g.Timeout)
+	defer cancel()
+
+	res, err := g.Client.GetFields(ctx, &pb.GetFieldsRequest{})
+	if err != nil {
+		return nil, err
+	}
+
+	fields := make([]mdb.Field, 0, len(res.Fields))
+	for _, field := range res.Fields {
+		fields = append(fields, mdb.Field{ID: field.Id, Name: field.Name, Type: mdb.FieldType(field.Type), Description: field.Description})
+	}
+	return fields, nil
+}
+
+// ExportEmails streams every email matching params with the StreamEmails RPC
+func (g GRPCClient) ExportEmails(list string, params mdb.GetEmailBatchQueryParams, fn func(mdb.EmailEntry) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), g.Timeout)
+	defer cancel()
+
+	filters := make([]string, 0, len(params.Filters))
+	for _, filter := range params.Filters {
+		filters = append(filters, filter.String())
+	}
+
+	stream, err := g.Client.StreamEmails(ctx, &pb.StreamEmailsRequest{
+		List: list,
+		IncludeOptedOut: params.IncludeOptOut,
+		ConfirmedOnly: params.ConfirmedOnly,
+		BatchSize: int32(params.Count),
+		Filters: filters,
+	})
+	if err != nil {
+		return err
+	}
+
+	for {
+		entry, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(pbEntryToMdbEntry(entry)); err != nil {
+			return err
+		}
+	}
+}
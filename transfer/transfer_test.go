@@ -0,0 +1,164 @@
+package transfer
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IM-Deane/mailing-list/mdb"
+)
+
+// testStore returns an empty memory store with first_name and age fields
+func testStore(t *testing.T) *mdb.MemoryStore {
+	store := mdb.NewMemoryStore(mdb.Options{})
+	for _, field := range []mdb.Field{{Name: "first_name", Type: mdb.FieldString}, {Name: "age", Type: mdb.FieldNumber}} {
+		if err := store.CreateField(field); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return store
+}
+
+// importFile imports file into dest with opts
+func importFile(t *testing.T, dest Destination, format Format, file string, opts ImportOptions) *ImportReport {
+	t.Helper()
+	reader, err := NewReader(strings.NewReader(file), format, mustParseColumnMap(t, ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := Import(reader, dest, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+func TestRoundTrip(t *testing.T) {
+	src := testStore(t)
+	confirmedAt := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	entries := []mdb.EmailEntry{
+		{Email: "ann@example.com", ConfirmedAt: &confirmedAt, Attributes: mdb.Attributes{"first_name": "Ann, \"Annie\"", "age": float64(31)}},
+		{Email: "bob@example.com", OptOut: true},
+		{Email: "carol@example.com", Attributes: mdb.Attributes{"age": 2.5}},
+	}
+	for _, entry := range entries {
+		if err := src.UpdateEmail(entry); err != nil {
+			t.Fatal(err)
+		}
+		if entry.Attributes != nil {
+			if err := src.SetAttributes(entry.Email, entry.Attributes); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	for _, format := range []Format{CSV, JSONL} {
+		t.Run(string(format), func(t *testing.T) {
+			var file bytes.Buffer
+			writer, err := NewWriter(&file, format, []string{"first_name", "age"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			// small pages so the export walks more than one
+			count, err := Export(StoreSource{Store: src, Fields: src}, writer, "", mdb.GetEmailBatchQueryParams{Count: 2, IncludeOptOut: true})
+			if err != nil || count != len(entries) {
+				t.Fatalf("got %v, %v exporting, want %v emails", count, err, len(entries))
+			}
+
+			dest := testStore(t)
+			report := importFile(t, dest, format, file.String(), ImportOptions{BatchSize: 2})
+			if report.Rows != len(entries) || report.Created != len(entries) || len(report.Rejects) != 0 {
+				t.Fatalf("got report %+v importing:\n%s", report, file.String())
+			}
+
+			for _, want := range entries {
+				got, err := dest.GetEmail(want.Email)
+				if err != nil || got == nil {
+					t.Fatalf("got %v, %v for %v", got, err, want.Email)
+				}
+				if got.OptOut != want.OptOut {
+					t.Errorf("%v: got opt out %v", want.Email, got.OptOut)
+				}
+				if want.ConfirmedAt != nil && !got.ConfirmedAt.Equal(*want.ConfirmedAt) {
+					t.Errorf("%v: got confirmed at %v, want %v", want.Email, got.ConfirmedAt, want.ConfirmedAt)
+				}
+				if len(got.Attributes) != len(want.Attributes) {
+					t.Errorf("%v: got attributes %v, want %v", want.Email, got.Attributes, want.Attributes)
+				}
+				for name, value := range want.Attributes {
+					if got.Attributes[name] != value {
+						t.Errorf("%v: got %v %#v, want %#v", want.Email, name, got.Attributes[name], value)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestEmptyExport(t *testing.T) {
+	var file bytes.Buffer
+	writer, err := NewWriter(&file, CSV, []string{"age"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := testStore(t)
+	if count, err := Export(StoreSource{Store: src, Fields: src}, writer, "", mdb.GetEmailBatchQueryParams{}); err != nil || count != 0 {
+		t.Fatalf("got %v, %v", count, err)
+	}
+	// the header is written so the file can be imported
+	if file.String() != "email,confirmed_at,opt_out,attributes.age\n" {
+		t.Errorf("got %q", file.String())
+	}
+}
+
+func TestImportRejects(t *testing.T) {
+	dest := testStore(t)
+	if err := dest.CreateEmail("existing@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := dest.UpdateEmail(mdb.EmailEntry{Email: "gone@example.com", OptOut: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	file := "email,opt_out,attributes.age\n" +
+		"new@example.com,,\n" + // 2 created
+		"not an address,,\n" + // 3 invalid address, from the store
+		"existing@example.com,,\n" + // 4 already exists
+		",,\n" + // 5 missing email, from the reader
+		"gone@example.com,,\n" + // 6 suppressed
+		"old@example.com,,old\n" + // 7 invalid attribute, from the store
+		"other@example.com,maybe,\n" // 8 invalid opt out, from the reader
+
+	// batches of 2 so rejects from different batches and the reader have to be merged
+	report := importFile(t, dest, CSV, file, ImportOptions{BatchSize: 2})
+	if report.Rows != 7 || report.Created != 1 || report.AlreadyExists != 1 || report.Invalid != 4 || report.Suppressed != 1 {
+		t.Errorf("got report %+v", report)
+	}
+	expectLines(t, report.Rejects, 3, 5, 6, 7, 8)
+	for _, reject := range report.Rejects {
+		if reject.Line == 6 && (reject.Email != "gone@example.com" || reject.Reason != "previously opted out") {
+			t.Errorf("got %+v for the suppressed address", reject)
+		}
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	dest := testStore(t)
+	file := `{"email": "new@example.com"}` + "\n" +
+		`{"email": "not an address"}` + "\n" +
+		`{"email": "other@example.com", "confirmed_at": "yesterday"}` + "\n" +
+		`{"email": "last@example.com"}` + "\n"
+
+	report := importFile(t, dest, JSONL, file, ImportOptions{DryRun: true})
+	if report.Rows != 4 || report.Created != 0 || report.Invalid != 2 {
+		t.Errorf("got report %+v", report)
+	}
+	expectLines(t, report.Rejects, 2, 3)
+
+	// nothing is written
+	entries, err := dest.GetEmailBatch(mdb.GetEmailBatchQueryParams{Count: 10, IncludeOptOut: true})
+	if err != nil || len(entries) != 0 {
+		t.Errorf("got %v, %v after a dry run, want no emails", entries, err)
+	}
+}